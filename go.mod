@@ -4,7 +4,10 @@ go 1.21
 
 require (
 	github.com/docker/docker v24.0.6+incompatible
+	github.com/klauspost/reedsolomon v1.11.8
+	github.com/miekg/dns v1.1.56
 	github.com/minio/minio-go/v7 v7.0.63
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -31,12 +34,12 @@ require (
 	github.com/rs/xid v1.5.0 // indirect
 	github.com/sirupsen/logrus v1.9.3 // indirect
 	golang.org/x/crypto v0.14.0 // indirect
-	golang.org/x/mod v0.8.0 // indirect
+	golang.org/x/mod v0.12.0 // indirect
 	golang.org/x/net v0.17.0 // indirect
 	golang.org/x/sys v0.13.0 // indirect
 	golang.org/x/text v0.13.0 // indirect
 	golang.org/x/time v0.3.0 // indirect
-	golang.org/x/tools v0.6.0 // indirect
+	golang.org/x/tools v0.13.0 // indirect
 	gopkg.in/ini.v1 v1.67.0 // indirect
 	gotest.tools/v3 v3.5.1 // indirect
 )
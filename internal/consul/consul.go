@@ -0,0 +1,241 @@
+// Package consul implements the service registry backed by the Consul catalog,
+// and the authentication details reader backed by the Consul KV store.
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAddress the default address of the Consul agent.
+	DefaultAddress = "http://127.0.0.1:8500"
+	// DefaultKVPrefix the default KV prefix of the storage instances' credentials.
+	DefaultKVPrefix = "object-storage-gateway/credentials"
+	// MetaScheme the service meta key to set the scheme of the storage instance's API, e.g. https.
+	MetaScheme = "scheme"
+)
+
+// NewClient initialises the client of the Consul HTTP API.
+func NewClient(address string, opts ...Option) (*Client, error) {
+	if address == "" {
+		address = DefaultAddress
+	}
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, errors.New("address must be defined as scheme://host:port")
+	}
+
+	o := &Client{
+		address:    strings.TrimSuffix(address, "/"),
+		kvPrefix:   DefaultKVPrefix,
+		httpClient: http.DefaultClient,
+		watchers:   map[string]*watchState{},
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o, nil
+}
+
+// Option defines the Client's optional configuration.
+type Option func(*Client)
+
+// WithToken sets the ACL token to authenticate the requests.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithKVPrefix sets the KV prefix to read the credentials from.
+func WithKVPrefix(prefix string) Option {
+	return func(c *Client) {
+		c.kvPrefix = strings.Trim(prefix, "/")
+	}
+}
+
+// WithHTTPClient sets the HTTP client.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// Client reads the storage instances from the Consul catalog and their credentials from the Consul KV store.
+type Client struct {
+	address    string
+	token      string
+	kvPrefix   string
+	httpClient *http.Client
+
+	mu       sync.RWMutex
+	watchers map[string]*watchState
+}
+
+// serviceEntry defines the element of the health service endpoint's response.
+type serviceEntry struct {
+	Node struct {
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		ID      string            `json:"ID"`
+		Address string            `json:"Address"`
+		Port    int               `json:"Port"`
+		Meta    map[string]string `json:"Meta"`
+	} `json:"Service"`
+}
+
+// Scan returns the passing instances of the service maintained by the watcher if it runs for the selector,
+// otherwise it queries the catalog. The selector is the service name.
+func (c *Client) Scan(ctx context.Context, serviceLabelFilter string) (map[string]string, error) {
+	if o, ok := c.watchedInstances(serviceLabelFilter); ok {
+		return o, nil
+	}
+
+	o, _, err := c.healthService(ctx, serviceLabelFilter, 0, 0)
+	return o, err
+}
+
+// healthService queries the passing instances of the service.
+// The query blocks until the catalog index exceeds the given index, or the wait time elapses if the index is set.
+func (c *Client) healthService(ctx context.Context, service string, index uint64, wait time.Duration) (
+	map[string]string, uint64, error,
+) {
+	query := url.Values{"passing": {"true"}}
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", wait.String())
+	}
+
+	var entries []serviceEntry
+	newIndex, err := c.get(ctx, "/v1/health/service/"+url.PathEscape(service), query, &entries)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var o = make(map[string]string, len(entries))
+	for _, entry := range entries {
+		if endpoint := serviceEndpoint(entry); endpoint != "" {
+			o[entry.Service.ID] = endpoint
+		}
+	}
+
+	return o, newIndex, nil
+}
+
+// serviceEndpoint returns the service instance's endpoint, the node's address is used if the service's is not set.
+func serviceEndpoint(entry serviceEntry) string {
+	address := entry.Service.Address
+	if address == "" {
+		address = entry.Node.Address
+	}
+	if address == "" {
+		return ""
+	}
+
+	o := address
+	if entry.Service.Port > 0 {
+		o = net.JoinHostPort(address, strconv.Itoa(entry.Service.Port))
+	}
+
+	if scheme := entry.Service.Meta[MetaScheme]; scheme != "" {
+		o = scheme + "://" + o
+	}
+
+	return o
+}
+
+// kvEntry defines the element of the KV endpoint's response.
+type kvEntry struct {
+	Value []byte `json:"Value"`
+}
+
+// credentials defines the KV value of the instance's credentials.
+type credentials struct {
+	AccessKey string `json:"accessKey"`
+	SecretKey string `json:"secretKey"`
+}
+
+// Read reads the instance's credentials stored as the JSON {"accessKey": "", "secretKey": ""}
+// under the key "{prefix}/{instanceID}".
+func (c *Client) Read(ctx context.Context, instanceID string) (string, string, error) {
+	var entries []kvEntry
+	if _, err := c.get(ctx, "/v1/kv/"+c.kvPrefix+"/"+url.PathEscape(instanceID), nil, &entries); err != nil {
+		return "", "", err
+	}
+
+	if len(entries) == 0 {
+		return "", "", fmt.Errorf("credentials of the instance %s not found", instanceID)
+	}
+
+	var v credentials
+	if err := json.Unmarshal(entries[0].Value, &v); err != nil {
+		return "", "", fmt.Errorf("cannot parse credentials of the instance %s: %w", instanceID, err)
+	}
+
+	return v.AccessKey, v.SecretKey, nil
+}
+
+// errNotFound is returned by the API if the resource does not exist.
+var errNotFound = errors.New("not found")
+
+// get sends the GET request and decodes the JSON response, it returns the value of the header X-Consul-Index.
+func (c *Client) get(ctx context.Context, path string, query url.Values, v any) (uint64, error) {
+	u := c.address + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	index, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		// the KV endpoint returns 404 if the key does not exist
+		if strings.HasPrefix(path, "/v1/kv/") {
+			return index, nil
+		}
+		return 0, errNotFound
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, fmt.Errorf("consul API %s returned %d: %s", path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return 0, err
+	}
+
+	return index, nil
+}
@@ -0,0 +1,257 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeInstance struct {
+	id      string
+	address string
+	port    int
+	meta    map[string]string
+	passing bool
+}
+
+// fakeConsulAPI emulates the Consul health and KV HTTP API including the blocking queries.
+type fakeConsulAPI struct {
+	mu        sync.Mutex
+	index     uint64
+	changed   chan struct{}
+	instances map[string][]fakeInstance
+	kv        map[string]string
+	token     string
+	// blockingQueries the number of received blocking queries.
+	blockingQueries int
+	// queries the number of received non-blocking queries.
+	queries int
+}
+
+func newFakeConsulAPI(t *testing.T) (*fakeConsulAPI, *httptest.Server) {
+	t.Helper()
+
+	f := &fakeConsulAPI{
+		index:     1,
+		changed:   make(chan struct{}),
+		instances: map[string][]fakeInstance{},
+		kv:        map[string]string{},
+	}
+
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	return f, server
+}
+
+func (f *fakeConsulAPI) setInstances(service string, instances ...fakeInstance) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.instances[service] = instances
+	f.index++
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *fakeConsulAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.token != "" && r.Header.Get("X-Consul-Token") != f.token {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte("ACL not found"))
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/v1/health/service/"):
+		f.healthService(w, r)
+	case strings.HasPrefix(r.URL.Path, "/v1/kv/"):
+		f.mu.Lock()
+		v, ok := f.kv[strings.TrimPrefix(r.URL.Path, "/v1/kv/")]
+		f.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode([]map[string]any{{"Value": []byte(v)}})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeConsulAPI) healthService(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("passing") != "true" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	v := r.URL.Query().Get("index")
+	if v == "" {
+		f.mu.Lock()
+		f.queries++
+		f.mu.Unlock()
+	}
+
+	if v != "" {
+		index, _ := strconv.ParseUint(v, 10, 64)
+		wait, _ := time.ParseDuration(r.URL.Query().Get("wait"))
+
+		f.mu.Lock()
+		f.blockingQueries++
+		current, changed := f.index, f.changed
+		f.mu.Unlock()
+
+		if current <= index {
+			select {
+			case <-changed:
+			case <-time.After(wait):
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var o []map[string]any
+	for _, instance := range f.instances[strings.TrimPrefix(r.URL.Path, "/v1/health/service/")] {
+		if !instance.passing {
+			continue
+		}
+		o = append(o, map[string]any{
+			"Node": map[string]any{"Address": "192.0.2.1"},
+			"Service": map[string]any{
+				"ID": instance.id, "Address": instance.address, "Port": instance.port, "Meta": instance.meta,
+			},
+		})
+	}
+
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(f.index, 10))
+	_ = json.NewEncoder(w).Encode(o)
+}
+
+func TestClient_Scan(t *testing.T) {
+	const service = "storage"
+
+	// GIVEN
+	api, server := newFakeConsulAPI(t)
+	api.setInstances(service,
+		fakeInstance{id: "node-0", address: "10.0.0.2", port: 9000, passing: true},
+		fakeInstance{id: "node-1", address: "10.0.0.3", port: 9443, meta: map[string]string{MetaScheme: "https"}, passing: true},
+		fakeInstance{id: "node-2", address: "10.0.0.4", port: 9000, passing: false},
+		fakeInstance{id: "node-3", port: 9000, passing: true},
+	)
+	api.setInstances("other", fakeInstance{id: "other-0", address: "10.0.0.100", port: 9000, passing: true})
+
+	c, _ := NewClient(server.URL)
+
+	// WHEN
+	got, err := c.Scan(context.Background(), service)
+
+	// THEN
+	if err != nil {
+		t.Fatalf("no error expected, got: %v", err)
+	}
+
+	want := map[string]string{
+		"node-0": "10.0.0.2:9000",
+		"node-1": "https://10.0.0.3:9443",
+		"node-3": "192.0.2.1:9000",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected instances, want: %v, got: %v", want, got)
+	}
+}
+
+func TestClient_Read(t *testing.T) {
+	// GIVEN
+	api, server := newFakeConsulAPI(t)
+	api.token = "secret-token"
+	api.kv[DefaultKVPrefix+"/node-0"] = `{"accessKey": "foo", "secretKey": "bar"}`
+	api.kv["custom/node-1"] = `{"accessKey": "baz", "secretKey": "qux"}`
+	api.kv[DefaultKVPrefix+"/node-2"] = `not json`
+
+	tests := []struct {
+		name            string
+		opts            []Option
+		instanceID      string
+		wantAccessKeyID string
+		wantSecretKey   string
+		wantErr         bool
+	}{
+		{
+			name:            "default prefix",
+			opts:            []Option{WithToken("secret-token")},
+			instanceID:      "node-0",
+			wantAccessKeyID: "foo",
+			wantSecretKey:   "bar",
+		},
+		{
+			name:            "custom prefix",
+			opts:            []Option{WithToken("secret-token"), WithKVPrefix("/custom/")},
+			instanceID:      "node-1",
+			wantAccessKeyID: "baz",
+			wantSecretKey:   "qux",
+		},
+		{
+			name:       "invalid value",
+			opts:       []Option{WithToken("secret-token")},
+			instanceID: "node-2",
+			wantErr:    true,
+		},
+		{
+			name:       "key not found",
+			opts:       []Option{WithToken("secret-token")},
+			instanceID: "node-100",
+			wantErr:    true,
+		},
+		{
+			name:       "token rejected",
+			instanceID: "node-0",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := NewClient(server.URL, tt.opts...)
+
+			// WHEN
+			accessKeyID, secretKey, err := c.Read(context.Background(), tt.instanceID)
+
+			// THEN
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if accessKeyID != tt.wantAccessKeyID || secretKey != tt.wantSecretKey {
+				t.Errorf("unexpected credentials, want: %s/%s, got: %s/%s",
+					tt.wantAccessKeyID, tt.wantSecretKey, accessKeyID, secretKey)
+			}
+		})
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		wantErr bool
+	}{
+		{name: "default address", address: ""},
+		{name: "address with scheme", address: "https://consul.example.com:8501"},
+		{name: "address without scheme", address: "consul.example.com:8500", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewClient(tt.address); (err != nil) != tt.wantErr {
+				t.Errorf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
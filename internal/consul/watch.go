@@ -0,0 +1,100 @@
+package consul
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"time"
+)
+
+// watchState defines the storage instances maintained by the watcher.
+type watchState struct {
+	instances map[string]string
+}
+
+const (
+	// blockingQueryWait the max time the blocking query waits for the change.
+	blockingQueryWait = 5 * time.Minute
+	// retryDelay the delay before retrying the blocking query after the failure.
+	retryDelay = time.Second
+)
+
+// Watch runs the blocking queries of the Consul health API to maintain the passing instances of the service in memory.
+// It blocks until the context is cancelled. While the watcher is in sync with the catalog,
+// Scan returns the instances from memory instead of querying the catalog.
+func (c *Client) Watch(ctx context.Context, serviceLabelFilter string, logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger = logger.WithGroup("consul")
+
+	defer c.setWatchState(serviceLabelFilter, nil)
+
+	var index uint64
+	for {
+		instances, newIndex, err := c.healthService(ctx, serviceLabelFilter, index, blockingQueryWait)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			c.setWatchState(serviceLabelFilter, nil)
+			index = 0
+
+			logger.Error("blocking query failed, retrying",
+				slog.String("selector", serviceLabelFilter),
+				slog.String("error", err.Error()),
+			)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryDelay):
+			}
+			continue
+		}
+
+		logger.Debug("catalog state",
+			slog.String("selector", serviceLabelFilter),
+			slog.Uint64("index", newIndex),
+			slog.Int("instances", len(instances)),
+		)
+
+		c.setWatchState(serviceLabelFilter, &watchState{instances: instances})
+
+		// the index must be reset if it goes backwards, e.g. after the Consul's snapshot restore,
+		// and it must be at least 1 to not turn the blocking queries into the busy loop
+		switch {
+		case newIndex < index:
+			index = 0
+		case newIndex < 1:
+			index = 1
+		default:
+			index = newIndex
+		}
+	}
+}
+
+func (c *Client) watchedInstances(serviceLabelFilter string) (map[string]string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	state, ok := c.watchers[serviceLabelFilter]
+	if !ok {
+		return nil, false
+	}
+
+	return maps.Clone(state.instances), true
+}
+
+func (c *Client) setWatchState(serviceLabelFilter string, state *watchState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if state == nil {
+		delete(c.watchers, serviceLabelFilter)
+		return
+	}
+
+	c.watchers[serviceLabelFilter] = state
+}
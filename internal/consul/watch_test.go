@@ -0,0 +1,108 @@
+package consul
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// waitForInstances waits up to a second for the watcher to maintain the expected instances.
+func waitForInstances(t *testing.T, c *Client, selector string, want map[string]string) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	var got map[string]string
+	for time.Now().Before(deadline) {
+		got, _ = c.watchedInstances(selector)
+		if reflect.DeepEqual(got, want) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Errorf("unexpected instances, want: %v, got: %v", want, got)
+}
+
+func TestClient_Watch(t *testing.T) {
+	const service = "storage"
+
+	// GIVEN
+	api, server := newFakeConsulAPI(t)
+	api.setInstances(service, fakeInstance{id: "node-0", address: "10.0.0.2", port: 9000, passing: true})
+
+	c, _ := NewClient(server.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		c.Watch(ctx, service, nil)
+		close(done)
+	}()
+
+	t.Run("shall sync the instances", func(t *testing.T) {
+		waitForInstances(t, c, service, map[string]string{"node-0": "10.0.0.2:9000"})
+	})
+
+	t.Run("shall add the instance which passes the health checks", func(t *testing.T) {
+		api.setInstances(service,
+			fakeInstance{id: "node-0", address: "10.0.0.2", port: 9000, passing: true},
+			fakeInstance{id: "node-1", address: "10.0.0.3", port: 9000, passing: true},
+		)
+
+		waitForInstances(t, c, service, map[string]string{"node-0": "10.0.0.2:9000", "node-1": "10.0.0.3:9000"})
+	})
+
+	t.Run("shall remove the instance which fails the health checks", func(t *testing.T) {
+		api.setInstances(service,
+			fakeInstance{id: "node-0", address: "10.0.0.2", port: 9000, passing: false},
+			fakeInstance{id: "node-1", address: "10.0.0.3", port: 9000, passing: true},
+		)
+
+		waitForInstances(t, c, service, map[string]string{"node-1": "10.0.0.3:9000"})
+	})
+
+	t.Run("shall use blocking queries", func(t *testing.T) {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		if api.blockingQueries == 0 {
+			t.Errorf("blocking queries expected")
+		}
+	})
+
+	t.Run("shall return the watched instances without querying the catalog", func(t *testing.T) {
+		api.mu.Lock()
+		queries := api.queries
+		api.mu.Unlock()
+
+		got, err := c.Scan(context.Background(), service)
+		if err != nil {
+			t.Fatalf("no error expected, got: %v", err)
+		}
+		if want := map[string]string{"node-1": "10.0.0.3:9000"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected instances, want: %v, got: %v", want, got)
+		}
+
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		if api.queries != queries {
+			t.Errorf("the catalog is not expected to be queried")
+		}
+	})
+
+	t.Run("shall stop when the context is cancelled", func(t *testing.T) {
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Errorf("the watcher is expected to stop")
+			return
+		}
+
+		if _, ok := c.watchedInstances(service); ok {
+			t.Errorf("no watched instances expected after the watcher stopped")
+		}
+	})
+}
@@ -0,0 +1,260 @@
+// Package dnsregistry implements the service registry which resolves the storage instances using DNS.
+package dnsregistry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DefaultResolvConf the resolver config used to define the DNS server by default.
+const DefaultResolvConf = "/etc/resolv.conf"
+
+// ednsUDPSize the max size of the UDP response advertised using EDNS0, see RFC 6891.
+const ednsUDPSize = 1232
+
+// NewClient initialises the client to query the DNS server "host:port".
+// The first nameserver from DefaultResolvConf is used if the server is not set.
+func NewClient(server string, opts ...Option) (*Client, error) {
+	if server == "" {
+		cfg, err := dns.ClientConfigFromFile(DefaultResolvConf)
+		if err != nil {
+			return nil, err
+		}
+		if len(cfg.Servers) == 0 {
+			return nil, errors.New("no nameserver found in " + DefaultResolvConf)
+		}
+		server = net.JoinHostPort(cfg.Servers[0], cfg.Port)
+	}
+
+	o := &Client{
+		server:    server,
+		dns:       &dns.Client{Timeout: 5 * time.Second},
+		dnsTCP:    &dns.Client{Net: "tcp", Timeout: 5 * time.Second},
+		now:       time.Now,
+		snapshots: map[string]snapshot{},
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o, nil
+}
+
+// Option defines the Client's optional configuration.
+type Option func(*Client)
+
+// WithPort sets the port of the storage instances resolved using A records.
+// The storage client's default port is used otherwise.
+func WithPort(port int) Option {
+	return func(c *Client) {
+		c.port = strconv.Itoa(port)
+	}
+}
+
+// Client resolves the storage instances using SRV, or A records.
+// The resolved instances are cached for the min TTL of the records.
+type Client struct {
+	server string
+	port   string
+	dns    *dns.Client
+	// dnsTCP the client to repeat the query if the UDP response is truncated.
+	dnsTCP *dns.Client
+	now    func() time.Time
+
+	mu        sync.Mutex
+	snapshots map[string]snapshot
+}
+
+// noRecordsTTL the sentinel TTL value which indicates that no records were resolved.
+const noRecordsTTL uint32 = math.MaxUint32
+
+type snapshot struct {
+	instances map[string]string
+	expiresAt time.Time
+}
+
+// Scan resolves the storage instances given the domain name as the selector.
+//
+// The SRV records are queried if the name starts with the underscore, e.g. "_minio._tcp.storage.example.com".
+// The instance ID is the target hostname, and the endpoint is the target's IP address and the port from the SRV record.
+//
+// The A records are queried otherwise, e.g. "storage.example.com".
+// The instance ID is the IP address, and the endpoint is the IP address and the port set using WithPort.
+// Note that the instance is identified as the new one when its IP address changes, hence the objects placed
+// to it are searched in the rest of the cluster. The SRV records shall be used to keep the instance IDs stable.
+func (c *Client) Scan(ctx context.Context, serviceLabelFilter string) (map[string]string, error) {
+	name := dns.Fqdn(serviceLabelFilter)
+
+	c.mu.Lock()
+	s, ok := c.snapshots[name]
+	c.mu.Unlock()
+
+	if ok && c.now().Before(s.expiresAt) {
+		return maps.Clone(s.instances), nil
+	}
+
+	var (
+		instances map[string]string
+		ttl       uint32
+		err       error
+	)
+	if strings.HasPrefix(name, "_") {
+		instances, ttl, err = c.resolveSRV(ctx, name)
+	} else {
+		instances, ttl, err = c.resolveA(ctx, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.snapshots[name] = snapshot{
+		instances: instances,
+		expiresAt: c.now().Add(time.Duration(ttl) * time.Second),
+	}
+	c.mu.Unlock()
+
+	return maps.Clone(instances), nil
+}
+
+// resolveSRV returns the instances from the SRV records and the min TTL of the records.
+func (c *Client) resolveSRV(ctx context.Context, name string) (map[string]string, uint32, error) {
+	msg, err := c.query(ctx, name, dns.TypeSRV)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// the targets' addresses are usually included to the additional section
+	addresses := map[string][]*dns.A{}
+	for _, rr := range msg.Extra {
+		if a, ok := rr.(*dns.A); ok {
+			target := strings.ToLower(a.Hdr.Name)
+			addresses[target] = append(addresses[target], a)
+		}
+	}
+
+	var (
+		o   = map[string]string{}
+		ttl = noRecordsTTL
+	)
+	for _, rr := range msg.Answer {
+		srv, ok := rr.(*dns.SRV)
+		if !ok {
+			continue
+		}
+		ttl = min(ttl, srv.Hdr.Ttl)
+
+		target := strings.ToLower(srv.Target)
+		records, found := addresses[target]
+		if !found {
+			targetMsg, err := c.query(ctx, target, dns.TypeA)
+			if err != nil {
+				return nil, 0, fmt.Errorf("cannot resolve SRV target %s: %w", target, err)
+			}
+			records = aRecords(targetMsg)
+			if len(records) == 0 {
+				ttl = min(ttl, negativeTTL(targetMsg))
+				continue
+			}
+		}
+
+		for _, a := range records {
+			ttl = min(ttl, a.Hdr.Ttl)
+		}
+
+		o[strings.TrimSuffix(target, ".")] = net.JoinHostPort(records[0].A.String(), strconv.Itoa(int(srv.Port)))
+	}
+
+	if ttl == noRecordsTTL {
+		ttl = negativeTTL(msg)
+	}
+
+	return o, ttl, nil
+}
+
+// resolveA returns the instances from the A records and the min TTL of the records.
+func (c *Client) resolveA(ctx context.Context, name string) (map[string]string, uint32, error) {
+	msg, err := c.query(ctx, name, dns.TypeA)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var (
+		o   = map[string]string{}
+		ttl = noRecordsTTL
+	)
+	for _, a := range aRecords(msg) {
+		ttl = min(ttl, a.Hdr.Ttl)
+
+		ipAddress := a.A.String()
+		endpoint := ipAddress
+		if c.port != "" {
+			endpoint = net.JoinHostPort(ipAddress, c.port)
+		}
+		o[ipAddress] = endpoint
+	}
+
+	if ttl == noRecordsTTL {
+		ttl = negativeTTL(msg)
+	}
+
+	return o, ttl, nil
+}
+
+// query sends the query to the DNS server. The non-existent domain is not considered an error.
+// The query is repeated over TCP if the UDP response is truncated, i.e. if it does not contain all records.
+func (c *Client) query(ctx context.Context, name string, qtype uint16) (*dns.Msg, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), qtype)
+	msg.SetEdns0(ednsUDPSize, false)
+
+	resp, _, err := c.dns.ExchangeContext(ctx, msg, c.server)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Truncated {
+		resp, _, err = c.dnsTCP.ExchangeContext(ctx, msg, c.server)
+		if err != nil {
+			return nil, fmt.Errorf("query %s %s over tcp failed: %w", dns.TypeToString[qtype], name, err)
+		}
+	}
+
+	switch resp.Rcode {
+	case dns.RcodeSuccess, dns.RcodeNameError:
+		return resp, nil
+	default:
+		return nil, fmt.Errorf("query %s %s failed: %s", dns.TypeToString[qtype], name, dns.RcodeToString[resp.Rcode])
+	}
+}
+
+func aRecords(msg *dns.Msg) []*dns.A {
+	var o []*dns.A
+	for _, rr := range msg.Answer {
+		if a, ok := rr.(*dns.A); ok {
+			o = append(o, a)
+		}
+	}
+	return o
+}
+
+// negativeTTL returns the TTL to cache the empty answer which is defined by the SOA record, see RFC 2308.
+func negativeTTL(msg *dns.Msg) uint32 {
+	for _, rr := range msg.Ns {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return min(soa.Hdr.Ttl, soa.Minttl)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,416 @@
+package dnsregistry
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// fakeDNSServer serves the records from memory over UDP and TCP.
+// The UDP responses are truncated to the size set by the query's EDNS0 record, or to 512 bytes.
+type fakeDNSServer struct {
+	mu      sync.Mutex
+	records map[string][]dns.RR
+	// extra defines the records returned in the additional section.
+	extra      map[string][]dns.RR
+	queries    atomic.Int64
+	tcpQueries atomic.Int64
+
+	addr string
+}
+
+func newFakeDNSServer(t *testing.T) *fakeDNSServer {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeDNSServer{
+		records: map[string][]dns.RR{},
+		extra:   map[string][]dns.RR{},
+		addr:    pc.LocalAddr().String(),
+	}
+
+	l, err := net.Listen("tcp", f.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, server := range []*dns.Server{{PacketConn: pc, Handler: f}, {Listener: l, Handler: f}} {
+		started := make(chan struct{})
+		server.NotifyStartedFunc = func() { close(started) }
+		go func() { _ = server.ActivateAndServe() }()
+		<-started
+
+		t.Cleanup(func() { _ = server.Shutdown() })
+	}
+
+	return f
+}
+
+func (f *fakeDNSServer) set(rrs ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.records = map[string][]dns.RR{}
+	for _, v := range rrs {
+		rr := mustNewRR(v)
+		key := strings.ToLower(rr.Header().Name) + dns.TypeToString[rr.Header().Rrtype]
+		f.records[key] = append(f.records[key], rr)
+	}
+}
+
+func (f *fakeDNSServer) setExtra(name string, rrs ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, v := range rrs {
+		f.extra[name] = append(f.extra[name], mustNewRR(v))
+	}
+}
+
+func (f *fakeDNSServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	f.queries.Add(1)
+	if w.LocalAddr().Network() == "tcp" {
+		f.tcpQueries.Add(1)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	msg := new(dns.Msg)
+	msg.SetReply(r)
+
+	q := r.Question[0]
+	msg.Answer = f.records[strings.ToLower(q.Name)+dns.TypeToString[q.Qtype]]
+	msg.Extra = f.extra[strings.ToLower(q.Name)]
+	if len(msg.Answer) == 0 {
+		msg.Rcode = dns.RcodeNameError
+		msg.Ns = []dns.RR{mustNewRR("example.com. 60 IN SOA ns.example.com. admin.example.com. 1 3600 600 86400 30")}
+	}
+
+	if w.LocalAddr().Network() == "udp" {
+		size := dns.MinMsgSize
+		if opt := r.IsEdns0(); opt != nil {
+			size = int(opt.UDPSize())
+		}
+		msg.Truncate(size)
+	}
+
+	_ = w.WriteMsg(msg)
+}
+
+func mustNewRR(s string) dns.RR {
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		panic(err)
+	}
+	return rr
+}
+
+type mockClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *mockClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *mockClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestClient_Scan_SRV(t *testing.T) {
+	const selector = "_minio._tcp.storage.example.com"
+
+	t.Run("shall resolve the SRV targets", func(t *testing.T) {
+		// GIVEN
+		server := newFakeDNSServer(t)
+		server.set(
+			selector+". 60 IN SRV 10 10 9000 node-0.example.com.",
+			selector+". 60 IN SRV 10 10 9443 Node-1.example.com.",
+			"node-0.example.com. 60 IN A 10.0.0.2",
+			"node-1.example.com. 60 IN A 10.0.0.3",
+		)
+
+		c, _ := NewClient(server.addr)
+
+		// WHEN
+		got, err := c.Scan(context.Background(), selector)
+
+		// THEN
+		if err != nil {
+			t.Fatalf("no error expected, got: %v", err)
+		}
+
+		want := map[string]string{"node-0.example.com": "10.0.0.2:9000", "node-1.example.com": "10.0.0.3:9443"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected instances, want: %v, got: %v", want, got)
+		}
+	})
+
+	t.Run("shall use the targets' addresses from the additional section", func(t *testing.T) {
+		// GIVEN
+		server := newFakeDNSServer(t)
+		server.set(selector + ". 60 IN SRV 10 10 9000 node-0.example.com.")
+		server.setExtra(selector+".", "node-0.example.com. 60 IN A 10.0.0.2")
+
+		c, _ := NewClient(server.addr)
+
+		// WHEN
+		got, err := c.Scan(context.Background(), selector)
+
+		// THEN
+		if err != nil {
+			t.Fatalf("no error expected, got: %v", err)
+		}
+
+		if want := map[string]string{"node-0.example.com": "10.0.0.2:9000"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected instances, want: %v, got: %v", want, got)
+		}
+
+		if server.queries.Load() != 1 {
+			t.Errorf("single query expected, got: %d", server.queries.Load())
+		}
+	})
+
+	// setCluster sets the SRV records, and the targets' addresses in the additional section for n instances.
+	setCluster := func(server *fakeDNSServer, n int) map[string]string {
+		want := map[string]string{}
+		var srv []string
+		for i := 0; i < n; i++ {
+			target := fmt.Sprintf("node-%d.example.com", i)
+			ipAddress := fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+			srv = append(srv, fmt.Sprintf("%s. 60 IN SRV 10 10 9000 %s.", selector, target))
+			server.setExtra(selector+".", fmt.Sprintf("%s. 60 IN A %s", target, ipAddress))
+			want[target] = ipAddress + ":9000"
+		}
+		server.set(srv...)
+		return want
+	}
+
+	t.Run("shall receive the answer larger than 512 bytes over UDP", func(t *testing.T) {
+		// GIVEN
+		server := newFakeDNSServer(t)
+		want := setCluster(server, 20)
+
+		c, _ := NewClient(server.addr)
+
+		// WHEN
+		got, err := c.Scan(context.Background(), selector)
+
+		// THEN
+		if err != nil {
+			t.Fatalf("no error expected, got: %v", err)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected instances, want: %v, got: %v", want, got)
+		}
+
+		if server.queries.Load() != 1 || server.tcpQueries.Load() != 0 {
+			t.Errorf("single UDP query expected, got: %d, TCP: %d", server.queries.Load(), server.tcpQueries.Load())
+		}
+	})
+
+	t.Run("shall repeat the query over TCP if the answer is truncated", func(t *testing.T) {
+		// GIVEN
+		server := newFakeDNSServer(t)
+		want := setCluster(server, 300)
+
+		c, _ := NewClient(server.addr)
+
+		// WHEN
+		got, err := c.Scan(context.Background(), selector)
+
+		// THEN
+		if err != nil {
+			t.Fatalf("no error expected, got: %v", err)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected instances, want: %d instances, got: %d", len(want), len(got))
+		}
+
+		if server.tcpQueries.Load() != 1 {
+			t.Errorf("single TCP query expected, got: %d", server.tcpQueries.Load())
+		}
+	})
+
+	t.Run("shall skip the target which cannot be resolved", func(t *testing.T) {
+		// GIVEN
+		server := newFakeDNSServer(t)
+		server.set(
+			selector+". 60 IN SRV 10 10 9000 node-0.example.com.",
+			selector+". 60 IN SRV 10 10 9000 node-1.example.com.",
+			"node-0.example.com. 60 IN A 10.0.0.2",
+		)
+
+		c, _ := NewClient(server.addr)
+
+		// WHEN
+		got, err := c.Scan(context.Background(), selector)
+
+		// THEN
+		if err != nil {
+			t.Fatalf("no error expected, got: %v", err)
+		}
+
+		if want := map[string]string{"node-0.example.com": "10.0.0.2:9000"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected instances, want: %v, got: %v", want, got)
+		}
+	})
+}
+
+func TestClient_Scan_A(t *testing.T) {
+	const selector = "storage.example.com"
+
+	tests := []struct {
+		name string
+		opts []Option
+		want map[string]string
+	}{
+		{
+			name: "default port",
+			want: map[string]string{"10.0.0.2": "10.0.0.2", "10.0.0.3": "10.0.0.3"},
+		},
+		{
+			name: "configured port",
+			opts: []Option{WithPort(9001)},
+			want: map[string]string{"10.0.0.2": "10.0.0.2:9001", "10.0.0.3": "10.0.0.3:9001"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			server := newFakeDNSServer(t)
+			server.set(
+				selector+". 60 IN A 10.0.0.2",
+				selector+". 60 IN A 10.0.0.3",
+			)
+
+			c, _ := NewClient(server.addr, tt.opts...)
+
+			// WHEN
+			got, err := c.Scan(context.Background(), selector)
+
+			// THEN
+			if err != nil {
+				t.Fatalf("no error expected, got: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unexpected instances, want: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestClient_Scan_TTL(t *testing.T) {
+	const selector = "storage.example.com"
+
+	// GIVEN
+	server := newFakeDNSServer(t)
+	server.set(
+		selector+". 60 IN A 10.0.0.2",
+		selector+". 30 IN A 10.0.0.3",
+	)
+
+	clock := &mockClock{now: time.Unix(0, 0)}
+	c, _ := NewClient(server.addr)
+	c.now = clock.Now
+
+	if _, err := c.Scan(context.Background(), selector); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("shall return the cached instances before the min TTL expires", func(t *testing.T) {
+		// GIVEN
+		server.set(selector + ". 60 IN A 10.0.0.4")
+		clock.Advance(29 * time.Second)
+
+		// WHEN
+		got, _ := c.Scan(context.Background(), selector)
+
+		// THEN
+		if want := map[string]string{"10.0.0.2": "10.0.0.2", "10.0.0.3": "10.0.0.3"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected instances, want: %v, got: %v", want, got)
+		}
+
+		if server.queries.Load() != 1 {
+			t.Errorf("single query expected, got: %d", server.queries.Load())
+		}
+	})
+
+	t.Run("shall resolve the instances after the min TTL expires", func(t *testing.T) {
+		// GIVEN
+		clock.Advance(time.Second)
+
+		// WHEN
+		got, _ := c.Scan(context.Background(), selector)
+
+		// THEN
+		if want := map[string]string{"10.0.0.4": "10.0.0.4"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected instances, want: %v, got: %v", want, got)
+		}
+	})
+
+	t.Run("shall cache the non-existent domain for the SOA min TTL", func(t *testing.T) {
+		// GIVEN
+		server.set()
+		clock.Advance(time.Minute)
+
+		got, _ := c.Scan(context.Background(), selector)
+		if len(got) != 0 {
+			t.Fatalf("no instances expected, got: %v", got)
+		}
+		queries := server.queries.Load()
+
+		// WHEN
+		clock.Advance(29 * time.Second)
+		_, _ = c.Scan(context.Background(), selector)
+
+		// THEN
+		if server.queries.Load() != queries {
+			t.Errorf("the negative answer is expected to be cached")
+		}
+	})
+}
+
+func TestClient_Scan_error(t *testing.T) {
+	// GIVEN
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		msg := new(dns.Msg)
+		msg.SetRcode(r, dns.RcodeServerFailure)
+		_ = w.WriteMsg(msg)
+	})}
+	go func() { _ = server.ActivateAndServe() }()
+	defer func() { _ = server.Shutdown() }()
+
+	c, _ := NewClient(pc.LocalAddr().String())
+
+	// WHEN
+	_, err = c.Scan(context.Background(), "storage.example.com")
+
+	// THEN
+	if err == nil {
+		t.Errorf("error expected")
+	}
+}
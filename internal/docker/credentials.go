@@ -0,0 +1,142 @@
+package docker
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// ErrCredentialsNotFound indicates that the storage instance's container defines no credentials.
+var ErrCredentialsNotFound = errors.New("credentials not found")
+
+// secretsDir the directory where Docker mounts the secrets.
+const secretsDir = "/run/secrets"
+
+// maxSecretFileSize the max size of the file with the credential.
+const maxSecretFileSize = 4096
+
+// accessKeyEnvs and secretKeyEnvs the environment variables which define the credential in the order of precedence.
+// The variables with the suffix _FILE define the path to the file with the credential.
+var (
+	accessKeyEnvs = []string{"MINIO_ROOT_USER", "MINIO_ROOT_USER_FILE", "MINIO_ACCESS_KEY", "MINIO_ACCESS_KEY_FILE"}
+	secretKeyEnvs = []string{
+		"MINIO_ROOT_PASSWORD", "MINIO_ROOT_PASSWORD_FILE", "MINIO_SECRET_KEY", "MINIO_SECRET_KEY_FILE",
+	}
+)
+
+// Read reads the credentials of the storage instance from its container's environment variables.
+// MINIO_ROOT_USER and MINIO_ROOT_PASSWORD take precedence over the deprecated MINIO_ACCESS_KEY and MINIO_SECRET_KEY.
+// The variables with the suffix _FILE are resolved by reading the file from the container,
+// the relative path is resolved against the Docker secrets directory /run/secrets.
+func (c *Client) Read(ctx context.Context, instanceID string) (string, string, error) {
+	info, err := c.api.ContainerInspect(ctx, instanceID)
+	if err != nil {
+		return "", "", err
+	}
+
+	if info.Config == nil {
+		return "", "", fmt.Errorf("container %s: %w", instanceID, ErrCredentialsNotFound)
+	}
+
+	envs := parseEnv(info.Config.Env)
+
+	accessKeyID, err := c.resolveCredential(ctx, instanceID, envs, accessKeyEnvs)
+	if err != nil {
+		return "", "", err
+	}
+
+	secretAccessKey, err := c.resolveCredential(ctx, instanceID, envs, secretKeyEnvs)
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessKeyID, secretAccessKey, nil
+}
+
+// parseEnv returns the map of the environment variables given the list of "key=value" pairs.
+func parseEnv(envVars []string) map[string]string {
+	var o = make(map[string]string, len(envVars))
+	for _, kvPair := range envVars {
+		if k, v, ok := strings.Cut(kvPair, "="); ok {
+			o[k] = v
+		}
+	}
+	return o
+}
+
+// resolveCredential returns the value of the first set variable, the file is read from the container
+// if the variable's name has the suffix _FILE.
+func (c *Client) resolveCredential(ctx context.Context, containerID string, envs map[string]string, names []string) (
+	string, error,
+) {
+	for _, name := range names {
+		v := envs[name]
+		if v == "" {
+			continue
+		}
+
+		if !strings.HasSuffix(name, "_FILE") {
+			return v, nil
+		}
+
+		filePath := v
+		if !path.IsAbs(filePath) {
+			filePath = path.Join(secretsDir, filePath)
+		}
+
+		o, err := c.readContainerFile(ctx, containerID, filePath)
+		if err != nil {
+			return "", fmt.Errorf("container %s: cannot read %s=%s: %w", containerID, name, v, err)
+		}
+
+		if o == "" {
+			return "", fmt.Errorf("container %s: %s=%s refers to empty file: %w",
+				containerID, name, v, ErrCredentialsNotFound)
+		}
+
+		return o, nil
+	}
+
+	return "", fmt.Errorf("container %s: none of %s is set: %w",
+		containerID, strings.Join(names, ", "), ErrCredentialsNotFound)
+}
+
+// readContainerFile reads the file from the container. The content is trimmed of the trailing whitespaces.
+func (c *Client) readContainerFile(ctx context.Context, containerID, filePath string) (string, error) {
+	reader, _, err := c.api.CopyFromContainer(ctx, containerID, filePath)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = reader.Close() }()
+
+	// the file is copied as the tar archive
+	archive := tar.NewReader(reader)
+	for {
+		header, err := archive.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return "", errors.New("no file found in the archive")
+			}
+			return "", err
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if header.Size > maxSecretFileSize {
+			return "", fmt.Errorf("file size exceeds %d bytes", maxSecretFileSize)
+		}
+
+		v, err := io.ReadAll(archive)
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimRight(string(v), " \t\r\n"), nil
+	}
+}
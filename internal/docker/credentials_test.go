@@ -0,0 +1,109 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestClient_Read(t *testing.T) {
+	tests := []struct {
+		name            string
+		env             []string
+		files           map[string]string
+		wantAccessKeyID string
+		wantSecretKey   string
+		wantErr         error
+	}{
+		{
+			name:            "root user and password",
+			env:             []string{"MINIO_ROOT_USER=foo", "MINIO_ROOT_PASSWORD=bar"},
+			wantAccessKeyID: "foo",
+			wantSecretKey:   "bar",
+		},
+		{
+			name:            "deprecated access and secret keys",
+			env:             []string{"MINIO_ACCESS_KEY=foo", "MINIO_SECRET_KEY=bar"},
+			wantAccessKeyID: "foo",
+			wantSecretKey:   "bar",
+		},
+		{
+			name: "root user and password take precedence over deprecated variables",
+			env: []string{
+				"MINIO_ACCESS_KEY=old", "MINIO_SECRET_KEY=old", "MINIO_ROOT_USER=foo", "MINIO_ROOT_PASSWORD=bar",
+			},
+			wantAccessKeyID: "foo",
+			wantSecretKey:   "bar",
+		},
+		{
+			name:            "docker secrets files",
+			env:             []string{"MINIO_ROOT_USER_FILE=access_key", "MINIO_ROOT_PASSWORD_FILE=/etc/minio/secret_key"},
+			files:           map[string]string{"/run/secrets/access_key": "foo\n", "/etc/minio/secret_key": "bar"},
+			wantAccessKeyID: "foo",
+			wantSecretKey:   "bar",
+		},
+		{
+			name:            "deprecated variables' files",
+			env:             []string{"MINIO_ACCESS_KEY_FILE=access_key", "MINIO_SECRET_KEY_FILE=secret_key"},
+			files:           map[string]string{"/run/secrets/access_key": "foo", "/run/secrets/secret_key": "bar"},
+			wantAccessKeyID: "foo",
+			wantSecretKey:   "bar",
+		},
+		{
+			name:    "no credentials",
+			env:     []string{"PATH=/usr/bin"},
+			wantErr: ErrCredentialsNotFound,
+		},
+		{
+			name:    "no secret key",
+			env:     []string{"MINIO_ROOT_USER=foo"},
+			wantErr: ErrCredentialsNotFound,
+		},
+		{
+			name:    "empty secret file",
+			env:     []string{"MINIO_ROOT_USER=foo", "MINIO_ROOT_PASSWORD_FILE=secret_key"},
+			files:   map[string]string{"/run/secrets/secret_key": "\n"},
+			wantErr: ErrCredentialsNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			api := newFakeDockerAPI()
+			c := newFakeContainer("c0", "storage-node-0", "192.0.2.10", true)
+			c.Config = &container.Config{Env: tt.env}
+			api.setContainer(c)
+			api.files["c0"] = tt.files
+
+			// WHEN
+			accessKeyID, secretKey, err := newClient(api).Read(context.Background(), "c0")
+
+			// THEN
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unexpected error, want: %v, got: %v", tt.wantErr, err)
+			}
+			if accessKeyID != tt.wantAccessKeyID || secretKey != tt.wantSecretKey {
+				t.Errorf("unexpected credentials, want: %s/%s, got: %s/%s",
+					tt.wantAccessKeyID, tt.wantSecretKey, accessKeyID, secretKey)
+			}
+		})
+	}
+
+	t.Run("shall fail if the secret file cannot be read", func(t *testing.T) {
+		// GIVEN
+		api := newFakeDockerAPI()
+		c := newFakeContainer("c0", "storage-node-0", "192.0.2.10", true)
+		c.Config = &container.Config{Env: []string{"MINIO_ROOT_USER=foo", "MINIO_ROOT_PASSWORD_FILE=missing"}}
+		api.setContainer(c)
+
+		// WHEN
+		_, _, err := newClient(api).Read(context.Background(), "c0")
+
+		// THEN
+		if err == nil {
+			t.Errorf("error expected")
+		}
+	})
+}
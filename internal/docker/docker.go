@@ -2,80 +2,172 @@ package docker
 
 import (
 	"context"
+	"io"
+	"net"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
 )
 
-func NewClient() (*Client, error) {
+const (
+	// LabelPort the container label to set the port of the storage instance's API.
+	LabelPort = "gateway.storage.port"
+	// LabelScheme the container label to set the scheme of the storage instance's API, e.g. https.
+	LabelScheme = "gateway.storage.scheme"
+)
+
+func NewClient(opts ...Option) (*Client, error) {
 	c, err := client.NewClientWithOpts()
 	if err != nil {
 		return nil, err
 	}
-	return &Client{c}, err
+	return newClient(c, opts...), err
+}
+
+func newClient(api dockerAPI, opts ...Option) *Client {
+	o := &Client{
+		api:      api,
+		watchers: map[string]*watchState{},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Option defines the Client's optional configuration.
+type Option func(*Client)
+
+// WithNetwork sets the name of the Docker network to read the storage instance's IP address from.
+// The IP address from the lexically first network is used by default.
+func WithNetwork(name string) Option {
+	return func(c *Client) {
+		c.network = name
+	}
 }
 
 type Client struct {
-	*client.Client
+	api     dockerAPI
+	network string
+
+	mu       sync.RWMutex
+	watchers map[string]*watchState
+}
+
+// dockerAPI defines the subset of the Docker Engine API used by the Client.
+type dockerAPI interface {
+	ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error)
+	ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error)
+	Events(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error)
+	CopyFromContainer(ctx context.Context, containerID, srcPath string) (io.ReadCloser, types.ContainerPathStat, error)
 }
 
+// Scan returns the storage instances maintained by the watcher if it runs for the selector,
+// otherwise it lists the containers.
+// The selector can be defined as the label "key=value", e.g. "gateway.role=storage",
+// or as the substring of the container name.
 func (c *Client) Scan(ctx context.Context, serviceLabelFilter string) (map[string]string, error) {
-	const statusOK = "running"
-	containers, err := c.ContainerList(ctx, types.ContainerListOptions{
-		Filters: filters.NewArgs(filters.KeyValuePair{
-			Key:   "name",
-			Value: serviceLabelFilter,
-		}),
+	if o, ok := c.watchedInstances(serviceLabelFilter); ok {
+		return o, nil
+	}
+	return c.listInstances(ctx, serviceLabelFilter)
+}
+
+func (c *Client) listInstances(ctx context.Context, serviceLabelFilter string) (map[string]string, error) {
+	containers, err := c.api.ContainerList(ctx, types.ContainerListOptions{
+		Filters: selectorFilter(serviceLabelFilter),
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	return c.instancesFromContainers(containers, serviceLabelFilter), nil
+}
+
+// instancesFromContainers returns the map of running containers' IDs to their endpoints.
+func (c *Client) instancesFromContainers(containers []types.Container, serviceLabelFilter string) map[string]string {
+	const statusOK = "running"
+
 	var o = make(map[string]string)
 	for _, container := range containers {
-		if container.State == statusOK {
-			if container.NetworkSettings != nil {
-				for _, network := range container.NetworkSettings.Networks {
-					o[container.ID] = network.IPAddress
-					// use the first found IP for now
-					break
-				}
-			}
+		if container.State != statusOK || container.NetworkSettings == nil {
+			continue
+		}
+
+		if !matchesSelector(strings.Join(container.Names, ","), container.Labels, serviceLabelFilter) {
+			continue
+		}
+
+		if endpoint := c.containerEndpoint(container.Labels, container.NetworkSettings.Networks); endpoint != "" {
+			o[container.ID] = endpoint
 		}
 	}
-	return o, err
+	return o
 }
 
-func (c *Client) Read(ctx context.Context, instanceID string) (string, string, error) {
-	info, err := c.ContainerInspect(ctx, instanceID)
-	if err != nil {
-		return "", "", err
+// selectorFilter defines the containers list filter given the selector.
+func selectorFilter(serviceLabelFilter string) filters.Args {
+	if _, _, ok := strings.Cut(serviceLabelFilter, "="); ok {
+		return filters.NewArgs(filters.Arg("label", serviceLabelFilter))
+	}
+	return filters.NewArgs(filters.Arg("name", serviceLabelFilter))
+}
+
+// matchesSelector defines if the container matches the selector given its name and labels.
+func matchesSelector(name string, labels map[string]string, serviceLabelFilter string) bool {
+	if key, value, ok := strings.Cut(serviceLabelFilter, "="); ok {
+		v, found := labels[key]
+		return found && v == value
 	}
+	return strings.Contains(name, serviceLabelFilter)
+}
 
-	accessKeyID, secretAccessKey := readAccessCredentialsFromEnv(info.Config.Env)
+// containerEndpoint returns the storage instance's endpoint given the container's labels and networks.
+// It returns empty string if the container is not attached to the network.
+func (c *Client) containerEndpoint(labels map[string]string, networks map[string]*network.EndpointSettings) string {
+	ipAddress := c.ipAddress(networks)
+	if ipAddress == "" {
+		return ""
+	}
+
+	o := ipAddress
+	if port := labels[LabelPort]; port != "" {
+		o = net.JoinHostPort(ipAddress, port)
+	}
 
-	return accessKeyID, secretAccessKey, nil
+	if scheme := labels[LabelScheme]; scheme != "" {
+		o = scheme + "://" + o
+	}
+
+	return o
 }
 
-func readAccessCredentialsFromEnv(envVars []string) (accessKeyID, secretAccessKey string) {
-	for _, kvPair := range envVars {
-		// stop scanning env variable when the credentials are found
-		if accessKeyID != "" && secretAccessKey != "" {
-			break
+// ipAddress returns the IP address in the configured network, or in the lexically first network.
+func (c *Client) ipAddress(networks map[string]*network.EndpointSettings) string {
+	if c.network != "" {
+		if settings, ok := networks[c.network]; ok && settings != nil {
+			return settings.IPAddress
 		}
+		return ""
+	}
 
-		const cntElements = 2
-		els := strings.SplitN(kvPair, "=", cntElements)
+	names := make([]string, 0, len(networks))
+	for name := range networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-		switch els[0] {
-		case "MINIO_SECRET_KEY":
-			secretAccessKey = els[1]
-		case "MINIO_ACCESS_KEY":
-			accessKeyID = els[1]
+	for _, name := range names {
+		if settings := networks[name]; settings != nil && settings.IPAddress != "" {
+			return settings.IPAddress
 		}
 	}
 
-	return
+	return ""
 }
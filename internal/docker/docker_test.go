@@ -0,0 +1,145 @@
+package docker
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+func newSummaryContainer(id, name, state string, labels map[string]string, ipAddresses map[string]string) types.Container {
+	networks := make(map[string]*network.EndpointSettings, len(ipAddresses))
+	for networkName, ipAddress := range ipAddresses {
+		networks[networkName] = &network.EndpointSettings{IPAddress: ipAddress}
+	}
+	return types.Container{
+		ID:              id,
+		Names:           []string{"/" + name},
+		Labels:          labels,
+		State:           state,
+		NetworkSettings: &types.SummaryNetworkSettings{Networks: networks},
+	}
+}
+
+func TestClient_instancesFromContainers(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []Option
+		selector   string
+		containers []types.Container
+		want       map[string]string
+	}{
+		{
+			name:     "shall select the running containers by name",
+			selector: "storage-node",
+			containers: []types.Container{
+				newSummaryContainer("c0", "storage-node-0", "running", nil, map[string]string{"bridge": "172.17.0.2"}),
+				newSummaryContainer("c1", "storage-node-1", "exited", nil, map[string]string{"bridge": "172.17.0.3"}),
+				newSummaryContainer("x0", "other", "running", nil, map[string]string{"bridge": "172.17.0.4"}),
+			},
+			want: map[string]string{"c0": "172.17.0.2"},
+		},
+		{
+			name:     "shall select the running containers by label",
+			selector: "gateway.role=storage",
+			containers: []types.Container{
+				newSummaryContainer("c0", "node-0", "running",
+					map[string]string{"gateway.role": "storage"}, map[string]string{"bridge": "172.17.0.2"}),
+				newSummaryContainer("x0", "node-1", "running",
+					map[string]string{"gateway.role": "gateway"}, map[string]string{"bridge": "172.17.0.3"}),
+				newSummaryContainer("x1", "gateway.role=storage", "running",
+					nil, map[string]string{"bridge": "172.17.0.4"}),
+			},
+			want: map[string]string{"c0": "172.17.0.2"},
+		},
+		{
+			name:     "shall define the endpoint using the port and scheme labels",
+			selector: "storage-node",
+			containers: []types.Container{
+				newSummaryContainer("c0", "storage-node-0", "running",
+					map[string]string{LabelPort: "9001"}, map[string]string{"bridge": "172.17.0.2"}),
+				newSummaryContainer("c1", "storage-node-1", "running",
+					map[string]string{LabelPort: "9443", LabelScheme: "https"}, map[string]string{"bridge": "172.17.0.3"}),
+				newSummaryContainer("c2", "storage-node-2", "running",
+					map[string]string{LabelScheme: "https"}, map[string]string{"bridge": "172.17.0.4"}),
+			},
+			want: map[string]string{
+				"c0": "172.17.0.2:9001",
+				"c1": "https://172.17.0.3:9443",
+				"c2": "https://172.17.0.4",
+			},
+		},
+		{
+			name:     "shall use the IP address from the configured network",
+			opts:     []Option{WithNetwork("storage")},
+			selector: "storage-node",
+			containers: []types.Container{
+				newSummaryContainer("c0", "storage-node-0", "running",
+					nil, map[string]string{"bridge": "172.17.0.2", "storage": "10.0.0.2"}),
+				newSummaryContainer("c1", "storage-node-1", "running",
+					nil, map[string]string{"bridge": "172.17.0.3"}),
+			},
+			want: map[string]string{"c0": "10.0.0.2"},
+		},
+		{
+			name:     "shall use the IP address from the lexically first network by default",
+			selector: "storage-node",
+			containers: []types.Container{
+				newSummaryContainer("c0", "storage-node-0", "running",
+					nil, map[string]string{"storage": "10.0.0.2", "bridge": "172.17.0.2", "admin": ""}),
+			},
+			want: map[string]string{"c0": "172.17.0.2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			c := newClient(nil, tt.opts...)
+
+			// WHEN
+			got := c.instancesFromContainers(tt.containers, tt.selector)
+
+			// THEN
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unexpected instances, want: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_selectorFilter(t *testing.T) {
+	if got := selectorFilter("gateway.role=storage").Get("label"); !reflect.DeepEqual(got, []string{"gateway.role=storage"}) {
+		t.Errorf("label filter expected, got: %v", got)
+	}
+
+	if got := selectorFilter("storage-node").Get("name"); !reflect.DeepEqual(got, []string{"storage-node"}) {
+		t.Errorf("name filter expected, got: %v", got)
+	}
+}
+
+func TestClient_Scan_labelSelector(t *testing.T) {
+	// GIVEN
+	api := newFakeDockerAPI()
+	labelled := newFakeContainer("c0", "node-0", "192.0.2.10", true)
+	labelled.Config = &container.Config{Labels: map[string]string{"gateway.role": "storage", LabelPort: "9001"}}
+	api.setContainer(labelled)
+	api.setContainer(newFakeContainer("x0", "storage-node", "192.0.2.100", true))
+
+	c := newClient(api)
+
+	// WHEN
+	got, err := c.Scan(context.Background(), "gateway.role=storage")
+
+	// THEN
+	if err != nil {
+		t.Errorf("no error expected, got: %v", err)
+		return
+	}
+
+	if want := map[string]string{"c0": "192.0.2.10:9001"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected instances, want: %v, got: %v", want, got)
+	}
+}
@@ -0,0 +1,209 @@
+package docker
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// watchState defines the storage instances maintained by the watcher.
+type watchState struct {
+	// synced indicates that the instances are in sync with the Docker daemon.
+	synced    bool
+	instances map[string]string
+}
+
+// resubscribeDelay the delay before resubscribing to the events stream after the failure.
+const resubscribeDelay = time.Second
+
+// Watch subscribes to the Docker events stream to maintain the storage instances matching the selector in memory.
+// It blocks until the context is cancelled. While the watcher is in sync with the Docker daemon,
+// Scan returns the instances from memory instead of listing the containers.
+func (c *Client) Watch(ctx context.Context, serviceLabelFilter string, logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger = logger.WithGroup("docker")
+
+	defer c.setWatchState(serviceLabelFilter, nil)
+
+	for {
+		err := c.watch(ctx, serviceLabelFilter, logger)
+		c.setWatchState(serviceLabelFilter, nil)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		logger.Error("events stream failed, resubscribing",
+			slog.String("selector", serviceLabelFilter),
+			slog.String("error", err.Error()),
+		)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(resubscribeDelay):
+		}
+	}
+}
+
+// watch subscribes to the events stream, syncs the state and applies the events until the stream fails.
+func (c *Client) watch(ctx context.Context, serviceLabelFilter string, logger *slog.Logger) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// subscription precedes the listing to not miss the events which occur in between
+	messages, errs := c.api.Events(ctx, types.EventsOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("type", events.ContainerEventType),
+			filters.Arg("type", events.NetworkEventType),
+			filters.Arg("event", "start"),
+			filters.Arg("event", "die"),
+			filters.Arg("event", "destroy"),
+			filters.Arg("event", "connect"),
+			filters.Arg("event", "disconnect"),
+		),
+	})
+
+	instances, err := c.listInstances(ctx, serviceLabelFilter)
+	if err != nil {
+		return err
+	}
+
+	c.setWatchState(serviceLabelFilter, &watchState{synced: true, instances: instances})
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errs:
+			return err
+		case msg := <-messages:
+			c.applyEvent(ctx, serviceLabelFilter, msg, logger)
+		}
+	}
+}
+
+// applyEvent updates the state of the container which the event refers to.
+func (c *Client) applyEvent(ctx context.Context, serviceLabelFilter string, msg events.Message, logger *slog.Logger) {
+	var containerID string
+	switch msg.Type {
+	case events.ContainerEventType:
+		containerID = msg.Actor.ID
+		// the container's labels are passed as the event attributes
+		if !matchesSelector(msg.Actor.Attributes["name"], msg.Actor.Attributes, serviceLabelFilter) {
+			return
+		}
+	case events.NetworkEventType:
+		containerID = msg.Actor.Attributes["container"]
+	default:
+		return
+	}
+
+	if containerID == "" {
+		return
+	}
+
+	var endpoint string
+	switch msg.Action {
+	case "die", "destroy":
+	default:
+		var ok bool
+		endpoint, ok = c.inspectInstance(ctx, containerID, serviceLabelFilter)
+		if !ok && msg.Type == events.NetworkEventType && !c.watchesInstance(serviceLabelFilter, containerID) {
+			// the network event of a container which is not a storage instance
+			return
+		}
+	}
+
+	logger.Debug("event",
+		slog.String("selector", serviceLabelFilter),
+		slog.String("type", msg.Type),
+		slog.String("action", msg.Action),
+		slog.String("instanceID", containerID),
+		slog.String("endpoint", endpoint),
+	)
+
+	c.updateWatchedInstance(serviceLabelFilter, containerID, endpoint)
+}
+
+// inspectInstance returns the container's endpoint if it is a running storage instance.
+func (c *Client) inspectInstance(ctx context.Context, containerID, serviceLabelFilter string) (string, bool) {
+	info, err := c.api.ContainerInspect(ctx, containerID)
+	if err != nil || info.ContainerJSONBase == nil || info.State == nil || !info.State.Running {
+		return "", false
+	}
+
+	var labels map[string]string
+	if info.Config != nil {
+		labels = info.Config.Labels
+	}
+
+	if info.NetworkSettings == nil || !matchesSelector(info.Name, labels, serviceLabelFilter) {
+		return "", false
+	}
+
+	endpoint := c.containerEndpoint(labels, info.NetworkSettings.Networks)
+	return endpoint, endpoint != ""
+}
+
+func (c *Client) watchedInstances(serviceLabelFilter string) (map[string]string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	state, ok := c.watchers[serviceLabelFilter]
+	if !ok || !state.synced {
+		return nil, false
+	}
+
+	return maps.Clone(state.instances), true
+}
+
+func (c *Client) watchesInstance(serviceLabelFilter, containerID string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	state, ok := c.watchers[serviceLabelFilter]
+	if !ok {
+		return false
+	}
+
+	_, ok = state.instances[containerID]
+	return ok
+}
+
+func (c *Client) setWatchState(serviceLabelFilter string, state *watchState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if state == nil {
+		delete(c.watchers, serviceLabelFilter)
+		return
+	}
+
+	c.watchers[serviceLabelFilter] = state
+}
+
+// updateWatchedInstance sets the instance's endpoint, or removes the instance if the endpoint is empty.
+func (c *Client) updateWatchedInstance(serviceLabelFilter, containerID, endpoint string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state, ok := c.watchers[serviceLabelFilter]
+	if !ok {
+		return
+	}
+
+	if endpoint == "" {
+		delete(state.instances, containerID)
+		return
+	}
+
+	state.instances[containerID] = endpoint
+}
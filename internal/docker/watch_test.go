@@ -0,0 +1,343 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/network"
+)
+
+// fakeDockerAPI emulates the Docker daemon with the set of containers and the events stream.
+type fakeDockerAPI struct {
+	mu         sync.Mutex
+	containers map[string]types.ContainerJSON
+	// files the containers' files keyed by the container ID and the path.
+	files map[string]map[string]string
+	// subscriptions the events streams opened by the client.
+	subscriptions chan fakeEventsStream
+}
+
+type fakeEventsStream struct {
+	messages chan events.Message
+	errs     chan error
+}
+
+func newFakeDockerAPI() *fakeDockerAPI {
+	return &fakeDockerAPI{
+		containers:    map[string]types.ContainerJSON{},
+		files:         map[string]map[string]string{},
+		subscriptions: make(chan fakeEventsStream, 10),
+	}
+}
+
+func newFakeContainer(id, name, ipAddress string, running bool) types.ContainerJSON {
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID:    id,
+			Name:  "/" + name,
+			State: &types.ContainerState{Running: running},
+		},
+		NetworkSettings: &types.NetworkSettings{
+			Networks: map[string]*network.EndpointSettings{"storage": {IPAddress: ipAddress}},
+		},
+	}
+}
+
+func (f *fakeDockerAPI) setContainer(c types.ContainerJSON) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.containers[c.ID] = c
+}
+
+func (f *fakeDockerAPI) ContainerList(_ context.Context, options types.ContainerListOptions) ([]types.Container, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var o []types.Container
+	for _, c := range f.containers {
+		var labels map[string]string
+		if c.Config != nil {
+			labels = c.Config.Labels
+		}
+		if !matchesNameFilter(c.Name, options.Filters.Get("name")) ||
+			!matchesLabelFilter(labels, options.Filters.Get("label")) {
+			continue
+		}
+		state := "exited"
+		if c.State.Running {
+			state = "running"
+		}
+		o = append(o, types.Container{
+			ID:              c.ID,
+			Names:           []string{c.Name},
+			Labels:          labels,
+			State:           state,
+			NetworkSettings: &types.SummaryNetworkSettings{Networks: c.NetworkSettings.Networks},
+		})
+	}
+	return o, nil
+}
+
+func matchesNameFilter(name string, filterValues []string) bool {
+	for _, v := range filterValues {
+		if !strings.Contains(name, v) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesLabelFilter(labels map[string]string, filterValues []string) bool {
+	for _, v := range filterValues {
+		key, value, _ := strings.Cut(v, "=")
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *fakeDockerAPI) ContainerInspect(_ context.Context, containerID string) (types.ContainerJSON, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.containers[containerID]
+	if !ok {
+		return types.ContainerJSON{}, errors.New("no such container")
+	}
+	return c, nil
+}
+
+func (f *fakeDockerAPI) CopyFromContainer(_ context.Context, containerID, srcPath string) (
+	io.ReadCloser, types.ContainerPathStat, error,
+) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	content, ok := f.files[containerID][srcPath]
+	if !ok {
+		return nil, types.ContainerPathStat{}, errors.New("no such file")
+	}
+
+	var buf bytes.Buffer
+	archive := tar.NewWriter(&buf)
+	_ = archive.WriteHeader(&tar.Header{
+		Name: path.Base(srcPath), Typeflag: tar.TypeReg, Mode: 0o400, Size: int64(len(content)),
+	})
+	_, _ = archive.Write([]byte(content))
+	_ = archive.Close()
+
+	return io.NopCloser(&buf), types.ContainerPathStat{Name: path.Base(srcPath), Size: int64(len(content))}, nil
+}
+
+func (f *fakeDockerAPI) Events(_ context.Context, _ types.EventsOptions) (<-chan events.Message, <-chan error) {
+	stream := fakeEventsStream{messages: make(chan events.Message), errs: make(chan error, 1)}
+	f.subscriptions <- stream
+	return stream.messages, stream.errs
+}
+
+// subscription waits for the client to subscribe to the events stream.
+func (f *fakeDockerAPI) subscription(t *testing.T) fakeEventsStream {
+	t.Helper()
+	select {
+	case stream := <-f.subscriptions:
+		return stream
+	case <-time.After(3 * time.Second):
+		t.Fatal("the client did not subscribe to the events stream")
+		return fakeEventsStream{}
+	}
+}
+
+// waitForInstances waits up to a second for Scan to return the expected instances.
+func waitForInstances(t *testing.T, c *Client, selector string, want map[string]string) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	var got map[string]string
+	for time.Now().Before(deadline) {
+		got, _ = c.watchedInstances(selector)
+		if reflect.DeepEqual(got, want) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Errorf("unexpected instances, want: %v, got: %v", want, got)
+}
+
+func TestClient_Watch(t *testing.T) {
+	const selector = "storage-node"
+
+	// GIVEN
+	api := newFakeDockerAPI()
+	api.setContainer(newFakeContainer("c0", selector+"-0", "192.0.2.10", true))
+	api.setContainer(newFakeContainer("x0", "other", "192.0.2.100", true))
+
+	c := newClient(api)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		c.Watch(ctx, selector, nil)
+		close(done)
+	}()
+
+	stream := api.subscription(t)
+
+	t.Run("shall sync the instances on subscription", func(t *testing.T) {
+		waitForInstances(t, c, selector, map[string]string{"c0": "192.0.2.10"})
+	})
+
+	t.Run("shall add the started instance", func(t *testing.T) {
+		api.setContainer(newFakeContainer("c1", selector+"-1", "192.0.2.11", true))
+		stream.messages <- events.Message{
+			Type:   events.ContainerEventType,
+			Action: "start",
+			Actor:  events.Actor{ID: "c1", Attributes: map[string]string{"name": selector + "-1"}},
+		}
+
+		waitForInstances(t, c, selector, map[string]string{"c0": "192.0.2.10", "c1": "192.0.2.11"})
+	})
+
+	t.Run("shall ignore the container which is not a storage instance", func(t *testing.T) {
+		api.setContainer(newFakeContainer("x1", "other-1", "192.0.2.101", true))
+		stream.messages <- events.Message{
+			Type:   events.ContainerEventType,
+			Action: "start",
+			Actor:  events.Actor{ID: "x1", Attributes: map[string]string{"name": "other-1"}},
+		}
+		stream.messages <- events.Message{
+			Type:   events.NetworkEventType,
+			Action: "connect",
+			Actor:  events.Actor{ID: "net", Attributes: map[string]string{"container": "x1"}},
+		}
+
+		waitForInstances(t, c, selector, map[string]string{"c0": "192.0.2.10", "c1": "192.0.2.11"})
+	})
+
+	t.Run("shall remove the instance disconnected from the network", func(t *testing.T) {
+		disconnected := newFakeContainer("c1", selector+"-1", "", true)
+		disconnected.NetworkSettings.Networks = nil
+		api.setContainer(disconnected)
+		stream.messages <- events.Message{
+			Type:   events.NetworkEventType,
+			Action: "disconnect",
+			Actor:  events.Actor{ID: "net", Attributes: map[string]string{"container": "c1"}},
+		}
+
+		waitForInstances(t, c, selector, map[string]string{"c0": "192.0.2.10"})
+	})
+
+	t.Run("shall remove the stopped instance", func(t *testing.T) {
+		api.setContainer(newFakeContainer("c0", selector+"-0", "192.0.2.10", false))
+		stream.messages <- events.Message{
+			Type:   events.ContainerEventType,
+			Action: "die",
+			Actor:  events.Actor{ID: "c0", Attributes: map[string]string{"name": selector + "-0"}},
+		}
+
+		waitForInstances(t, c, selector, map[string]string{})
+	})
+
+	t.Run("shall resubscribe and resync after the stream failure", func(t *testing.T) {
+		api.setContainer(newFakeContainer("c2", selector+"-2", "192.0.2.12", true))
+		stream.errs <- errors.New("connection reset")
+
+		stream = api.subscription(t)
+		waitForInstances(t, c, selector, map[string]string{"c2": "192.0.2.12"})
+	})
+
+	t.Run("shall stop when the context is cancelled", func(t *testing.T) {
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Errorf("the watcher is expected to stop")
+			return
+		}
+
+		if _, ok := c.watchedInstances(selector); ok {
+			t.Errorf("no watched instances expected after the watcher stopped")
+		}
+	})
+}
+
+func TestClient_Scan(t *testing.T) {
+	const selector = "storage-node"
+
+	// GIVEN
+	api := newFakeDockerAPI()
+	api.setContainer(newFakeContainer("c0", selector+"-0", "192.0.2.10", true))
+	api.setContainer(newFakeContainer("c1", selector+"-1", "192.0.2.11", false))
+	api.setContainer(newFakeContainer("x0", "other", "192.0.2.100", true))
+
+	c := newClient(api)
+
+	// WHEN
+	got, err := c.Scan(context.Background(), selector)
+
+	// THEN
+	if err != nil {
+		t.Errorf("no error expected, got: %v", err)
+		return
+	}
+
+	if want := map[string]string{"c0": "192.0.2.10"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected instances, want: %v, got: %v", want, got)
+	}
+}
+
+func TestClient_Watch_labelSelector(t *testing.T) {
+	const selector = "gateway.role=storage"
+
+	// GIVEN
+	api := newFakeDockerAPI()
+	c := newClient(api, WithNetwork("storage"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go c.Watch(ctx, selector, nil)
+
+	stream := api.subscription(t)
+	waitForInstances(t, c, selector, map[string]string{})
+
+	// WHEN
+	labelled := newFakeContainer("c0", "node-0", "192.0.2.10", true)
+	labelled.Config = &container.Config{
+		Labels: map[string]string{"gateway.role": "storage", LabelPort: "9001", LabelScheme: "https"},
+	}
+	api.setContainer(labelled)
+	stream.messages <- events.Message{
+		Type:   events.ContainerEventType,
+		Action: "start",
+		Actor: events.Actor{
+			ID:         "c0",
+			Attributes: map[string]string{"name": "node-0", "gateway.role": "storage"},
+		},
+	}
+
+	api.setContainer(newFakeContainer("x0", "storage-node", "192.0.2.100", true))
+	stream.messages <- events.Message{
+		Type:   events.ContainerEventType,
+		Action: "start",
+		Actor:  events.Actor{ID: "x0", Attributes: map[string]string{"name": "storage-node"}},
+	}
+
+	// THEN
+	waitForInstances(t, c, selector, map[string]string{"c0": "https://192.0.2.10:9001"})
+}
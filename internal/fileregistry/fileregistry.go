@@ -0,0 +1,231 @@
+// Package fileregistry implements the service registry and the authentication details reader
+// backed by the static YAML, or JSON file.
+package fileregistry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPollInterval the default interval to check the registry file for changes.
+const DefaultPollInterval = 5 * time.Second
+
+// Config defines the registry file's content.
+type Config struct {
+	Instances []Instance `yaml:"instances"`
+}
+
+// Instance defines the storage instance.
+type Instance struct {
+	// ID the instance's unique identifier.
+	ID string `yaml:"id"`
+	// Endpoint the instance's endpoint "[scheme://]host[:port]".
+	Endpoint string `yaml:"endpoint"`
+	// Labels the instance's labels used to select the instances.
+	Labels map[string]string `yaml:"labels"`
+	// Credentials the reference to the credentials to connect to the instance.
+	Credentials Credentials `yaml:"credentials"`
+}
+
+// Credentials defines the reference to the access key ID and the secret access key.
+// Every key can be set inline, as the name of the environment variable, or as the path to the file.
+type Credentials struct {
+	AccessKey     string `yaml:"accessKey"`
+	AccessKeyEnv  string `yaml:"accessKeyEnv"`
+	AccessKeyFile string `yaml:"accessKeyFile"`
+
+	SecretKey     string `yaml:"secretKey"`
+	SecretKeyEnv  string `yaml:"secretKeyEnv"`
+	SecretKeyFile string `yaml:"secretKeyFile"`
+}
+
+// NewClient reads the registry file.
+func NewClient(path string) (*Client, error) {
+	c := &Client{path: path}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.load(content); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Client reads the storage instances and their credentials from the registry file.
+type Client struct {
+	path string
+
+	mu        sync.RWMutex
+	content   []byte
+	instances map[string]Instance
+}
+
+// Scan returns the storage instances matching the selector.
+// The selector can be defined as the label "key=value", e.g. "gateway.role=storage",
+// or as the substring of the instance ID. All instances are returned if the selector is empty.
+func (c *Client) Scan(_ context.Context, serviceLabelFilter string) (map[string]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var o = make(map[string]string)
+	for id, instance := range c.instances {
+		if matchesSelector(instance, serviceLabelFilter) {
+			o[id] = instance.Endpoint
+		}
+	}
+	return o, nil
+}
+
+func matchesSelector(instance Instance, serviceLabelFilter string) bool {
+	if key, value, ok := strings.Cut(serviceLabelFilter, "="); ok {
+		v, found := instance.Labels[key]
+		return found && v == value
+	}
+	return strings.Contains(instance.ID, serviceLabelFilter)
+}
+
+// Read resolves the reference to the credentials of the instance.
+func (c *Client) Read(_ context.Context, instanceID string) (string, string, error) {
+	c.mu.RLock()
+	instance, ok := c.instances[instanceID]
+	c.mu.RUnlock()
+
+	if !ok {
+		return "", "", fmt.Errorf("instance %s not found in the registry", instanceID)
+	}
+
+	accessKeyID, err := resolveSecret(instance.Credentials.AccessKey, instance.Credentials.AccessKeyEnv,
+		instance.Credentials.AccessKeyFile)
+	if err != nil {
+		return "", "", fmt.Errorf("instance %s access key: %w", instanceID, err)
+	}
+
+	secretAccessKey, err := resolveSecret(instance.Credentials.SecretKey, instance.Credentials.SecretKeyEnv,
+		instance.Credentials.SecretKeyFile)
+	if err != nil {
+		return "", "", fmt.Errorf("instance %s secret key: %w", instanceID, err)
+	}
+
+	return accessKeyID, secretAccessKey, nil
+}
+
+// resolveSecret returns the inline value, or reads it from the environment variable, or from the file.
+func resolveSecret(value, envName, filePath string) (string, error) {
+	switch {
+	case value != "":
+		return value, nil
+	case envName != "":
+		v, ok := os.LookupEnv(envName)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s not set", envName)
+		}
+		return v, nil
+	case filePath != "":
+		v, err := os.ReadFile(filePath)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(v)), nil
+	default:
+		return "", errors.New("not defined")
+	}
+}
+
+// Watch polls the registry file and reloads it on change. It blocks until the context is cancelled.
+// The previous state is kept if the file cannot be read, or its content is invalid.
+func (c *Client) Watch(ctx context.Context, pollInterval time.Duration, logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger = logger.WithGroup("fileregistry")
+
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := c.reload()
+			switch {
+			case err != nil:
+				logger.Error("registry file reload failed",
+					slog.String("path", c.path),
+					slog.String("error", err.Error()),
+				)
+			case reloaded:
+				logger.Info("registry file reloaded", slog.String("path", c.path))
+			}
+		}
+	}
+}
+
+// reload reads the registry file and loads it if the content changed.
+func (c *Client) reload() (bool, error) {
+	content, err := os.ReadFile(c.path)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.RLock()
+	unchanged := bytes.Equal(content, c.content)
+	c.mu.RUnlock()
+
+	if unchanged {
+		return false, nil
+	}
+
+	if err := c.load(content); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// load parses and validates the registry file's content, and replaces the instances.
+func (c *Client) load(content []byte) error {
+	// JSON is parsed as well because it is a subset of YAML
+	var cfg Config
+	if err := yaml.Unmarshal(content, &cfg); err != nil {
+		return fmt.Errorf("cannot parse registry file: %w", err)
+	}
+
+	instances := make(map[string]Instance, len(cfg.Instances))
+	for i, instance := range cfg.Instances {
+		if instance.ID == "" {
+			return fmt.Errorf("instance #%d: id must be set", i)
+		}
+		if instance.Endpoint == "" {
+			return fmt.Errorf("instance %s: endpoint must be set", instance.ID)
+		}
+		if _, ok := instances[instance.ID]; ok {
+			return fmt.Errorf("instance %s: duplicate id", instance.ID)
+		}
+		instances[instance.ID] = instance
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.content = content
+	c.instances = instances
+
+	return nil
+}
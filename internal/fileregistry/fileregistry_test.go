@@ -0,0 +1,263 @@
+package fileregistry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const registryYAML = `instances:
+  - id: node-0
+    endpoint: 10.0.0.2
+    labels:
+      gateway.role: storage
+    credentials:
+      accessKey: foo
+      secretKey: bar
+  - id: node-1
+    endpoint: https://10.0.0.3:9443
+    labels:
+      gateway.role: storage
+    credentials:
+      accessKeyEnv: TEST_NODE_1_ACCESS_KEY
+      secretKeyFile: %s
+  - id: gateway-0
+    endpoint: 10.0.0.4
+    labels:
+      gateway.role: gateway
+`
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestRegistryFile(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	secretPath := filepath.Join(dir, "secret")
+	writeFile(t, secretPath, "qux\n")
+
+	path := filepath.Join(dir, "registry.yaml")
+	writeFile(t, path, fmt.Sprintf(registryYAML, secretPath))
+	return path
+}
+
+func TestClient_Scan(t *testing.T) {
+	// GIVEN
+	c, err := NewClient(newTestRegistryFile(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		selector string
+		want     map[string]string
+	}{
+		{
+			name:     "shall select the instances by label",
+			selector: "gateway.role=storage",
+			want:     map[string]string{"node-0": "10.0.0.2", "node-1": "https://10.0.0.3:9443"},
+		},
+		{
+			name:     "shall select the instances by ID",
+			selector: "gateway",
+			want:     map[string]string{"gateway-0": "10.0.0.4"},
+		},
+		{
+			name:     "shall select all instances given empty selector",
+			selector: "",
+			want: map[string]string{
+				"node-0": "10.0.0.2", "node-1": "https://10.0.0.3:9443", "gateway-0": "10.0.0.4",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// WHEN
+			got, err := c.Scan(context.Background(), tt.selector)
+
+			// THEN
+			if err != nil {
+				t.Errorf("no error expected, got: %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unexpected instances, want: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestClient_Read(t *testing.T) {
+	t.Setenv("TEST_NODE_1_ACCESS_KEY", "baz")
+
+	// GIVEN
+	c, err := NewClient(newTestRegistryFile(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		instanceID      string
+		wantAccessKeyID string
+		wantSecretKey   string
+		wantErr         bool
+	}{
+		{
+			name:            "inline credentials",
+			instanceID:      "node-0",
+			wantAccessKeyID: "foo",
+			wantSecretKey:   "bar",
+		},
+		{
+			name:            "credentials from env variable and file",
+			instanceID:      "node-1",
+			wantAccessKeyID: "baz",
+			wantSecretKey:   "qux",
+		},
+		{
+			name:       "credentials not defined",
+			instanceID: "gateway-0",
+			wantErr:    true,
+		},
+		{
+			name:       "unknown instance",
+			instanceID: "node-100",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// WHEN
+			accessKeyID, secretKey, err := c.Read(context.Background(), tt.instanceID)
+
+			// THEN
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if accessKeyID != tt.wantAccessKeyID || secretKey != tt.wantSecretKey {
+				t.Errorf("unexpected credentials, want: %s/%s, got: %s/%s",
+					tt.wantAccessKeyID, tt.wantSecretKey, accessKeyID, secretKey)
+			}
+		})
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{
+			name:    "json file",
+			content: `{"instances": [{"id": "node-0", "endpoint": "10.0.0.2"}]}`,
+		},
+		{
+			name:    "missing id",
+			content: `{"instances": [{"endpoint": "10.0.0.2"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "missing endpoint",
+			content: `{"instances": [{"id": "node-0"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "duplicate id",
+			content: `{"instances": [{"id": "node-0", "endpoint": "10.0.0.2"}, {"id": "node-0", "endpoint": "10.0.0.3"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid syntax",
+			content: `{"instances": [`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			path := filepath.Join(t.TempDir(), "registry.json")
+			writeFile(t, path, tt.content)
+
+			// WHEN
+			_, err := NewClient(path)
+
+			// THEN
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("shall fail if the file does not exist", func(t *testing.T) {
+		if _, err := NewClient(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+			t.Errorf("error expected")
+		}
+	})
+}
+
+// waitForInstances waits up to a second for Scan to return the expected instances.
+func waitForInstances(t *testing.T, c *Client, want map[string]string) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	var got map[string]string
+	for time.Now().Before(deadline) {
+		got, _ = c.Scan(context.Background(), "")
+		if reflect.DeepEqual(got, want) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Errorf("unexpected instances, want: %v, got: %v", want, got)
+}
+
+func TestClient_Watch(t *testing.T) {
+	// GIVEN
+	path := filepath.Join(t.TempDir(), "registry.yaml")
+	writeFile(t, path, `{"instances": [{"id": "node-0", "endpoint": "10.0.0.2"}]}`)
+
+	c, err := NewClient(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go c.Watch(ctx, 10*time.Millisecond, nil)
+
+	t.Run("shall reload the changed file", func(t *testing.T) {
+		writeFile(t, path, `{"instances": [{"id": "node-0", "endpoint": "10.0.0.2"}, {"id": "node-1", "endpoint": "10.0.0.3"}]}`)
+
+		waitForInstances(t, c, map[string]string{"node-0": "10.0.0.2", "node-1": "10.0.0.3"})
+	})
+
+	t.Run("shall keep the previous state if the file is invalid", func(t *testing.T) {
+		writeFile(t, path, `{"instances": [{"id": "node-0"}]}`)
+		time.Sleep(50 * time.Millisecond)
+
+		waitForInstances(t, c, map[string]string{"node-0": "10.0.0.2", "node-1": "10.0.0.3"})
+	})
+
+	t.Run("shall keep the previous state if the file is removed", func(t *testing.T) {
+		if err := os.Remove(path); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(50 * time.Millisecond)
+
+		waitForInstances(t, c, map[string]string{"node-0": "10.0.0.2", "node-1": "10.0.0.3"})
+	})
+}
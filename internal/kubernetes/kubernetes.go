@@ -0,0 +1,361 @@
+// Package kubernetes implements the service registry backed by the Kubernetes EndpointSlices,
+// and the authentication details reader backed by the Kubernetes Secrets referenced by the pods' annotations.
+package kubernetes
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// AnnotationCredentialsSecret the pod annotation which defines the name of the Secret with the credentials.
+	AnnotationCredentialsSecret = "gateway.storage/credentials-secret"
+	// SecretKeyAccessKey the key of the Secret's data with the access key ID.
+	SecretKeyAccessKey = "accessKey"
+	// SecretKeySecretKey the key of the Secret's data with the secret access key.
+	SecretKeySecretKey = "secretKey"
+	// LabelServiceName the label set by Kubernetes to link the EndpointSlice to the Service.
+	LabelServiceName = "kubernetes.io/service-name"
+
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+)
+
+// NewInClusterClient initialises the client using the pod's service account.
+func NewInClusterClient(opts ...Option) (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be set")
+	}
+
+	namespace, err := os.ReadFile(filepath.Join(serviceAccountDir, "namespace"))
+	if err != nil {
+		return nil, err
+	}
+
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("cannot parse the service account's CA certificate")
+	}
+
+	opts = append([]Option{
+		WithTokenFile(filepath.Join(serviceAccountDir, "token")),
+		WithHTTPClient(&http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}},
+		}),
+	}, opts...)
+
+	return NewClient("https://"+net.JoinHostPort(host, port), strings.TrimSpace(string(namespace)), opts...)
+}
+
+// NewClient initialises the client of the Kubernetes API server to discover the storage instances in the namespace.
+func NewClient(apiServer, namespace string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(apiServer)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, errors.New("apiServer must be defined as scheme://host:port")
+	}
+
+	if namespace == "" {
+		return nil, errors.New("namespace must be set")
+	}
+
+	o := &Client{
+		apiServer:  strings.TrimSuffix(apiServer, "/"),
+		namespace:  namespace,
+		httpClient: http.DefaultClient,
+		watchers:   map[string]*watchState{},
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o, nil
+}
+
+// Option defines the Client's optional configuration.
+type Option func(*Client)
+
+// WithToken sets the bearer token to authenticate the requests.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithTokenFile sets the path to the bearer token to authenticate the requests.
+// The file is read on every request because the projected service account tokens are rotated.
+func WithTokenFile(path string) Option {
+	return func(c *Client) {
+		c.tokenFile = path
+	}
+}
+
+// WithHTTPClient sets the HTTP client.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithPortName sets the name of the EndpointSlice's port to connect to the storage instances.
+// The first port is used by default.
+func WithPortName(name string) Option {
+	return func(c *Client) {
+		c.portName = name
+	}
+}
+
+// Client reads the storage instances from the EndpointSlices and their credentials from the Secrets.
+type Client struct {
+	apiServer  string
+	namespace  string
+	token      string
+	tokenFile  string
+	portName   string
+	httpClient *http.Client
+
+	mu       sync.RWMutex
+	watchers map[string]*watchState
+}
+
+type objectMeta struct {
+	Name            string            `json:"name"`
+	ResourceVersion string            `json:"resourceVersion"`
+	Annotations     map[string]string `json:"annotations"`
+}
+
+type endpointSlice struct {
+	Metadata  objectMeta `json:"metadata"`
+	Endpoints []struct {
+		Addresses  []string `json:"addresses"`
+		Conditions struct {
+			Ready *bool `json:"ready"`
+		} `json:"conditions"`
+		TargetRef *struct {
+			Kind string `json:"kind"`
+			Name string `json:"name"`
+		} `json:"targetRef"`
+	} `json:"endpoints"`
+	Ports []struct {
+		Name        string  `json:"name"`
+		Port        *int    `json:"port"`
+		AppProtocol *string `json:"appProtocol"`
+	} `json:"ports"`
+}
+
+type endpointSliceList struct {
+	Metadata objectMeta      `json:"metadata"`
+	Items    []endpointSlice `json:"items"`
+}
+
+// Scan returns the ready endpoints maintained by the watcher if it runs for the selector,
+// otherwise it lists the EndpointSlices.
+// The selector can be defined as the label selector, e.g. "gateway.role=storage",
+// or as the name of the Service.
+func (c *Client) Scan(ctx context.Context, serviceLabelFilter string) (map[string]string, error) {
+	if o, ok := c.watchedInstances(serviceLabelFilter); ok {
+		return o, nil
+	}
+
+	list, err := c.listEndpointSlices(ctx, serviceLabelFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	var o = make(map[string]string)
+	for _, slice := range list.Items {
+		for id, endpoint := range c.sliceInstances(slice) {
+			o[id] = endpoint
+		}
+	}
+	return o, nil
+}
+
+// labelSelector returns the label selector of the EndpointSlices given the selector.
+func labelSelector(serviceLabelFilter string) string {
+	if strings.Contains(serviceLabelFilter, "=") {
+		return serviceLabelFilter
+	}
+	return LabelServiceName + "=" + serviceLabelFilter
+}
+
+func (c *Client) endpointSlicesPath() string {
+	return "/apis/discovery.k8s.io/v1/namespaces/" + url.PathEscape(c.namespace) + "/endpointslices"
+}
+
+func (c *Client) listEndpointSlices(ctx context.Context, serviceLabelFilter string) (endpointSliceList, error) {
+	var o endpointSliceList
+	err := c.get(ctx, c.endpointSlicesPath(), url.Values{"labelSelector": {labelSelector(serviceLabelFilter)}}, &o)
+	return o, err
+}
+
+// sliceInstances returns the map of the ready endpoints' IDs to their endpoints.
+// The endpoint's ID is the name of the pod, or the address if the endpoint does not refer to the pod.
+func (c *Client) sliceInstances(slice endpointSlice) map[string]string {
+	var (
+		port   int
+		scheme string
+		found  bool
+	)
+	for _, p := range slice.Ports {
+		if p.Port == nil || (c.portName != "" && p.Name != c.portName) {
+			continue
+		}
+		port, found = *p.Port, true
+		if p.AppProtocol != nil && strings.EqualFold(*p.AppProtocol, "https") {
+			scheme = "https"
+		}
+		break
+	}
+
+	var o = make(map[string]string)
+	if !found {
+		return o
+	}
+
+	for _, endpoint := range slice.Endpoints {
+		// the endpoint with unknown readiness shall be interpreted as ready
+		if len(endpoint.Addresses) == 0 || (endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready) {
+			continue
+		}
+
+		address := endpoint.Addresses[0]
+		id := address
+		if endpoint.TargetRef != nil && endpoint.TargetRef.Kind == "Pod" && endpoint.TargetRef.Name != "" {
+			id = endpoint.TargetRef.Name
+		}
+
+		v := net.JoinHostPort(address, strconv.Itoa(port))
+		if scheme != "" {
+			v = scheme + "://" + v
+		}
+		o[id] = v
+	}
+
+	return o
+}
+
+type pod struct {
+	Metadata objectMeta `json:"metadata"`
+}
+
+type secret struct {
+	Data map[string][]byte `json:"data"`
+}
+
+// Read reads the credentials from the Secret referenced by the pod's annotation AnnotationCredentialsSecret.
+// The Secret is expected to store the credentials under the keys SecretKeyAccessKey and SecretKeySecretKey.
+func (c *Client) Read(ctx context.Context, instanceID string) (string, string, error) {
+	var p pod
+	if err := c.get(ctx, "/api/v1/namespaces/"+url.PathEscape(c.namespace)+"/pods/"+url.PathEscape(instanceID),
+		nil, &p); err != nil {
+		return "", "", err
+	}
+
+	secretName := p.Metadata.Annotations[AnnotationCredentialsSecret]
+	if secretName == "" {
+		return "", "", fmt.Errorf("pod %s has no annotation %s", instanceID, AnnotationCredentialsSecret)
+	}
+
+	var s secret
+	if err := c.get(ctx, "/api/v1/namespaces/"+url.PathEscape(c.namespace)+"/secrets/"+url.PathEscape(secretName),
+		nil, &s); err != nil {
+		return "", "", err
+	}
+
+	accessKeyID, secretAccessKey := string(s.Data[SecretKeyAccessKey]), string(s.Data[SecretKeySecretKey])
+	if accessKeyID == "" || secretAccessKey == "" {
+		return "", "", fmt.Errorf("secret %s must define %s and %s", secretName, SecretKeyAccessKey, SecretKeySecretKey)
+	}
+
+	return accessKeyID, secretAccessKey, nil
+}
+
+// statusError defines the error returned by the API server.
+type statusError struct {
+	code    int
+	message string
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("kubernetes API returned %d: %s", e.code, e.message)
+}
+
+// get sends the GET request and decodes the JSON response.
+func (c *Client) get(ctx context.Context, path string, query url.Values, v any) error {
+	resp, err := c.do(ctx, path, query)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// do sends the GET request, and returns the response if the request succeeded.
+func (c *Client) do(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	u := c.apiServer + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	token := c.token
+	if c.tokenFile != "" {
+		v, err := os.ReadFile(c.tokenFile)
+		if err != nil {
+			return nil, err
+		}
+		token = strings.TrimSpace(string(v))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer func() { _ = resp.Body.Close() }()
+
+		var status struct {
+			Message string `json:"message"`
+		}
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if err := json.Unmarshal(msg, &status); err != nil || status.Message == "" {
+			status.Message = strings.TrimSpace(string(msg))
+		}
+		return nil, statusError{code: resp.StatusCode, message: status.Message}
+	}
+
+	return resp, nil
+}
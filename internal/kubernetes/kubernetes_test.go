@@ -0,0 +1,366 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type fakeEndpoint struct {
+	address string
+	pod     string
+	ready   *bool
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func newFakeSlice(name string, labels map[string]string, port int, appProtocol string, endpoints ...fakeEndpoint) map[string]any {
+	var eps []map[string]any
+	for _, e := range endpoints {
+		ep := map[string]any{"addresses": []string{e.address}, "conditions": map[string]any{"ready": e.ready}}
+		if e.pod != "" {
+			ep["targetRef"] = map[string]any{"kind": "Pod", "name": e.pod}
+		}
+		eps = append(eps, ep)
+	}
+
+	p := map[string]any{"name": "api", "port": port}
+	if appProtocol != "" {
+		p["appProtocol"] = appProtocol
+	}
+
+	return map[string]any{
+		"metadata":  map[string]any{"name": name, "labels": labels},
+		"endpoints": eps,
+		"ports":     []map[string]any{p},
+	}
+}
+
+// fakeAPIServer emulates the Kubernetes API server's endpoints to list and watch EndpointSlices,
+// and to read pods and secrets.
+type fakeAPIServer struct {
+	mu              sync.Mutex
+	token           string
+	resourceVersion int
+	slices          map[string]map[string]any
+	pods            map[string]map[string]any
+	secrets         map[string]map[string]any
+	watchers        []chan []byte
+	// lists the number of received list requests.
+	lists int
+}
+
+func newFakeAPIServer(t *testing.T) (*fakeAPIServer, *httptest.Server) {
+	t.Helper()
+
+	f := &fakeAPIServer{
+		token:   "test-token",
+		slices:  map[string]map[string]any{},
+		pods:    map[string]map[string]any{},
+		secrets: map[string]map[string]any{},
+	}
+
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	return f, server
+}
+
+// event stores the EndpointSlice and notifies the watchers.
+func (f *fakeAPIServer) event(eventType string, slice map[string]any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.resourceVersion++
+	slice["metadata"].(map[string]any)["resourceVersion"] = strconv.Itoa(f.resourceVersion)
+
+	name := slice["metadata"].(map[string]any)["name"].(string)
+	if eventType == "DELETED" {
+		delete(f.slices, name)
+	} else {
+		f.slices[name] = slice
+	}
+
+	f.notify(map[string]any{"type": eventType, "object": slice})
+}
+
+// expire sends the error to the watchers which indicates that the resource version is too old.
+func (f *fakeAPIServer) expire() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.notify(map[string]any{"type": "ERROR", "object": map[string]any{"code": http.StatusGone, "message": "too old"}})
+}
+
+func (f *fakeAPIServer) notify(event map[string]any) {
+	v, _ := json.Marshal(event)
+	for _, w := range f.watchers {
+		w <- v
+	}
+}
+
+func matchesLabelSelector(slice map[string]any, selector string) bool {
+	key, value, _ := strings.Cut(selector, "=")
+	labels, _ := slice["metadata"].(map[string]any)["labels"].(map[string]string)
+	return labels[key] == value
+}
+
+func (f *fakeAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+f.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"kind": "Status", "message": "Unauthorized", "code": 401}`))
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/apis/discovery.k8s.io/v1/namespaces/default/endpointslices"):
+		if r.URL.Query().Get("watch") == "true" {
+			f.watch(w, r)
+			return
+		}
+		f.list(w, r)
+
+	case len(parts) == 6 && parts[0] == "api" && parts[3] == "default" && parts[4] == "pods":
+		f.writeObject(w, f.pods, parts[5])
+
+	case len(parts) == 6 && parts[0] == "api" && parts[3] == "default" && parts[4] == "secrets":
+		f.writeObject(w, f.secrets, parts[5])
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeAPIServer) writeObject(w http.ResponseWriter, objects map[string]map[string]any, name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	v, ok := objects[name]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"kind": "Status", "message": "not found", "code": 404}`))
+		return
+	}
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (f *fakeAPIServer) list(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.lists++
+
+	var items []map[string]any
+	for _, slice := range f.slices {
+		if matchesLabelSelector(slice, r.URL.Query().Get("labelSelector")) {
+			items = append(items, slice)
+		}
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"metadata": map[string]any{"resourceVersion": strconv.Itoa(f.resourceVersion)},
+		"items":    items,
+	})
+}
+
+func (f *fakeAPIServer) watch(w http.ResponseWriter, r *http.Request) {
+	events := make(chan []byte, 10)
+
+	f.mu.Lock()
+	f.watchers = append(f.watchers, events)
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for i, v := range f.watchers {
+			if v == events {
+				f.watchers = append(f.watchers[:i], f.watchers[i+1:]...)
+				break
+			}
+		}
+	}()
+
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+
+	selector := r.URL.Query().Get("labelSelector")
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case v := <-events:
+			var event struct {
+				Type   string         `json:"type"`
+				Object map[string]any `json:"object"`
+			}
+			_ = json.Unmarshal(v, &event)
+			if event.Type != "ERROR" {
+				labels := map[string]string{}
+				if l, ok := event.Object["metadata"].(map[string]any)["labels"].(map[string]any); ok {
+					for k, v := range l {
+						labels[k] = v.(string)
+					}
+				}
+				key, value, _ := strings.Cut(selector, "=")
+				if labels[key] != value {
+					continue
+				}
+			}
+			_, _ = w.Write(append(v, '\n'))
+			w.(http.Flusher).Flush()
+			if event.Type == "ERROR" {
+				return
+			}
+		}
+	}
+}
+
+func TestClient_Scan(t *testing.T) {
+	// GIVEN
+	api, server := newFakeAPIServer(t)
+	api.event("ADDED", newFakeSlice("storage-abc", map[string]string{LabelServiceName: "storage", "gateway.role": "storage"},
+		9000, "",
+		fakeEndpoint{address: "10.0.0.2", pod: "storage-0", ready: ptr(true)},
+		fakeEndpoint{address: "10.0.0.3", pod: "storage-1", ready: ptr(false)},
+		fakeEndpoint{address: "10.0.0.4"},
+	))
+	api.event("ADDED", newFakeSlice("storage-tls-abc", map[string]string{LabelServiceName: "storage-tls"},
+		9443, "https",
+		fakeEndpoint{address: "10.0.0.5", pod: "storage-tls-0", ready: ptr(true)},
+	))
+
+	tests := []struct {
+		name     string
+		selector string
+		want     map[string]string
+	}{
+		{
+			name:     "shall select the ready endpoints by the service name",
+			selector: "storage",
+			want:     map[string]string{"storage-0": "10.0.0.2:9000", "10.0.0.4": "10.0.0.4:9000"},
+		},
+		{
+			name:     "shall select the ready endpoints by label",
+			selector: "gateway.role=storage",
+			want:     map[string]string{"storage-0": "10.0.0.2:9000", "10.0.0.4": "10.0.0.4:9000"},
+		},
+		{
+			name:     "shall define the scheme using the port's app protocol",
+			selector: "storage-tls",
+			want:     map[string]string{"storage-tls-0": "https://10.0.0.5:9443"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := NewClient(server.URL, "default", WithToken("test-token"))
+
+			// WHEN
+			got, err := c.Scan(context.Background(), tt.selector)
+
+			// THEN
+			if err != nil {
+				t.Fatalf("no error expected, got: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unexpected instances, want: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("shall fail if the token is rejected", func(t *testing.T) {
+		c, _ := NewClient(server.URL, "default", WithToken("invalid"))
+		if _, err := c.Scan(context.Background(), "storage"); err == nil {
+			t.Errorf("error expected")
+		}
+	})
+
+	t.Run("shall read the token from the file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "token")
+		if err := os.WriteFile(path, []byte("test-token\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		c, _ := NewClient(server.URL, "default", WithTokenFile(path))
+		if _, err := c.Scan(context.Background(), "storage"); err != nil {
+			t.Errorf("no error expected, got: %v", err)
+		}
+	})
+}
+
+func TestClient_Read(t *testing.T) {
+	// GIVEN
+	api, server := newFakeAPIServer(t)
+	api.pods["storage-0"] = map[string]any{
+		"metadata": map[string]any{"name": "storage-0", "annotations": map[string]string{
+			AnnotationCredentialsSecret: "storage-0-credentials",
+		}},
+	}
+	api.pods["storage-1"] = map[string]any{"metadata": map[string]any{"name": "storage-1"}}
+	api.pods["storage-2"] = map[string]any{
+		"metadata": map[string]any{"name": "storage-2", "annotations": map[string]string{
+			AnnotationCredentialsSecret: "storage-2-credentials",
+		}},
+	}
+	api.secrets["storage-0-credentials"] = map[string]any{
+		"data": map[string][]byte{SecretKeyAccessKey: []byte("foo"), SecretKeySecretKey: []byte("bar")},
+	}
+	api.secrets["storage-2-credentials"] = map[string]any{
+		"data": map[string][]byte{SecretKeyAccessKey: []byte("foo")},
+	}
+
+	tests := []struct {
+		name            string
+		instanceID      string
+		wantAccessKeyID string
+		wantSecretKey   string
+		wantErr         bool
+	}{
+		{
+			name:            "credentials from the referenced secret",
+			instanceID:      "storage-0",
+			wantAccessKeyID: "foo",
+			wantSecretKey:   "bar",
+		},
+		{
+			name:       "pod without annotation",
+			instanceID: "storage-1",
+			wantErr:    true,
+		},
+		{
+			name:       "secret without secret key",
+			instanceID: "storage-2",
+			wantErr:    true,
+		},
+		{
+			name:       "pod not found",
+			instanceID: "storage-100",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := NewClient(server.URL, "default", WithToken("test-token"))
+
+			// WHEN
+			accessKeyID, secretKey, err := c.Read(context.Background(), tt.instanceID)
+
+			// THEN
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if accessKeyID != tt.wantAccessKeyID || secretKey != tt.wantSecretKey {
+				t.Errorf("unexpected credentials, want: %s/%s, got: %s/%s",
+					tt.wantAccessKeyID, tt.wantSecretKey, accessKeyID, secretKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// watchState defines the storage instances maintained by the watcher.
+type watchState struct {
+	// slices the ready endpoints of every EndpointSlice.
+	slices map[string]map[string]string
+}
+
+func (s *watchState) instances() map[string]string {
+	var o = make(map[string]string)
+	for _, slice := range s.slices {
+		maps.Copy(o, slice)
+	}
+	return o
+}
+
+const (
+	// rewatchDelay the delay before listing the EndpointSlices again after the watch failure.
+	rewatchDelay = time.Second
+	// watchTimeout the max duration of the single watch request.
+	watchTimeout = 5 * time.Minute
+)
+
+// errWatchExpired is returned if the resource version of the watch is too old, the EndpointSlices must be relisted.
+var errWatchExpired = errors.New("watch expired")
+
+// watchEvent defines the element of the watch stream.
+type watchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// Watch lists and watches the EndpointSlices matching the selector to maintain the ready endpoints in memory.
+// It blocks until the context is cancelled. While the watcher is in sync with the API server,
+// Scan returns the instances from memory instead of listing the EndpointSlices.
+func (c *Client) Watch(ctx context.Context, serviceLabelFilter string, logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger = logger.WithGroup("kubernetes")
+
+	defer c.setWatchState(serviceLabelFilter, nil)
+
+	for {
+		err := c.watch(ctx, serviceLabelFilter, logger)
+		c.setWatchState(serviceLabelFilter, nil)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		// the expired watch is expected when the resource version is compacted, it is relisted immediately
+		if errors.Is(err, errWatchExpired) {
+			logger.Debug("watch expired, relisting", slog.String("selector", serviceLabelFilter))
+			continue
+		}
+
+		logger.Error("watch failed, relisting",
+			slog.String("selector", serviceLabelFilter),
+			slog.String("error", err.Error()),
+		)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(rewatchDelay):
+		}
+	}
+}
+
+// watch lists the EndpointSlices, and applies the watch events until the watch fails.
+func (c *Client) watch(ctx context.Context, serviceLabelFilter string, logger *slog.Logger) error {
+	list, err := c.listEndpointSlices(ctx, serviceLabelFilter)
+	if err != nil {
+		return err
+	}
+
+	state := &watchState{slices: make(map[string]map[string]string, len(list.Items))}
+	for _, slice := range list.Items {
+		state.slices[slice.Metadata.Name] = c.sliceInstances(slice)
+	}
+	c.setWatchState(serviceLabelFilter, state)
+
+	resourceVersion := list.Metadata.ResourceVersion
+	for {
+		resourceVersion, err = c.watchStream(ctx, serviceLabelFilter, resourceVersion, state, logger)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// watchStream applies the events of the single watch request, and returns the last seen resource version.
+// The API server closes the stream after the timeout, and the watch continues from the last seen resource version.
+func (c *Client) watchStream(
+	ctx context.Context, serviceLabelFilter, resourceVersion string, state *watchState, logger *slog.Logger,
+) (string, error) {
+	resp, err := c.do(ctx, c.endpointSlicesPath(), url.Values{
+		"labelSelector":       {labelSelector(serviceLabelFilter)},
+		"watch":               {"true"},
+		"resourceVersion":     {resourceVersion},
+		"allowWatchBookmarks": {"true"},
+		"timeoutSeconds":      {strconv.Itoa(int(watchTimeout.Seconds()))},
+	})
+	if err != nil {
+		var e statusError
+		if errors.As(err, &e) && e.code == http.StatusGone {
+			return "", errWatchExpired
+		}
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event watchEvent
+		if err := decoder.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) {
+				return resourceVersion, nil
+			}
+			return "", err
+		}
+
+		if event.Type == "ERROR" {
+			var status struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}
+			_ = json.Unmarshal(event.Object, &status)
+			if status.Code == http.StatusGone {
+				return "", errWatchExpired
+			}
+			return "", statusError{code: status.Code, message: status.Message}
+		}
+
+		var slice endpointSlice
+		if err := json.Unmarshal(event.Object, &slice); err != nil {
+			return "", err
+		}
+		resourceVersion = slice.Metadata.ResourceVersion
+
+		var instances map[string]string
+		switch event.Type {
+		case "ADDED", "MODIFIED":
+			instances = c.sliceInstances(slice)
+		case "DELETED":
+		default:
+			// the bookmark only advances the resource version
+			continue
+		}
+
+		logger.Debug("event",
+			slog.String("selector", serviceLabelFilter),
+			slog.String("type", event.Type),
+			slog.String("endpointSlice", slice.Metadata.Name),
+			slog.Int("instances", len(instances)),
+		)
+
+		c.updateWatchedSlice(state, slice.Metadata.Name, instances)
+	}
+}
+
+func (c *Client) watchedInstances(serviceLabelFilter string) (map[string]string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	state, ok := c.watchers[serviceLabelFilter]
+	if !ok {
+		return nil, false
+	}
+
+	return state.instances(), true
+}
+
+func (c *Client) setWatchState(serviceLabelFilter string, state *watchState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if state == nil {
+		delete(c.watchers, serviceLabelFilter)
+		return
+	}
+
+	c.watchers[serviceLabelFilter] = state
+}
+
+// updateWatchedSlice sets the EndpointSlice's instances, or removes the EndpointSlice if the instances are nil.
+func (c *Client) updateWatchedSlice(state *watchState, name string, instances map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if instances == nil {
+		delete(state.slices, name)
+		return
+	}
+
+	state.slices[name] = instances
+}
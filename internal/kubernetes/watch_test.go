@@ -0,0 +1,133 @@
+package kubernetes
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// waitForInstances waits up to a second for the watcher to maintain the expected instances.
+func waitForInstances(t *testing.T, c *Client, selector string, want map[string]string) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	var got map[string]string
+	for time.Now().Before(deadline) {
+		got, _ = c.watchedInstances(selector)
+		if reflect.DeepEqual(got, want) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Errorf("unexpected instances, want: %v, got: %v", want, got)
+}
+
+// waitForWatchers waits up to a second for the client to open the watch request.
+func waitForWatchers(t *testing.T, api *fakeAPIServer) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		api.mu.Lock()
+		cnt := len(api.watchers)
+		api.mu.Unlock()
+		if cnt > 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("the client did not open the watch request")
+}
+
+func TestClient_Watch(t *testing.T) {
+	const selector = "storage"
+	labels := map[string]string{LabelServiceName: selector}
+
+	// GIVEN
+	api, server := newFakeAPIServer(t)
+	api.event("ADDED", newFakeSlice("storage-a", labels, 9000, "",
+		fakeEndpoint{address: "10.0.0.2", pod: "storage-0", ready: ptr(true)},
+	))
+
+	c, _ := NewClient(server.URL, "default", WithToken("test-token"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		c.Watch(ctx, selector, nil)
+		close(done)
+	}()
+
+	t.Run("shall sync the instances", func(t *testing.T) {
+		waitForInstances(t, c, selector, map[string]string{"storage-0": "10.0.0.2:9000"})
+		waitForWatchers(t, api)
+	})
+
+	t.Run("shall add the instances of the new EndpointSlice", func(t *testing.T) {
+		api.event("ADDED", newFakeSlice("storage-b", labels, 9000, "",
+			fakeEndpoint{address: "10.0.0.3", pod: "storage-1", ready: ptr(true)},
+		))
+
+		waitForInstances(t, c, selector, map[string]string{"storage-0": "10.0.0.2:9000", "storage-1": "10.0.0.3:9000"})
+	})
+
+	t.Run("shall remove the endpoint which is not ready", func(t *testing.T) {
+		api.event("MODIFIED", newFakeSlice("storage-a", labels, 9000, "",
+			fakeEndpoint{address: "10.0.0.2", pod: "storage-0", ready: ptr(false)},
+		))
+
+		waitForInstances(t, c, selector, map[string]string{"storage-1": "10.0.0.3:9000"})
+	})
+
+	t.Run("shall ignore the EndpointSlice of other service", func(t *testing.T) {
+		api.event("ADDED", newFakeSlice("other-a", map[string]string{LabelServiceName: "other"}, 9000, "",
+			fakeEndpoint{address: "10.0.0.100", pod: "other-0", ready: ptr(true)},
+		))
+
+		waitForInstances(t, c, selector, map[string]string{"storage-1": "10.0.0.3:9000"})
+	})
+
+	t.Run("shall remove the instances of the deleted EndpointSlice", func(t *testing.T) {
+		api.event("DELETED", newFakeSlice("storage-b", labels, 9000, ""))
+
+		waitForInstances(t, c, selector, map[string]string{})
+	})
+
+	t.Run("shall relist after the watch expired", func(t *testing.T) {
+		api.mu.Lock()
+		lists := api.lists
+		api.mu.Unlock()
+
+		api.expire()
+		api.event("ADDED", newFakeSlice("storage-c", labels, 9000, "",
+			fakeEndpoint{address: "10.0.0.4", pod: "storage-2", ready: ptr(true)},
+		))
+
+		waitForInstances(t, c, selector, map[string]string{"storage-2": "10.0.0.4:9000"})
+
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		if api.lists <= lists {
+			t.Errorf("the EndpointSlices are expected to be relisted")
+		}
+	})
+
+	t.Run("shall stop when the context is cancelled", func(t *testing.T) {
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Errorf("the watcher is expected to stop")
+			return
+		}
+
+		if _, ok := c.watchedInstances(selector); ok {
+			t.Errorf("no watched instances expected after the watcher stopped")
+		}
+	})
+}
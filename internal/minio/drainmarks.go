@@ -0,0 +1,69 @@
+package minio
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// drainMarkPrefix the prefix of the draining marks' objects stored in the lock bucket. The objects' IDs
+// cannot contain "/", hence the marks do not collide with the lock objects.
+const drainMarkPrefix = "drain/"
+
+// Draining returns the sorted IDs of the instances marked as draining, i.e. the IDs of the marker objects
+// "drain/{instanceID}" stored in the lock bucket. The Locker implements gateway.DrainMarks, therefore
+// the draining marks are shared by the gateways sharing the locks, and kept when the gateways restart.
+// The instance's endpoint is re-read from the service registry the same way as by Lock.
+func (l *Locker) Draining(ctx context.Context) ([]string, error) {
+	l.refreshEndpoint(ctx)
+
+	var o []string
+	err := l.retryOnAuthenticationError(func() error {
+		o = nil
+		for info := range l.client().ListObjects(ctx, l.bucket, minio.ListObjectsOptions{
+			Prefix:    drainMarkPrefix,
+			Recursive: true,
+		}) {
+			if info.Err != nil {
+				return info.Err
+			}
+			o = append(o, strings.TrimPrefix(info.Key, drainMarkPrefix))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	sort.Strings(o)
+	return o, nil
+}
+
+// SetDraining writes the instance's marker object, or deletes it if the mark is cleared.
+func (l *Locker) SetDraining(ctx context.Context, instanceID string, draining bool) error {
+	l.refreshEndpoint(ctx)
+
+	name := drainMarkPrefix + instanceID
+
+	if !draining {
+		err := l.retryOnAuthenticationError(func() error {
+			return l.client().RemoveObject(ctx, l.bucket, name, minio.RemoveObjectOptions{})
+		})
+		if err != nil && !isNotFoundError(err) {
+			return wrapError(err)
+		}
+		return nil
+	}
+
+	err := l.retryOnAuthenticationError(func() error {
+		_, err := l.client().PutObject(ctx, l.bucket, name, bytes.NewReader(nil), 0, minio.PutObjectOptions{})
+		return err
+	})
+	if err != nil {
+		return wrapError(err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package minio
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestLocker_SetDraining(t *testing.T) {
+	t.Run("shall share the draining marks between the lockers", func(t *testing.T) {
+		// GIVEN
+		storage, endpoint := newFakeStorage(t)
+		l := newTestLocker(t, endpoint)
+		another := newTestLocker(t, endpoint)
+		_, unlock, err := l.Lock(context.TODO(), "obj")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer unlock()
+
+		// WHEN
+		for _, instanceID := range []string{"node1", "node0"} {
+			if err := l.SetDraining(context.TODO(), instanceID, true); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		// THEN
+		got, err := another.Draining(context.TODO())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := []string{"node0", "node1"}; !slices.Equal(got, want) {
+			t.Errorf("unexpected draining instances want: %v, got: %v", want, got)
+		}
+		if got := storage.objects(DefaultLockBucket); !slices.Contains(got, "drain/node0") {
+			t.Errorf("the marker object is expected to be stored in the lock bucket, got: %v", got)
+		}
+	})
+
+	t.Run("shall clear the draining mark", func(t *testing.T) {
+		// GIVEN
+		_, endpoint := newFakeStorage(t)
+		l := newTestLocker(t, endpoint)
+		if err := l.SetDraining(context.TODO(), "node0", true); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// WHEN
+		err := l.SetDraining(context.TODO(), "node0", false)
+
+		// THEN
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, _ := l.Draining(context.TODO()); len(got) > 0 {
+			t.Errorf("no draining instances expected, got: %v", got)
+		}
+		if err := l.SetDraining(context.TODO(), "node0", false); err != nil {
+			t.Errorf("clearing the missing mark is not expected to fail, got: %v", err)
+		}
+	})
+}
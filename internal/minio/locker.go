@@ -0,0 +1,611 @@
+package minio
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kislerdm/object-storage-gateway/pkg/gateway"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const (
+	// DefaultLockBucket the default bucket to store the lock objects.
+	DefaultLockBucket = "locks"
+	// DefaultLockTTL the default time the lock is held unless it is extended, e.g. if the gateway crashed.
+	DefaultLockTTL = 30 * time.Second
+	// DefaultLockRetryInterval the default interval to retry locking the key locked by another owner.
+	DefaultLockRetryInterval = 50 * time.Millisecond
+
+	lockObjectSuffix = ".lock"
+)
+
+// ErrConditionalWritesNotSupported indicates that the storage instance overwrites the existing object
+// despite the header "If-None-Match: *", hence it cannot store the lock objects.
+var ErrConditionalWritesNotSupported = errors.New("the storage instance does not support conditional writes")
+
+// NewLocker connects to the storage instance which stores the lock objects. The instance's endpoint is read
+// from the service registry given the instances selector, and re-read before every lock, hence the Locker
+// follows the instance if its endpoint changes. The instance's credentials are read using the authReader,
+// and re-read if the instance rejects them. The lock bucket is created if it does not exist,
+// and the support of conditional writes is verified.
+func NewLocker(
+	ctx context.Context, registry gateway.ServiceRegistryScanner, selector, instanceID string,
+	authReader gateway.AuthenticationDetailsReader, logger *slog.Logger, opts ...LockerOption,
+) (*Locker, error) {
+	if registry == nil {
+		return nil, errors.New("registry must be not nil")
+	}
+
+	if authReader == nil {
+		return nil, errors.New("authReader must be not nil")
+	}
+
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	o := &Locker{
+		registry:      registry,
+		selector:      selector,
+		instanceID:    instanceID,
+		authReader:    authReader,
+		bucket:        DefaultLockBucket,
+		ttl:           DefaultLockTTL,
+		retryInterval: DefaultLockRetryInterval,
+		logger:        logger.WithGroup("locker"),
+		now:           time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.bucket == "" {
+		return nil, errors.New("lock bucket must be set")
+	}
+
+	if o.ttl <= 0 {
+		return nil, errors.New("lock TTL must be positive")
+	}
+
+	if o.retryInterval <= 0 {
+		return nil, errors.New("lock retry interval must be positive")
+	}
+
+	o.creds = credentials.New(&credentialsProvider{
+		instanceID: instanceID,
+		authReader: authReader,
+		timeout:    o.ttl,
+	})
+	if _, err := o.creds.Get(); err != nil {
+		return nil, err
+	}
+
+	if err := o.resolve(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := o.checkConditionalWrites(ctx); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// LockerOption defines the Locker's optional configuration.
+type LockerOption func(*Locker)
+
+// WithLockBucket sets the bucket to store the lock objects.
+func WithLockBucket(bucket string) LockerOption {
+	return func(l *Locker) {
+		l.bucket = bucket
+	}
+}
+
+// WithLockTTL sets the time the lock is held unless it is extended.
+func WithLockTTL(ttl time.Duration) LockerOption {
+	return func(l *Locker) {
+		l.ttl = ttl
+	}
+}
+
+// WithLockRetryInterval sets the interval to retry locking the key locked by another owner.
+func WithLockRetryInterval(interval time.Duration) LockerOption {
+	return func(l *Locker) {
+		l.retryInterval = interval
+	}
+}
+
+// Locker implements gateway.Locker using the lock objects stored in the storage instance.
+// The key is locked by the conditional write of the lock object which fails if the object exists.
+// The lock expires after its TTL unless the owner extends it, the expired lock, e.g. held by the crashed gateway,
+// is taken over by the conditional write which fails unless the lock object's ETag matches.
+// The lock is lost if it cannot be extended before it expires, or if it was taken over.
+// Note that the gateways' clocks shall be synchronised with the precision much higher than the TTL, and that
+// the lock does not fence the writes of the owner which lost the lock.
+type Locker struct {
+	registry gateway.ServiceRegistryScanner
+	selector string
+	// mu guards the connection to the instance's current endpoint.
+	mu       sync.Mutex
+	endpoint string
+	conn     *minio.Client
+
+	creds         *credentials.Credentials
+	instanceID    string
+	authReader    gateway.AuthenticationDetailsReader
+	bucket        string
+	ttl           time.Duration
+	retryInterval time.Duration
+	logger        *slog.Logger
+	now           func() time.Time
+}
+
+// lockRecord defines the content of the lock object.
+type lockRecord struct {
+	Owner     string    `json:"owner"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Lock waits until the key is unlocked, or the context is cancelled, and locks the key.
+// The lock is extended in background until the returned function is called. The returned context is cancelled
+// with the cause gateway.ErrLockLost if the lock is lost. The instance's endpoint is re-read from
+// the service registry before the key is locked, the previous endpoint is used if it cannot be read.
+func (l *Locker) Lock(ctx context.Context, key string) (context.Context, func(), error) {
+	name := key + lockObjectSuffix
+
+	owner, err := newLockOwner()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	l.refreshEndpoint(ctx)
+
+	for {
+		etag, err := l.tryLock(ctx, name, owner)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if etag != "" {
+			lockCtx, unlock := l.hold(ctx, name, owner, etag)
+			return lockCtx, unlock, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(l.retryInterval):
+		}
+	}
+}
+
+// refreshEndpoint re-reads the instance's endpoint from the service registry, the previous endpoint is used
+// if it cannot be read.
+func (l *Locker) refreshEndpoint(ctx context.Context) {
+	if err := l.resolve(ctx); err != nil {
+		l.logger.Warn("failed to resolve the lock storage instance, the previous endpoint is used",
+			slog.String("instanceID", l.instanceID),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// resolve reads the instance's endpoint from the service registry, and connects to it if the endpoint changed.
+// The lock bucket is created in the instance if it does not exist.
+func (l *Locker) resolve(ctx context.Context) error {
+	instances, err := l.registry.Scan(ctx, l.selector)
+	if err != nil {
+		return err
+	}
+
+	endpoint, ok := instances[l.instanceID]
+	if !ok {
+		return fmt.Errorf("the lock storage instance %s not found", l.instanceID)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if endpoint == l.endpoint {
+		return nil
+	}
+
+	host, secure, err := parseEndpoint(endpoint)
+	if err != nil {
+		return err
+	}
+
+	transport, err := minio.DefaultTransport(secure)
+	if err != nil {
+		return err
+	}
+
+	conn, err := minio.New(host, &minio.Options{
+		Creds:     l.creds,
+		Secure:    secure,
+		Transport: conditionalTransport{base: transport},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := l.createBucket(ctx, conn); err != nil {
+		return err
+	}
+
+	if l.endpoint != "" {
+		l.logger.Info("the lock storage instance's endpoint changed",
+			slog.String("instanceID", l.instanceID),
+			slog.String("endpoint", endpoint),
+		)
+	}
+	l.endpoint, l.conn = endpoint, conn
+
+	return nil
+}
+
+// client returns the connection to the instance's current endpoint.
+func (l *Locker) client() *minio.Client {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.conn
+}
+
+// tryLock writes the lock object unless it exists, or takes over the expired lock.
+// It returns the lock object's ETag if the key was locked.
+func (l *Locker) tryLock(ctx context.Context, name, owner string) (string, error) {
+	etag, ok, err := l.put(ctx, name, lockRecord{Owner: owner, ExpiresAt: l.now().Add(l.ttl)}, "")
+	if err != nil || ok {
+		return etag, err
+	}
+
+	current, currentETag, found, err := l.read(ctx, name)
+	if err != nil || !found {
+		// the lock was released after the write failed, the write is retried
+		return "", err
+	}
+
+	if l.now().Before(current.ExpiresAt) {
+		return "", nil
+	}
+
+	l.logger.Warn("taking over the expired lock",
+		slog.String("objectID", name),
+		slog.String("owner", current.Owner),
+		slog.Time("expiresAt", current.ExpiresAt),
+	)
+
+	etag, _, err = l.put(ctx, name, lockRecord{Owner: owner, ExpiresAt: l.now().Add(l.ttl)}, currentETag)
+	return etag, err
+}
+
+// hold extends the lock at the third of its TTL until the returned function is called,
+// the function stops extending the lock and deletes the lock object. The returned context is cancelled
+// with the cause gateway.ErrLockLost if the lock was taken over, or if it expired before it was extended.
+func (l *Locker) hold(ctx context.Context, name, owner, etag string) (context.Context, func()) {
+	var (
+		stop      = make(chan struct{})
+		done      = make(chan struct{})
+		expiresAt = l.now().Add(l.ttl)
+	)
+
+	lockCtx, cancel := context.WithCancelCause(ctx)
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(l.ttl / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			newExpiresAt := l.now().Add(l.ttl)
+			ctx, cancelExtension := context.WithTimeout(context.Background(), l.ttl/3)
+			newETag, ok, err := l.put(ctx, name, lockRecord{Owner: owner, ExpiresAt: newExpiresAt}, etag)
+			cancelExtension()
+
+			switch {
+			case err != nil && l.now().Before(expiresAt):
+				l.logger.Warn("failed to extend the lock",
+					slog.String("objectID", name),
+					slog.String("error", err.Error()),
+				)
+			case err != nil:
+				l.logger.Error("the lock expired before it was extended",
+					slog.String("objectID", name),
+					slog.String("error", err.Error()),
+				)
+				cancel(gateway.ErrLockLost)
+				return
+			case !ok:
+				l.logger.Error("the lock expired and was taken over by another owner",
+					slog.String("objectID", name),
+				)
+				cancel(gateway.ErrLockLost)
+				etag = ""
+				return
+			default:
+				etag, expiresAt = newETag, newExpiresAt
+			}
+		}
+	}()
+
+	var once sync.Once
+	return lockCtx, func() {
+		once.Do(func() {
+			close(stop)
+			<-done
+			cancel(nil)
+
+			if etag == "" {
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), l.ttl)
+			defer cancel()
+
+			if err := l.release(ctx, name, etag); err != nil {
+				l.logger.Warn("failed to release the lock, it will expire",
+					slog.String("objectID", name),
+					slog.String("error", err.Error()),
+				)
+			}
+		})
+	}
+}
+
+// release deletes the lock object unless it was taken over by another owner.
+// Note that the lock taken over between the check and the deletion is deleted, because
+// the conditional deletion is not supported by the Minio client.
+func (l *Locker) release(ctx context.Context, name, etag string) error {
+	var info minio.ObjectInfo
+	err := l.retryOnAuthenticationError(func() (err error) {
+		info, err = l.client().StatObject(ctx, l.bucket, name, minio.StatObjectOptions{})
+		return err
+	})
+	if err != nil {
+		if isNotFoundError(err) {
+			return nil
+		}
+		return wrapError(err)
+	}
+
+	if info.ETag != etag {
+		return nil
+	}
+
+	err = l.retryOnAuthenticationError(func() error {
+		return l.client().RemoveObject(ctx, l.bucket, name, minio.RemoveObjectOptions{})
+	})
+	if err != nil && !isNotFoundError(err) {
+		return wrapError(err)
+	}
+	return nil
+}
+
+// put writes the lock object if it does not exist given empty etag, or if its ETag matches otherwise.
+// It returns false if the condition does not hold, including the lock object deleted before it was overwritten.
+func (l *Locker) put(ctx context.Context, name string, record lockRecord, etag string) (string, bool, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", false, err
+	}
+
+	opts := minio.PutObjectOptions{ContentType: "application/json"}
+	if etag == "" {
+		ctx = context.WithValue(ctx, ifNoneMatchKey{}, struct{}{})
+	} else {
+		opts.SetMatchETag(etag)
+	}
+
+	var info minio.UploadInfo
+	err = l.retryOnAuthenticationError(func() (err error) {
+		info, err = l.client().PutObject(ctx, l.bucket, name, bytes.NewReader(data), int64(len(data)), opts)
+		return err
+	})
+	if err != nil {
+		// the lock bucket not found is an error unless the existing lock object is overwritten
+		if isPreconditionFailedError(err) || (etag != "" && isNotFoundError(err)) {
+			return "", false, nil
+		}
+		return "", false, wrapError(err)
+	}
+
+	return info.ETag, true, nil
+}
+
+// read reads the lock object. The lock object which cannot be decoded is considered expired.
+func (l *Locker) read(ctx context.Context, name string) (lockRecord, string, bool, error) {
+	var (
+		info minio.ObjectInfo
+		data []byte
+	)
+	err := l.retryOnAuthenticationError(func() error {
+		obj, err := l.client().GetObject(ctx, l.bucket, name, minio.GetObjectOptions{})
+		if err != nil {
+			return err
+		}
+		defer func() { _ = obj.Close() }()
+
+		if info, err = obj.Stat(); err != nil {
+			return err
+		}
+
+		data, err = io.ReadAll(obj)
+		return err
+	})
+	if err != nil {
+		if isNotFoundError(err) {
+			return lockRecord{}, "", false, nil
+		}
+		return lockRecord{}, "", false, wrapError(err)
+	}
+
+	var o lockRecord
+	if err := json.Unmarshal(data, &o); err != nil {
+		l.logger.Warn("cannot decode the lock object",
+			slog.String("objectID", name),
+			slog.String("error", err.Error()),
+		)
+	}
+
+	return o, info.ETag, true, nil
+}
+
+func (l *Locker) createBucket(ctx context.Context, conn *minio.Client) error {
+	var exists bool
+	err := l.retryOnAuthenticationError(func() (err error) {
+		exists, err = conn.BucketExists(ctx, l.bucket)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("cannot create the lock bucket: %w", wrapError(err))
+	}
+	if exists {
+		return nil
+	}
+
+	err = conn.MakeBucket(ctx, l.bucket, minio.MakeBucketOptions{})
+	if err != nil && minio.ToErrorResponse(err).Code != "BucketAlreadyOwnedByYou" {
+		return fmt.Errorf("cannot create the lock bucket: %w", wrapError(err))
+	}
+	return nil
+}
+
+// checkConditionalWrites verifies that the storage instance rejects the conditional write of the existing object,
+// otherwise the locks would be overwritten.
+func (l *Locker) checkConditionalWrites(ctx context.Context) error {
+	owner, err := newLockOwner()
+	if err != nil {
+		return err
+	}
+
+	name := ".probe-" + owner + lockObjectSuffix
+	record := lockRecord{Owner: owner, ExpiresAt: l.now()}
+
+	if _, ok, err := l.put(ctx, name, record, ""); err != nil || !ok {
+		if err == nil {
+			err = errors.New("the probe object exists")
+		}
+		return fmt.Errorf("cannot write the probe object: %w", err)
+	}
+	defer func() { _ = l.client().RemoveObject(ctx, l.bucket, name, minio.RemoveObjectOptions{}) }()
+
+	_, ok, err := l.put(ctx, name, record, "")
+	if err != nil {
+		return fmt.Errorf("cannot write the probe object: %w", err)
+	}
+	if ok {
+		return ErrConditionalWritesNotSupported
+	}
+	return nil
+}
+
+// retryOnAuthenticationError calls the request, and repeats it once with the credentials re-read if the instance
+// rejected them, e.g. after the rotation. The credentials cached by the authReader are invalidated
+// if it implements gateway.CredentialsInvalidator.
+func (l *Locker) retryOnAuthenticationError(request func() error) error {
+	err := request()
+	if err == nil || !isAuthenticationError(err) {
+		return err
+	}
+
+	l.logger.Info("credentials rejected, re-reading",
+		slog.String("instanceID", l.instanceID),
+		slog.String("error", err.Error()),
+	)
+
+	if invalidator, ok := l.authReader.(gateway.CredentialsInvalidator); ok {
+		invalidator.Invalidate(l.instanceID)
+	}
+	l.creds.Expire()
+
+	return request()
+}
+
+// credentialsProvider reads the credentials of the lock storage instance using the authReader.
+// The credentials are read once, and re-read after they are expired using credentials.Credentials.Expire.
+type credentialsProvider struct {
+	instanceID string
+	authReader gateway.AuthenticationDetailsReader
+	// timeout the time to read the credentials, the Minio client does not pass the request's context.
+	timeout time.Duration
+}
+
+// Retrieve reads the credentials, the requests are not signed if the credentials are empty.
+func (p *credentialsProvider) Retrieve() (credentials.Value, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	accessKeyID, secretAccessKey, err := p.authReader.Read(ctx, p.instanceID)
+	if err != nil {
+		return credentials.Value{}, err
+	}
+
+	if accessKeyID == "" || secretAccessKey == "" {
+		return credentials.Value{SignerType: credentials.SignatureAnonymous}, nil
+	}
+
+	return credentials.Value{
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		SignerType:      credentials.SignatureV4,
+	}, nil
+}
+
+// IsExpired returns false, the credentials are only re-read after the instance rejected them.
+func (p *credentialsProvider) IsExpired() bool {
+	return false
+}
+
+func newLockOwner() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ifNoneMatchKey marks the context of the write which shall fail if the object exists.
+type ifNoneMatchKey struct{}
+
+// conditionalTransport sets the header "If-None-Match: *" to the PUT requests marked by the context,
+// because the Minio client only supports the header with the ETag. The header is not signed.
+type conditionalTransport struct {
+	base http.RoundTripper
+}
+
+func (t conditionalTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodPut && req.Context().Value(ifNoneMatchKey{}) != nil {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", "*")
+	}
+	return t.base.RoundTrip(req)
+}
+
+// isPreconditionFailedError defines if the Minio client's error indicates that the write's condition did not hold.
+func isPreconditionFailedError(err error) bool {
+	switch e := err.(type) { //nolint:errorlint // no wrapped is expected
+	case minio.ErrorResponse:
+		return e.StatusCode == http.StatusPreconditionFailed
+	default:
+		return false
+	}
+}
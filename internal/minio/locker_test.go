@@ -0,0 +1,573 @@
+package minio
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kislerdm/object-storage-gateway/pkg/gateway"
+)
+
+// fakeStorage implements the subset of the S3 API used by the Locker, including the conditional writes.
+type fakeStorage struct {
+	mu      sync.Mutex
+	buckets map[string]map[string]fakeObject
+	cntPuts int
+	// ignoreConditions emulates the storage which does not support the conditional writes.
+	ignoreConditions bool
+	// accessKeyID the access key of the signed requests, the requests are not authenticated if it is empty.
+	accessKeyID string
+}
+
+type fakeObject struct {
+	data []byte
+	etag string
+}
+
+func newFakeStorage(t *testing.T) (*fakeStorage, string) {
+	t.Helper()
+
+	s := &fakeStorage{buckets: map[string]map[string]fakeObject{}}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return s, server.URL
+}
+
+func (s *fakeStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.accessKeyID != "" && !strings.Contains(r.Header.Get("Authorization"), "Credential="+s.accessKeyID+"/") {
+		writeFakeError(w, http.StatusForbidden, "InvalidAccessKeyId")
+		return
+	}
+
+	bucket, name, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	objects, bucketExists := s.buckets[bucket]
+
+	if name == "" {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Query().Has("location"):
+			_, _ = io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?>`+
+				`<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/"></LocationConstraint>`)
+		case r.Method == http.MethodHead && !bucketExists:
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+			s.list(w, objects, r.URL.Query().Get("prefix"))
+		case r.Method == http.MethodPut:
+			s.buckets[bucket] = map[string]fakeObject{}
+		}
+		return
+	}
+
+	if !bucketExists {
+		writeFakeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	obj, exists := objects[name]
+	switch r.Method {
+	case http.MethodPut:
+		if !s.ignoreConditions {
+			if r.Header.Get("If-None-Match") == "*" && exists {
+				writeFakeError(w, http.StatusPreconditionFailed, "PreconditionFailed")
+				return
+			}
+			if etag := r.Header.Get("If-Match"); etag != "" && (!exists || strings.Trim(etag, `"`) != obj.etag) {
+				writeFakeError(w, http.StatusPreconditionFailed, "PreconditionFailed")
+				return
+			}
+		}
+
+		data, _ := io.ReadAll(r.Body)
+		s.cntPuts++
+		obj = fakeObject{data: data, etag: fmt.Sprintf("%x", md5.Sum([]byte(strconv.Itoa(s.cntPuts))))}
+		objects[name] = obj
+		w.Header().Set("ETag", `"`+obj.etag+`"`)
+
+	case http.MethodGet, http.MethodHead:
+		if !exists {
+			writeFakeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", `"`+obj.etag+`"`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(obj.data)
+		}
+
+	case http.MethodDelete:
+		delete(objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// list writes the ListObjectsV2 response listing the objects with the prefix in one page.
+func (s *fakeStorage) list(w http.ResponseWriter, objects map[string]fakeObject, prefix string) {
+	var names []string
+	for name := range objects {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var contents strings.Builder
+	for _, name := range names {
+		_, _ = fmt.Fprintf(&contents, `<Contents><Key>%s</Key><ETag>"%s"</ETag><Size>%d</Size>`+
+			`<LastModified>2024-01-01T00:00:00.000Z</LastModified></Contents>`,
+			name, objects[name].etag, len(objects[name].data))
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	_, _ = fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>`+
+		`<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Prefix>%s</Prefix>`+
+		`<KeyCount>%d</KeyCount><MaxKeys>1000</MaxKeys><IsTruncated>false</IsTruncated>%s</ListBucketResult>`,
+		prefix, len(names), contents.String())
+}
+
+func writeFakeError(w http.ResponseWriter, statusCode int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(statusCode)
+	_, _ = fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code></Error>`, code)
+}
+
+// objects returns the names of the objects stored in the bucket.
+func (s *fakeStorage) objects(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var o []string
+	for name := range s.buckets[bucket] {
+		o = append(o, name)
+	}
+	sort.Strings(o)
+	return o
+}
+
+func (s *fakeStorage) record(t *testing.T, bucket, name string) lockRecord {
+	t.Helper()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var o lockRecord
+	if err := json.Unmarshal(s.buckets[bucket][name].data, &o); err != nil {
+		t.Fatalf("cannot decode the lock object %s: %v", name, err)
+	}
+	return o
+}
+
+// fakeCredentials implements gateway.AuthenticationDetailsReader and gateway.CredentialsInvalidator.
+type fakeCredentials struct {
+	mu                           sync.Mutex
+	accessKeyID, secretAccessKey string
+	err                          error
+	reads                        int
+	invalidated                  []string
+}
+
+func (c *fakeCredentials) Read(context.Context, string) (string, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reads++
+	return c.accessKeyID, c.secretAccessKey, c.err
+}
+
+func (c *fakeCredentials) Invalidate(instanceID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.invalidated = append(c.invalidated, instanceID)
+}
+
+// fakeRegistry implements gateway.ServiceRegistryScanner.
+type fakeRegistry struct {
+	mu        sync.Mutex
+	instances map[string]string
+	err       error
+}
+
+func newFakeRegistry(endpoint string) *fakeRegistry {
+	return &fakeRegistry{instances: map[string]string{"locker": endpoint}}
+}
+
+func (r *fakeRegistry) Scan(context.Context, string) (map[string]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return maps.Clone(r.instances), r.err
+}
+
+func (r *fakeRegistry) set(endpoint string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.instances["locker"], r.err = endpoint, err
+}
+
+func newTestLocker(t *testing.T, endpoint string, opts ...LockerOption) *Locker {
+	t.Helper()
+
+	// the anonymous requests are not signed, hence their payload is not chunked
+	l, err := NewLocker(context.TODO(), newFakeRegistry(endpoint), "", "locker", &fakeCredentials{},
+		slog.New(slog.NewTextHandler(io.Discard, nil)), opts...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return l
+}
+
+func TestLocker_Lock(t *testing.T) {
+	t.Run("shall lock the key exclusively", func(t *testing.T) {
+		// GIVEN
+		storage, endpoint := newFakeStorage(t)
+		locker := newTestLocker(t, endpoint)
+		anotherLocker := newTestLocker(t, endpoint)
+
+		_, unlock, err := locker.Lock(context.TODO(), "foo")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// WHEN
+		ctx, cancel := context.WithTimeout(context.TODO(), 200*time.Millisecond)
+		defer cancel()
+		_, _, err = anotherLocker.Lock(ctx, "foo")
+
+		// THEN
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("the locked key is not expected to be locked, got: %v", err)
+		}
+
+		_, unlockBar, err := anotherLocker.Lock(context.TODO(), "bar")
+		if err != nil {
+			t.Fatalf("the other key is expected to be locked, got: %v", err)
+		}
+		unlockBar()
+
+		unlock()
+		_, unlock, err = anotherLocker.Lock(context.TODO(), "foo")
+		if err != nil {
+			t.Fatalf("the unlocked key is expected to be locked, got: %v", err)
+		}
+		unlock()
+
+		if got := storage.objects(DefaultLockBucket); len(got) > 0 {
+			t.Errorf("the lock objects are expected to be deleted, got: %v", got)
+		}
+	})
+
+	t.Run("shall take over the expired lock", func(t *testing.T) {
+		// GIVEN
+		storage, endpoint := newFakeStorage(t)
+		locker := newTestLocker(t, endpoint)
+		_, unlock, err := locker.Lock(context.TODO(), "foo")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		anotherLocker := newTestLocker(t, endpoint)
+		anotherLocker.now = func() time.Time { return time.Now().Add(time.Hour) }
+
+		// WHEN
+		ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+		defer cancel()
+		_, unlockTakenOver, err := anotherLocker.Lock(ctx, "foo")
+
+		// THEN
+		if err != nil {
+			t.Fatalf("the expired lock is expected to be taken over, got: %v", err)
+		}
+
+		unlock()
+		if got := storage.objects(DefaultLockBucket); len(got) != 1 {
+			t.Errorf("the lock taken over is not expected to be released by the previous owner, got: %v", got)
+		}
+
+		unlockTakenOver()
+		if got := storage.objects(DefaultLockBucket); len(got) > 0 {
+			t.Errorf("the lock objects are expected to be deleted, got: %v", got)
+		}
+	})
+
+	t.Run("shall extend the lock while it is held", func(t *testing.T) {
+		// GIVEN
+		const ttl = 300 * time.Millisecond
+		storage, endpoint := newFakeStorage(t)
+		locker := newTestLocker(t, endpoint, WithLockTTL(ttl))
+		_, unlock, err := locker.Lock(context.TODO(), "foo")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer unlock()
+
+		// WHEN
+		time.Sleep(2 * ttl)
+
+		// THEN
+		if got := storage.record(t, DefaultLockBucket, "foo"+lockObjectSuffix); !got.ExpiresAt.After(time.Now()) {
+			t.Errorf("the lock is expected to be extended, it expired at: %v", got.ExpiresAt)
+		}
+	})
+
+	t.Run("shall re-read the credentials rejected after the rotation", func(t *testing.T) {
+		// GIVEN
+		storage, endpoint := newFakeStorage(t)
+		storage.accessKeyID = "foo"
+		creds := &fakeCredentials{accessKeyID: "foo", secretAccessKey: "secret"}
+		locker, err := NewLocker(context.TODO(), newFakeRegistry(endpoint), "", "locker", creds,
+			slog.New(slog.NewTextHandler(io.Discard, nil)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		storage.mu.Lock()
+		storage.accessKeyID = "bar"
+		storage.mu.Unlock()
+		creds.mu.Lock()
+		creds.accessKeyID = "bar"
+		creds.mu.Unlock()
+
+		// WHEN
+		_, unlock, err := locker.Lock(context.TODO(), "foo")
+
+		// THEN
+		if err != nil {
+			t.Fatalf("the key is expected to be locked with the rotated credentials, got: %v", err)
+		}
+		unlock()
+
+		if creds.reads != 2 {
+			t.Errorf("the credentials are expected to be read twice, got: %d", creds.reads)
+		}
+		if !slices.Equal(creds.invalidated, []string{"locker"}) {
+			t.Errorf("the cached credentials are expected to be invalidated, got: %v", creds.invalidated)
+		}
+		if got := storage.objects(DefaultLockBucket); len(got) > 0 {
+			t.Errorf("the lock objects are expected to be deleted, got: %v", got)
+		}
+	})
+}
+
+func TestLocker_Lock_lost(t *testing.T) {
+	t.Run("shall cancel the lock's context when the lock is taken over", func(t *testing.T) {
+		// GIVEN
+		const ttl = 300 * time.Millisecond
+		_, endpoint := newFakeStorage(t)
+		locker := newTestLocker(t, endpoint, WithLockTTL(ttl))
+		lockCtx, unlock, err := locker.Lock(context.TODO(), "foo")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer unlock()
+
+		// WHEN
+		anotherLocker := newTestLocker(t, endpoint, WithLockTTL(ttl))
+		anotherLocker.now = func() time.Time { return time.Now().Add(time.Hour) }
+		_, unlockTakenOver, err := anotherLocker.Lock(context.TODO(), "foo")
+		if err != nil {
+			t.Fatalf("the expired lock is expected to be taken over, got: %v", err)
+		}
+		defer unlockTakenOver()
+
+		// THEN
+		select {
+		case <-lockCtx.Done():
+		case <-time.After(5 * ttl):
+			t.Fatalf("the lock's context is expected to be cancelled")
+		}
+		if got := context.Cause(lockCtx); !errors.Is(got, gateway.ErrLockLost) {
+			t.Errorf("unexpected cause want: %v, got: %v", gateway.ErrLockLost, got)
+		}
+	})
+
+	t.Run("shall not cancel the lock's context while the lock is held", func(t *testing.T) {
+		// GIVEN
+		const ttl = 300 * time.Millisecond
+		_, endpoint := newFakeStorage(t)
+		locker := newTestLocker(t, endpoint, WithLockTTL(ttl))
+
+		// WHEN
+		lockCtx, unlock, err := locker.Lock(context.TODO(), "foo")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		time.Sleep(2 * ttl)
+
+		// THEN
+		if err := lockCtx.Err(); err != nil {
+			t.Errorf("the lock's context is not expected to be cancelled, got: %v", err)
+		}
+		unlock()
+		if !errors.Is(context.Cause(lockCtx), context.Canceled) {
+			t.Errorf("the lock's context is expected to be cancelled after the unlock")
+		}
+	})
+
+	t.Run("shall fail if the lock bucket is not found", func(t *testing.T) {
+		// GIVEN
+		storage, endpoint := newFakeStorage(t)
+		locker := newTestLocker(t, endpoint)
+		storage.mu.Lock()
+		delete(storage.buckets, DefaultLockBucket)
+		storage.mu.Unlock()
+
+		// WHEN
+		ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+		defer cancel()
+		_, _, err := locker.Lock(ctx, "foo")
+
+		// THEN
+		if err == nil || errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("the missing bucket is expected to fail the lock, got: %v", err)
+		}
+	})
+}
+
+func TestLocker_Lock_endpoint(t *testing.T) {
+	t.Run("shall follow the instance's endpoint read from the registry", func(t *testing.T) {
+		// GIVEN
+		storage, endpoint := newFakeStorage(t)
+		registry := newFakeRegistry(endpoint)
+		locker, err := NewLocker(context.TODO(), registry, "", "locker", &fakeCredentials{},
+			slog.New(slog.NewTextHandler(io.Discard, nil)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		movedStorage, movedEndpoint := newFakeStorage(t)
+		registry.set(movedEndpoint, nil)
+
+		// WHEN
+		_, unlock, err := locker.Lock(context.TODO(), "foo")
+
+		// THEN
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := movedStorage.objects(DefaultLockBucket); !slices.Equal(got, []string{"foo" + lockObjectSuffix}) {
+			t.Errorf("the lock object is expected to be written to the moved instance, got: %v", got)
+		}
+		if got := storage.objects(DefaultLockBucket); len(got) > 0 {
+			t.Errorf("no lock objects are expected in the previous endpoint, got: %v", got)
+		}
+		unlock()
+		if got := movedStorage.objects(DefaultLockBucket); len(got) > 0 {
+			t.Errorf("the lock objects are expected to be deleted, got: %v", got)
+		}
+	})
+
+	t.Run("shall use the previous endpoint if the registry fails", func(t *testing.T) {
+		// GIVEN
+		storage, endpoint := newFakeStorage(t)
+		registry := newFakeRegistry(endpoint)
+		locker, err := NewLocker(context.TODO(), registry, "", "locker", &fakeCredentials{},
+			slog.New(slog.NewTextHandler(io.Discard, nil)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		registry.set("", errors.New("unavailable"))
+
+		// WHEN
+		_, unlock, err := locker.Lock(context.TODO(), "foo")
+
+		// THEN
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := storage.objects(DefaultLockBucket); !slices.Equal(got, []string{"foo" + lockObjectSuffix}) {
+			t.Errorf("the lock object is expected to be written to the previous endpoint, got: %v", got)
+		}
+		unlock()
+	})
+}
+
+func TestNewLocker(t *testing.T) {
+	tests := []struct {
+		name             string
+		opts             []LockerOption
+		authReader       gateway.AuthenticationDetailsReader
+		ignoreConditions bool
+		instanceNotFound bool
+		wantErr          error
+	}{
+		{
+			name: "shall create the lock bucket",
+		},
+		{
+			name:             "shall fail if conditional writes are not supported",
+			ignoreConditions: true,
+			wantErr:          ErrConditionalWritesNotSupported,
+		},
+		{
+			name:    "shall fail if the bucket is not set",
+			opts:    []LockerOption{WithLockBucket("")},
+			wantErr: errors.New("lock bucket must be set"),
+		},
+		{
+			name:    "shall fail if the TTL is not positive",
+			opts:    []LockerOption{WithLockTTL(0)},
+			wantErr: errors.New("lock TTL must be positive"),
+		},
+		{
+			name:    "shall fail if the retry interval is not positive",
+			opts:    []LockerOption{WithLockRetryInterval(-time.Second)},
+			wantErr: errors.New("lock retry interval must be positive"),
+		},
+		{
+			name:             "shall fail if the instance is not found in the registry",
+			instanceNotFound: true,
+			wantErr:          errors.New("the lock storage instance locker not found"),
+		},
+		{
+			name:       "shall fail if the credentials cannot be read",
+			authReader: &fakeCredentials{err: errors.New("foo")},
+			wantErr:    errors.New("foo"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			storage, endpoint := newFakeStorage(t)
+			storage.ignoreConditions = tt.ignoreConditions
+			if tt.authReader == nil {
+				tt.authReader = &fakeCredentials{}
+			}
+			registry := newFakeRegistry(endpoint)
+			if tt.instanceNotFound {
+				registry.instances = map[string]string{}
+			}
+
+			// WHEN
+			_, err := NewLocker(context.TODO(), registry, "", "locker", tt.authReader, nil, tt.opts...)
+
+			// THEN
+			if (err == nil) != (tt.wantErr == nil) || (err != nil && err.Error() != tt.wantErr.Error()) {
+				t.Fatalf("unexpected error want: %v, got: %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if _, ok := storage.buckets[DefaultLockBucket]; !ok {
+				t.Errorf("the lock bucket is expected to be created")
+			}
+			if got := storage.objects(DefaultLockBucket); len(got) > 0 {
+				t.Errorf("the probe object is expected to be deleted, got: %v", got)
+			}
+		})
+	}
+}
@@ -5,18 +5,25 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"strings"
 
 	"github.com/kislerdm/object-storage-gateway/pkg/gateway"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
-func NewClient(ipAddress, accessKeyID, secretAccessKey string) (gateway.ObjectReadWriteFinder, error) {
-	const defaultPort = "9000"
-	host := ipAddress + ":" + defaultPort
+// NewClient connects to the storage instance.
+// The endpoint is defined as "[scheme://]host[:port]", the port 9000 and the scheme http are used by default.
+func NewClient(endpoint, accessKeyID, secretAccessKey string) (gateway.ObjectReadWriteFinder, error) {
+	host, secure, err := parseEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
 	c, err := minio.New(host, &minio.Options{
-		Creds: credentials.NewStaticV4(accessKeyID, secretAccessKey, ""),
+		Creds:  credentials.NewStaticV4(accessKeyID, secretAccessKey, ""),
+		Secure: secure,
 	})
 	if err != nil {
 		return nil, err
@@ -27,21 +34,66 @@ func NewClient(ipAddress, accessKeyID, secretAccessKey string) (gateway.ObjectRe
 	return &Client{c}, nil
 }
 
+// parseEndpoint returns the host:port of the storage instance and the flag if TLS shall be used.
+func parseEndpoint(endpoint string) (string, bool, error) {
+	const defaultPort = "9000"
+
+	var secure bool
+	if scheme, rest, ok := strings.Cut(endpoint, "://"); ok {
+		switch strings.ToLower(scheme) {
+		case "http":
+		case "https":
+			secure = true
+		default:
+			return "", false, fmt.Errorf("unsupported scheme %q", scheme)
+		}
+		endpoint = rest
+	}
+
+	if endpoint == "" {
+		return "", false, errors.New("empty endpoint")
+	}
+
+	if _, _, err := net.SplitHostPort(endpoint); err != nil {
+		endpoint = net.JoinHostPort(strings.Trim(endpoint, "[]"), defaultPort)
+	}
+
+	return endpoint, secure, nil
+}
+
 type Client struct {
 	*minio.Client
 }
 
-func (c *Client) Read(ctx context.Context, bucketName, objectName string) (io.ReadCloser, bool, error) {
-	exists, _ := c.BucketExists(ctx, bucketName)
+func (c *Client) Read(ctx context.Context, bucketName, objectName string, offset, length int64) (
+	io.ReadCloser, bool, error,
+) {
+	exists, err := c.BucketExists(ctx, bucketName)
+	if err != nil && isAuthenticationError(err) {
+		return nil, false, wrapError(err)
+	}
 	if !exists {
 		return nil, false, nil
 	}
-	reader, err := c.GetObject(ctx, bucketName, objectName, minio.GetObjectOptions{})
+
+	var opts minio.GetObjectOptions
+	switch {
+	case length > 0:
+		if err := opts.SetRange(offset, offset+length-1); err != nil {
+			return nil, false, err
+		}
+	case offset > 0:
+		if err := opts.SetRange(offset, 0); err != nil {
+			return nil, false, err
+		}
+	}
+
+	reader, err := c.GetObject(ctx, bucketName, objectName, opts)
 	if err != nil {
 		if isNotFoundError(err) {
 			return nil, false, nil
 		}
-		return nil, false, err
+		return nil, false, wrapError(err)
 	}
 	return reader, true, nil
 }
@@ -49,16 +101,16 @@ func (c *Client) Read(ctx context.Context, bucketName, objectName string) (io.Re
 func (c *Client) Write(ctx context.Context, bucketName, objectName string, reader io.Reader, objectSizeBytes int64) error {
 	exists, err := c.BucketExists(ctx, bucketName)
 	if err != nil {
-		return fmt.Errorf("cannot store the object: %w", err)
+		return fmt.Errorf("cannot store the object: %w", wrapError(err))
 	}
 	if !exists {
 		if err = c.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{}); err != nil {
-			return fmt.Errorf("cannot create bucket to store objects %w", err)
+			return fmt.Errorf("cannot create bucket to store objects %w", wrapError(err))
 		}
 	}
 
 	_, err = c.PutObject(ctx, bucketName, objectName, reader, objectSizeBytes, minio.PutObjectOptions{})
-	return err
+	return wrapError(err)
 }
 
 func (c *Client) Find(ctx context.Context, bucketName, objectName string) (bool, error) {
@@ -67,11 +119,101 @@ func (c *Client) Find(ctx context.Context, bucketName, objectName string) (bool,
 		if isNotFoundError(err) {
 			return false, nil
 		}
-		return false, err
+		return false, wrapError(err)
 	}
 	return true, nil
 }
 
+func (c *Client) Delete(ctx context.Context, bucketName, objectName string) error {
+	err := c.RemoveObject(ctx, bucketName, objectName, minio.RemoveObjectOptions{})
+	if err != nil && !isNotFoundError(err) {
+		return wrapError(err)
+	}
+	return nil
+}
+
+func (c *Client) Stat(ctx context.Context, bucketName, objectName string) (gateway.ObjectInfo, bool, error) {
+	info, err := c.StatObject(ctx, bucketName, objectName, minio.StatObjectOptions{})
+	if err != nil {
+		if isNotFoundError(err) {
+			return gateway.ObjectInfo{}, false, nil
+		}
+		return gateway.ObjectInfo{}, false, wrapError(err)
+	}
+	return gateway.ObjectInfo{
+		ID:           objectName,
+		Size:         info.Size,
+		ETag:         info.ETag,
+		ContentType:  info.ContentType,
+		LastModified: info.LastModified,
+	}, true, nil
+}
+
+func (c *Client) List(ctx context.Context, bucketName, prefix, startAfter string, maxKeys int) (
+	[]gateway.ObjectInfo, error,
+) {
+	exists, err := c.BucketExists(ctx, bucketName)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	// the context is cancelled to stop listing when maxKeys objects are read
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var o = make([]gateway.ObjectInfo, 0, maxKeys)
+	for info := range c.ListObjects(ctx, bucketName, minio.ListObjectsOptions{
+		Prefix:     prefix,
+		StartAfter: startAfter,
+		Recursive:  true,
+		MaxKeys:    maxKeys,
+	}) {
+		if info.Err != nil {
+			return nil, wrapError(info.Err)
+		}
+
+		o = append(o, gateway.ObjectInfo{
+			ID:           info.Key,
+			Size:         info.Size,
+			ETag:         info.ETag,
+			ContentType:  info.ContentType,
+			LastModified: info.LastModified,
+		})
+
+		if len(o) == maxKeys {
+			break
+		}
+	}
+
+	return o, nil
+}
+
+// wrapError wraps the Minio client's error with gateway.ErrAuthentication if the credentials were rejected.
+func wrapError(err error) error {
+	if err != nil && isAuthenticationError(err) {
+		return fmt.Errorf("%w: %w", gateway.ErrAuthentication, err)
+	}
+	return err
+}
+
+// isAuthenticationError defines if the Minio client's error indicates that the credentials were rejected.
+func isAuthenticationError(err error) bool {
+	switch e := err.(type) { //nolint:errorlint // no wrapped is expected
+	case minio.ErrorResponse:
+		switch e.Code {
+		case "InvalidAccessKeyId", "SignatureDoesNotMatch", "AccessDenied":
+			return true
+		default:
+			return e.StatusCode == http.StatusForbidden
+		}
+	default:
+		return false
+	}
+}
+
 // isNotFoundError defines if the Minion client's error indicated that the obj is not found.
 func isNotFoundError(err error) bool {
 	switch e := err.(type) { //nolint:errorlint // no wrapped is expected
@@ -0,0 +1,67 @@
+package minio
+
+import "testing"
+
+func Test_parseEndpoint(t *testing.T) {
+	tests := []struct {
+		name       string
+		endpoint   string
+		wantHost   string
+		wantSecure bool
+		wantErr    bool
+	}{
+		{
+			name:     "ip address only",
+			endpoint: "172.17.0.2",
+			wantHost: "172.17.0.2:9000",
+		},
+		{
+			name:     "ip address and port",
+			endpoint: "172.17.0.2:9001",
+			wantHost: "172.17.0.2:9001",
+		},
+		{
+			name:       "https scheme, ip address and port",
+			endpoint:   "https://172.17.0.2:9443",
+			wantHost:   "172.17.0.2:9443",
+			wantSecure: true,
+		},
+		{
+			name:     "http scheme and ip address",
+			endpoint: "http://172.17.0.2",
+			wantHost: "172.17.0.2:9000",
+		},
+		{
+			name:     "ipv6 address",
+			endpoint: "fd00::2",
+			wantHost: "[fd00::2]:9000",
+		},
+		{
+			name:     "unsupported scheme",
+			endpoint: "ftp://172.17.0.2",
+			wantErr:  true,
+		},
+		{
+			name:     "empty endpoint",
+			endpoint: "",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// WHEN
+			host, secure, err := parseEndpoint(tt.endpoint)
+
+			// THEN
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseEndpoint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if host != tt.wantHost {
+				t.Errorf("parseEndpoint() host = %v, want %v", host, tt.wantHost)
+			}
+			if secure != tt.wantSecure {
+				t.Errorf("parseEndpoint() secure = %v, want %v", secure, tt.wantSecure)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package restfulhandler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kislerdm/object-storage-gateway/pkg/gateway"
+)
+
+const (
+	rebalanceRoute   = "/admin/rebalance"
+	metricsRoute     = "/admin/metrics"
+	drainRoutePrefix = "/admin/instances/"
+	drainRouteSuffix = "/drain"
+)
+
+// NewAdmin initialises the admin API handler which exposes the status of the rebalancer at GET /admin/rebalance,
+// the Gateway's metrics at GET /admin/metrics, and the drain of the storage instances at /admin/instances/{id}/drain.
+// The admin API is not authenticated, hence it shall be served on the address not exposed to the Gateway's clients.
+func NewAdmin(gw *gateway.Gateway, r *gateway.Rebalancer, logger *slog.Logger) (*AdminHandler, error) {
+	if gw == nil {
+		return nil, errors.New("gateway must be not nil")
+	}
+
+	if r == nil {
+		return nil, errors.New("rebalancer must be not nil")
+	}
+
+	return &AdminHandler{
+		gateway:    gw,
+		rebalancer: r,
+		logger:     newLogger(logger).WithGroup("admin"),
+	}, nil
+}
+
+// AdminHandler the admin API handler.
+type AdminHandler struct {
+	gateway    metricsReader
+	rebalancer rebalancer
+	logger     *slog.Logger
+}
+
+func (h AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.logger.Debug("request",
+		slog.String("path", r.URL.Path),
+		slog.String("method", r.Method),
+	)
+
+	p := strings.TrimRight(r.URL.Path, "/")
+	switch p {
+	case rebalanceRoute:
+		h.rebalanceStatus(w, r)
+		return
+	case metricsRoute:
+		h.metrics(w, r)
+		return
+	}
+
+	if instanceID, ok := readDrainInstanceID(p); ok {
+		h.drain(w, r, instanceID)
+		return
+	}
+
+	h.logError(r, http.StatusBadRequest, "route not found")
+	writeErrorMessage(w, http.StatusBadRequest, "route cannot be handled")
+}
+
+// rebalanceStatus defines the response body of the rebalancer's status route.
+type rebalanceStatus struct {
+	Running        bool       `json:"running"`
+	Instances      []string   `json:"instances"`
+	StartedAt      *time.Time `json:"startedAt,omitempty"`
+	FinishedAt     *time.Time `json:"finishedAt,omitempty"`
+	ScannedObjects uint64     `json:"scannedObjects"`
+	MovedObjects   uint64     `json:"movedObjects"`
+	MovedBytes     uint64     `json:"movedBytes"`
+	FailedObjects  uint64     `json:"failedObjects"`
+	LastError      string     `json:"lastError,omitempty"`
+}
+
+func (h AdminHandler) rebalanceStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.logError(r, http.StatusMethodNotAllowed, "method not allowed")
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	status := h.rebalancer.Status()
+	o := rebalanceStatus{
+		Running:        status.Running,
+		Instances:      status.Instances,
+		ScannedObjects: status.ScannedObjects,
+		MovedObjects:   status.MovedObjects,
+		MovedBytes:     status.MovedBytes,
+		FailedObjects:  status.FailedObjects,
+		LastError:      status.LastError,
+	}
+	if o.Instances == nil {
+		o.Instances = []string{}
+	}
+	if !status.StartedAt.IsZero() {
+		o.StartedAt = &status.StartedAt
+	}
+	if !status.FinishedAt.IsZero() {
+		o.FinishedAt = &status.FinishedAt
+	}
+
+	body, err := json.Marshal(o)
+	if err != nil {
+		h.logError(r, http.StatusInternalServerError, err.Error())
+		writeErrorMessage(w, http.StatusInternalServerError, "failed to read the rebalancing status")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// metrics defines the response body of the Gateway's metrics route.
+type metrics struct {
+	PlacementFallbacks    uint64 `json:"placementFallbacks"`
+	MisplacedObjects      uint64 `json:"misplacedObjects"`
+	AuthenticationRetries uint64 `json:"authenticationRetries"`
+	DegradedWrites        uint64 `json:"degradedWrites"`
+	ReadRepairs           uint64 `json:"readRepairs"`
+	ReadRepairFailures    uint64 `json:"readRepairFailures"`
+	DegradedReads         uint64 `json:"degradedReads"`
+	DuplicatesDeleted     uint64 `json:"duplicatesDeleted"`
+}
+
+func (h AdminHandler) metrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.logError(r, http.StatusMethodNotAllowed, "method not allowed")
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	m := h.gateway.Metrics()
+	body, err := json.Marshal(metrics{
+		PlacementFallbacks:    m.PlacementFallbacks,
+		MisplacedObjects:      m.MisplacedObjects,
+		AuthenticationRetries: m.AuthenticationRetries,
+		DegradedWrites:        m.DegradedWrites,
+		ReadRepairs:           m.ReadRepairs,
+		ReadRepairFailures:    m.ReadRepairFailures,
+		DegradedReads:         m.DegradedReads,
+		DuplicatesDeleted:     m.DuplicatesDeleted,
+	})
+	if err != nil {
+		h.logError(r, http.StatusInternalServerError, err.Error())
+		writeErrorMessage(w, http.StatusInternalServerError, "failed to read the metrics")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// drainStatus defines the response body of the instance's drain routes.
+type drainStatus struct {
+	InstanceID       string     `json:"instanceID"`
+	Drained          bool       `json:"drained"`
+	StartedAt        time.Time  `json:"startedAt"`
+	FinishedAt       *time.Time `json:"finishedAt,omitempty"`
+	RemainingObjects uint64     `json:"remainingObjects"`
+	MovedObjects     uint64     `json:"movedObjects"`
+	MovedBytes       uint64     `json:"movedBytes"`
+	FailedObjects    uint64     `json:"failedObjects"`
+	LastError        string     `json:"lastError,omitempty"`
+}
+
+func (h AdminHandler) drain(w http.ResponseWriter, r *http.Request, instanceID string) {
+	statusCode := http.StatusOK
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if err := h.rebalancer.Drain(r.Context(), instanceID); err != nil {
+			switch {
+			case errors.Is(err, gateway.ErrInstanceNotFound):
+				statusCode = http.StatusNotFound
+			case errors.Is(err, gateway.ErrDrainInProgress), errors.Is(err, gateway.ErrNoPlacementInstances):
+				statusCode = http.StatusConflict
+			default:
+				h.logError(r, http.StatusInternalServerError, err.Error())
+				writeErrorMessage(w, http.StatusInternalServerError, "failed to drain the instance")
+				return
+			}
+			h.logError(r, statusCode, err.Error())
+			writeErrorMessage(w, statusCode, err.Error())
+			return
+		}
+		statusCode = http.StatusAccepted
+	case http.MethodDelete:
+		cancelled, err := h.rebalancer.CancelDrain(r.Context(), instanceID)
+		if err != nil {
+			h.logError(r, http.StatusInternalServerError, err.Error())
+			writeErrorMessage(w, http.StatusInternalServerError, "failed to cancel the drain")
+			return
+		}
+		if !cancelled {
+			h.logError(r, http.StatusNotFound, "instance is not draining")
+			writeErrorMessage(w, http.StatusNotFound, "instance is not draining")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		h.logError(r, http.StatusMethodNotAllowed, "method not allowed")
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	status, ok := h.rebalancer.DrainStatus(instanceID)
+	if !ok {
+		h.logError(r, http.StatusNotFound, "instance is not draining")
+		writeErrorMessage(w, http.StatusNotFound, "instance is not draining")
+		return
+	}
+
+	o := drainStatus{
+		InstanceID:       status.InstanceID,
+		Drained:          status.Drained,
+		StartedAt:        status.StartedAt,
+		RemainingObjects: status.RemainingObjects,
+		MovedObjects:     status.MovedObjects,
+		MovedBytes:       status.MovedBytes,
+		FailedObjects:    status.FailedObjects,
+		LastError:        status.LastError,
+	}
+	if !status.FinishedAt.IsZero() {
+		o.FinishedAt = &status.FinishedAt
+	}
+
+	body, err := json.Marshal(o)
+	if err != nil {
+		h.logError(r, http.StatusInternalServerError, err.Error())
+		writeErrorMessage(w, http.StatusInternalServerError, "failed to read the drain status")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(body)
+}
+
+// readDrainInstanceID reads the instance ID from the path /admin/instances/{id}/drain.
+func readDrainInstanceID(p string) (string, bool) {
+	s, ok := strings.CutPrefix(p, drainRoutePrefix)
+	if !ok {
+		return "", false
+	}
+
+	instanceID, ok := strings.CutSuffix(s, drainRouteSuffix)
+	if !ok || instanceID == "" || strings.Contains(instanceID, "/") {
+		return "", false
+	}
+
+	return instanceID, true
+}
+
+func (h AdminHandler) logError(r *http.Request, statusCode int, msg string) {
+	h.logger.Error(msg,
+		slog.Int("code", statusCode),
+		slog.String("path", r.URL.Path),
+		slog.String("method", r.Method),
+	)
+}
+
+// metricsReader defines the interface to read the Gateway's metrics.
+type metricsReader interface {
+	Metrics() gateway.Metrics
+}
+
+// rebalancer defines the interface to read the status of the rebalancer, and to drain the storage instances.
+type rebalancer interface {
+	Status() gateway.RebalanceStatus
+	Drain(ctx context.Context, instanceID string) error
+	CancelDrain(ctx context.Context, instanceID string) (bool, error)
+	DrainStatus(instanceID string) (gateway.DrainStatus, bool)
+}
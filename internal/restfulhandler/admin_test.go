@@ -0,0 +1,348 @@
+package restfulhandler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/kislerdm/object-storage-gateway/pkg/gateway"
+)
+
+type mockRebalancer struct {
+	status      gateway.RebalanceStatus
+	drainErr    error
+	cancelErr   error
+	drainStatus *gateway.DrainStatus
+}
+
+func (m mockRebalancer) Status() gateway.RebalanceStatus {
+	return m.status
+}
+
+func (m mockRebalancer) Drain(context.Context, string) error {
+	return m.drainErr
+}
+
+func (m mockRebalancer) CancelDrain(context.Context, string) (bool, error) {
+	return m.drainStatus != nil, m.cancelErr
+}
+
+func (m mockRebalancer) DrainStatus(string) (gateway.DrainStatus, bool) {
+	if m.drainStatus == nil {
+		return gateway.DrainStatus{}, false
+	}
+	return *m.drainStatus, true
+}
+
+func TestAdminHandler_ServeHTTP_rebalanceStatus(t *testing.T) {
+	t.Parallel()
+
+	startedAt := time.Date(2023, 10, 21, 7, 28, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		rebalancer     rebalancer
+		method         string
+		path           string
+		wantStatusCode int
+		wantBody       string
+	}{
+		{
+			name: "shall return the status of the running rebalancing",
+			rebalancer: mockRebalancer{status: gateway.RebalanceStatus{
+				Running:        true,
+				Instances:      []string{"node0", "node1"},
+				StartedAt:      startedAt,
+				ScannedObjects: 10,
+				MovedObjects:   2,
+				MovedBytes:     100,
+			}},
+			method:         http.MethodGet,
+			path:           "/admin/rebalance/",
+			wantStatusCode: http.StatusOK,
+			wantBody: `{"running":true,"instances":["node0","node1"],"startedAt":"2023-10-21T07:28:00Z",` +
+				`"scannedObjects":10,"movedObjects":2,"movedBytes":100,"failedObjects":0}`,
+		},
+		{
+			name:           "shall return the status before the first pass",
+			rebalancer:     mockRebalancer{},
+			method:         http.MethodGet,
+			path:           "/admin/rebalance",
+			wantStatusCode: http.StatusOK,
+			wantBody: `{"running":false,"instances":[],"scannedObjects":0,"movedObjects":0,"movedBytes":0,` +
+				`"failedObjects":0}`,
+		},
+		{
+			name:           "shall not find the route",
+			rebalancer:     mockRebalancer{},
+			method:         http.MethodGet,
+			path:           "/object/foo",
+			wantStatusCode: http.StatusBadRequest,
+			wantBody:       `{"error":"route cannot be handled"}`,
+		},
+		{
+			name:           "shall not allow other methods",
+			rebalancer:     mockRebalancer{},
+			method:         http.MethodPost,
+			path:           "/admin/rebalance",
+			wantStatusCode: http.StatusMethodNotAllowed,
+			wantBody:       `{"error":"method not allowed"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			h := AdminHandler{
+				rebalancer: tt.rebalancer,
+				logger:     slog.Default(),
+			}
+			w := &mockResponseWriter{Headers: map[string][]string{}}
+
+			// WHEN
+			h.ServeHTTP(w, &http.Request{Method: tt.method, URL: &url.URL{Path: tt.path}})
+
+			// THEN
+			if w.StatusCode != tt.wantStatusCode {
+				t.Errorf("wrong StatusCode, want: %d, got: %d", tt.wantStatusCode, w.StatusCode)
+			}
+			if got := string(w.Body); got != tt.wantBody {
+				t.Errorf("wrong body, want: %s, got: %s", tt.wantBody, got)
+			}
+		})
+	}
+}
+
+type mockMetricsReader gateway.Metrics
+
+func (m mockMetricsReader) Metrics() gateway.Metrics {
+	return gateway.Metrics(m)
+}
+
+func TestAdminHandler_ServeHTTP_metrics(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		method         string
+		wantStatusCode int
+		wantBody       string
+	}{
+		{
+			name:           "shall return the gateway's metrics",
+			method:         http.MethodGet,
+			wantStatusCode: http.StatusOK,
+			wantBody: `{"placementFallbacks":1,"misplacedObjects":2,"authenticationRetries":3,"degradedWrites":4,` +
+				`"readRepairs":5,"readRepairFailures":6,"degradedReads":7,"duplicatesDeleted":8}`,
+		},
+		{
+			name:           "shall not allow other methods",
+			method:         http.MethodPost,
+			wantStatusCode: http.StatusMethodNotAllowed,
+			wantBody:       `{"error":"method not allowed"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			h := AdminHandler{
+				gateway: mockMetricsReader{
+					PlacementFallbacks:    1,
+					MisplacedObjects:      2,
+					AuthenticationRetries: 3,
+					DegradedWrites:        4,
+					ReadRepairs:           5,
+					ReadRepairFailures:    6,
+					DegradedReads:         7,
+					DuplicatesDeleted:     8,
+				},
+				rebalancer: mockRebalancer{},
+				logger:     slog.Default(),
+			}
+			w := &mockResponseWriter{Headers: map[string][]string{}}
+
+			// WHEN
+			h.ServeHTTP(w, &http.Request{Method: tt.method, URL: &url.URL{Path: "/admin/metrics"}})
+
+			// THEN
+			if w.StatusCode != tt.wantStatusCode {
+				t.Errorf("wrong StatusCode, want: %d, got: %d", tt.wantStatusCode, w.StatusCode)
+			}
+			if got := string(w.Body); got != tt.wantBody {
+				t.Errorf("wrong body, want: %s, got: %s", tt.wantBody, got)
+			}
+		})
+	}
+}
+
+func TestAdminHandler_ServeHTTP_drain(t *testing.T) {
+	startedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		rebalancer     rebalancer
+		method         string
+		path           string
+		wantStatusCode int
+		wantBody       string
+	}{
+		{
+			name: "shall start the drain",
+			rebalancer: mockRebalancer{drainStatus: &gateway.DrainStatus{
+				InstanceID: "node0",
+				StartedAt:  startedAt,
+			}},
+			method:         http.MethodPost,
+			path:           "/admin/instances/node0/drain",
+			wantStatusCode: http.StatusAccepted,
+			wantBody: `{"instanceID":"node0","drained":false,"startedAt":"2024-01-01T00:00:00Z",` +
+				`"remainingObjects":0,"movedObjects":0,"movedBytes":0,"failedObjects":0}`,
+		},
+		{
+			name:           "shall not find the instance to drain",
+			rebalancer:     mockRebalancer{drainErr: gateway.ErrInstanceNotFound},
+			method:         http.MethodPost,
+			path:           "/admin/instances/node9/drain",
+			wantStatusCode: http.StatusNotFound,
+			wantBody:       `{"error":"instance not found"}`,
+		},
+		{
+			name:           "shall reject the drain in progress",
+			rebalancer:     mockRebalancer{drainErr: gateway.ErrDrainInProgress},
+			method:         http.MethodPost,
+			path:           "/admin/instances/node0/drain",
+			wantStatusCode: http.StatusConflict,
+			wantBody:       `{"error":"instance is being drained"}`,
+		},
+		{
+			name:           "shall reject the drain if not enough instances are left",
+			rebalancer:     mockRebalancer{drainErr: gateway.ErrNoPlacementInstances},
+			method:         http.MethodPost,
+			path:           "/admin/instances/node0/drain",
+			wantStatusCode: http.StatusConflict,
+			wantBody:       `{"error":"not enough instances to store new objects"}`,
+		},
+		{
+			name:           "shall fail to start the drain",
+			rebalancer:     mockRebalancer{drainErr: errors.New("foo")},
+			method:         http.MethodPost,
+			path:           "/admin/instances/node0/drain/",
+			wantStatusCode: http.StatusInternalServerError,
+			wantBody:       `{"error":"failed to drain the instance"}`,
+		},
+		{
+			name: "shall return the status of the finished drain",
+			rebalancer: mockRebalancer{drainStatus: &gateway.DrainStatus{
+				InstanceID:    "node0",
+				Drained:       true,
+				StartedAt:     startedAt,
+				FinishedAt:    startedAt.Add(time.Minute),
+				MovedObjects:  10,
+				MovedBytes:    100,
+				FailedObjects: 1,
+				LastError:     "foo",
+			}},
+			method:         http.MethodGet,
+			path:           "/admin/instances/node0/drain",
+			wantStatusCode: http.StatusOK,
+			wantBody: `{"instanceID":"node0","drained":true,"startedAt":"2024-01-01T00:00:00Z",` +
+				`"finishedAt":"2024-01-01T00:01:00Z","remainingObjects":0,"movedObjects":10,"movedBytes":100,` +
+				`"failedObjects":1,"lastError":"foo"}`,
+		},
+		{
+			name:           "shall not find the status if the instance is not draining",
+			rebalancer:     mockRebalancer{},
+			method:         http.MethodGet,
+			path:           "/admin/instances/node0/drain",
+			wantStatusCode: http.StatusNotFound,
+			wantBody:       `{"error":"instance is not draining"}`,
+		},
+		{
+			name:           "shall cancel the drain",
+			rebalancer:     mockRebalancer{drainStatus: &gateway.DrainStatus{InstanceID: "node0"}},
+			method:         http.MethodDelete,
+			path:           "/admin/instances/node0/drain",
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name: "shall fail to cancel the drain",
+			rebalancer: mockRebalancer{
+				drainStatus: &gateway.DrainStatus{InstanceID: "node0"},
+				cancelErr:   errors.New("foo"),
+			},
+			method:         http.MethodDelete,
+			path:           "/admin/instances/node0/drain",
+			wantStatusCode: http.StatusInternalServerError,
+			wantBody:       `{"error":"failed to cancel the drain"}`,
+		},
+		{
+			name:           "shall not cancel the drain if the instance is not draining",
+			rebalancer:     mockRebalancer{},
+			method:         http.MethodDelete,
+			path:           "/admin/instances/node0/drain",
+			wantStatusCode: http.StatusNotFound,
+			wantBody:       `{"error":"instance is not draining"}`,
+		},
+		{
+			name:           "shall not allow other methods",
+			rebalancer:     mockRebalancer{},
+			method:         http.MethodPut,
+			path:           "/admin/instances/node0/drain",
+			wantStatusCode: http.StatusMethodNotAllowed,
+			wantBody:       `{"error":"method not allowed"}`,
+		},
+		{
+			name:           "shall not find the route without the instance ID",
+			rebalancer:     mockRebalancer{},
+			method:         http.MethodGet,
+			path:           "/admin/instances//drain",
+			wantStatusCode: http.StatusBadRequest,
+			wantBody:       `{"error":"route cannot be handled"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			h := AdminHandler{
+				rebalancer: tt.rebalancer,
+				logger:     slog.Default(),
+			}
+			w := &mockResponseWriter{Headers: map[string][]string{}}
+
+			// WHEN
+			h.ServeHTTP(w, &http.Request{Method: tt.method, URL: &url.URL{Path: tt.path}})
+
+			// THEN
+			if w.StatusCode != tt.wantStatusCode {
+				t.Errorf("wrong StatusCode, want: %d, got: %d", tt.wantStatusCode, w.StatusCode)
+			}
+			if got := string(w.Body); got != tt.wantBody {
+				t.Errorf("wrong body, want: %s, got: %s", tt.wantBody, got)
+			}
+		})
+	}
+}
+
+func TestNewAdmin(t *testing.T) {
+	t.Run("shall fail if the gateway is not set", func(t *testing.T) {
+		// WHEN
+		_, err := NewAdmin(nil, &gateway.Rebalancer{}, nil)
+
+		// THEN
+		if err == nil {
+			t.Errorf("error expected")
+		}
+	})
+	t.Run("shall fail if the rebalancer is not set", func(t *testing.T) {
+		// WHEN
+		_, err := NewAdmin(&gateway.Gateway{}, nil, nil)
+
+		// THEN
+		if err == nil {
+			t.Errorf("error expected")
+		}
+	})
+}
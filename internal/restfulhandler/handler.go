@@ -2,6 +2,8 @@ package restfulhandler
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"io"
 	"log/slog"
 	"net/http"
@@ -19,20 +21,23 @@ func New(gw *gateway.Gateway) (*Handler, error) {
 	o := &Handler{
 		rw:                gw,
 		commonRoutePrefix: defaultPrefix,
-		logger: slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-			AddSource: false,
-			Level:     slog.LevelError,
-		})),
+		logger:            newLogger(gw.Logger).WithGroup("webserver"),
 	}
 
-	if gw.Logger != nil {
-		o.logger = gw.Logger
-	}
-	o.logger = o.logger.WithGroup("webserver")
-
 	return o, nil
 }
 
+// newLogger returns the logger of the errors to stdout unless the logger is set.
+func newLogger(logger *slog.Logger) *slog.Logger {
+	if logger != nil {
+		return logger
+	}
+	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		AddSource: false,
+		Level:     slog.LevelError,
+	}))
+}
+
 // Handler Gateway Restful API handler.
 type Handler struct {
 	rw readWriter
@@ -55,6 +60,11 @@ func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	objectID := h.readObjectID(r.URL.Path)
+	if objectID == "" && r.Method == http.MethodGet {
+		h.list(w, r)
+		return
+	}
+
 	if err := validateInputObjectID(objectID); err != nil {
 		h.logError(r, http.StatusUnprocessableEntity, err.Error())
 		writeErrorMessage(w, http.StatusUnprocessableEntity, err.Error())
@@ -63,51 +73,201 @@ func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
-		readCloser, found, err := h.rw.Read(r.Context(), objectID)
+		h.read(w, r, objectID)
+	case http.MethodHead:
+		h.stat(w, r, objectID)
+	case http.MethodPut:
+		h.write(w, r, objectID)
+	case http.MethodDelete:
+		h.delete(w, r, objectID)
+	default:
+		h.logError(r, http.StatusInternalServerError, "method not allowed")
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (h Handler) read(w http.ResponseWriter, r *http.Request, objectID string) {
+	var (
+		offset, length int64 = 0, -1
+		statusCode           = http.StatusOK
+	)
+
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+		info, found, err := h.rw.Stat(r.Context(), objectID)
 		if err != nil {
 			h.logError(r, http.StatusInternalServerError, err.Error())
 			writeErrorMessage(w, http.StatusInternalServerError, "failed to read object")
 			return
 		}
 
-		if !found || readCloser == nil {
+		if !found {
 			h.logError(r, http.StatusNotFound, "object not found")
 			writeErrorMessage(w, http.StatusNotFound, "object not found")
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/octet-stream")
-		w.WriteHeader(http.StatusOK)
-		defer func() { _ = readCloser.Close() }()
-		if _, err := io.Copy(w, readCloser); err != nil {
-			h.logError(r, http.StatusInternalServerError, err.Error())
-			writeErrorMessage(w, http.StatusInternalServerError, "server error")
+		byteRange, ok, err := parseByteRange(rangeHeader, info.Size)
+		if err != nil {
+			h.logError(r, http.StatusRequestedRangeNotSatisfiable, err.Error())
+			w.Header().Set("Content-Range", "bytes */"+strconv.FormatInt(info.Size, 10))
+			writeErrorMessage(w, http.StatusRequestedRangeNotSatisfiable, err.Error())
+			return
 		}
 
+		if ok {
+			offset, length = byteRange.start, byteRange.length
+			statusCode = http.StatusPartialContent
+			w.Header().Set("Content-Range", byteRange.contentRange(info.Size))
+			w.Header().Set("Content-Length", strconv.FormatInt(byteRange.length, 10))
+		}
+	}
+
+	readCloser, found, err := h.rw.Read(r.Context(), objectID, offset, length)
+	if err != nil {
+		h.logError(r, http.StatusInternalServerError, err.Error())
+		writeErrorMessage(w, http.StatusInternalServerError, "failed to read object")
 		return
+	}
 
-	case http.MethodPut:
-		if r.Body == nil {
-			h.logError(r, http.StatusBadRequest, "nil request body")
-			writeErrorMessage(w, http.StatusBadRequest, "failed to write: request body shall be provided")
+	if !found || readCloser == nil {
+		h.logError(r, http.StatusNotFound, "object not found")
+		writeErrorMessage(w, http.StatusNotFound, "object not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.WriteHeader(statusCode)
+	defer func() { _ = readCloser.Close() }()
+	if _, err := io.Copy(w, readCloser); err != nil {
+		h.logError(r, http.StatusInternalServerError, err.Error())
+		writeErrorMessage(w, http.StatusInternalServerError, "server error")
+	}
+}
+
+func (h Handler) stat(w http.ResponseWriter, r *http.Request, objectID string) {
+	info, found, err := h.rw.Stat(r.Context(), objectID)
+	if err != nil {
+		h.logError(r, http.StatusInternalServerError, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if !found {
+		h.logError(r, http.StatusNotFound, "object not found")
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	if info.ETag != "" {
+		w.Header().Set("ETag", `"`+strings.Trim(info.ETag, `"`)+`"`)
+	}
+	if !info.LastModified.IsZero() {
+		w.Header().Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h Handler) write(w http.ResponseWriter, r *http.Request, objectID string) {
+	if r.Body == nil {
+		h.logError(r, http.StatusBadRequest, "nil request body")
+		writeErrorMessage(w, http.StatusBadRequest, "failed to write: request body shall be provided")
+		return
+	}
+
+	defer func() { _ = r.Body.Close() }()
+	if err := h.rw.Write(r.Context(), objectID, r.Body, contentSize(r)); err != nil {
+		h.logError(r, http.StatusInternalServerError, err.Error())
+		writeErrorMessage(w, http.StatusInternalServerError, "failed to write object")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h Handler) delete(w http.ResponseWriter, r *http.Request, objectID string) {
+	found, err := h.rw.Delete(r.Context(), objectID)
+	if err != nil {
+		h.logError(r, http.StatusInternalServerError, err.Error())
+		writeErrorMessage(w, http.StatusInternalServerError, "failed to delete object")
+		return
+	}
+
+	if !found {
+		h.logError(r, http.StatusNotFound, "object not found")
+		writeErrorMessage(w, http.StatusNotFound, "object not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// objectList defines the response body of the list objects route.
+type objectList struct {
+	Objects      []listedObject `json:"objects"`
+	Continuation string         `json:"continuation,omitempty"`
+}
+
+type listedObject struct {
+	ID         string `json:"id"`
+	Size       int64  `json:"size"`
+	InstanceID string `json:"instanceID"`
+}
+
+func (h Handler) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	prefix := query.Get("prefix")
+	if err := validateInputObjectIDPrefix(prefix); err != nil {
+		h.logError(r, http.StatusUnprocessableEntity, err.Error())
+		writeErrorMessage(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	var limit int
+	if v := query.Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > gateway.MaxListLimit {
+			h.logError(r, http.StatusUnprocessableEntity, "limit is not valid")
+			writeErrorMessage(w, http.StatusUnprocessableEntity, "limit is not valid")
 			return
 		}
+	}
 
-		defer func() { _ = r.Body.Close() }()
-		if err := h.rw.Write(r.Context(), objectID, r.Body, contentSize(r)); err != nil {
-			h.logError(r, http.StatusInternalServerError, err.Error())
-			writeErrorMessage(w, http.StatusInternalServerError, "failed to write object")
+	page, err := h.rw.List(r.Context(), prefix, query.Get("continuation"), limit)
+	if err != nil {
+		if errors.Is(err, gateway.ErrInvalidContinuationToken) {
+			h.logError(r, http.StatusUnprocessableEntity, err.Error())
+			writeErrorMessage(w, http.StatusUnprocessableEntity, err.Error())
 			return
 		}
-
-		w.WriteHeader(http.StatusCreated)
+		h.logError(r, http.StatusInternalServerError, err.Error())
+		writeErrorMessage(w, http.StatusInternalServerError, "failed to list objects")
 		return
+	}
 
-	default:
-		h.logError(r, http.StatusInternalServerError, "method not allowed")
-		writeErrorMessage(w, http.StatusMethodNotAllowed, "method not allowed")
+	o := objectList{
+		Objects:      make([]listedObject, len(page.Objects)),
+		Continuation: page.ContinuationToken,
+	}
+	for i, obj := range page.Objects {
+		o.Objects[i] = listedObject{ID: obj.ID, Size: obj.Size, InstanceID: obj.InstanceID}
+	}
+
+	body, err := json.Marshal(o)
+	if err != nil {
+		h.logError(r, http.StatusInternalServerError, err.Error())
+		writeErrorMessage(w, http.StatusInternalServerError, "failed to list objects")
 		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
 }
 
 func contentSize(r *http.Request) int64 {
@@ -155,8 +315,11 @@ func writeErrorMessage(w http.ResponseWriter, statusCode int, s string) {
 	_, _ = w.Write([]byte(`{"error":"` + s + `"}`))
 }
 
-// reader defines the interface to store and retrieve data.
+// reader defines the interface to store, retrieve and delete data.
 type readWriter interface {
-	Read(ctx context.Context, id string) (readCloser io.ReadCloser, found bool, err error)
+	Read(ctx context.Context, id string, offset, length int64) (readCloser io.ReadCloser, found bool, err error)
 	Write(ctx context.Context, id string, reader io.Reader, objectSizeBytes int64) error
+	Delete(ctx context.Context, id string) (found bool, err error)
+	Stat(ctx context.Context, id string) (info gateway.ObjectInfo, found bool, err error)
+	List(ctx context.Context, prefix, continuationToken string, limit int) (gateway.ObjectList, error)
 }
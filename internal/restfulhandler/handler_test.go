@@ -9,6 +9,9 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/kislerdm/object-storage-gateway/pkg/gateway"
 )
 
 type mockResponseWriter struct {
@@ -33,9 +36,13 @@ func (m *mockResponseWriter) WriteHeader(statusCode int) {
 type mockReadWriter struct {
 	err        error
 	readCloser io.Reader
+	info       gateway.ObjectInfo
+	list       gateway.ObjectList
 }
 
-func (m *mockReadWriter) Read(_ context.Context, _ string) (readCloser io.ReadCloser, found bool, err error) {
+func (m *mockReadWriter) Read(_ context.Context, _ string, _, _ int64) (
+	readCloser io.ReadCloser, found bool, err error,
+) {
 	if m.err != nil {
 		return nil, false, m.err
 	}
@@ -50,6 +57,32 @@ func (m *mockReadWriter) Write(_ context.Context, _ string, reader io.Reader, _
 	return nil
 }
 
+func (m *mockReadWriter) Delete(_ context.Context, _ string) (found bool, err error) {
+	if m.err != nil {
+		return false, m.err
+	}
+	found = m.readCloser != nil
+	m.readCloser = nil
+	return found, nil
+}
+
+func (m *mockReadWriter) Stat(_ context.Context, _ string) (info gateway.ObjectInfo, found bool, err error) {
+	if m.err != nil {
+		return gateway.ObjectInfo{}, false, m.err
+	}
+	return m.info, m.readCloser != nil, nil
+}
+
+func (m *mockReadWriter) List(_ context.Context, _, continuationToken string, _ int) (gateway.ObjectList, error) {
+	if m.err != nil {
+		return gateway.ObjectList{}, m.err
+	}
+	if continuationToken == "invalid" {
+		return gateway.ObjectList{}, gateway.ErrInvalidContinuationToken
+	}
+	return m.list, nil
+}
+
 func TestHandler_ServeHTTP(t *testing.T) {
 	type fields struct {
 		readWriter        readWriter
@@ -89,6 +122,66 @@ func TestHandler_ServeHTTP(t *testing.T) {
 			wantStatusCode:  http.StatusOK,
 			wantContentType: "application/octet-stream",
 		},
+		{
+			name: "shall successfully read the object's range",
+			fields: fields{
+				readWriter: &mockReadWriter{
+					readCloser: strings.NewReader("obj"),
+					info:       gateway.ObjectInfo{Size: 3},
+				},
+				commonRoutePrefix: defaultPrefix,
+				logger:            slog.Default(),
+			},
+			args: args{
+				w: &mockResponseWriter{Headers: map[string][]string{}},
+				r: &http.Request{
+					Method: http.MethodGet,
+					URL:    &url.URL{Path: "/object/bAr1"},
+					Header: http.Header{"Range": []string{"bytes=1-"}},
+				},
+			},
+			wantStatusCode:  http.StatusPartialContent,
+			wantContentType: "application/octet-stream",
+		},
+		{
+			name: "shall fail to read the object's range - range not satisfiable",
+			fields: fields{
+				readWriter: &mockReadWriter{
+					readCloser: strings.NewReader("obj"),
+					info:       gateway.ObjectInfo{Size: 3},
+				},
+				commonRoutePrefix: defaultPrefix,
+				logger:            slog.Default(),
+			},
+			args: args{
+				w: &mockResponseWriter{Headers: map[string][]string{}},
+				r: &http.Request{
+					Method: http.MethodGet,
+					URL:    &url.URL{Path: "/object/bAr1"},
+					Header: http.Header{"Range": []string{"bytes=3-"}},
+				},
+			},
+			wantStatusCode:  http.StatusRequestedRangeNotSatisfiable,
+			wantContentType: contentTypeJSON,
+		},
+		{
+			name: "shall fail to read the object's range - object not found",
+			fields: fields{
+				readWriter:        &mockReadWriter{},
+				commonRoutePrefix: defaultPrefix,
+				logger:            slog.Default(),
+			},
+			args: args{
+				w: &mockResponseWriter{Headers: map[string][]string{}},
+				r: &http.Request{
+					Method: http.MethodGet,
+					URL:    &url.URL{Path: "/object/bAr1"},
+					Header: http.Header{"Range": []string{"bytes=0-1"}},
+				},
+			},
+			wantStatusCode:  http.StatusNotFound,
+			wantContentType: contentTypeJSON,
+		},
 		{
 			name: "shall successfully write the object",
 			fields: fields{
@@ -192,6 +285,201 @@ func TestHandler_ServeHTTP(t *testing.T) {
 			wantStatusCode:  http.StatusBadRequest,
 			wantContentType: contentTypeJSON,
 		},
+		{
+			name: "shall successfully delete the object",
+			fields: fields{
+				readWriter: &mockReadWriter{
+					readCloser: strings.NewReader("obj"),
+				},
+				commonRoutePrefix: defaultPrefix,
+				logger:            slog.Default(),
+			},
+			args: args{
+				w: &mockResponseWriter{Headers: map[string][]string{}},
+				r: &http.Request{
+					Method: http.MethodDelete,
+					URL:    &url.URL{Path: "/object/bAr1"},
+				},
+			},
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name: "shall fail to delete the object - object not found",
+			fields: fields{
+				readWriter:        &mockReadWriter{},
+				commonRoutePrefix: defaultPrefix,
+				logger:            slog.Default(),
+			},
+			args: args{
+				w: &mockResponseWriter{Headers: map[string][]string{}},
+				r: &http.Request{
+					Method: http.MethodDelete,
+					URL:    &url.URL{Path: "/object/bAr1"},
+				},
+			},
+			wantStatusCode:  http.StatusNotFound,
+			wantContentType: contentTypeJSON,
+		},
+		{
+			name: "shall fail to delete the object - storage error",
+			fields: fields{
+				readWriter:        &mockReadWriter{err: errors.New("error")},
+				commonRoutePrefix: defaultPrefix,
+				logger:            slog.Default(),
+			},
+			args: args{
+				w: &mockResponseWriter{Headers: map[string][]string{}},
+				r: &http.Request{
+					Method: http.MethodDelete,
+					URL:    &url.URL{Path: "/object/bAr1"},
+				},
+			},
+			wantStatusCode:  http.StatusInternalServerError,
+			wantContentType: contentTypeJSON,
+		},
+		{
+			name: "shall successfully read the object's metadata",
+			fields: fields{
+				readWriter: &mockReadWriter{
+					readCloser: strings.NewReader("obj"),
+					info:       gateway.ObjectInfo{Size: 3},
+				},
+				commonRoutePrefix: defaultPrefix,
+				logger:            slog.Default(),
+			},
+			args: args{
+				w: &mockResponseWriter{Headers: map[string][]string{}},
+				r: &http.Request{
+					Method: http.MethodHead,
+					URL:    &url.URL{Path: "/object/bAr1"},
+				},
+			},
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "application/octet-stream",
+		},
+		{
+			name: "shall fail to read the object's metadata - object not found",
+			fields: fields{
+				readWriter:        &mockReadWriter{},
+				commonRoutePrefix: defaultPrefix,
+				logger:            slog.Default(),
+			},
+			args: args{
+				w: &mockResponseWriter{Headers: map[string][]string{}},
+				r: &http.Request{
+					Method: http.MethodHead,
+					URL:    &url.URL{Path: "/object/bAr1"},
+				},
+			},
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name: "shall fail to read the object's metadata - storage error",
+			fields: fields{
+				readWriter:        &mockReadWriter{err: errors.New("error")},
+				commonRoutePrefix: defaultPrefix,
+				logger:            slog.Default(),
+			},
+			args: args{
+				w: &mockResponseWriter{Headers: map[string][]string{}},
+				r: &http.Request{
+					Method: http.MethodHead,
+					URL:    &url.URL{Path: "/object/bAr1"},
+				},
+			},
+			wantStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name: "shall successfully list objects",
+			fields: fields{
+				readWriter: &mockReadWriter{
+					list: gateway.ObjectList{
+						Objects: []gateway.ListedObject{
+							{ObjectInfo: gateway.ObjectInfo{ID: "foo", Size: 3}, InstanceID: "bar"},
+						},
+					},
+				},
+				commonRoutePrefix: defaultPrefix,
+				logger:            slog.Default(),
+			},
+			args: args{
+				w: &mockResponseWriter{Headers: map[string][]string{}},
+				r: &http.Request{
+					Method: http.MethodGet,
+					URL:    &url.URL{Path: "/object", RawQuery: "prefix=fo&limit=10"},
+				},
+			},
+			wantStatusCode:  http.StatusOK,
+			wantContentType: contentTypeJSON,
+		},
+		{
+			name: "shall fail to list objects - invalid prefix",
+			fields: fields{
+				readWriter:        &mockReadWriter{},
+				commonRoutePrefix: defaultPrefix,
+				logger:            slog.Default(),
+			},
+			args: args{
+				w: &mockResponseWriter{Headers: map[string][]string{}},
+				r: &http.Request{
+					Method: http.MethodGet,
+					URL:    &url.URL{Path: "/object/", RawQuery: "prefix=-!"},
+				},
+			},
+			wantStatusCode:  http.StatusUnprocessableEntity,
+			wantContentType: contentTypeJSON,
+		},
+		{
+			name: "shall fail to list objects - invalid limit",
+			fields: fields{
+				readWriter:        &mockReadWriter{},
+				commonRoutePrefix: defaultPrefix,
+				logger:            slog.Default(),
+			},
+			args: args{
+				w: &mockResponseWriter{Headers: map[string][]string{}},
+				r: &http.Request{
+					Method: http.MethodGet,
+					URL:    &url.URL{Path: "/object", RawQuery: "limit=-1"},
+				},
+			},
+			wantStatusCode:  http.StatusUnprocessableEntity,
+			wantContentType: contentTypeJSON,
+		},
+		{
+			name: "shall fail to list objects - invalid continuation token",
+			fields: fields{
+				readWriter:        &mockReadWriter{},
+				commonRoutePrefix: defaultPrefix,
+				logger:            slog.Default(),
+			},
+			args: args{
+				w: &mockResponseWriter{Headers: map[string][]string{}},
+				r: &http.Request{
+					Method: http.MethodGet,
+					URL:    &url.URL{Path: "/object", RawQuery: "continuation=invalid"},
+				},
+			},
+			wantStatusCode:  http.StatusUnprocessableEntity,
+			wantContentType: contentTypeJSON,
+		},
+		{
+			name: "shall fail to list objects - storage error",
+			fields: fields{
+				readWriter:        &mockReadWriter{err: errors.New("error")},
+				commonRoutePrefix: defaultPrefix,
+				logger:            slog.Default(),
+			},
+			args: args{
+				w: &mockResponseWriter{Headers: map[string][]string{}},
+				r: &http.Request{
+					Method: http.MethodGet,
+					URL:    &url.URL{Path: "/object"},
+				},
+			},
+			wantStatusCode:  http.StatusInternalServerError,
+			wantContentType: contentTypeJSON,
+		},
 		{
 			name: "shall fail - the route is unknown",
 			fields: fields{
@@ -208,6 +496,22 @@ func TestHandler_ServeHTTP(t *testing.T) {
 			wantStatusCode:  http.StatusBadRequest,
 			wantContentType: contentTypeJSON,
 		},
+		{
+			name: "shall fail - the admin route is not served",
+			fields: fields{
+				commonRoutePrefix: defaultPrefix,
+				logger:            slog.Default(),
+			},
+			args: args{
+				w: &mockResponseWriter{Headers: map[string][]string{}},
+				r: &http.Request{
+					Method: http.MethodPost,
+					URL:    &url.URL{Path: "/admin/instances/node0/drain"},
+				},
+			},
+			wantStatusCode:  http.StatusBadRequest,
+			wantContentType: contentTypeJSON,
+		},
 		{
 			name: "shall fail - unsupported object id",
 			fields: fields{
@@ -266,3 +570,42 @@ func TestHandler_ServeHTTP(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_ServeHTTP_Head(t *testing.T) {
+	t.Parallel()
+
+	// GIVEN
+	lastModified := time.Date(2023, 10, 21, 7, 28, 0, 0, time.UTC)
+	h := Handler{
+		rw: &mockReadWriter{
+			readCloser: strings.NewReader("obj"),
+			info: gateway.ObjectInfo{
+				Size:         3,
+				ETag:         "acbd18db4cc2f85cedef654fccc4a4d8",
+				LastModified: lastModified,
+			},
+		},
+		commonRoutePrefix: defaultPrefix,
+		logger:            slog.Default(),
+	}
+	w := &mockResponseWriter{Headers: map[string][]string{}}
+
+	// WHEN
+	h.ServeHTTP(w, &http.Request{Method: http.MethodHead, URL: &url.URL{Path: "/object/bAr1"}})
+
+	// THEN
+	wantHeaders := map[string]string{
+		"Content-Length": "3",
+		"ETag":           `"acbd18db4cc2f85cedef654fccc4a4d8"`,
+		"Last-Modified":  "Sat, 21 Oct 2023 07:28:00 GMT",
+	}
+	for k, want := range wantHeaders {
+		if got := w.Headers.Get(k); got != want {
+			t.Errorf("wrong %s header, want: %s, got: %s", k, want, got)
+		}
+	}
+
+	if len(w.Body) > 0 {
+		t.Errorf("no body is expected")
+	}
+}
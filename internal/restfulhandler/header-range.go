@@ -0,0 +1,81 @@
+package restfulhandler
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var errRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// byteRange defines the part of the object to read.
+type byteRange struct {
+	start  int64
+	length int64
+}
+
+// parseByteRange parses the value of the Range header given the object size.
+// It returns false if the header shall be ignored, i.e. it is not set, malformed, or defines multiple ranges.
+// The error errRangeNotSatisfiable is returned if the range lies outside the object.
+func parseByteRange(s string, size int64) (byteRange, bool, error) {
+	const unitPrefix = "bytes="
+	if !strings.HasPrefix(s, unitPrefix) {
+		return byteRange{}, false, nil
+	}
+
+	spec := strings.TrimSpace(strings.TrimPrefix(s, unitPrefix))
+	if strings.Contains(spec, ",") {
+		return byteRange{}, false, nil
+	}
+
+	const cntElements = 2
+	els := strings.SplitN(spec, "-", cntElements)
+	if len(els) != cntElements {
+		return byteRange{}, false, nil
+	}
+
+	startStr, endStr := strings.TrimSpace(els[0]), strings.TrimSpace(els[1])
+
+	// suffix range: the last N bytes of the object
+	if startStr == "" {
+		suffixLength, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || suffixLength < 0 {
+			return byteRange{}, false, nil
+		}
+		if suffixLength == 0 || size == 0 {
+			return byteRange{}, false, errRangeNotSatisfiable
+		}
+		if suffixLength > size {
+			suffixLength = size
+		}
+		return byteRange{start: size - suffixLength, length: suffixLength}, true, nil
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return byteRange{}, false, nil
+	}
+
+	end := size - 1
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return byteRange{}, false, nil
+		}
+		if end > size-1 {
+			end = size - 1
+		}
+	}
+
+	if start >= size {
+		return byteRange{}, false, errRangeNotSatisfiable
+	}
+
+	return byteRange{start: start, length: end - start + 1}, true, nil
+}
+
+// contentRange returns the value of the Content-Range header.
+func (r byteRange) contentRange(size int64) string {
+	return "bytes " + strconv.FormatInt(r.start, 10) + "-" + strconv.FormatInt(r.start+r.length-1, 10) +
+		"/" + strconv.FormatInt(size, 10)
+}
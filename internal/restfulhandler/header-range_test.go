@@ -0,0 +1,110 @@
+package restfulhandler
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_parseByteRange(t *testing.T) {
+	type args struct {
+		s    string
+		size int64
+	}
+	tests := []struct {
+		name             string
+		args             args
+		want             byteRange
+		wantOk           bool
+		wantErr          error
+		wantContentRange string
+	}{
+		{
+			name:             "start and end",
+			args:             args{s: "bytes=0-499", size: 1000},
+			want:             byteRange{start: 0, length: 500},
+			wantOk:           true,
+			wantContentRange: "bytes 0-499/1000",
+		},
+		{
+			name:             "open end",
+			args:             args{s: "bytes=900-", size: 1000},
+			want:             byteRange{start: 900, length: 100},
+			wantOk:           true,
+			wantContentRange: "bytes 900-999/1000",
+		},
+		{
+			name:             "end beyond the object size",
+			args:             args{s: "bytes=900-2000", size: 1000},
+			want:             byteRange{start: 900, length: 100},
+			wantOk:           true,
+			wantContentRange: "bytes 900-999/1000",
+		},
+		{
+			name:             "suffix",
+			args:             args{s: "bytes=-100", size: 1000},
+			want:             byteRange{start: 900, length: 100},
+			wantOk:           true,
+			wantContentRange: "bytes 900-999/1000",
+		},
+		{
+			name:             "suffix longer than the object",
+			args:             args{s: "bytes=-2000", size: 1000},
+			want:             byteRange{start: 0, length: 1000},
+			wantOk:           true,
+			wantContentRange: "bytes 0-999/1000",
+		},
+		{
+			name:    "start beyond the object size",
+			args:    args{s: "bytes=1000-", size: 1000},
+			wantErr: errRangeNotSatisfiable,
+		},
+		{
+			name:    "zero suffix",
+			args:    args{s: "bytes=-0", size: 1000},
+			wantErr: errRangeNotSatisfiable,
+		},
+		{
+			name:    "empty object",
+			args:    args{s: "bytes=0-", size: 0},
+			wantErr: errRangeNotSatisfiable,
+		},
+		{
+			name: "unknown unit",
+			args: args{s: "items=0-1", size: 1000},
+		},
+		{
+			name: "multiple ranges",
+			args: args{s: "bytes=0-1,5-6", size: 1000},
+		},
+		{
+			name: "end before start",
+			args: args{s: "bytes=5-1", size: 1000},
+		},
+		{
+			name: "malformed",
+			args: args{s: "bytes=foo", size: 1000},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotOk, err := parseByteRange(tt.args.s, tt.args.size)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("parseByteRange() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotOk != tt.wantOk {
+				t.Errorf("parseByteRange() ok = %v, want %v", gotOk, tt.wantOk)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parseByteRange() got = %v, want %v", got, tt.want)
+				return
+			}
+			if tt.wantOk {
+				if gotContentRange := got.contentRange(tt.args.size); gotContentRange != tt.wantContentRange {
+					t.Errorf("contentRange() = %v, want %v", gotContentRange, tt.wantContentRange)
+				}
+			}
+		})
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"regexp"
 )
 
-var regExpID = regexp.MustCompile("^[a-zA-Z0-9]{1,32}$")
+var (
+	regExpID       = regexp.MustCompile("^[a-zA-Z0-9]{1,32}$")
+	regExpIDPrefix = regexp.MustCompile("^[a-zA-Z0-9]{0,32}$")
+)
 
 // validateInputObjectID validates the input object ID.
 func validateInputObjectID(id string) error {
@@ -14,3 +17,11 @@ func validateInputObjectID(id string) error {
 	}
 	return nil
 }
+
+// validateInputObjectIDPrefix validates the prefix of object IDs used to list objects.
+func validateInputObjectIDPrefix(prefix string) error {
+	if !regExpIDPrefix.MatchString(prefix) {
+		return errors.New("prefix is not valid")
+	}
+	return nil
+}
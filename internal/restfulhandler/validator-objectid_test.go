@@ -77,3 +77,53 @@ func Test_validateObjectID(t *testing.T) {
 		})
 	}
 }
+
+func Test_validateInputObjectIDPrefix(t *testing.T) {
+	type args struct {
+		prefix string
+	}
+
+	const alphanumericCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "shall be valid",
+			args: args{
+				randomStr(alphanumericCharset, generatePositiveInt(1, 20)),
+			},
+			wantErr: false,
+		},
+		{
+			name: "shall be valid: empty",
+			args: args{
+				"",
+			},
+			wantErr: false,
+		},
+		{
+			name: "shall be invalid: too long",
+			args: args{
+				randomStr(alphanumericCharset, generatePositiveInt(50, 55)),
+			},
+			wantErr: true,
+		},
+		{
+			name: "shall be invalid: wrong charset",
+			args: args{
+				randomStr("!-/$%", generatePositiveInt(1, 20)),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateInputObjectIDPrefix(tt.args.prefix); (err != nil) != tt.wantErr {
+				t.Errorf("validateInputObjectIDPrefix() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
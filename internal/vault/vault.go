@@ -0,0 +1,373 @@
+// Package vault implements the authentication details reader backed by the HashiCorp Vault KV v2 secrets engine.
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAddress the default address of the Vault server.
+	DefaultAddress = "http://127.0.0.1:8200"
+	// DefaultMount the default mount path of the KV v2 secrets engine.
+	DefaultMount = "secret"
+	// DefaultPathPrefix the default path prefix of the storage instances' credentials.
+	DefaultPathPrefix = "object-storage-gateway/credentials"
+	// DefaultCacheTTL the time to cache the credentials if the secret has no lease.
+	DefaultCacheTTL = 5 * time.Minute
+	// KeyAccessKey the secret's key with the access key ID.
+	KeyAccessKey = "accessKey"
+	// KeySecretKey the secret's key with the secret access key.
+	KeySecretKey = "secretKey"
+)
+
+// renewFraction the fraction of the TTL after which the token is renewed and the credentials are re-read.
+const renewFraction = 2.0 / 3
+
+// NewClient initialises the client of the Vault HTTP API.
+// The token, or the AppRole credentials must be set using the options.
+func NewClient(address string, logger *slog.Logger, opts ...Option) (*Client, error) {
+	if address == "" {
+		address = DefaultAddress
+	}
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, errors.New("address must be defined as scheme://host:port")
+	}
+
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	o := &Client{
+		address:    strings.TrimSuffix(address, "/"),
+		mount:      DefaultMount,
+		pathPrefix: DefaultPathPrefix,
+		cacheTTL:   DefaultCacheTTL,
+		httpClient: http.DefaultClient,
+		logger:     logger.WithGroup("vault"),
+		now:        time.Now,
+		cache:      map[string]cachedCredentials{},
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.token == "" && o.roleID == "" {
+		return nil, errors.New("token or AppRole credentials must be set")
+	}
+
+	if o.cacheTTL <= 0 {
+		return nil, errors.New("cacheTTL must be positive")
+	}
+
+	return o, nil
+}
+
+// Option defines the Client's optional configuration.
+type Option func(*Client)
+
+// WithToken sets the token to authenticate the requests.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithAppRole sets the AppRole credentials to log in.
+func WithAppRole(roleID, secretID string) Option {
+	return func(c *Client) {
+		c.roleID = roleID
+		c.secretID = secretID
+	}
+}
+
+// WithMount sets the mount path of the KV v2 secrets engine.
+func WithMount(mount string) Option {
+	return func(c *Client) {
+		c.mount = strings.Trim(mount, "/")
+	}
+}
+
+// WithPathPrefix sets the path prefix of the credentials.
+func WithPathPrefix(prefix string) Option {
+	return func(c *Client) {
+		c.pathPrefix = strings.Trim(prefix, "/")
+	}
+}
+
+// WithCacheTTL sets the time to cache the credentials if the secret has no lease.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(c *Client) {
+		c.cacheTTL = ttl
+	}
+}
+
+// WithHTTPClient sets the HTTP client.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// Client reads the storage instances' credentials from the KV v2 secrets engine.
+// The credentials are cached for the secret's lease duration, and re-read before the lease expires.
+type Client struct {
+	address    string
+	mount      string
+	pathPrefix string
+	cacheTTL   time.Duration
+	httpClient *http.Client
+	logger     *slog.Logger
+	now        func() time.Time
+
+	roleID, secretID string
+
+	// tokenMu guards the token and serializes the login and renewal.
+	tokenMu sync.Mutex
+	token   string
+	// tokenRenewAt the time to renew the token, zero if the token never expires.
+	tokenRenewAt  time.Time
+	tokenExpireAt time.Time
+	// tokenRenew indicates that the token can be renewed, otherwise the client logs in again.
+	tokenRenew bool
+
+	mu    sync.Mutex
+	cache map[string]cachedCredentials
+}
+
+type cachedCredentials struct {
+	accessKeyID, secretAccessKey string
+	refreshAt, expireAt          time.Time
+}
+
+// Read returns the instance's credentials stored under the path "{mount}/data/{prefix}/{instanceID}".
+// The cached credentials are returned if they are not expired, and the re-read after the refresh time failed.
+func (c *Client) Read(ctx context.Context, instanceID string) (string, string, error) {
+	now := c.now()
+
+	c.mu.Lock()
+	cached, ok := c.cache[instanceID]
+	c.mu.Unlock()
+
+	if ok && now.Before(cached.refreshAt) {
+		return cached.accessKeyID, cached.secretAccessKey, nil
+	}
+
+	v, err := c.readSecret(ctx, instanceID)
+	if err != nil {
+		if ok && now.Before(cached.expireAt) {
+			c.logger.Error("cannot re-read credentials, using cached",
+				slog.String("instanceID", instanceID),
+				slog.String("error", err.Error()),
+			)
+			return cached.accessKeyID, cached.secretAccessKey, nil
+		}
+		return "", "", err
+	}
+
+	c.mu.Lock()
+	c.cache[instanceID] = v
+	c.mu.Unlock()
+
+	return v.accessKeyID, v.secretAccessKey, nil
+}
+
+// Invalidate drops the cached credentials of the instance, e.g. rejected by the instance after the rotation,
+// hence they are read from Vault on the next Read.
+func (c *Client) Invalidate(instanceID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.cache, instanceID)
+}
+
+// secretResponse defines the response of the API.
+type secretResponse struct {
+	LeaseDuration int             `json:"lease_duration"`
+	Data          json.RawMessage `json:"data"`
+	Auth          *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+	} `json:"auth"`
+}
+
+func (c *Client) readSecret(ctx context.Context, instanceID string) (cachedCredentials, error) {
+	token, err := c.authToken(ctx)
+	if err != nil {
+		return cachedCredentials{}, err
+	}
+
+	path := "/v1/" + c.mount + "/data/" + c.pathPrefix + "/" + url.PathEscape(instanceID)
+
+	resp, err := c.do(ctx, http.MethodGet, path, token, nil)
+	if err != nil {
+		return cachedCredentials{}, fmt.Errorf("cannot read credentials of the instance %s: %w", instanceID, err)
+	}
+
+	var data struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		return cachedCredentials{}, err
+	}
+
+	accessKeyID, _ := data.Data[KeyAccessKey].(string)
+	secretAccessKey, _ := data.Data[KeySecretKey].(string)
+	if accessKeyID == "" || secretAccessKey == "" {
+		return cachedCredentials{}, fmt.Errorf("secret %s must define %s and %s", path, KeyAccessKey, KeySecretKey)
+	}
+
+	ttl := c.cacheTTL
+	if resp.LeaseDuration > 0 {
+		ttl = time.Duration(resp.LeaseDuration) * time.Second
+	}
+
+	now := c.now()
+	return cachedCredentials{
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		refreshAt:       now.Add(time.Duration(float64(ttl) * renewFraction)),
+		expireAt:        now.Add(ttl),
+	}, nil
+}
+
+// authToken returns the token, it logs in using AppRole, or renews the token if required.
+func (c *Client) authToken(ctx context.Context) (string, error) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	if c.roleID == "" {
+		return c.token, nil
+	}
+
+	now := c.now()
+	switch {
+	case c.token == "", c.expires(now, c.tokenExpireAt):
+		if err := c.login(ctx); err != nil {
+			return "", err
+		}
+
+	case c.expires(now, c.tokenRenewAt):
+		// the token which cannot be renewed is replaced before it expires
+		if !c.tokenRenew {
+			if err := c.login(ctx); err != nil {
+				return "", err
+			}
+			break
+		}
+
+		if err := c.renewToken(ctx); err != nil {
+			c.logger.Error("cannot renew token, logging in", slog.String("error", err.Error()))
+			if err := c.login(ctx); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return c.token, nil
+}
+
+// expires defines if the deadline passed, zero deadline never passes.
+func (c *Client) expires(now, deadline time.Time) bool {
+	return !deadline.IsZero() && !now.Before(deadline)
+}
+
+func (c *Client) login(ctx context.Context) error {
+	resp, err := c.do(ctx, http.MethodPost, "/v1/auth/approle/login", "",
+		map[string]string{"role_id": c.roleID, "secret_id": c.secretID})
+	if err != nil {
+		return fmt.Errorf("AppRole login failed: %w", err)
+	}
+
+	return c.setToken(resp)
+}
+
+func (c *Client) renewToken(ctx context.Context) error {
+	resp, err := c.do(ctx, http.MethodPost, "/v1/auth/token/renew-self", c.token, map[string]string{})
+	if err != nil {
+		return err
+	}
+
+	return c.setToken(resp)
+}
+
+func (c *Client) setToken(resp secretResponse) error {
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return errors.New("no token returned")
+	}
+
+	ttl := time.Duration(resp.Auth.LeaseDuration) * time.Second
+	now := c.now()
+
+	c.token = resp.Auth.ClientToken
+	c.tokenRenew = resp.Auth.Renewable
+	c.tokenRenewAt, c.tokenExpireAt = time.Time{}, time.Time{}
+
+	// the token without TTL never expires
+	if ttl > 0 {
+		c.tokenRenewAt = now.Add(time.Duration(float64(ttl) * renewFraction))
+		c.tokenExpireAt = now.Add(ttl)
+	}
+
+	return nil
+}
+
+// do sends the request and decodes the JSON response.
+func (c *Client) do(ctx context.Context, method, path, token string, body any) (secretResponse, error) {
+	var reader io.Reader
+	if body != nil {
+		v, err := json.Marshal(body)
+		if err != nil {
+			return secretResponse{}, err
+		}
+		reader = bytes.NewReader(v)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.address+path, reader)
+	if err != nil {
+		return secretResponse{}, err
+	}
+
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return secretResponse{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Errors []string `json:"errors"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&e)
+		return secretResponse{}, fmt.Errorf("vault API %s returned %d: %s", path, resp.StatusCode,
+			strings.Join(e.Errors, "; "))
+	}
+
+	var o secretResponse
+	if err := json.NewDecoder(resp.Body).Decode(&o); err != nil {
+		return secretResponse{}, err
+	}
+
+	return o, nil
+}
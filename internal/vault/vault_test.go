@@ -0,0 +1,537 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kislerdm/object-storage-gateway/pkg/gateway"
+)
+
+// fakeVault emulates the Vault's AppRole login, token renewal and KV v2 read endpoints.
+type fakeVault struct {
+	mu sync.Mutex
+
+	roleID, secretID string
+	tokenTTL         int
+	renewable        bool
+	renewFails       bool
+
+	// tokens the valid tokens.
+	tokens  map[string]bool
+	secrets map[string]map[string]string
+	// leaseDuration the lease duration of the KV secrets.
+	leaseDuration int
+
+	logins, renewals, reads int
+}
+
+func newFakeVault(t *testing.T) (*fakeVault, *httptest.Server) {
+	t.Helper()
+
+	f := &fakeVault{
+		roleID:   "role",
+		secretID: "secret",
+		tokenTTL: 60,
+		tokens:   map[string]bool{"static-token": true},
+		secrets:  map[string]map[string]string{},
+	}
+
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	return f, server
+}
+
+func (f *fakeVault) writeError(w http.ResponseWriter, code int, msg string) {
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]any{"errors": []string{msg}})
+}
+
+func (f *fakeVault) issueToken() map[string]any {
+	token := "token-" + strings.Repeat("x", f.logins+f.renewals)
+	f.tokens[token] = true
+	return map[string]any{"auth": map[string]any{
+		"client_token": token, "lease_duration": f.tokenTTL, "renewable": f.renewable,
+	}}
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/auth/approle/login":
+		var req struct {
+			RoleID   string `json:"role_id"`
+			SecretID string `json:"secret_id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.RoleID != f.roleID || req.SecretID != f.secretID {
+			f.writeError(w, http.StatusBadRequest, "invalid role or secret ID")
+			return
+		}
+		f.logins++
+		_ = json.NewEncoder(w).Encode(f.issueToken())
+
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/auth/token/renew-self":
+		if !f.tokens[r.Header.Get("X-Vault-Token")] || f.renewFails {
+			f.writeError(w, http.StatusForbidden, "permission denied")
+			return
+		}
+		f.renewals++
+		_ = json.NewEncoder(w).Encode(f.issueToken())
+
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/secret/data/"):
+		if !f.tokens[r.Header.Get("X-Vault-Token")] {
+			f.writeError(w, http.StatusForbidden, "permission denied")
+			return
+		}
+		v, ok := f.secrets[strings.TrimPrefix(r.URL.Path, "/v1/secret/data/")]
+		if !ok {
+			f.writeError(w, http.StatusNotFound, "")
+			return
+		}
+		f.reads++
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"lease_duration": f.leaseDuration,
+			"data":           map[string]any{"data": v, "metadata": map[string]any{"version": 1}},
+		})
+
+	default:
+		f.writeError(w, http.StatusNotFound, "")
+	}
+}
+
+func (f *fakeVault) counters() (logins, renewals, reads int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.logins, f.renewals, f.reads
+}
+
+type mockClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *mockClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *mockClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestClient(t *testing.T, address string, clock *mockClock, opts ...Option) *Client {
+	t.Helper()
+
+	c, err := NewClient(address, nil, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.now = clock.Now
+	return c
+}
+
+func TestClient_Read(t *testing.T) {
+	// GIVEN
+	vault, server := newFakeVault(t)
+	vault.secrets[DefaultPathPrefix+"/node-0"] = map[string]string{KeyAccessKey: "foo", KeySecretKey: "bar"}
+	vault.secrets["custom/node-1"] = map[string]string{KeyAccessKey: "baz", KeySecretKey: "qux"}
+	vault.secrets[DefaultPathPrefix+"/node-2"] = map[string]string{KeyAccessKey: "foo"}
+
+	tests := []struct {
+		name            string
+		opts            []Option
+		instanceID      string
+		wantAccessKeyID string
+		wantSecretKey   string
+		wantErr         bool
+	}{
+		{
+			name:            "static token",
+			opts:            []Option{WithToken("static-token")},
+			instanceID:      "node-0",
+			wantAccessKeyID: "foo",
+			wantSecretKey:   "bar",
+		},
+		{
+			name:            "AppRole",
+			opts:            []Option{WithAppRole("role", "secret")},
+			instanceID:      "node-0",
+			wantAccessKeyID: "foo",
+			wantSecretKey:   "bar",
+		},
+		{
+			name:            "custom path prefix",
+			opts:            []Option{WithToken("static-token"), WithPathPrefix("/custom/")},
+			instanceID:      "node-1",
+			wantAccessKeyID: "baz",
+			wantSecretKey:   "qux",
+		},
+		{
+			name:       "secret without secret key",
+			opts:       []Option{WithToken("static-token")},
+			instanceID: "node-2",
+			wantErr:    true,
+		},
+		{
+			name:       "secret not found",
+			opts:       []Option{WithToken("static-token")},
+			instanceID: "node-100",
+			wantErr:    true,
+		},
+		{
+			name:       "invalid token",
+			opts:       []Option{WithToken("invalid")},
+			instanceID: "node-0",
+			wantErr:    true,
+		},
+		{
+			name:       "invalid AppRole credentials",
+			opts:       []Option{WithAppRole("role", "invalid")},
+			instanceID: "node-0",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, server.URL, &mockClock{now: time.Unix(0, 0)}, tt.opts...)
+
+			// WHEN
+			accessKeyID, secretKey, err := c.Read(context.Background(), tt.instanceID)
+
+			// THEN
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if accessKeyID != tt.wantAccessKeyID || secretKey != tt.wantSecretKey {
+				t.Errorf("unexpected credentials, want: %s/%s, got: %s/%s",
+					tt.wantAccessKeyID, tt.wantSecretKey, accessKeyID, secretKey)
+			}
+		})
+	}
+}
+
+func TestClient_Read_cache(t *testing.T) {
+	// GIVEN
+	vault, server := newFakeVault(t)
+	vault.secrets[DefaultPathPrefix+"/node-0"] = map[string]string{KeyAccessKey: "foo", KeySecretKey: "bar"}
+	vault.leaseDuration = 30
+
+	clock := &mockClock{now: time.Unix(0, 0)}
+	c := newTestClient(t, server.URL, clock, WithToken("static-token"))
+
+	if _, _, err := c.Read(context.Background(), "node-0"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("shall return the cached credentials before the refresh time", func(t *testing.T) {
+		// GIVEN
+		clock.Advance(19 * time.Second)
+
+		// WHEN
+		_, _, _ = c.Read(context.Background(), "node-0")
+
+		// THEN
+		if _, _, reads := vault.counters(); reads != 1 {
+			t.Errorf("single read expected, got: %d", reads)
+		}
+	})
+
+	t.Run("shall re-read the credentials before the lease expires", func(t *testing.T) {
+		// GIVEN
+		vault.mu.Lock()
+		vault.secrets[DefaultPathPrefix+"/node-0"] = map[string]string{KeyAccessKey: "foo", KeySecretKey: "rotated"}
+		vault.mu.Unlock()
+		clock.Advance(time.Second)
+
+		// WHEN
+		_, secretKey, err := c.Read(context.Background(), "node-0")
+
+		// THEN
+		if err != nil {
+			t.Fatalf("no error expected, got: %v", err)
+		}
+		if secretKey != "rotated" {
+			t.Errorf("rotated secret key expected, got: %s", secretKey)
+		}
+		if _, _, reads := vault.counters(); reads != 2 {
+			t.Errorf("two reads expected, got: %d", reads)
+		}
+	})
+
+	t.Run("shall return the cached credentials if the re-read fails before the lease expires", func(t *testing.T) {
+		// GIVEN
+		vault.mu.Lock()
+		delete(vault.secrets, DefaultPathPrefix+"/node-0")
+		vault.mu.Unlock()
+		clock.Advance(25 * time.Second)
+
+		// WHEN
+		_, secretKey, err := c.Read(context.Background(), "node-0")
+
+		// THEN
+		if err != nil {
+			t.Fatalf("no error expected, got: %v", err)
+		}
+		if secretKey != "rotated" {
+			t.Errorf("cached secret key expected, got: %s", secretKey)
+		}
+	})
+
+	t.Run("shall fail if the re-read fails after the lease expired", func(t *testing.T) {
+		// GIVEN
+		clock.Advance(5 * time.Second)
+
+		// WHEN
+		_, _, err := c.Read(context.Background(), "node-0")
+
+		// THEN
+		if err == nil {
+			t.Errorf("error expected")
+		}
+	})
+}
+
+func TestClient_Read_tokenRenewal(t *testing.T) {
+	t.Run("shall renew the renewable token before it expires", func(t *testing.T) {
+		// GIVEN
+		vault, server := newFakeVault(t)
+		vault.secrets[DefaultPathPrefix+"/node-0"] = map[string]string{KeyAccessKey: "foo", KeySecretKey: "bar"}
+		vault.renewable = true
+
+		clock := &mockClock{now: time.Unix(0, 0)}
+		c := newTestClient(t, server.URL, clock, WithAppRole("role", "secret"), WithCacheTTL(time.Second))
+
+		// WHEN
+		_, _, _ = c.Read(context.Background(), "node-0")
+		clock.Advance(39 * time.Second)
+		_, _, _ = c.Read(context.Background(), "node-0")
+		clock.Advance(time.Second)
+		_, _, err := c.Read(context.Background(), "node-0")
+
+		// THEN
+		if err != nil {
+			t.Fatalf("no error expected, got: %v", err)
+		}
+		if logins, renewals, _ := vault.counters(); logins != 1 || renewals != 1 {
+			t.Errorf("single login and renewal expected, got: %d logins, %d renewals", logins, renewals)
+		}
+	})
+
+	t.Run("shall log in if the renewal fails", func(t *testing.T) {
+		// GIVEN
+		vault, server := newFakeVault(t)
+		vault.secrets[DefaultPathPrefix+"/node-0"] = map[string]string{KeyAccessKey: "foo", KeySecretKey: "bar"}
+		vault.renewable = true
+		vault.renewFails = true
+
+		clock := &mockClock{now: time.Unix(0, 0)}
+		c := newTestClient(t, server.URL, clock, WithAppRole("role", "secret"), WithCacheTTL(time.Second))
+
+		// WHEN
+		_, _, _ = c.Read(context.Background(), "node-0")
+		clock.Advance(40 * time.Second)
+		_, _, err := c.Read(context.Background(), "node-0")
+
+		// THEN
+		if err != nil {
+			t.Fatalf("no error expected, got: %v", err)
+		}
+		if logins, _, _ := vault.counters(); logins != 2 {
+			t.Errorf("two logins expected, got: %d", logins)
+		}
+	})
+
+	t.Run("shall log in before the non-renewable token expires", func(t *testing.T) {
+		// GIVEN
+		vault, server := newFakeVault(t)
+		vault.secrets[DefaultPathPrefix+"/node-0"] = map[string]string{KeyAccessKey: "foo", KeySecretKey: "bar"}
+
+		clock := &mockClock{now: time.Unix(0, 0)}
+		c := newTestClient(t, server.URL, clock, WithAppRole("role", "secret"), WithCacheTTL(time.Second))
+
+		// WHEN
+		_, _, _ = c.Read(context.Background(), "node-0")
+		clock.Advance(40 * time.Second)
+		_, _, err := c.Read(context.Background(), "node-0")
+
+		// THEN
+		if err != nil {
+			t.Fatalf("no error expected, got: %v", err)
+		}
+		if logins, renewals, _ := vault.counters(); logins != 2 || renewals != 0 {
+			t.Errorf("two logins and no renewals expected, got: %d logins, %d renewals", logins, renewals)
+		}
+	})
+}
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		opts    []Option
+		wantErr bool
+	}{
+		{name: "token", opts: []Option{WithToken("token")}},
+		{name: "AppRole", address: "https://vault.example.com:8200", opts: []Option{WithAppRole("role", "secret")}},
+		{name: "no auth method", wantErr: true},
+		{name: "invalid address", address: "vault:8200", opts: []Option{WithToken("token")}, wantErr: true},
+		{name: "invalid cache TTL", opts: []Option{WithToken("token"), WithCacheTTL(0)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewClient(tt.address, nil, tt.opts...); (err != nil) != tt.wantErr {
+				t.Errorf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClient_Invalidate(t *testing.T) {
+	// GIVEN
+	vault, server := newFakeVault(t)
+	vault.secrets[DefaultPathPrefix+"/node-0"] = map[string]string{KeyAccessKey: "foo", KeySecretKey: "bar"}
+	vault.leaseDuration = 30
+
+	clock := &mockClock{now: time.Unix(0, 0)}
+	c := newTestClient(t, server.URL, clock, WithToken("static-token"))
+
+	if _, _, err := c.Read(context.Background(), "node-0"); err != nil {
+		t.Fatal(err)
+	}
+
+	vault.mu.Lock()
+	vault.secrets[DefaultPathPrefix+"/node-0"] = map[string]string{KeyAccessKey: "foo", KeySecretKey: "rotated"}
+	vault.mu.Unlock()
+
+	// WHEN
+	c.Invalidate("node-0")
+	_, secretKey, err := c.Read(context.Background(), "node-0")
+
+	// THEN
+	if err != nil {
+		t.Fatalf("no error expected, got: %v", err)
+	}
+	if secretKey != "rotated" {
+		t.Errorf("rotated secret key expected before the refresh time, got: %s", secretKey)
+	}
+	if _, _, reads := vault.counters(); reads != 2 {
+		t.Errorf("two reads expected, got: %d", reads)
+	}
+}
+
+// staticRegistry defines the cluster of the single storage instance.
+type staticRegistry struct{}
+
+func (staticRegistry) Scan(context.Context, string) (map[string]string, error) {
+	return map[string]string{"node-0": "192.0.2.10"}, nil
+}
+
+// rotatedStorage emulates the storage instance which accepts the single secret key.
+type rotatedStorage struct {
+	mu        sync.Mutex
+	secretKey string
+}
+
+func (s *rotatedStorage) rotate(secretKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secretKey = secretKey
+}
+
+type rotatedStorageClient struct {
+	storage   *rotatedStorage
+	secretKey string
+}
+
+func (c rotatedStorageClient) authenticate() error {
+	c.storage.mu.Lock()
+	defer c.storage.mu.Unlock()
+	if c.secretKey != c.storage.secretKey {
+		return fmt.Errorf("%w: signature does not match", gateway.ErrAuthentication)
+	}
+	return nil
+}
+
+func (c rotatedStorageClient) Read(context.Context, string, string, int64, int64) (io.ReadCloser, bool, error) {
+	if err := c.authenticate(); err != nil {
+		return nil, false, err
+	}
+	return io.NopCloser(strings.NewReader("qux")), true, nil
+}
+
+func (c rotatedStorageClient) Write(context.Context, string, string, io.Reader, int64) error {
+	return c.authenticate()
+}
+
+func (c rotatedStorageClient) Find(context.Context, string, string) (bool, error) {
+	return true, c.authenticate()
+}
+
+func (c rotatedStorageClient) Delete(context.Context, string, string) error {
+	return c.authenticate()
+}
+
+func (c rotatedStorageClient) Stat(context.Context, string, string) (gateway.ObjectInfo, bool, error) {
+	return gateway.ObjectInfo{}, true, c.authenticate()
+}
+
+func (c rotatedStorageClient) List(context.Context, string, string, string, int) ([]gateway.ObjectInfo, error) {
+	return nil, c.authenticate()
+}
+
+func TestClient_credentialsRotation(t *testing.T) {
+	// GIVEN
+	vault, server := newFakeVault(t)
+	vault.secrets[DefaultPathPrefix+"/node-0"] = map[string]string{KeyAccessKey: "foo", KeySecretKey: "bar"}
+	vault.leaseDuration = 3600
+
+	clock := &mockClock{now: time.Unix(0, 0)}
+	c := newTestClient(t, server.URL, clock, WithToken("static-token"))
+
+	storage := &rotatedStorage{secretKey: "bar"}
+	g, err := gateway.New("storage", "bucket", staticRegistry{}, c,
+		func(_, _, secretAccessKey string) (gateway.ObjectReadWriteFinder, error) {
+			return rotatedStorageClient{storage: storage, secretKey: secretAccessKey}, nil
+		}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := g.Read(context.Background(), "obj", 0, -1); err != nil {
+		t.Fatal(err)
+	}
+
+	// WHEN
+	vault.mu.Lock()
+	vault.secrets[DefaultPathPrefix+"/node-0"] = map[string]string{KeyAccessKey: "foo", KeySecretKey: "rotated"}
+	vault.mu.Unlock()
+	storage.rotate("rotated")
+
+	_, found, err := g.Read(context.Background(), "obj", 0, -1)
+
+	// THEN
+	if err != nil || !found {
+		t.Fatalf("shall read the object using the rotated credentials before the lease expires, got error: %v", err)
+	}
+	if got := g.Metrics().AuthenticationRetries; got != 1 {
+		t.Errorf("single retry expected, got: %d", got)
+	}
+	if _, _, reads := vault.counters(); reads != 2 {
+		t.Errorf("two reads expected, got: %d", reads)
+	}
+}
@@ -4,24 +4,27 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
 	"strconv"
+	"time"
 
+	"github.com/kislerdm/object-storage-gateway/internal/consul"
+	"github.com/kislerdm/object-storage-gateway/internal/dnsregistry"
 	"github.com/kislerdm/object-storage-gateway/internal/docker"
+	"github.com/kislerdm/object-storage-gateway/internal/fileregistry"
+	"github.com/kislerdm/object-storage-gateway/internal/kubernetes"
 	"github.com/kislerdm/object-storage-gateway/internal/minio"
 	"github.com/kislerdm/object-storage-gateway/internal/restfulhandler"
+	"github.com/kislerdm/object-storage-gateway/internal/vault"
 	"github.com/kislerdm/object-storage-gateway/pkg/gateway"
 )
 
 func main() {
-	cl, err := docker.NewClient()
-	if err != nil {
-		log.Fatalln(err)
-	}
-
 	storageInstanceSelector := "amazin-object-storage-node"
 	if v := os.Getenv("STORAGE_INSTANCES_SELECTOR"); v != "" {
 		storageInstanceSelector = v
@@ -34,23 +37,118 @@ func main() {
 		loggerLevel = slog.LevelDebug
 	}
 
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		AddSource: true,
+		Level:     loggerLevel,
+	}))
+
 	const storageBucket = "store"
 
-	gw, err := gateway.New(storageInstanceSelector, storageBucket, cl, cl, minio.NewClient,
-		slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-			AddSource: true,
-			Level:     loggerLevel,
-		})),
+	placement, err := newPlacementStrategy(os.Getenv("PLACEMENT_STRATEGY"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	scanner, authReader, err := newDiscovery(os.Getenv("SERVICE_REGISTRY"), storageInstanceSelector, logger)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if os.Getenv("CREDENTIALS_READER") == "vault" {
+		authReader, err = newVaultClient(logger)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	registry, err := newServiceRegistry(scanner, storageInstanceSelector, logger)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	replicationFactor, err := intFromEnv("REPLICATION_FACTOR", 1)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// the majority of replicas shall acknowledge the write by default
+	writeQuorum, err := intFromEnv("WRITE_QUORUM", replicationFactor/2+1)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	opts := []gateway.Option{
+		gateway.WithPlacementStrategy(placement),
+		gateway.WithReplication(replicationFactor, writeQuorum),
+	}
+
+	erasureDataShards, err := intFromEnv("ERASURE_DATA_SHARDS", 0)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if erasureDataShards > 0 {
+		erasureParityShards, err := intFromEnv("ERASURE_PARITY_SHARDS", 2)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		opts = append(opts, gateway.WithErasureCoding(erasureDataShards, erasureParityShards))
+	}
+
+	locker, err := newLocker(registry, authReader, storageInstanceSelector, logger)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if locker != nil {
+		drainMarksRefreshInterval, err := durationFromEnv("DRAIN_MARKS_REFRESH_INTERVAL",
+			gateway.DefaultDrainMarksRefreshInterval)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		// the draining marks are stored next to the locks to be shared by the gateways
+		opts = append(opts, gateway.WithLocker(locker), gateway.WithDrainMarks(locker, drainMarksRefreshInterval))
+	}
+
+	gw, err := gateway.New(storageInstanceSelector, storageBucket, registry, authReader, minio.NewClient, logger,
+		opts...,
 	)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
+	rebalancer, err := newRebalancer(gw)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if enabled, _ := strconv.ParseBool(os.Getenv("REBALANCE")); enabled {
+		go rebalancer.Run(context.Background())
+	}
+
 	gwHandler, err := restfulhandler.New(gw)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
+	// the admin API is not authenticated, it is only served if the address is set
+	if adminAddr := os.Getenv("ADMIN_ADDR"); adminAddr != "" {
+		adminHandler, err := restfulhandler.NewAdmin(gw, rebalancer, logger)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		go func() {
+			adminServer := &http.Server{
+				Addr:    adminAddr,
+				Handler: adminHandler,
+			}
+			if err := adminServer.ListenAndServe(); err != nil {
+				log.Fatalln(err)
+			}
+		}()
+	}
+
 	server := &http.Server{
 		Addr:         ":8000",
 		ReadTimeout:  -1,
@@ -62,3 +160,238 @@ func main() {
 		log.Fatalln(err)
 	}
 }
+
+func newPlacementStrategy(name string) (gateway.PlacementStrategy, error) {
+	switch name {
+	case "rendezvous":
+		return gateway.RendezvousPlacement{}, nil
+	case "maglev":
+		placement, err := gateway.NewMaglevPlacement(gateway.DefaultMaglevTableSize)
+		if err != nil {
+			return nil, err
+		}
+		return placement, nil
+	case "jump":
+		return gateway.JumpHashPlacement{}, nil
+	case "", "summodulo":
+		return gateway.SumModuloPlacement{}, nil
+	default:
+		return nil, errors.New("unknown placement strategy " + name)
+	}
+}
+
+// newDiscovery initialises the service registry and the authentication details reader of the given kind.
+func newDiscovery(kind, selector string, logger *slog.Logger) (
+	gateway.ServiceRegistryScanner, gateway.AuthenticationDetailsReader, error,
+) {
+	switch kind {
+	case "", "docker":
+		var opts []docker.Option
+		if v := os.Getenv("STORAGE_NETWORK"); v != "" {
+			opts = append(opts, docker.WithNetwork(v))
+		}
+
+		cl, err := docker.NewClient(opts...)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if watchEvents, err := strconv.ParseBool(os.Getenv("DOCKER_EVENTS")); err != nil || watchEvents {
+			go cl.Watch(context.Background(), selector, logger)
+		}
+
+		return cl, cl, nil
+
+	case "file":
+		cl, err := fileregistry.NewClient(os.Getenv("SERVICE_REGISTRY_FILE"))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		pollInterval, err := durationFromEnv("SERVICE_REGISTRY_FILE_POLL_INTERVAL", fileregistry.DefaultPollInterval)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		go cl.Watch(context.Background(), pollInterval, logger)
+
+		return cl, cl, nil
+
+	case "consul":
+		var opts []consul.Option
+		if v := os.Getenv("CONSUL_HTTP_TOKEN"); v != "" {
+			opts = append(opts, consul.WithToken(v))
+		}
+		if v := os.Getenv("CONSUL_KV_PREFIX"); v != "" {
+			opts = append(opts, consul.WithKVPrefix(v))
+		}
+
+		cl, err := consul.NewClient(os.Getenv("CONSUL_HTTP_ADDR"), opts...)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		go cl.Watch(context.Background(), selector, logger)
+
+		return cl, cl, nil
+
+	case "kubernetes":
+		var opts []kubernetes.Option
+		if v := os.Getenv("STORAGE_PORT_NAME"); v != "" {
+			opts = append(opts, kubernetes.WithPortName(v))
+		}
+
+		cl, err := kubernetes.NewInClusterClient(opts...)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		go cl.Watch(context.Background(), selector, logger)
+
+		return cl, cl, nil
+
+	case "dns":
+		var opts []dnsregistry.Option
+		if v := os.Getenv("DNS_STORAGE_PORT"); v != "" {
+			port, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, nil, err
+			}
+			opts = append(opts, dnsregistry.WithPort(port))
+		}
+
+		cl, err := dnsregistry.NewClient(os.Getenv("DNS_SERVER"), opts...)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return cl, staticCredentials{
+			accessKeyID:     os.Getenv("STORAGE_ACCESS_KEY"),
+			secretAccessKey: os.Getenv("STORAGE_SECRET_KEY"),
+		}, nil
+
+	default:
+		return nil, nil, errors.New("unknown service registry " + kind)
+	}
+}
+
+// newVaultClient initialises the credentials reader backed by Vault.
+func newVaultClient(logger *slog.Logger) (*vault.Client, error) {
+	var opts []vault.Option
+	if v := os.Getenv("VAULT_TOKEN"); v != "" {
+		opts = append(opts, vault.WithToken(v))
+	}
+	if v := os.Getenv("VAULT_ROLE_ID"); v != "" {
+		opts = append(opts, vault.WithAppRole(v, os.Getenv("VAULT_SECRET_ID")))
+	}
+	if v := os.Getenv("VAULT_KV_MOUNT"); v != "" {
+		opts = append(opts, vault.WithMount(v))
+	}
+	if v := os.Getenv("VAULT_KV_PATH_PREFIX"); v != "" {
+		opts = append(opts, vault.WithPathPrefix(v))
+	}
+
+	return vault.NewClient(os.Getenv("VAULT_ADDR"), logger, opts...)
+}
+
+// staticCredentials the credentials shared by all storage instances.
+type staticCredentials struct {
+	accessKeyID, secretAccessKey string
+}
+
+func (c staticCredentials) Read(context.Context, string) (string, string, error) {
+	if c.accessKeyID == "" || c.secretAccessKey == "" {
+		return "", "", errors.New("STORAGE_ACCESS_KEY and STORAGE_SECRET_KEY must be set")
+	}
+	return c.accessKeyID, c.secretAccessKey, nil
+}
+
+// newLocker initialises the Locker which stores the lock objects and the draining marks in the storage instance
+// LOCKER_INSTANCE_ID to serialize the writes across the gateways, and to share the drains. The writes are only
+// serialized by the gateway itself, and the draining marks are kept in its memory if it is not set.
+// The instance's endpoint is re-read from the service registry, and its credentials are re-read if the instance
+// rejects them, e.g. after the rotation. Note that the instance must not be removed from the cluster while
+// the gateways use it.
+func newLocker(
+	scanner gateway.ServiceRegistryScanner, authReader gateway.AuthenticationDetailsReader, selector string,
+	logger *slog.Logger,
+) (*minio.Locker, error) {
+	instanceID := os.Getenv("LOCKER_INSTANCE_ID")
+	if instanceID == "" {
+		return nil, nil
+	}
+
+	ttl, err := durationFromEnv("LOCKER_TTL", minio.DefaultLockTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	locker, err := minio.NewLocker(context.Background(), scanner, selector, instanceID, authReader, logger,
+		minio.WithLockTTL(ttl))
+	if err != nil {
+		return nil, err
+	}
+
+	return locker, nil
+}
+
+// newServiceRegistry wraps the service registry client to cache the membership snapshots.
+// The cache is disabled unless the refresh interval is set.
+func newServiceRegistry(scanner gateway.ServiceRegistryScanner, selector string, logger *slog.Logger) (
+	gateway.ServiceRegistryScanner, error,
+) {
+	refreshInterval, err := durationFromEnv("SERVICE_REGISTRY_REFRESH_INTERVAL", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if refreshInterval == 0 {
+		return scanner, nil
+	}
+
+	maxStaleness, err := durationFromEnv("SERVICE_REGISTRY_MAX_STALENESS", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	registry, err := gateway.NewCachedServiceRegistry(scanner, refreshInterval, maxStaleness, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	go registry.Run(context.Background(), selector)
+
+	return registry, nil
+}
+
+// newRebalancer initialises the rebalancer. It drains the instances on request,
+// and runs the rebalancing passes in background if it is enabled.
+func newRebalancer(gw *gateway.Gateway) (*gateway.Rebalancer, error) {
+	pollInterval, err := durationFromEnv("REBALANCE_INTERVAL", gateway.DefaultRebalanceInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	maxBytesPerSecond, err := intFromEnv("REBALANCE_MAX_BYTES_PER_SECOND", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return gateway.NewRebalancer(gw, pollInterval, int64(maxBytesPerSecond))
+}
+
+func durationFromEnv(name string, defaultValue time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return defaultValue, nil
+	}
+	return time.ParseDuration(v)
+}
+
+func intFromEnv(name string, defaultValue int) (int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(v)
+}
@@ -0,0 +1,257 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultCredentialsTTL the default time to cache the credentials read from the AuthenticationDetailsReader.
+const DefaultCredentialsTTL = 5 * time.Minute
+
+// DefaultWriteRetryBufferSize the default max number of bytes of the object's content buffered to retry the write.
+const DefaultWriteRetryBufferSize = 8 << 20
+
+// ErrWriteNotRetried indicates that the write rejected by the storage instance was not retried, because its content
+// exceeds the retry buffer, and the reader cannot be rewound.
+var ErrWriteNotRetried = errors.New("write cannot be retried, the content exceeds the retry buffer")
+
+// WithWriteRetryBufferSize sets the max number of bytes of the object's content buffered in memory to retry the write
+// after the authentication failure. The content is not buffered if the reader implements io.Seeker, and the writes
+// of the readers which cannot be rewound are not retried if zero is provided.
+func WithWriteRetryBufferSize(n int64) Option {
+	return func(g *Gateway) {
+		g.writeRetryBufferSize = n
+	}
+}
+
+// WithCredentialsTTL sets the time to cache the credentials read from the AuthenticationDetailsReader.
+// The credentials are not cached if zero is provided.
+func WithCredentialsTTL(ttl time.Duration) Option {
+	return func(g *Gateway) {
+		g.credentials = newCredentialsCache(ttl)
+	}
+}
+
+// credentialsCache caches the storage instances' credentials keyed by the instance ID.
+// Note that nil cache is valid, it does not cache credentials.
+type credentialsCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu          sync.Mutex
+	credentials map[string]cachedCredentials
+}
+
+type cachedCredentials struct {
+	accessKeyID, secretAccessKey string
+	expiresAt                    time.Time
+}
+
+func newCredentialsCache(ttl time.Duration) *credentialsCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &credentialsCache{
+		ttl:         ttl,
+		now:         time.Now,
+		credentials: map[string]cachedCredentials{},
+	}
+}
+
+// get returns the instance's credentials unless they expired.
+func (c *credentialsCache) get(instanceID string) (string, string, bool) {
+	if c == nil {
+		return "", "", false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	v, ok := c.credentials[instanceID]
+	if !ok {
+		return "", "", false
+	}
+
+	if !c.now().Before(v.expiresAt) {
+		delete(c.credentials, instanceID)
+		return "", "", false
+	}
+
+	return v.accessKeyID, v.secretAccessKey, true
+}
+
+func (c *credentialsCache) put(instanceID, accessKeyID, secretAccessKey string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.credentials[instanceID] = cachedCredentials{
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		expiresAt:       c.now().Add(c.ttl),
+	}
+}
+
+// invalidate removes the instance's credentials.
+func (c *credentialsCache) invalidate(instanceID string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.credentials, instanceID)
+}
+
+// retain evicts credentials of the instances which are not present in the cluster.
+func (c *credentialsCache) retain(instances map[string]string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for instanceID := range c.credentials {
+		if _, ok := instances[instanceID]; !ok {
+			delete(c.credentials, instanceID)
+		}
+	}
+}
+
+// readCredentials returns the cached credentials of the instance, or reads them from the AuthenticationDetailsReader.
+func (s *Gateway) readCredentials(ctx context.Context, instanceID string) (string, string, error) {
+	if accessKeyID, secretAccessKey, ok := s.credentials.get(instanceID); ok {
+		return accessKeyID, secretAccessKey, nil
+	}
+
+	accessKeyID, secretAccessKey, err := s.connectionDetailsReader.Read(ctx, instanceID)
+	if err != nil {
+		return "", "", err
+	}
+
+	s.credentials.put(instanceID, accessKeyID, secretAccessKey)
+
+	return accessKeyID, secretAccessKey, nil
+}
+
+// retryOnAuthenticationError runs the operation using the connection to the instance.
+// If the instance rejects the credentials, e.g. because they were rotated, the operation is retried once
+// using new connection established with the re-read credentials.
+// It returns the connection used by the last attempt.
+func (s *Gateway) retryOnAuthenticationError(
+	ctx context.Context, instanceID, endpoint string, conn ObjectReadWriteFinder,
+	operation func(conn ObjectReadWriteFinder) error,
+) (ObjectReadWriteFinder, error) {
+	return s.retryRewoundOnAuthenticationError(ctx, instanceID, endpoint, conn, operation, nil)
+}
+
+// retryRewoundOnAuthenticationError runs the operation the same way as retryOnAuthenticationError,
+// the rewind is called before the retry, e.g. to read the written content from the beginning.
+// The operation is not retried if the rewind fails.
+func (s *Gateway) retryRewoundOnAuthenticationError(
+	ctx context.Context, instanceID, endpoint string, conn ObjectReadWriteFinder,
+	operation func(conn ObjectReadWriteFinder) error, rewind func() error,
+) (ObjectReadWriteFinder, error) {
+	err := s.checkConnectionError(instanceID, operation(conn))
+	if !errors.Is(err, ErrAuthentication) {
+		return conn, err
+	}
+
+	if rewind != nil {
+		if rewindErr := rewind(); rewindErr != nil {
+			return conn, fmt.Errorf("%w: %w", rewindErr, err)
+		}
+	}
+
+	s.metrics.authenticationRetries.Add(1)
+	s.Logger.Info("credentials rejected, reconnecting",
+		slog.String("instanceID", instanceID),
+		slog.String("error", err.Error()),
+	)
+
+	conn, err = s.newStorageInstanceConnection(ctx, instanceID, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return conn, s.checkConnectionError(instanceID, operation(conn))
+}
+
+// writeObject writes the object to the instance. The write is retried after the authentication failure,
+// the reader is rewound if it implements io.Seeker, otherwise the content read by the failed write is replayed
+// from the buffer of up to writeRetryBufferSize bytes. The error wrapping ErrWriteNotRetried is returned
+// if the content exceeds the buffer.
+func (s *Gateway) writeObject(
+	ctx context.Context, instanceID, endpoint string, conn ObjectReadWriteFinder,
+	id string, reader io.Reader, objectSizeBytes int64,
+) error {
+	reader, rewind := s.newRewindableReader(reader, objectSizeBytes)
+	_, err := s.retryRewoundOnAuthenticationError(ctx, instanceID, endpoint, conn,
+		func(conn ObjectReadWriteFinder) error {
+			return conn.Write(ctx, s.storageBucket, id, reader, objectSizeBytes)
+		}, rewind)
+	return err
+}
+
+// newRewindableReader returns the reader which content can be read from the beginning again after the rewind.
+func (s *Gateway) newRewindableReader(reader io.Reader, objectSizeBytes int64) (io.Reader, func() error) {
+	if seeker, ok := reader.(io.Seeker); ok {
+		if start, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			return reader, func() error {
+				_, err := seeker.Seek(start, io.SeekStart)
+				return err
+			}
+		}
+	}
+
+	if s.writeRetryBufferSize <= 0 || objectSizeBytes > s.writeRetryBufferSize {
+		return reader, func() error { return ErrWriteNotRetried }
+	}
+
+	r := &replayReader{reader: reader, limit: s.writeRetryBufferSize}
+	return r, r.rewind
+}
+
+// replayReader buffers the content read from the reader up to the limit to read it again once it is rewound.
+type replayReader struct {
+	reader io.Reader
+	limit  int64
+	buf    bytes.Buffer
+	// done indicates that the content is not buffered anymore, because it exceeded the limit, or it was rewound.
+	done bool
+}
+
+func (r *replayReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if !r.done {
+		if int64(r.buf.Len()+n) > r.limit {
+			r.done = true
+			r.buf = bytes.Buffer{}
+		} else {
+			r.buf.Write(p[:n])
+		}
+	}
+	return n, err
+}
+
+// rewind replays the buffered content before the rest of the reader's content.
+// The reader can be rewound once, it returns ErrWriteNotRetried if the content exceeded the limit.
+func (r *replayReader) rewind() error {
+	if r.done {
+		return ErrWriteNotRetried
+	}
+	r.done = true
+	r.reader = io.MultiReader(bytes.NewReader(r.buf.Bytes()), r.reader)
+	return nil
+}
@@ -0,0 +1,421 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// rotatingCredentialsReader returns the current secret key, and counts the reads.
+type rotatingCredentialsReader struct {
+	mu        sync.Mutex
+	secretKey string
+	reads     atomic.Int64
+}
+
+func (r *rotatingCredentialsReader) Read(context.Context, string) (string, string, error) {
+	r.reads.Add(1)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return "foo", r.secretKey, nil
+}
+
+func (r *rotatingCredentialsReader) rotate(secretKey string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.secretKey = secretKey
+}
+
+// invalidatingCredentialsReader caches the credentials until they are invalidated.
+type invalidatingCredentialsReader struct {
+	*rotatingCredentialsReader
+	cached      string
+	invalidated []string
+}
+
+func (r *invalidatingCredentialsReader) Read(ctx context.Context, instanceID string) (string, string, error) {
+	r.mu.Lock()
+	cached := r.cached
+	r.mu.Unlock()
+	if cached != "" {
+		return "foo", cached, nil
+	}
+
+	accessKeyID, secretKey, err := r.rotatingCredentialsReader.Read(ctx, instanceID)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cached = secretKey
+	return accessKeyID, secretKey, err
+}
+
+func (r *invalidatingCredentialsReader) Invalidate(instanceID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cached = ""
+	r.invalidated = append(r.invalidated, instanceID)
+}
+
+// authenticatingStorage emulates the storage instance which accepts the single secret key.
+type authenticatingStorage struct {
+	mu        sync.Mutex
+	secretKey string
+	data      []byte
+	writes    int
+}
+
+func (s *authenticatingStorage) rotate(secretKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secretKey = secretKey
+}
+
+func (s *authenticatingStorage) connectionFn(cnt *atomic.Int64) StorageConnectionFn {
+	return func(_, _, secretAccessKey string) (ObjectReadWriteFinder, error) {
+		cnt.Add(1)
+		return &authenticatingStorageClient{storage: s, secretKey: secretAccessKey}, nil
+	}
+}
+
+type authenticatingStorageClient struct {
+	storage   *authenticatingStorage
+	secretKey string
+}
+
+func (c *authenticatingStorageClient) authenticate() error {
+	c.storage.mu.Lock()
+	defer c.storage.mu.Unlock()
+	if c.secretKey != c.storage.secretKey {
+		return fmt.Errorf("%w: signature does not match", ErrAuthentication)
+	}
+	return nil
+}
+
+func (c *authenticatingStorageClient) Read(context.Context, string, string, int64, int64) (io.ReadCloser, bool, error) {
+	if err := c.authenticate(); err != nil {
+		return nil, false, err
+	}
+	c.storage.mu.Lock()
+	defer c.storage.mu.Unlock()
+	return io.NopCloser(bytes.NewReader(c.storage.data)), c.storage.data != nil, nil
+}
+
+func (c *authenticatingStorageClient) Write(_ context.Context, _, _ string, reader io.Reader, _ int64) error {
+	c.storage.mu.Lock()
+	c.storage.writes++
+	c.storage.mu.Unlock()
+
+	// the rejected request consumes the part of the body
+	if err := c.authenticate(); err != nil {
+		_, _ = io.CopyN(io.Discard, reader, 1)
+		return err
+	}
+
+	v, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	c.storage.mu.Lock()
+	defer c.storage.mu.Unlock()
+	c.storage.data = v
+	return nil
+}
+
+func (c *authenticatingStorageClient) Find(context.Context, string, string) (bool, error) {
+	if err := c.authenticate(); err != nil {
+		return false, err
+	}
+	c.storage.mu.Lock()
+	defer c.storage.mu.Unlock()
+	return c.storage.data != nil, nil
+}
+
+func (c *authenticatingStorageClient) Delete(context.Context, string, string) error {
+	return c.authenticate()
+}
+
+func (c *authenticatingStorageClient) Stat(context.Context, string, string) (ObjectInfo, bool, error) {
+	found, err := c.Find(context.Background(), "", "")
+	return ObjectInfo{}, found, err
+}
+
+func (c *authenticatingStorageClient) List(context.Context, string, string, string, int) ([]ObjectInfo, error) {
+	return nil, c.authenticate()
+}
+
+func newRotationTestGateway(storage *authenticatingStorage, reader *rotatingCredentialsReader, cnt *atomic.Int64) *Gateway {
+	gateway := newMockGateway()
+	gateway.connections = newConnectionPool(time.Minute)
+	gateway.credentials = newCredentialsCache(time.Minute)
+	gateway.writeRetryBufferSize = DefaultWriteRetryBufferSize
+	gateway.connectionDetailsReader = reader
+	gateway.newStorageConnectionFn = storage.connectionFn(cnt)
+	return gateway
+}
+
+func TestGateway_credentialsRotation(t *testing.T) {
+	t.Parallel()
+
+	const inputID = "obj"
+
+	t.Run("shall cache the credentials", func(t *testing.T) {
+		// GIVEN
+		var cntConnections atomic.Int64
+		storage := &authenticatingStorage{secretKey: "bar", data: []byte("qux")}
+		reader := &rotatingCredentialsReader{secretKey: "bar"}
+		gateway := newRotationTestGateway(storage, reader, &cntConnections)
+		gateway.connections = nil
+
+		// WHEN
+		for i := 0; i < 3; i++ {
+			if _, _, err := gateway.Read(context.TODO(), inputID, 0, -1); err != nil {
+				t.Fatalf("no error expected, got: %v", err)
+			}
+		}
+
+		// THEN
+		if got := reader.reads.Load(); got != 1 {
+			t.Errorf("single credentials read expected, got: %d", got)
+		}
+	})
+
+	t.Run("shall re-read the credentials and retry after the rotation", func(t *testing.T) {
+		// GIVEN
+		var cntConnections atomic.Int64
+		storage := &authenticatingStorage{secretKey: "bar", data: []byte("qux")}
+		reader := &rotatingCredentialsReader{secretKey: "bar"}
+		gateway := newRotationTestGateway(storage, reader, &cntConnections)
+
+		if _, _, err := gateway.Read(context.TODO(), inputID, 0, -1); err != nil {
+			t.Fatal(err)
+		}
+
+		// WHEN
+		storage.rotate("baz")
+		reader.rotate("baz")
+		r, found, err := gateway.Read(context.TODO(), inputID, 0, -1)
+
+		// THEN
+		if err != nil || !found {
+			t.Fatalf("the object is expected to be read, got error: %v", err)
+		}
+		if v, _ := io.ReadAll(r); string(v) != "qux" {
+			t.Errorf("unexpected content: %s", v)
+		}
+		if got := reader.reads.Load(); got != 2 {
+			t.Errorf("two credentials reads expected, got: %d", got)
+		}
+		if got := cntConnections.Load(); got != 2 {
+			t.Errorf("two connections expected, got: %d", got)
+		}
+		if got := gateway.Metrics().AuthenticationRetries; got != 1 {
+			t.Errorf("single retry expected, got: %d", got)
+		}
+	})
+
+	t.Run("shall invalidate the credentials cached by the reader to retry after the rotation", func(t *testing.T) {
+		// GIVEN
+		var cntConnections atomic.Int64
+		storage := &authenticatingStorage{secretKey: "bar", data: []byte("qux")}
+		reader := &invalidatingCredentialsReader{rotatingCredentialsReader: &rotatingCredentialsReader{secretKey: "bar"}}
+		gateway := newRotationTestGateway(storage, reader.rotatingCredentialsReader, &cntConnections)
+		gateway.connectionDetailsReader = reader
+
+		if _, _, err := gateway.Read(context.TODO(), inputID, 0, -1); err != nil {
+			t.Fatal(err)
+		}
+
+		// WHEN
+		storage.rotate("baz")
+		reader.rotate("baz")
+		_, found, err := gateway.Read(context.TODO(), inputID, 0, -1)
+
+		// THEN
+		if err != nil || !found {
+			t.Fatalf("the object is expected to be read, got error: %v", err)
+		}
+		if want := []string{mockClusterPrefix + "-0"}; !reflect.DeepEqual(reader.invalidated, want) {
+			t.Errorf("the credentials of the instance %v are expected to be invalidated, got: %v", want,
+				reader.invalidated)
+		}
+		if got := reader.reads.Load(); got != 2 {
+			t.Errorf("two credentials reads expected, got: %d", got)
+		}
+	})
+
+	t.Run("shall retry only once", func(t *testing.T) {
+		// GIVEN
+		var cntConnections atomic.Int64
+		storage := &authenticatingStorage{secretKey: "baz", data: []byte("qux")}
+		reader := &rotatingCredentialsReader{secretKey: "bar"}
+		gateway := newRotationTestGateway(storage, reader, &cntConnections)
+
+		// WHEN
+		_, err := gateway.Delete(context.TODO(), inputID)
+
+		// THEN
+		if err == nil {
+			t.Errorf("error expected")
+		}
+		if got := cntConnections.Load(); got != 2 {
+			t.Errorf("two connections expected, got: %d", got)
+		}
+		if _, _, ok := gateway.credentials.get(mockClusterPrefix + "-0"); ok {
+			t.Errorf("the rejected credentials are expected to be evicted")
+		}
+	})
+
+	t.Run("shall rewind the seekable reader to retry the write", func(t *testing.T) {
+		// GIVEN
+		var cntConnections atomic.Int64
+		storage := &authenticatingStorage{secretKey: "baz"}
+		reader := &rotatingCredentialsReader{secretKey: "baz"}
+		gateway := newRotationTestGateway(storage, reader, &cntConnections)
+		staleConn := &authenticatingStorageClient{storage: storage, secretKey: "bar"}
+
+		// WHEN
+		err := gateway.writeObject(context.TODO(), mockClusterPrefix+"-0", "192.0.2.10", staleConn,
+			inputID, strings.NewReader("qux"), 3)
+
+		// THEN
+		if err != nil {
+			t.Fatalf("no error expected, got: %v", err)
+		}
+		if string(storage.data) != "qux" {
+			t.Errorf("the complete content is expected to be written, got: %s", storage.data)
+		}
+		if storage.writes != 2 {
+			t.Errorf("two write attempts expected, got: %d", storage.writes)
+		}
+	})
+
+	t.Run("shall replay the buffered content to retry the write", func(t *testing.T) {
+		// GIVEN
+		var cntConnections atomic.Int64
+		storage := &authenticatingStorage{secretKey: "baz"}
+		reader := &rotatingCredentialsReader{secretKey: "baz"}
+		gateway := newRotationTestGateway(storage, reader, &cntConnections)
+
+		// WHEN
+		err := gateway.writeObject(context.TODO(), mockClusterPrefix+"-0", "192.0.2.10",
+			&authenticatingStorageClient{storage: storage, secretKey: "bar"}, inputID,
+			io.MultiReader(strings.NewReader("qux")), -1)
+
+		// THEN
+		if err != nil {
+			t.Fatalf("no error expected, got: %v", err)
+		}
+		if string(storage.data) != "qux" {
+			t.Errorf("the complete content is expected to be written, got: %s", storage.data)
+		}
+		if storage.writes != 2 {
+			t.Errorf("two write attempts expected, got: %d", storage.writes)
+		}
+	})
+
+	for name, objectSizeBytes := range map[string]int64{"known size": 3, "unknown size": -1} {
+		objectSizeBytes := objectSizeBytes
+		t.Run("shall not retry the write which content exceeds the buffer given "+name, func(t *testing.T) {
+			// GIVEN
+			var cntConnections atomic.Int64
+			storage := &authenticatingStorage{secretKey: "baz"}
+			reader := &rotatingCredentialsReader{secretKey: "baz"}
+			gateway := newRotationTestGateway(storage, reader, &cntConnections)
+			gateway.writeRetryBufferSize = 0
+
+			// WHEN
+			err := gateway.writeObject(context.TODO(), mockClusterPrefix+"-0", "192.0.2.10",
+				&authenticatingStorageClient{storage: storage, secretKey: "bar"}, inputID,
+				io.MultiReader(strings.NewReader("qux")), objectSizeBytes)
+
+			// THEN
+			if !errors.Is(err, ErrWriteNotRetried) || !errors.Is(err, ErrAuthentication) {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if storage.writes != 1 {
+				t.Errorf("single write attempt expected, got: %d", storage.writes)
+			}
+			if got := gateway.Metrics().AuthenticationRetries; got != 0 {
+				t.Errorf("no retries expected, got: %d", got)
+			}
+		})
+	}
+}
+
+func Test_replayReader(t *testing.T) {
+	t.Run("shall replay the content read before the rewind", func(t *testing.T) {
+		// GIVEN
+		r := &replayReader{reader: strings.NewReader("foobar"), limit: 3}
+		_, _ = io.ReadFull(r, make([]byte, 3))
+
+		// WHEN
+		err := r.rewind()
+
+		// THEN
+		if err != nil {
+			t.Fatalf("no error expected, got: %v", err)
+		}
+		if got, _ := io.ReadAll(r); string(got) != "foobar" {
+			t.Errorf("the complete content is expected to be read, got: %s", got)
+		}
+		if err := r.rewind(); !errors.Is(err, ErrWriteNotRetried) {
+			t.Errorf("the reader is expected to be rewound once, got: %v", err)
+		}
+	})
+
+	t.Run("shall not rewind once the content exceeded the limit", func(t *testing.T) {
+		// GIVEN
+		r := &replayReader{reader: strings.NewReader("foobar"), limit: 3}
+		_, _ = io.ReadFull(r, make([]byte, 4))
+
+		// WHEN
+		err := r.rewind()
+
+		// THEN
+		if !errors.Is(err, ErrWriteNotRetried) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func Test_credentialsCache(t *testing.T) {
+	const instanceID = "node0"
+
+	start := time.Date(2023, 10, 21, 7, 28, 0, 0, time.UTC)
+	now := start
+
+	c := newCredentialsCache(time.Minute)
+	c.now = func() time.Time { return now }
+	c.put(instanceID, "foo", "bar")
+
+	t.Run("shall return the credentials before they expire", func(t *testing.T) {
+		now = start.Add(59 * time.Second)
+		if accessKeyID, secretAccessKey, ok := c.get(instanceID); !ok || accessKeyID != "foo" || secretAccessKey != "bar" {
+			t.Errorf("cached credentials expected")
+		}
+	})
+
+	t.Run("shall evict the expired credentials", func(t *testing.T) {
+		now = start.Add(time.Minute)
+		if _, _, ok := c.get(instanceID); ok {
+			t.Errorf("the credentials are expected to expire")
+		}
+	})
+
+	t.Run("shall not cache the credentials given zero TTL", func(t *testing.T) {
+		var nilCache = newCredentialsCache(0)
+		nilCache.put(instanceID, "foo", "bar")
+		if _, _, ok := nilCache.get(instanceID); ok {
+			t.Errorf("no credentials expected")
+		}
+	})
+}
@@ -0,0 +1,394 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInstanceNotFound indicates that the instance is not found in the cluster.
+	ErrInstanceNotFound = errors.New("instance not found")
+	// ErrDrainInProgress indicates that the instance is being drained already.
+	ErrDrainInProgress = errors.New("instance is being drained")
+	// ErrNoPlacementInstances indicates that no instances are left to store new objects.
+	ErrNoPlacementInstances = errors.New("not enough instances to store new objects")
+)
+
+// DefaultDrainMarksRefreshInterval the default interval to re-read the draining marks from the DrainMarks.
+const DefaultDrainMarksRefreshInterval = 5 * time.Second
+
+// DrainMarks defines the port to store the draining marks shared by the gateways, e.g. by the gateways behind
+// the load balancer, the marks shall be kept when the gateways restart.
+type DrainMarks interface {
+	// Draining returns the IDs of the instances marked as draining.
+	Draining(ctx context.Context) ([]string, error)
+	// SetDraining marks the instance as draining, or clears the mark.
+	SetDraining(ctx context.Context, instanceID string, draining bool) error
+}
+
+// WithDrainMarks sets the DrainMarks to share the draining marks across the gateways. The marks are re-read
+// at the refresh interval when the cluster membership is read, and on every read of the membership
+// if zero is provided. The marks are only kept in memory of the Gateway by default.
+func WithDrainMarks(marks DrainMarks, refreshInterval time.Duration) Option {
+	return func(g *Gateway) {
+		g.drainMarks = &drainMarksCache{marks: marks, refreshInterval: refreshInterval, now: time.Now}
+	}
+}
+
+// MemoryDrainMarks implements DrainMarks in memory, i.e. it shares the marks between the gateways
+// running in the same process, e.g. in tests. The zero value is ready to use.
+type MemoryDrainMarks struct {
+	mu        sync.Mutex
+	instances map[string]struct{}
+}
+
+// Draining returns the sorted IDs of the instances marked as draining.
+func (m *MemoryDrainMarks) Draining(context.Context) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	o := make([]string, 0, len(m.instances))
+	for instanceID := range m.instances {
+		o = append(o, instanceID)
+	}
+	sort.Strings(o)
+	return o, nil
+}
+
+// SetDraining marks the instance as draining, or clears the mark.
+func (m *MemoryDrainMarks) SetDraining(_ context.Context, instanceID string, draining bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !draining {
+		delete(m.instances, instanceID)
+		return nil
+	}
+
+	if m.instances == nil {
+		m.instances = map[string]struct{}{}
+	}
+	m.instances[instanceID] = struct{}{}
+	return nil
+}
+
+// drainMarksCache defines the state of the draining marks read from the DrainMarks.
+type drainMarksCache struct {
+	marks           DrainMarks
+	refreshInterval time.Duration
+	now             func() time.Time
+
+	// mu serializes the refreshes with the changes of the marks.
+	mu     sync.Mutex
+	readAt time.Time
+}
+
+// SetDraining marks the instance as draining, or clears the mark. The draining instance is excluded from
+// the placement of new objects, the objects stored in it can be read.
+// The mark is stored using the DrainMarks if it is set, otherwise it is kept in memory of the Gateway.
+func (s *Gateway) SetDraining(ctx context.Context, instanceID string, draining bool) error {
+	if c := s.drainMarks; c != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if err := c.marks.SetDraining(ctx, instanceID, draining); err != nil {
+			return fmt.Errorf("cannot set the draining mark of the instance %s: %w", instanceID, err)
+		}
+	}
+
+	if draining {
+		s.draining.Store(instanceID, struct{}{})
+		return nil
+	}
+	s.draining.Delete(instanceID)
+	return nil
+}
+
+// DrainingInstances returns the sorted IDs of the draining instances.
+func (s *Gateway) DrainingInstances() []string {
+	var o []string
+	s.draining.Range(func(key, _ any) bool {
+		o = append(o, key.(string))
+		return true
+	})
+	sort.Strings(o)
+	return o
+}
+
+// refreshDrainingInstances re-reads the draining marks from the DrainMarks once the refresh interval passed
+// since the previous read. The previous marks are kept until the next refresh if the marks cannot be read.
+func (s *Gateway) refreshDrainingInstances(ctx context.Context) {
+	c := s.drainMarks
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if !c.readAt.IsZero() && now.Sub(c.readAt) < c.refreshInterval {
+		return
+	}
+	c.readAt = now
+
+	instances, err := c.marks.Draining(ctx)
+	if err != nil {
+		s.Logger.Warn("failed to read the draining marks, the previous marks are used",
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	marked := make(map[string]struct{}, len(instances))
+	for _, instanceID := range instances {
+		marked[instanceID] = struct{}{}
+		s.draining.Store(instanceID, struct{}{})
+	}
+	s.draining.Range(func(key, _ any) bool {
+		if _, ok := marked[key.(string)]; !ok {
+			s.draining.Delete(key)
+		}
+		return true
+	})
+}
+
+func (s *Gateway) isDraining(instanceID string) bool {
+	_, ok := s.draining.Load(instanceID)
+	return ok
+}
+
+// placementInstances returns the instances which can be selected by the placement strategy,
+// i.e. the instances which are not draining.
+func (s *Gateway) placementInstances(instances map[string]string) map[string]string {
+	o := make(map[string]string, len(instances))
+	for instanceID, endpoint := range instances {
+		if !s.isDraining(instanceID) {
+			o[instanceID] = endpoint
+		}
+	}
+	return o
+}
+
+// minPlacementInstances the number of instances required to store the object with all its replicas, or shards.
+func (s *Gateway) minPlacementInstances() int {
+	if s.erasure != nil {
+		return s.erasure.dataShards + s.erasure.parityShards
+	}
+	return max(s.replicationFactor, 1)
+}
+
+// DrainStatus defines the progress of the instance's drain.
+type DrainStatus struct {
+	InstanceID string
+	// Drained indicates that the instance stores no objects, and can be stopped.
+	Drained bool
+	// StartedAt the time the drain started.
+	StartedAt time.Time
+	// FinishedAt the time the instance was drained, zero if the drain is in progress.
+	FinishedAt time.Time
+	// RemainingObjects the number of objects, including the shards and the manifests of the erasure coded objects,
+	// stored in the instance at the last check.
+	RemainingObjects uint64
+	// MovedObjects the number of objects moved to the rest of the instances.
+	MovedObjects uint64
+	// MovedBytes the number of bytes copied.
+	MovedBytes uint64
+	// FailedObjects the number of failed attempts to move the objects.
+	FailedObjects uint64
+	// LastError the last error of the drain.
+	LastError string
+}
+
+// drain defines the instance's drain running in background.
+type drain struct {
+	status DrainStatus
+	stats  *transferStats
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Drain marks the instance as draining, and moves its objects to the instances selected by the placement strategy
+// in background until the instance is empty. The drain is repeated at the poll interval if any object failed
+// to be moved. The instance stays draining after it was drained until the drain is cancelled.
+// The instance marked as draining by another gateway sharing the DrainMarks, or before the restart,
+// is drained by the Rebalancer as well.
+func (r *Rebalancer) Drain(ctx context.Context, instanceID string) error {
+	instances, err := r.gw.scanStorageInstances(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := instances[instanceID]; !ok {
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, instanceID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.drains[instanceID]; ok {
+		return fmt.Errorf("%w: %s", ErrDrainInProgress, instanceID)
+	}
+
+	resumed := r.gw.isDraining(instanceID)
+
+	placementInstances := r.gw.placementInstances(instances)
+	delete(placementInstances, instanceID)
+	if required := r.gw.minPlacementInstances(); len(placementInstances) < required {
+		return fmt.Errorf("%w: %d instances are left after the drain, %d required",
+			ErrNoPlacementInstances, len(placementInstances), required)
+	}
+
+	if err := r.gw.SetDraining(ctx, instanceID, true); err != nil {
+		return err
+	}
+
+	// the drain shall not be cancelled when the request is over
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	d := &drain{
+		status: DrainStatus{InstanceID: instanceID, StartedAt: r.now()},
+		stats:  &transferStats{},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	if r.drains == nil {
+		r.drains = map[string]*drain{}
+	}
+	r.drains[instanceID] = d
+
+	if resumed {
+		r.logger.Info("drain resumed", slog.String("instanceID", instanceID))
+	} else {
+		r.logger.Info("drain started", slog.String("instanceID", instanceID))
+	}
+
+	go r.runDrain(ctx, instanceID, d)
+
+	return nil
+}
+
+// CancelDrain stops the instance's drain, and clears the draining mark, including the mark set by another gateway
+// sharing the DrainMarks. The objects moved already are kept in the instances they were moved to.
+// It returns false if the instance is not draining.
+func (r *Rebalancer) CancelDrain(ctx context.Context, instanceID string) (bool, error) {
+	r.gw.refreshDrainingInstances(ctx)
+
+	r.mu.Lock()
+	d, ok := r.drains[instanceID]
+	delete(r.drains, instanceID)
+	r.mu.Unlock()
+
+	if ok {
+		d.cancel()
+		<-d.done
+	} else if !r.gw.isDraining(instanceID) {
+		return false, nil
+	}
+
+	if err := r.gw.SetDraining(ctx, instanceID, false); err != nil {
+		return false, err
+	}
+	r.logger.Info("drain cancelled", slog.String("instanceID", instanceID))
+
+	return true, nil
+}
+
+// DrainStatus returns the progress of the instance's drain. It returns false if the instance is not draining.
+// The progress is only reported by the Rebalancer running the drain, the status of the instance marked
+// as draining by another gateway, or before the restart, only defines its ID.
+func (r *Rebalancer) DrainStatus(instanceID string) (DrainStatus, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, ok := r.drains[instanceID]
+	if !ok {
+		if r.gw.isDraining(instanceID) {
+			return DrainStatus{InstanceID: instanceID}, true
+		}
+		return DrainStatus{}, false
+	}
+
+	o := d.status
+	c := d.stats.counters()
+	o.MovedObjects, o.MovedBytes, o.FailedObjects = c.moved, c.movedBytes, c.failed
+	if o.LastError == "" {
+		o.LastError = c.lastError
+	}
+
+	return o, true
+}
+
+// runDrain moves the objects of the draining instance until the instance is empty, or the context is cancelled.
+func (r *Rebalancer) runDrain(ctx context.Context, instanceID string, d *drain) {
+	defer close(d.done)
+
+	for {
+		remaining, err := r.drainOnce(ctx, instanceID, d.stats)
+		if ctx.Err() != nil {
+			return
+		}
+
+		r.mu.Lock()
+		d.status.RemainingObjects = uint64(remaining)
+		d.status.LastError = ""
+		if err != nil {
+			d.status.LastError = err.Error()
+		}
+		if err == nil && remaining == 0 {
+			d.status.Drained = true
+			d.status.FinishedAt = r.now()
+		}
+		r.mu.Unlock()
+
+		if err == nil && remaining == 0 {
+			r.logger.Info("instance drained", slog.String("instanceID", instanceID))
+			return
+		}
+
+		r.logger.Warn("instance is not drained, the drain will be repeated",
+			slog.String("instanceID", instanceID),
+			slog.Int("remainingObjects", remaining),
+		)
+
+		if err := sleepContext(ctx, r.pollInterval); err != nil {
+			return
+		}
+	}
+}
+
+// drainOnce moves the objects of the draining instance, and returns the number of objects left in the instance.
+func (r *Rebalancer) drainOnce(ctx context.Context, instanceID string, stats *transferStats) (int, error) {
+	instances, err := r.gw.scanStorageInstances(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, ok := instances[instanceID]; !ok {
+		return 0, fmt.Errorf("%w: %s left the cluster before it was drained", ErrInstanceNotFound, instanceID)
+	}
+
+	// the drain does not run concurrently with the rebalancing pass which moves the same objects
+	r.runMu.Lock()
+	err = r.rebalanceInstance(ctx, instances, instanceID, stats)
+	r.runMu.Unlock()
+
+	var remaining int
+	for startAfter := ""; ; {
+		objects, listErr := r.gw.listInstance(ctx, instanceID, instances[instanceID], "", startAfter,
+			rebalanceListPageSize)
+		if listErr != nil {
+			return remaining, errors.Join(err, listErr)
+		}
+
+		remaining += len(objects)
+		if len(objects) < rebalanceListPageSize {
+			return remaining, err
+		}
+		startAfter = objects[len(objects)-1].ID
+	}
+}
@@ -0,0 +1,430 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// waitDrained waits until the instance is drained, or the timeout is reached.
+func waitDrained(t *testing.T, r *Rebalancer, instanceID string) DrainStatus {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, ok := r.DrainStatus(instanceID)
+		if !ok {
+			t.Fatalf("the instance %s is expected to be draining", instanceID)
+		}
+		if status.Drained {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the instance %s is not drained: %#v", instanceID, status)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func setError(client *memoryStorageClient, err error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.err = err
+}
+
+func TestRebalancer_Drain(t *testing.T) {
+	t.Parallel()
+
+	const cntObjects = 100
+
+	newRebalancer := func(t *testing.T, gateway *Gateway) *Rebalancer {
+		t.Helper()
+
+		r, err := NewRebalancer(gateway, time.Millisecond, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	t.Run("shall move all objects from the draining instance", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, cluster := newMemoryCluster(4)
+		objects := writeObjects(t, gateway, cntObjects)
+		wantMoved := len(cluster.client("node1").names())
+		r := newRebalancer(t, gateway)
+
+		// WHEN
+		err := r.Drain(context.TODO(), "node1")
+
+		// THEN
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		status := waitDrained(t, r, "node1")
+		if got := cluster.client("node1").names(); len(got) > 0 {
+			t.Errorf("the drained instance is expected to be empty, got: %v", got)
+		}
+		if status.RemainingObjects != 0 || status.MovedObjects != uint64(wantMoved) || status.FinishedAt.IsZero() {
+			t.Errorf("unexpected status, %d objects are expected to be moved, got: %#v", wantMoved, status)
+		}
+		readObjects(t, gateway, objects)
+
+		newObjects := writeObjects(t, gateway, 2*cntObjects)
+		if got := cluster.client("node1").names(); len(got) > 0 {
+			t.Errorf("new objects are not expected to be written to the draining instance, got: %v", got)
+		}
+		readObjects(t, gateway, newObjects)
+	})
+
+	t.Run("shall move the erasure coded objects from the draining instance", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, cluster := newMemoryCluster(5)
+		gateway.erasure = &erasureCoding{dataShards: 2, parityShards: 1}
+		objects := writeObjects(t, gateway, cntObjects)
+		r := newRebalancer(t, gateway)
+
+		// WHEN
+		err := r.Drain(context.TODO(), "node0")
+
+		// THEN
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_ = waitDrained(t, r, "node0")
+		if got := cluster.client("node0").names(); len(got) > 0 {
+			t.Errorf("the drained instance is expected to be empty, got: %v", got)
+		}
+		for id := range objects {
+			if shards := shardInstances(gateway, id); slices.Contains(shards, "node0") {
+				t.Errorf("the draining instance is not expected to be selected for the shards of %s", id)
+			}
+		}
+		readObjects(t, gateway, objects)
+	})
+
+	t.Run("shall repeat the drain until all objects are moved", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, cluster := newMemoryCluster(3)
+		objects := writeObjects(t, gateway, cntObjects)
+		setError(cluster.client("node2"), errors.New("unavailable"))
+		r := newRebalancer(t, gateway)
+
+		// WHEN
+		if err := r.Drain(context.TODO(), "node0"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var status DrainStatus
+		for deadline := time.Now().Add(5 * time.Second); status.FailedObjects == 0; {
+			if time.Now().After(deadline) {
+				t.Fatalf("the objects are expected to fail to be moved")
+			}
+			status, _ = r.DrainStatus("node0")
+			time.Sleep(time.Millisecond)
+		}
+		setError(cluster.client("node2"), nil)
+
+		// THEN
+		if status.Drained {
+			t.Errorf("the instance is not expected to be drained while the objects fail to be moved")
+		}
+		_ = waitDrained(t, r, "node0")
+		readObjects(t, gateway, objects)
+	})
+
+	t.Run("shall clear the draining mark when the drain is cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, _ := newMemoryCluster(3)
+		r := newRebalancer(t, gateway)
+		if err := r.Drain(context.TODO(), "node0"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// WHEN
+		cancelled, err := r.CancelDrain(context.TODO(), "node0")
+
+		// THEN
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !cancelled {
+			t.Errorf("the drain is expected to be cancelled")
+		}
+		if got := gateway.DrainingInstances(); len(got) > 0 {
+			t.Errorf("no draining instances expected, got: %v", got)
+		}
+		if _, ok := r.DrainStatus("node0"); ok {
+			t.Errorf("the drain's status is not expected to be found")
+		}
+		if cancelled, _ := r.CancelDrain(context.TODO(), "node0"); cancelled {
+			t.Errorf("the cancelled drain is not expected to be cancelled again")
+		}
+	})
+
+	t.Run("shall reject the drain", func(t *testing.T) {
+		t.Parallel()
+
+		tests := []struct {
+			name        string
+			erasure     *erasureCoding
+			drained     []string
+			instanceID  string
+			wantErr     error
+			wantDrained []string
+		}{
+			{
+				name:        "unknown instance",
+				instanceID:  "node9",
+				wantErr:     ErrInstanceNotFound,
+				wantDrained: []string{},
+			},
+			{
+				name:        "drain in progress",
+				drained:     []string{"node0"},
+				instanceID:  "node0",
+				wantErr:     ErrDrainInProgress,
+				wantDrained: []string{"node0"},
+			},
+			{
+				name:        "not enough instances for the shards",
+				erasure:     &erasureCoding{dataShards: 1, parityShards: 1},
+				drained:     []string{"node0"},
+				instanceID:  "node1",
+				wantErr:     ErrNoPlacementInstances,
+				wantDrained: []string{"node0"},
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				// GIVEN
+				gateway, _ := newMemoryCluster(3)
+				gateway.erasure = tt.erasure
+				r := newRebalancer(t, gateway)
+				for _, instanceID := range tt.drained {
+					if err := r.Drain(context.TODO(), instanceID); err != nil {
+						t.Fatalf("unexpected error: %v", err)
+					}
+				}
+
+				// WHEN
+				err := r.Drain(context.TODO(), tt.instanceID)
+
+				// THEN
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("unexpected error want: %v, got: %v", tt.wantErr, err)
+				}
+				if got := gateway.DrainingInstances(); !slices.Equal(got, tt.wantDrained) {
+					t.Errorf("unexpected draining instances want: %v, got: %v", tt.wantDrained, got)
+				}
+			})
+		}
+	})
+}
+
+func TestGateway_Write_draining(t *testing.T) {
+	// GIVEN
+	const objectID = "obj"
+	gateway, cluster := newMemoryCluster(3)
+	instances, _ := cluster.Scan(context.TODO(), "")
+	home := pickStorageInstance(gateway.placement, instances, objectID)
+	_ = cluster.client(home).Write(context.TODO(), "", objectID, bytes.NewReader([]byte("foo")), 3)
+	_ = gateway.SetDraining(context.TODO(), home, true)
+
+	// WHEN
+	err := gateway.Write(context.TODO(), objectID, bytes.NewReader([]byte("bar")), 3)
+
+	// THEN
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	locations := cluster.locate(objectID)
+	if len(locations) != 1 || locations[0] == home {
+		t.Fatalf("the object is expected to be moved outside the draining instance, got: %v", locations)
+	}
+	readObjects(t, gateway, map[string][]byte{objectID: []byte("bar")})
+
+	for _, instanceID := range []string{"node0", "node1", "node2"} {
+		_ = gateway.SetDraining(context.TODO(), instanceID, true)
+	}
+	if err := gateway.Write(context.TODO(), "qux", bytes.NewReader(nil), 0); !errors.Is(err, ErrNoPlacementInstances) {
+		t.Errorf("the write is expected to fail if all instances are draining, got: %v", err)
+	}
+}
+
+type mockDrainMarks struct {
+	err error
+}
+
+func (m mockDrainMarks) Draining(context.Context) ([]string, error) {
+	return nil, m.err
+}
+
+func (m mockDrainMarks) SetDraining(context.Context, string, bool) error {
+	return m.err
+}
+
+func TestRebalancer_Drain_sharedMarks(t *testing.T) {
+	t.Parallel()
+
+	// newGateway returns the gateway reading the draining marks on every read of the cluster membership.
+	newGateway := func(t *testing.T, cluster *memoryCluster, marks DrainMarks) (*Gateway, *Rebalancer) {
+		t.Helper()
+
+		gateway, _ := newMemoryCluster(0)
+		gateway.serviceRegistryClient = cluster
+		gateway.newStorageConnectionFn = cluster.connect
+		WithDrainMarks(marks, 0)(gateway)
+
+		r, err := NewRebalancer(gateway, time.Millisecond, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return gateway, r
+	}
+
+	t.Run("shall exclude the instance drained by another gateway from the placement", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		marks := &MemoryDrainMarks{}
+		gateway, cluster := newMemoryCluster(3)
+		_, r := newGateway(t, cluster, marks)
+		anotherGateway, anotherRebalancer := newGateway(t, cluster, marks)
+
+		// WHEN
+		if err := r.Drain(context.TODO(), "node1"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_ = waitDrained(t, r, "node1")
+
+		// THEN
+		objects := writeObjects(t, anotherGateway, 100)
+		if got := cluster.client("node1").names(); len(got) > 0 {
+			t.Errorf("new objects are not expected to be written to the draining instance, got: %v", got)
+		}
+		readObjects(t, gateway, objects)
+		if got := anotherGateway.DrainingInstances(); !slices.Equal(got, []string{"node1"}) {
+			t.Errorf("unexpected draining instances want: [node1], got: %v", got)
+		}
+		if status, ok := anotherRebalancer.DrainStatus("node1"); !ok || status.InstanceID != "node1" {
+			t.Errorf("the drain is expected to be reported by another gateway, got: %#v", status)
+		}
+
+		cancelled, err := anotherRebalancer.CancelDrain(context.TODO(), "node1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !cancelled {
+			t.Errorf("the drain is expected to be cancelled by another gateway")
+		}
+		if got, _ := marks.Draining(context.TODO()); len(got) > 0 {
+			t.Errorf("the draining mark is expected to be cleared, got: %v", got)
+		}
+	})
+
+	t.Run("shall keep the draining mark after the restart", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		marks := &MemoryDrainMarks{}
+		_, cluster := newMemoryCluster(3)
+		gateway, _ := newGateway(t, cluster, marks)
+		objects := writeObjects(t, gateway, 100)
+		// the mark was set before the gateway restarted
+		_ = marks.SetDraining(context.TODO(), "node0", true)
+
+		// WHEN
+		restarted, restartedRebalancer := newGateway(t, cluster, marks)
+		if _, err := restarted.scanStorageInstances(context.TODO()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// THEN
+		if got := restarted.DrainingInstances(); !slices.Equal(got, []string{"node0"}) {
+			t.Errorf("unexpected draining instances want: [node0], got: %v", got)
+		}
+		if _, ok := restartedRebalancer.DrainStatus("node0"); !ok {
+			t.Errorf("the instance is expected to be draining after the restart")
+		}
+		if err := restartedRebalancer.Drain(context.TODO(), "node0"); err != nil {
+			t.Fatalf("the drain is expected to be resumed, got: %v", err)
+		}
+		_ = waitDrained(t, restartedRebalancer, "node0")
+		if got := cluster.client("node0").names(); len(got) > 0 {
+			t.Errorf("the drained instance is expected to be empty, got: %v", got)
+		}
+		readObjects(t, restarted, objects)
+	})
+
+	t.Run("shall keep the previous marks if the marks cannot be read", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		marks := &MemoryDrainMarks{}
+		gateway, _ := newMemoryCluster(3)
+		WithDrainMarks(marks, 0)(gateway)
+		r, err := NewRebalancer(gateway, time.Millisecond, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = marks.SetDraining(context.TODO(), "node0", true)
+		_, _ = gateway.scanStorageInstances(context.TODO())
+
+		// WHEN
+		gateway.drainMarks.marks = mockDrainMarks{err: errors.New("unavailable")}
+		_, err = gateway.scanStorageInstances(context.TODO())
+
+		// THEN
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := gateway.DrainingInstances(); !slices.Equal(got, []string{"node0"}) {
+			t.Errorf("unexpected draining instances want: [node0], got: %v", got)
+		}
+		if err := r.Drain(context.TODO(), "node1"); err == nil {
+			t.Errorf("the drain is expected to fail if the mark cannot be set")
+		}
+		if got := gateway.DrainingInstances(); slices.Contains(got, "node1") {
+			t.Errorf("the instance is not expected to be draining, got: %v", got)
+		}
+	})
+
+	t.Run("shall re-read the marks after the refresh interval", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		marks := &MemoryDrainMarks{}
+		gateway, _ := newMemoryCluster(3)
+		WithDrainMarks(marks, time.Minute)(gateway)
+		now := time.Now()
+		gateway.drainMarks.now = func() time.Time { return now }
+		_, _ = gateway.scanStorageInstances(context.TODO())
+		_ = marks.SetDraining(context.TODO(), "node0", true)
+
+		// WHEN
+		_, _ = gateway.scanStorageInstances(context.TODO())
+		before := gateway.DrainingInstances()
+		now = now.Add(time.Minute)
+		_, _ = gateway.scanStorageInstances(context.TODO())
+
+		// THEN
+		if len(before) > 0 {
+			t.Errorf("the marks are not expected to be re-read before the refresh interval, got: %v", before)
+		}
+		if got := gateway.DrainingInstances(); !slices.Equal(got, []string{"node0"}) {
+			t.Errorf("unexpected draining instances want: [node0], got: %v", got)
+		}
+	})
+}
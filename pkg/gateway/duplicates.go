@@ -0,0 +1,116 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+)
+
+// deleteDuplicatesIfMembershipChanged scans the cluster membership after the write, and deletes the duplicates
+// of the object using sweep if the membership changed since the write started, i.e. the object could
+// have been written concurrently by another gateway to the instances selected for the different membership.
+// The duplicates are not searched otherwise to avoid probing every instance on each write, they are deleted
+// by the Rebalancer.
+func (s *Gateway) deleteDuplicatesIfMembershipChanged(
+	ctx context.Context, instances map[string]string, id string,
+	sweep func(instances map[string]string) error,
+) {
+	current, err := s.scanStorageInstances(ctx)
+	if err != nil {
+		s.logDuplicatesError(id, err)
+		return
+	}
+
+	if slices.Equal(readSortedMapKeys(instances), readSortedMapKeys(current)) {
+		return
+	}
+
+	s.Logger.Debug("cluster membership changed during the write, searching the duplicates",
+		slog.String("operation", "write"),
+		slog.String("objectID", id),
+	)
+	s.logDuplicatesError(id, sweep(current))
+}
+
+// deleteDuplicates deletes the copies of the object stored in the instances other than the instances
+// it was written to.
+func (s *Gateway) deleteDuplicates(
+	ctx context.Context, instances map[string]string, id string, written []string,
+) error {
+	others := excludeInstances(instances, written)
+
+	var errs []error
+	results := s.probeInstances(ctx, others, id, "write")
+	for range others {
+		r := <-results
+		if r.err != nil {
+			errs = append(errs, r.err)
+			continue
+		}
+		if !r.found {
+			continue
+		}
+
+		if err := s.deleteObject(ctx, r.instanceID, others[r.instanceID], r.conn, id); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		s.metrics.duplicatesDeleted.Add(1)
+		s.Logger.Info("duplicate deleted",
+			slog.String("operation", "write"),
+			slog.String("instanceID", r.instanceID),
+			slog.String("objectID", id),
+			slog.Any("instances", written),
+		)
+	}
+
+	return errors.Join(errs...)
+}
+
+// deleteDuplicateLayouts deletes the manifests and the shards of the erasure coded object written
+// to the instances other than the written manifest's instances, e.g. before, or concurrently by another gateway after
+// the cluster membership changed.
+func (s *Gateway) deleteDuplicateLayouts(
+	ctx context.Context, instances map[string]string, id string, written erasureManifest,
+) error {
+	others := excludeInstances(instances, written.instances())
+
+	var errs []error
+	results := s.probeInstances(ctx, others, manifestObjectName(id), "write")
+	for range others {
+		r := <-results
+		if r.err != nil {
+			errs = append(errs, r.err)
+			continue
+		}
+		if !r.found {
+			continue
+		}
+
+		manifest, found, err := s.readManifest(ctx, r.instanceID, others[r.instanceID], r.conn, id)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !found {
+			continue
+		}
+
+		if err := s.deleteStaleLayout(ctx, instances, id, manifest, written); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		s.metrics.duplicatesDeleted.Add(1)
+		s.Logger.Info("duplicate deleted",
+			slog.String("operation", "write"),
+			slog.String("instanceID", r.instanceID),
+			slog.String("objectID", id),
+			slog.Any("instances", written.instances()),
+		)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,317 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+)
+
+// blockingReader blocks the first read until it is released.
+type blockingReader struct {
+	reader   io.Reader
+	started  chan struct{}
+	release  chan struct{}
+	blocking bool
+}
+
+func newBlockingReader(data []byte) *blockingReader {
+	return &blockingReader{
+		reader:   bytes.NewReader(data),
+		started:  make(chan struct{}),
+		release:  make(chan struct{}),
+		blocking: true,
+	}
+}
+
+func (r *blockingReader) Read(p []byte) (int, error) {
+	if r.blocking {
+		r.blocking = false
+		close(r.started)
+		<-r.release
+	}
+	return r.reader.Read(p)
+}
+
+// waitFor waits until the condition is met, or the timeout is reached.
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("the condition is not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waiters returns the number of operations which hold, or wait for the key's lock.
+func (m *keyedMutex) waiters(key string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if l, ok := m.locks[key]; ok {
+		return l.refs
+	}
+	return 0
+}
+
+// pickMovedObjectID returns the ID of the object which is placed to the joining instance.
+func pickMovedObjectID(t *testing.T, gateway *Gateway, cluster *memoryCluster, joining int) string {
+	t.Helper()
+
+	instances, _ := cluster.Scan(context.TODO(), "")
+	joined := maps.Clone(instances)
+	joined[fmt.Sprintf("node%d", joining)] = fmt.Sprintf("192.0.2.%d", joining)
+
+	for _, id := range generateIDs("obj", 100) {
+		if pickStorageInstance(gateway.placement, joined, id) == fmt.Sprintf("node%d", joining) {
+			return id
+		}
+	}
+
+	t.Fatal("no object is placed to the joining instance")
+	return ""
+}
+
+func readContent(t *testing.T, gateway *Gateway, id string) []byte {
+	t.Helper()
+
+	reader, found, err := gateway.Read(context.TODO(), id, 0, -1)
+	if err != nil || !found {
+		t.Fatalf("the object %s is expected to be found, got: %v, %v", id, found, err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	o, _ := io.ReadAll(reader)
+	return o
+}
+
+func TestGateway_Write_concurrent(t *testing.T) {
+	t.Parallel()
+
+	t.Run("shall serialize the writes of the same object by the gateway", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, cluster := newMemoryCluster(3)
+		id := pickMovedObjectID(t, gateway, cluster, 3)
+		instances, _ := cluster.Scan(context.TODO(), "")
+		home := pickStorageInstance(gateway.placement, instances, id)
+
+		first := newBlockingReader([]byte("foo"))
+		errs := make(chan error, 2)
+		go func() { errs <- gateway.Write(context.TODO(), id, first, 3) }()
+		<-first.started
+
+		// WHEN
+		// the membership changes while the first write is in progress
+		cluster.join(3)
+		go func() { errs <- gateway.Write(context.TODO(), id, bytes.NewReader([]byte("bar")), 3) }()
+		waitFor(t, func() bool { return gateway.writes.waiters(id) == 2 })
+		close(first.release)
+
+		// THEN
+		for i := 0; i < 2; i++ {
+			if err := <-errs; err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if got := cluster.locate(id); !slices.Equal(got, []string{home}) {
+			t.Errorf("the object is expected to be overwritten in %s, got: %v", home, got)
+		}
+		if got := readContent(t, gateway, id); string(got) != "bar" {
+			t.Errorf("the last write is expected to be read, got: %s", got)
+		}
+		if got := gateway.Metrics().DuplicatesDeleted; got != 0 {
+			t.Errorf("no duplicates are expected, got: %d", got)
+		}
+	})
+
+	t.Run("shall delete the duplicate written concurrently by another gateway", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, cluster := newMemoryCluster(3)
+		anotherGateway, _ := newMemoryCluster(0)
+		anotherGateway.serviceRegistryClient = cluster
+		anotherGateway.newStorageConnectionFn = cluster.connect
+
+		id := pickMovedObjectID(t, gateway, cluster, 3)
+		instances, _ := cluster.Scan(context.TODO(), "")
+		home := pickStorageInstance(gateway.placement, instances, id)
+
+		first := newBlockingReader([]byte("foo"))
+		errs := make(chan error, 1)
+		go func() { errs <- gateway.Write(context.TODO(), id, first, 3) }()
+		<-first.started
+
+		// the membership changes while the first write is in progress
+		cluster.join(3)
+		if err := anotherGateway.Write(context.TODO(), id, bytes.NewReader([]byte("bar")), 3); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := cluster.locate(id); !slices.Equal(got, []string{"node3"}) {
+			t.Fatalf("the object is expected to be written to node3, got: %v", got)
+		}
+
+		// WHEN
+		close(first.release)
+		err := <-errs
+
+		// THEN
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := cluster.locate(id); !slices.Equal(got, []string{home}) {
+			t.Errorf("the object is expected to be stored in %s only, got: %v", home, got)
+		}
+		if got := readContent(t, anotherGateway, id); string(got) != "foo" {
+			t.Errorf("the last write is expected to be read, got: %s", got)
+		}
+		if got := gateway.Metrics().DuplicatesDeleted; got != 1 {
+			t.Errorf("one duplicate is expected to be deleted, got: %d", got)
+		}
+	})
+
+	t.Run("shall leave the copies stored outside the replicas to the rebalancer if the membership did not change",
+		func(t *testing.T) {
+			t.Parallel()
+
+			// GIVEN
+			const id = "obj"
+			gateway, cluster := newMemoryCluster(5)
+			gateway.replicationFactor, gateway.writeQuorum = 2, 2
+			instances, _ := cluster.Scan(context.TODO(), "")
+			replicas := gateway.pickReplicas(instances, id)
+			for _, instanceID := range readSortedMapKeys(instances) {
+				if !slices.Contains(replicas, instanceID) {
+					_ = cluster.client(instanceID).Write(context.TODO(), "", id, bytes.NewReader([]byte("foo")), 3)
+				}
+			}
+
+			// WHEN
+			err := gateway.Write(context.TODO(), id, bytes.NewReader([]byte("bar")), 3)
+
+			// THEN
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := cluster.locate(id); len(got) != len(instances) {
+				t.Errorf("the copies are expected to be kept by the write, got: %v", got)
+			}
+			if got := gateway.Metrics().DuplicatesDeleted; got != 0 {
+				t.Errorf("no duplicates are expected to be deleted by the write, got: %d", got)
+			}
+
+			if err := newTestRebalancer(t, gateway).Rebalance(context.TODO()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got, want := cluster.locate(id), readSorted(replicas); !slices.Equal(got, want) {
+				t.Errorf("the object is expected to be stored in the replicas %v only, got: %v", want, got)
+			}
+			if got := readContent(t, gateway, id); string(got) != "bar" {
+				t.Errorf("the last write is expected to be read, got: %s", got)
+			}
+		})
+
+	t.Run("shall delete the shards of the erasure coded object written concurrently by another gateway",
+		func(t *testing.T) {
+			t.Parallel()
+
+			// GIVEN
+			gateway, cluster := newMemoryCluster(3)
+			gateway.erasure = &erasureCoding{dataShards: 2, parityShards: 1}
+			anotherGateway, _ := newMemoryCluster(0)
+			anotherGateway.erasure = gateway.erasure
+			anotherGateway.serviceRegistryClient = cluster
+			anotherGateway.newStorageConnectionFn = cluster.connect
+
+			var id string
+			for _, candidate := range generateIDs("obj", 100) {
+				joined := map[string]string{}
+				for i := 0; i < 5; i++ {
+					joined[fmt.Sprintf("node%d", i)] = fmt.Sprintf("192.0.2.%d", i)
+				}
+				if layout := gateway.pickInstances(joined, candidate, 3); slices.Contains(layout, "node3") &&
+					slices.Contains(layout, "node4") {
+					id = candidate
+					break
+				}
+			}
+			layout := shardInstances(gateway, id)
+
+			first := newBlockingReader([]byte("foo"))
+			errs := make(chan error, 1)
+			go func() { errs <- gateway.Write(context.TODO(), id, first, 3) }()
+			<-first.started
+
+			// the membership changes while the first write is in progress
+			cluster.join(3)
+			cluster.join(4)
+			if err := anotherGateway.Write(context.TODO(), id, bytes.NewReader([]byte("bar")), 3); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// WHEN
+			close(first.release)
+			err := <-errs
+
+			// THEN
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			generation := currentManifest(t, gateway, id).Generation
+			for i := 0; i < 5; i++ {
+				instanceID := fmt.Sprintf("node%d", i)
+
+				var want []string
+				if shardIndex := slices.Index(layout, instanceID); shardIndex >= 0 {
+					want = []string{manifestObjectName(id), shardObjectName(id, generation, shardIndex)}
+				}
+				if got := cluster.client(instanceID).names(); !slices.Equal(got, want) {
+					t.Errorf("unexpected objects in %s want: %v, got: %v", instanceID, want, got)
+				}
+			}
+			if got := readContent(t, gateway, id); string(got) != "foo" {
+				t.Errorf("the last write is expected to be read, got: %s", got)
+			}
+			if got := gateway.Metrics().DuplicatesDeleted; got == 0 {
+				t.Errorf("the duplicates are expected to be deleted")
+			}
+		})
+
+	t.Run("shall delete the plain copies of the erasure coded object", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		const id = "obj"
+		gateway, cluster := newMemoryCluster(4)
+		if err := gateway.Write(context.TODO(), id, bytes.NewReader([]byte("foo")), 3); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		gateway.erasure = &erasureCoding{dataShards: 2, parityShards: 1}
+
+		// WHEN
+		err := gateway.Write(context.TODO(), id, bytes.NewReader([]byte("bar")), 3)
+
+		// THEN
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := cluster.locate(id); len(got) > 0 {
+			t.Errorf("the plain copies are expected to be deleted, got: %v", got)
+		}
+		if got := readContent(t, gateway, id); string(got) != "bar" {
+			t.Errorf("the last write is expected to be read, got: %s", got)
+		}
+		if got := gateway.Metrics().DuplicatesDeleted; got != 1 {
+			t.Errorf("one duplicate is expected to be deleted, got: %d", got)
+		}
+	})
+}
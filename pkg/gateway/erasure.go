@@ -0,0 +1,922 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	stdhash "hash"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/reedsolomon"
+)
+
+const (
+	// erasureBlockSize the size of the shard's chunk encoded at once, i.e. the stripe of the object
+	// encoded at once contains dataShards*erasureBlockSize bytes.
+	erasureBlockSize = 256 << 10
+
+	erasureManifestVersion = 1
+	maxManifestSizeBytes   = 64 << 10
+
+	manifestObjectNameSuffix = ".manifest"
+	shardObjectNameInfix     = ".shard."
+)
+
+var (
+	// ErrNotEnoughShards indicates that fewer shards than required to reconstruct the erasure coded object
+	// are available.
+	ErrNotEnoughShards = errors.New("not enough shards to reconstruct the object")
+	// ErrShardChecksumMismatch indicates that the shard's content differs from the one written with the manifest.
+	ErrShardChecksumMismatch = errors.New("shard checksum mismatch")
+)
+
+// WithErasureCoding sets the number of data and parity shards to store the erasure coded objects.
+// Every object is split into dataShards shards, parityShards shards are computed using the Reed-Solomon code,
+// and each shard is stored on a distinct instance. The object can be read if up to parityShards instances
+// are unavailable. The option cannot be combined with WithReplication.
+func WithErasureCoding(dataShards, parityShards int) Option {
+	return func(g *Gateway) {
+		g.erasure = &erasureCoding{dataShards: dataShards, parityShards: parityShards}
+	}
+}
+
+// erasureCoding defines the erasure coding configuration.
+type erasureCoding struct {
+	dataShards, parityShards int
+}
+
+func (c erasureCoding) validate() error {
+	if c.dataShards < 1 || c.parityShards < 1 {
+		return errors.New("the number of data and parity shards must be positive")
+	}
+	if c.dataShards+c.parityShards > 256 {
+		return errors.New("the total number of shards must not exceed 256")
+	}
+	return nil
+}
+
+// writeQuorum the number of shards which shall be stored for the write to succeed.
+func (c erasureCoding) writeQuorum() int {
+	return min(c.dataShards+1, c.dataShards+c.parityShards)
+}
+
+// erasureManifest defines the layout of the erasure coded object.
+type erasureManifest struct {
+	Version int `json:"version"`
+	// Size the object's size in bytes.
+	Size int64 `json:"size"`
+	// ETag the hex encoded MD5 checksum of the object.
+	ETag         string    `json:"etag"`
+	LastModified time.Time `json:"lastModified"`
+	DataShards   int       `json:"dataShards"`
+	ParityShards int       `json:"parityShards"`
+	BlockSize    int       `json:"blockSize"`
+	// Generation the ID of the object's version, the shards are stored under the names which include it.
+	Generation string `json:"generation"`
+	// Shards the shards in the order of their indices.
+	Shards []erasureShard `json:"shards"`
+}
+
+// erasureShard defines the shard of the erasure coded object.
+type erasureShard struct {
+	// InstanceID the ID of the instance which stores the shard, empty if the instance failed to store it.
+	InstanceID string `json:"instanceID,omitempty"`
+	// Checksum the hex encoded MD5 checksum of the shard.
+	Checksum string `json:"checksum,omitempty"`
+}
+
+func (m erasureManifest) validate() error {
+	switch {
+	case m.Version != erasureManifestVersion:
+		return fmt.Errorf("unsupported manifest version %d", m.Version)
+	case m.DataShards < 1 || m.ParityShards < 0 || len(m.Shards) != m.DataShards+m.ParityShards:
+		return errors.New("invalid number of shards")
+	case m.BlockSize <= 0 || m.Size < 0 || m.Generation == "":
+		return errors.New("invalid object layout")
+	default:
+		return nil
+	}
+}
+
+// instances returns the IDs of the instances which store the shards, and the manifest.
+func (m erasureManifest) instances() []string {
+	var o []string
+	for _, shard := range m.Shards {
+		if shard.InstanceID != "" {
+			o = append(o, shard.InstanceID)
+		}
+	}
+	return o
+}
+
+// instancesExcept returns the IDs of the instances which store the shards, and the manifest,
+// except the instances of the other manifest.
+func (m erasureManifest) instancesExcept(other erasureManifest) []string {
+	otherInstances := other.instances()
+	return slices.DeleteFunc(m.instances(), func(instanceID string) bool {
+		return slices.Contains(otherInstances, instanceID)
+	})
+}
+
+// placedOn defines if every stored shard is stored in the instance selected for it.
+func (m erasureManifest) placedOn(shardInstances []string) bool {
+	if len(m.Shards) != len(shardInstances) {
+		return false
+	}
+	for i, shard := range m.Shards {
+		if shard.InstanceID != "" && shard.InstanceID != shardInstances[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// newerThan defines if the manifest was written after the other one.
+func (m erasureManifest) newerThan(other erasureManifest) bool {
+	if m.LastModified.Equal(other.LastModified) {
+		return m.Generation > other.Generation
+	}
+	return m.LastModified.After(other.LastModified)
+}
+
+func (m erasureManifest) objectInfo(id string) ObjectInfo {
+	return ObjectInfo{ID: id, Size: m.Size, ETag: m.ETag, LastModified: m.LastModified}
+}
+
+func manifestObjectName(id string) string {
+	return id + manifestObjectNameSuffix
+}
+
+func shardObjectName(id, generation string, shardIndex int) string {
+	return id + shardObjectNameInfix + generation + "." + strconv.Itoa(shardIndex)
+}
+
+// newErasureGeneration generates the random ID of the erasure coded object's version.
+func newErasureGeneration() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// isShardObjectName defines if the object is the shard of the erasure coded object.
+func isShardObjectName(objectName string) bool {
+	return strings.Contains(objectName, shardObjectNameInfix)
+}
+
+// cutShardObjectName returns the ID of the erasure coded object given its shard's name.
+func cutShardObjectName(objectName string) (id string, ok bool) {
+	id, _, ok = strings.Cut(objectName, shardObjectNameInfix)
+	return id, ok
+}
+
+// erasureShardSize calculates the size of the shard given the object's size, -1 is returned if the size is unknown.
+func erasureShardSize(objectSizeBytes int64, dataShards, blockSize int) int64 {
+	if objectSizeBytes < 0 {
+		return -1
+	}
+	stripeSize := int64(dataShards * blockSize)
+	rest := objectSizeBytes % stripeSize
+	return objectSizeBytes/stripeSize*int64(blockSize) + (rest+int64(dataShards)-1)/int64(dataShards)
+}
+
+// writeErasureCoded splits the object into the shards and writes them to distinct instances selected by the placement
+// strategy concurrently. The shards are stored under the names of the new generation, so the shards of the object's
+// previous generation are not overwritten. Once at least dataShards+1 instances stored the shard, the manifest
+// listing the stored shards and their checksums is written to them. The write succeeds if the write quorum
+// of the instances stored the manifest, otherwise the new generation is rolled back to keep reading the previous one
+// given its manifest, which is empty if the object is new. It returns the written manifest.
+func (s *Gateway) writeErasureCoded(
+	ctx context.Context, instances map[string]string, id string, reader io.Reader, objectSizeBytes int64,
+	previous erasureManifest,
+) (erasureManifest, error) {
+	cntShards := s.erasure.dataShards + s.erasure.parityShards
+	shardInstances := s.pickInstances(instances, id, cntShards)
+	if len(shardInstances) < cntShards {
+		return erasureManifest{}, fmt.Errorf("%w: %d instances available, %d shards required",
+			ErrWriteQuorum, len(shardInstances), cntShards)
+	}
+
+	encoder, err := reedsolomon.New(s.erasure.dataShards, s.erasure.parityShards)
+	if err != nil {
+		return erasureManifest{}, err
+	}
+
+	generation, err := newErasureGeneration()
+	if err != nil {
+		return erasureManifest{}, err
+	}
+
+	shardNames := make([]string, cntShards)
+	for i := range shardNames {
+		shardNames[i] = shardObjectName(id, generation, i)
+	}
+
+	writers, wait := s.startWrites(ctx, instances, shardInstances, shardNames,
+		erasureShardSize(objectSizeBytes, s.erasure.dataShards, erasureBlockSize))
+
+	checksum := md5.New()
+	size, shardChecksums, readErr := encodeStripes(encoder, io.TeeReader(reader, checksum), writers,
+		s.erasure.dataShards, erasureBlockSize)
+
+	errList := wait()
+
+	manifest := erasureManifest{
+		Version:      erasureManifestVersion,
+		Size:         size,
+		ETag:         hex.EncodeToString(checksum.Sum(nil)),
+		LastModified: time.Now().UTC(),
+		DataShards:   s.erasure.dataShards,
+		ParityShards: s.erasure.parityShards,
+		BlockSize:    erasureBlockSize,
+		Generation:   generation,
+		Shards:       make([]erasureShard, cntShards),
+	}
+
+	var stored []string
+	for i, instanceID := range shardInstances {
+		if errList[i] != nil {
+			if readErr != nil {
+				continue
+			}
+			s.Logger.Warn("shard write failed",
+				slog.String("operation", "write"),
+				slog.String("instanceID", instanceID),
+				slog.String("objectID", shardNames[i]),
+				slog.String("error", errList[i].Error()),
+			)
+			continue
+		}
+		manifest.Shards[i] = erasureShard{InstanceID: instanceID, Checksum: shardChecksums[i]}
+		stored = append(stored, instanceID)
+	}
+
+	if readErr != nil {
+		s.rollbackGeneration(ctx, instances, id, manifest, previous, nil)
+		return erasureManifest{}, readErr
+	}
+
+	quorum := s.erasure.writeQuorum()
+	if len(stored) < quorum {
+		s.rollbackGeneration(ctx, instances, id, manifest, previous, nil)
+		return erasureManifest{}, fmt.Errorf("%w: %d of %d shards stored, %d required: %w",
+			ErrWriteQuorum, len(stored), cntShards, quorum, errors.Join(errList...))
+	}
+
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		s.rollbackGeneration(ctx, instances, id, manifest, previous, nil)
+		return erasureManifest{}, err
+	}
+
+	errList, _ = s.writeToInstances(ctx, instances, stored, manifestObjectName(id), bytes.NewReader(manifestData),
+		int64(len(manifestData)))
+
+	var acked []string
+	for i, instanceID := range stored {
+		if errList[i] != nil {
+			s.Logger.Warn("manifest write failed",
+				slog.String("operation", "write"),
+				slog.String("instanceID", instanceID),
+				slog.String("objectID", id),
+				slog.String("error", errList[i].Error()),
+			)
+			continue
+		}
+		acked = append(acked, instanceID)
+	}
+
+	if len(acked) < quorum {
+		s.rollbackGeneration(ctx, instances, id, manifest, previous, acked)
+		return erasureManifest{}, fmt.Errorf("%w: %d of %d manifests stored, %d required: %w",
+			ErrWriteQuorum, len(acked), cntShards, quorum, errors.Join(errList...))
+	}
+
+	if len(acked) < cntShards {
+		s.metrics.degradedWrites.Add(1)
+	}
+
+	return manifest, nil
+}
+
+// rollbackGeneration restores the previous manifest in the given instances which stored it before the failed write,
+// deletes the failed generation's manifests from the rest of them, and deletes the failed generation's shards.
+// The failure is logged, the shards left are not read, because no manifest lists them.
+func (s *Gateway) rollbackGeneration(
+	ctx context.Context, instances map[string]string, id string, failed, previous erasureManifest,
+	manifestInstances []string,
+) {
+	// the failed write shall be rolled back when the write request is cancelled
+	ctx = context.WithoutCancel(ctx)
+
+	var (
+		restore, remove   []string
+		previousInstances = previous.instances()
+	)
+	for _, instanceID := range manifestInstances {
+		if slices.Contains(previousInstances, instanceID) {
+			restore = append(restore, instanceID)
+		} else {
+			remove = append(remove, instanceID)
+		}
+	}
+
+	if len(restore) > 0 {
+		var errList []error
+		data, err := json.Marshal(previous)
+		if err == nil {
+			errList, err = s.writeToInstances(ctx, instances, restore, manifestObjectName(id), bytes.NewReader(data),
+				int64(len(data)))
+		}
+		for i, instanceID := range restore {
+			// the failed generation's manifest is deleted if the previous one cannot be restored
+			if err != nil || errList[i] != nil {
+				remove = append(remove, instanceID)
+			}
+		}
+	}
+
+	if err := s.deleteLayout(ctx, instances, id, remove, failed, erasureManifest{}); err != nil {
+		s.Logger.Warn("failed to roll back the failed write",
+			slog.String("operation", "write"),
+			slog.String("objectID", id),
+			slog.String("generation", failed.Generation),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// encodeStripes reads the object by stripes of dataShards*blockSize bytes, splits every stripe into the data shards,
+// computes the parity shards, and writes the i-th shard to the i-th writer. The writer is dropped once it fails.
+// The writers are closed when the object is read, the error is propagated to the writers.
+// It returns the number of bytes read, and the hex encoded MD5 checksums of the shards.
+func encodeStripes(
+	encoder reedsolomon.Encoder, reader io.Reader, writers []*io.PipeWriter, dataShards, blockSize int,
+) (int64, []string, error) {
+	active := make([]*io.PipeWriter, len(writers))
+	copy(active, writers)
+
+	checksums := make([]stdhash.Hash, len(writers))
+	for i := range checksums {
+		checksums[i] = md5.New()
+	}
+
+	var err error
+	defer func() {
+		for _, w := range active {
+			if w != nil {
+				_ = w.CloseWithError(err)
+			}
+		}
+	}()
+
+	var (
+		size int64
+		n    int
+		// the capacity lets the encoder split the stripe without allocations
+		buf = make([]byte, dataShards*blockSize, len(writers)*blockSize)
+	)
+	for {
+		n, err = io.ReadFull(reader, buf)
+		size += int64(n)
+
+		if n > 0 {
+			var shards [][]byte
+			if shards, err = encoder.Split(buf[:n]); err != nil {
+				return size, nil, err
+			}
+			if err = encoder.Encode(shards); err != nil {
+				return size, nil, err
+			}
+
+			for i, shard := range shards {
+				_, _ = checksums[i].Write(shard)
+			}
+
+			for i, w := range active {
+				if w == nil {
+					continue
+				}
+				if _, werr := w.Write(shards[i]); werr != nil {
+					_ = w.Close()
+					active[i] = nil
+				}
+			}
+		}
+
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			err = nil
+			o := make([]string, len(checksums))
+			for i, checksum := range checksums {
+				o[i] = hex.EncodeToString(checksum.Sum(nil))
+			}
+			return size, o, nil
+		}
+
+		if err != nil {
+			return size, nil, err
+		}
+	}
+}
+
+// findManifest searches the manifest of the erasure coded object in the instances selected by the placement strategy
+// to store the object's shards first, and falls back to the scan of the cluster. The newest of the manifests found
+// in the selected instances is returned, because the instance which missed the object's overwrite stores
+// the manifest of the previous generation.
+func (s *Gateway) findManifest(ctx context.Context, instances map[string]string, id, operation string) (
+	erasureManifest, bool, error,
+) {
+	name := manifestObjectName(id)
+	candidates := s.pickInstances(instances, id, s.erasure.dataShards+s.erasure.parityShards)
+
+	var (
+		newest       erasureManifest
+		found        bool
+		candidateErr error
+	)
+	results := s.probeInstances(ctx, selectInstances(instances, candidates), name, operation)
+	for range candidates {
+		r := <-results
+		if r.found {
+			manifest, ok, err := s.readManifest(ctx, r.instanceID, instances[r.instanceID], r.conn, id)
+			if ok && (!found || manifest.newerThan(newest)) {
+				newest, found = manifest, true
+			}
+			r.err = err
+		}
+
+		if r.err != nil && candidateErr == nil {
+			candidateErr = r.err
+		}
+	}
+
+	if found {
+		return newest, true, nil
+	}
+
+	instanceID, conn, found, err := s.findMisplacedObject(ctx, instances, candidates, name, operation)
+	if !found {
+		if candidateErr != nil {
+			return erasureManifest{}, false, candidateErr
+		}
+		return erasureManifest{}, false, err
+	}
+
+	return s.readManifest(ctx, instanceID, instances[instanceID], conn, id)
+}
+
+func (s *Gateway) readManifest(
+	ctx context.Context, instanceID, endpoint string, conn ObjectReadWriteFinder, id string,
+) (erasureManifest, bool, error) {
+	reader, found, err := s.readObject(ctx, instanceID, endpoint, conn, manifestObjectName(id), 0, -1)
+	if err != nil || !found {
+		return erasureManifest{}, false, err
+	}
+	defer func() { _ = reader.Close() }()
+
+	var manifest erasureManifest
+	if err := json.NewDecoder(io.LimitReader(reader, maxManifestSizeBytes)).Decode(&manifest); err != nil {
+		return erasureManifest{}, false, fmt.Errorf("cannot decode the manifest of %s: %w", id, err)
+	}
+
+	if err := manifest.validate(); err != nil {
+		return erasureManifest{}, false, fmt.Errorf("invalid manifest of %s: %w", id, err)
+	}
+
+	return manifest, true, nil
+}
+
+// readErasureCoded reads the erasure coded object. It returns false if the object's manifest is not found.
+func (s *Gateway) readErasureCoded(
+	ctx context.Context, instances map[string]string, id string, offset, length int64,
+) (io.ReadCloser, bool, error) {
+	manifest, found, err := s.findManifest(ctx, instances, id, "read")
+	if err != nil || !found {
+		return nil, false, err
+	}
+
+	reader, err := s.readShards(ctx, instances, id, manifest, offset, length)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return reader, true, nil
+}
+
+// readShards reconstructs the erasure coded object from the shards listed in the manifest.
+func (s *Gateway) readShards(
+	ctx context.Context, instances map[string]string, id string, manifest erasureManifest, offset, length int64,
+) (io.ReadCloser, error) {
+	encoder, err := reedsolomon.New(manifest.DataShards, manifest.ParityShards)
+	if err != nil {
+		return nil, err
+	}
+
+	end := manifest.Size
+	if length >= 0 && offset+length < end {
+		end = offset + length
+	}
+	offset = min(offset, end)
+
+	stripeSize := int64(manifest.DataShards * manifest.BlockSize)
+	r := &erasureReader{
+		encoder:   encoder,
+		manifest:  manifest,
+		shards:    make([]io.ReadCloser, len(manifest.Shards)),
+		failed:    make([]bool, len(manifest.Shards)),
+		chunks:    make([][]byte, len(manifest.Shards)),
+		checksums: make([]stdhash.Hash, len(manifest.Shards)),
+		stripe:    offset / stripeSize,
+		skip:      offset % stripeSize,
+		remaining: end - offset,
+		logger:    s.Logger.With(slog.String("objectID", id)),
+		onDegraded: func() {
+			s.metrics.degradedReads.Add(1)
+		},
+		open: func(shardIndex int, offset int64) (io.ReadCloser, error) {
+			return s.openShard(ctx, instances, manifest.Shards[shardIndex].InstanceID,
+				shardObjectName(id, manifest.Generation, shardIndex), offset)
+		},
+	}
+
+	// the first stripe is decoded to report unavailable shards before the object is returned
+	if r.remaining > 0 {
+		if err := r.decodeStripe(); err != nil {
+			_ = r.Close()
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+func (s *Gateway) openShard(
+	ctx context.Context, instances map[string]string, instanceID, name string, offset int64,
+) (io.ReadCloser, error) {
+	if instanceID == "" {
+		return nil, errors.New("shard was not stored")
+	}
+
+	endpoint, ok := instances[instanceID]
+	if !ok {
+		return nil, fmt.Errorf("instance %s is not found in the cluster", instanceID)
+	}
+
+	r := s.probeInstance(ctx, instanceID, endpoint, name, "read")
+	if r.err != nil {
+		return nil, r.err
+	}
+	if !r.found {
+		return nil, fmt.Errorf("shard is not found in the instance %s", instanceID)
+	}
+
+	reader, found, err := s.readObject(ctx, instanceID, endpoint, r.conn, name, offset, -1)
+	if err == nil && !found {
+		err = fmt.Errorf("shard is not found in the instance %s", instanceID)
+	}
+
+	return reader, err
+}
+
+// erasureReader reconstructs the erasure coded object stripe by stripe.
+// The data shards are read first, the parity shards are only read if the data shards are unavailable.
+// The checksums of the shards read from the first to the last stripe are verified against the manifest.
+type erasureReader struct {
+	encoder  reedsolomon.Encoder
+	manifest erasureManifest
+	// open opens the shard starting from the offset.
+	open func(shardIndex int, offset int64) (io.ReadCloser, error)
+	// onDegraded is called once if the object is reconstructed using the parity shards.
+	onDegraded func()
+	logger     *slog.Logger
+
+	shards []io.ReadCloser
+	failed []bool
+	chunks [][]byte
+	// checksums the checksums of the shards read from the first stripe.
+	checksums []stdhash.Hash
+
+	// stripe the index of the next stripe to decode.
+	stripe int64
+	// skip the number of bytes to skip in the next stripe.
+	skip int64
+	// remaining the number of bytes to return.
+	remaining int64
+	// buf decoded bytes which were not returned yet.
+	buf       []byte
+	stripeBuf []byte
+	degraded  bool
+}
+
+func (r *erasureReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.remaining == 0 {
+			return 0, io.EOF
+		}
+		if err := r.decodeStripe(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *erasureReader) Close() error {
+	var errList []error
+	for i, shard := range r.shards {
+		if shard != nil {
+			errList = append(errList, shard.Close())
+			r.shards[i] = nil
+		}
+	}
+	return errors.Join(errList...)
+}
+
+// decodeStripe reads the stripe's chunks of the first dataShards available shards, and reconstructs the data.
+// The shard is marked as failed if it cannot be read, the next shard is opened then. The checksums of the shards
+// are verified once the last stripe is read: the shard which does not match the manifest is marked as failed
+// if the object consists of the single stripe, the read fails otherwise, because the previous stripes were returned.
+func (r *erasureReader) decodeStripe() error {
+	var (
+		dataShards = r.manifest.DataShards
+		blockSize  = int64(r.manifest.BlockSize)
+		stripeSize = int64(dataShards) * blockSize
+		stripeLen  = min(stripeSize, r.manifest.Size-r.stripe*stripeSize)
+		chunkSize  = (stripeLen + int64(dataShards) - 1) / int64(dataShards)
+		lastStripe = r.stripe*stripeSize+stripeLen == r.manifest.Size
+		shards     = make([][]byte, len(r.shards))
+		cnt        int
+	)
+
+	for {
+		for i := 0; i < len(r.shards) && cnt < dataShards; i++ {
+			if r.failed[i] || shards[i] != nil {
+				continue
+			}
+
+			if r.shards[i] == nil {
+				shard, err := r.open(i, r.stripe*blockSize)
+				if err != nil {
+					r.fail(i, err)
+					continue
+				}
+				r.shards[i] = shard
+				if r.stripe == 0 {
+					r.checksums[i] = md5.New()
+				}
+			}
+
+			if r.chunks[i] == nil {
+				r.chunks[i] = make([]byte, blockSize)
+			}
+
+			chunk := r.chunks[i][:chunkSize]
+			if _, err := io.ReadFull(r.shards[i], chunk); err != nil {
+				r.fail(i, err)
+				continue
+			}
+
+			if r.checksums[i] != nil {
+				_, _ = r.checksums[i].Write(chunk)
+			}
+
+			shards[i] = chunk
+			cnt++
+		}
+
+		if cnt < dataShards {
+			return fmt.Errorf("%w: %d of %d shards available, %d required",
+				ErrNotEnoughShards, cnt, len(r.shards), dataShards)
+		}
+
+		if !lastStripe {
+			break
+		}
+
+		mismatched := r.verifyChecksums(shards)
+		if len(mismatched) == 0 {
+			break
+		}
+
+		for _, i := range mismatched {
+			err := fmt.Errorf("%w: shard %d", ErrShardChecksumMismatch, i)
+			if r.stripe > 0 {
+				return err
+			}
+
+			r.fail(i, err)
+			shards[i] = nil
+			cnt--
+		}
+	}
+
+	for i := 0; i < dataShards; i++ {
+		if shards[i] != nil {
+			continue
+		}
+
+		if err := r.encoder.ReconstructData(shards); err != nil {
+			return err
+		}
+
+		if !r.degraded {
+			r.degraded = true
+			r.onDegraded()
+		}
+		break
+	}
+
+	if r.stripeBuf == nil {
+		r.stripeBuf = make([]byte, 0, stripeSize)
+	}
+
+	out := r.stripeBuf[:0]
+	for _, shard := range shards[:dataShards] {
+		out = append(out, shard...)
+	}
+	out = out[r.skip:stripeLen]
+	if int64(len(out)) > r.remaining {
+		out = out[:r.remaining]
+	}
+
+	r.buf = out
+	r.remaining -= int64(len(out))
+	r.skip = 0
+	r.stripe++
+
+	return nil
+}
+
+// verifyChecksums returns the indices of the shards read from the first stripe whose checksums
+// do not match the manifest.
+func (r *erasureReader) verifyChecksums(shards [][]byte) []int {
+	var o []int
+	for i, checksum := range r.checksums {
+		if checksum == nil || shards[i] == nil {
+			continue
+		}
+		if hex.EncodeToString(checksum.Sum(nil)) != r.manifest.Shards[i].Checksum {
+			o = append(o, i)
+		}
+	}
+	return o
+}
+
+func (r *erasureReader) fail(shardIndex int, err error) {
+	r.logger.Warn("shard unavailable",
+		slog.Int("shardIndex", shardIndex),
+		slog.String("instanceID", r.manifest.Shards[shardIndex].InstanceID),
+		slog.String("error", err.Error()),
+	)
+
+	r.failed[shardIndex] = true
+	r.checksums[shardIndex] = nil
+	if r.shards[shardIndex] != nil {
+		_ = r.shards[shardIndex].Close()
+		r.shards[shardIndex] = nil
+	}
+}
+
+// deleteErasureCoded deletes the shards and the manifests of the erasure coded object from all instances.
+// The shards are deleted first to keep the manifests if the deletion fails.
+func (s *Gateway) deleteErasureCoded(ctx context.Context, instances map[string]string, id string) (bool, error) {
+	name := manifestObjectName(id)
+
+	type shardLocation struct {
+		instanceID, name string
+	}
+
+	var (
+		manifestInstances = map[string]ObjectReadWriteFinder{}
+		shards            = map[shardLocation]struct{}{}
+	)
+	for instanceID, endpoint := range instances {
+		r := s.probeInstance(ctx, instanceID, endpoint, name, "delete")
+		if r.err != nil {
+			return false, r.err
+		}
+		if !r.found {
+			continue
+		}
+
+		manifestInstances[instanceID] = r.conn
+
+		manifest, found, err := s.readManifest(ctx, instanceID, endpoint, r.conn, id)
+		if err != nil {
+			s.Logger.Warn("cannot read the manifest, only the instance's shard will be deleted",
+				slog.String("operation", "delete"),
+				slog.String("instanceID", instanceID),
+				slog.String("objectID", id),
+				slog.String("error", err.Error()),
+			)
+		}
+		if !found {
+			continue
+		}
+
+		for i, shard := range manifest.Shards {
+			if _, ok := instances[shard.InstanceID]; ok {
+				shards[shardLocation{
+					instanceID: shard.InstanceID,
+					name:       shardObjectName(id, manifest.Generation, i),
+				}] = struct{}{}
+			}
+		}
+	}
+
+	for shard := range shards {
+		if err := s.deleteObject(ctx, shard.instanceID, instances[shard.instanceID], nil, shard.name); err != nil {
+			return false, err
+		}
+	}
+
+	for instanceID, conn := range manifestInstances {
+		if err := s.deleteObject(ctx, instanceID, instances[instanceID], conn, name); err != nil {
+			return false, err
+		}
+	}
+
+	return len(manifestInstances) > 0, nil
+}
+
+// deleteLayout deletes the manifests of the erasure coded object from the given instances, and the shards
+// of the layout which are not a part of the current layout, i.e. the shards of the other generation, or stored
+// in the instances other than the ones selected for them by the current layout. The manifests are deleted first
+// to not be found after their shards are deleted. The deletion proceeds if the instance fails, the errors
+// are joined.
+func (s *Gateway) deleteLayout(
+	ctx context.Context, instances map[string]string, id string, manifestInstances []string,
+	layout, current erasureManifest,
+) error {
+	var errs []error
+	for _, instanceID := range manifestInstances {
+		if _, ok := instances[instanceID]; !ok {
+			continue
+		}
+
+		if err := s.deleteObject(ctx, instanceID, instances[instanceID], nil, manifestObjectName(id)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for i, shard := range layout.Shards {
+		if _, ok := instances[shard.InstanceID]; !ok {
+			continue
+		}
+		if layout.Generation == current.Generation && i < len(current.Shards) &&
+			current.Shards[i].InstanceID == shard.InstanceID {
+			continue
+		}
+
+		name := shardObjectName(id, layout.Generation, i)
+		if err := s.deleteObject(ctx, shard.InstanceID, instances[shard.InstanceID], nil, name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// deleteStaleLayout deletes the manifests and the shards of the stale layout of the erasure coded object
+// which are not a part of the current layout.
+func (s *Gateway) deleteStaleLayout(
+	ctx context.Context, instances map[string]string, id string, stale, current erasureManifest,
+) error {
+	return s.deleteLayout(ctx, instances, id, stale.instancesExcept(current), stale, current)
+}
+
+// deleteObject deletes the object from the instance, new connection is established if conn is nil.
+func (s *Gateway) deleteObject(
+	ctx context.Context, instanceID, endpoint string, conn ObjectReadWriteFinder, name string,
+) error {
+	if conn == nil {
+		var err error
+		if conn, err = s.newStorageInstanceConnection(ctx, instanceID, endpoint); err != nil {
+			return err
+		}
+	}
+
+	s.Logger.Debug("deleting",
+		slog.String("operation", "delete"),
+		slog.String("instanceID", instanceID),
+		slog.String("objectID", name),
+	)
+
+	_, err := s.retryOnAuthenticationError(ctx, instanceID, endpoint, conn, func(conn ObjectReadWriteFinder) error {
+		return conn.Delete(ctx, s.storageBucket, name)
+	})
+	return err
+}
@@ -0,0 +1,659 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newErasureCodedCluster initialises the gateway with the cluster of instances storing objects in memory.
+// The map of clients by instance ID is returned to inject failures.
+func newErasureCodedCluster(cntInstances, dataShards, parityShards int) (*Gateway, map[string]*memoryStorageClient) {
+	gateway, cluster := newMemoryCluster(cntInstances)
+	gateway.erasure = &erasureCoding{dataShards: dataShards, parityShards: parityShards}
+	return gateway, cluster.instanceClients()
+}
+
+func randomData(size int) []byte {
+	o := make([]byte, size)
+	_, _ = rand.New(rand.NewSource(int64(size))).Read(o)
+	return o
+}
+
+// currentManifest returns the newest manifest of the erasure coded object.
+func currentManifest(t *testing.T, gateway *Gateway, objectID string) erasureManifest {
+	t.Helper()
+
+	instances, _ := gateway.serviceRegistryClient.Scan(context.TODO(), mockClusterPrefix)
+	manifest, found, err := gateway.findManifest(context.TODO(), instances, objectID, "read")
+	if err != nil || !found {
+		t.Fatalf("the manifest of %s is expected to be found, got: %v, %v", objectID, found, err)
+	}
+	return manifest
+}
+
+// shardInstances returns the IDs of the instances selected to store the object's shards in the order of shards.
+func shardInstances(gateway *Gateway, objectID string) []string {
+	instances, _ := gateway.serviceRegistryClient.Scan(context.TODO(), mockClusterPrefix)
+	return gateway.pickInstances(instances, objectID,
+		gateway.erasure.dataShards+gateway.erasure.parityShards)
+}
+
+func Test_erasureShardSize(t *testing.T) {
+	tests := []struct {
+		objectSize int64
+		want       int64
+	}{
+		{objectSize: -1, want: -1},
+		{objectSize: 0, want: 0},
+		{objectSize: 1, want: 1},
+		{objectSize: 4, want: 1},
+		{objectSize: 5, want: 2},
+		{objectSize: 40, want: 10},
+		{objectSize: 41, want: 11},
+		{objectSize: 79, want: 20},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d", tt.objectSize), func(t *testing.T) {
+			if got := erasureShardSize(tt.objectSize, 4, 10); got != tt.want {
+				t.Errorf("unexpected shard size want: %d, got: %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGateway_erasureCoding(t *testing.T) {
+	t.Parallel()
+
+	const (
+		objectID     = "obj"
+		dataShards   = 4
+		parityShards = 2
+		stripeSize   = dataShards * erasureBlockSize
+	)
+
+	readObject := func(gateway *Gateway, offset, length int64) ([]byte, error) {
+		reader, found, err := gateway.Read(context.TODO(), objectID, offset, length)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, errors.New("object not found")
+		}
+		defer func() { _ = reader.Close() }()
+		return io.ReadAll(reader)
+	}
+
+	for _, size := range []int{0, 1, 1000, stripeSize, stripeSize + 1, 3*stripeSize - 7} {
+		size := size
+		t.Run(fmt.Sprintf("shall write and read the object of %d bytes", size), func(t *testing.T) {
+			t.Parallel()
+
+			// GIVEN
+			gateway, clients := newErasureCodedCluster(8, dataShards, parityShards)
+			data := randomData(size)
+
+			// WHEN
+			err := gateway.Write(context.TODO(), objectID, bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, err := readObject(gateway, 0, -1)
+
+			// THEN
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("the read object does not match the written one")
+			}
+
+			generation := currentManifest(t, gateway, objectID).Generation
+			for i, instanceID := range shardInstances(gateway, objectID) {
+				want := []string{manifestObjectName(objectID), shardObjectName(objectID, generation, i)}
+				if got := clients[instanceID].names(); !slices.Equal(got, want) {
+					t.Errorf("unexpected objects in %s want: %v, got: %v", instanceID, want, got)
+				}
+			}
+
+			info, found, err := gateway.Stat(context.TODO(), objectID)
+			if err != nil || !found {
+				t.Fatalf("the object is expected to be found, got: %v, %v", found, err)
+			}
+			if wantETag := fmt.Sprintf("%x", md5.Sum(data)); info.Size != int64(size) || info.ETag != wantETag {
+				t.Errorf("unexpected metadata want: %d %s, got: %d %s", size, wantETag, info.Size, info.ETag)
+			}
+		})
+	}
+
+	t.Run("shall reconstruct the object if parity shards' number of instances are unavailable", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, clients := newErasureCodedCluster(8, dataShards, parityShards)
+		data := randomData(2*stripeSize + 100)
+		if err := gateway.Write(context.TODO(), objectID, bytes.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		shards := shardInstances(gateway, objectID)
+		clients[shards[0]].err = errors.New("unavailable")
+		clients[shards[2]].err = errors.New("unavailable")
+
+		// WHEN
+		got, err := readObject(gateway, 0, -1)
+
+		// THEN
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("the read object does not match the written one")
+		}
+		if got := gateway.Metrics().DegradedReads; got != 1 {
+			t.Errorf("unexpected number of degraded reads want: 1, got: %d", got)
+		}
+	})
+
+	t.Run("shall reconstruct the object if the shard fails while it is read", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, clients := newErasureCodedCluster(8, dataShards, parityShards)
+		data := randomData(3 * stripeSize)
+		if err := gateway.Write(context.TODO(), objectID, bytes.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		shards := shardInstances(gateway, objectID)
+		clients[shards[1]].failReadAfter = erasureBlockSize + 10
+
+		// WHEN
+		got, err := readObject(gateway, 0, -1)
+
+		// THEN
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("the read object does not match the written one")
+		}
+	})
+
+	t.Run("shall fail to read if more than parity shards' number of instances are unavailable", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, clients := newErasureCodedCluster(8, dataShards, parityShards)
+		data := randomData(1000)
+		if err := gateway.Write(context.TODO(), objectID, bytes.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for _, instanceID := range shardInstances(gateway, objectID)[3:] {
+			clients[instanceID].err = errors.New("unavailable")
+		}
+
+		// WHEN
+		_, err := readObject(gateway, 0, -1)
+
+		// THEN
+		if !errors.Is(err, ErrNotEnoughShards) {
+			t.Errorf("unexpected error want: %v, got: %v", ErrNotEnoughShards, err)
+		}
+	})
+
+	t.Run("shall read the range of the object", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, clients := newErasureCodedCluster(8, dataShards, parityShards)
+		data := randomData(3*stripeSize + 100)
+		if err := gateway.Write(context.TODO(), objectID, bytes.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		clients[shardInstances(gateway, objectID)[1]].err = errors.New("unavailable")
+
+		tests := []struct {
+			offset, length int64
+		}{
+			{offset: 0, length: 10},
+			{offset: 10, length: -1},
+			{offset: stripeSize - 5, length: 10},
+			{offset: stripeSize + erasureBlockSize + 3, length: 2 * stripeSize},
+			{offset: 3*stripeSize + 99, length: 10},
+			{offset: 3*stripeSize + 100, length: -1},
+		}
+		for _, tt := range tests {
+			// WHEN
+			got, err := readObject(gateway, tt.offset, tt.length)
+
+			// THEN
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			want := data[tt.offset:]
+			if tt.length >= 0 && tt.length < int64(len(want)) {
+				want = want[:tt.length]
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("unexpected range %d-%d: want %d bytes, got %d bytes",
+					tt.offset, tt.length, len(want), len(got))
+			}
+		}
+	})
+
+	t.Run("shall reach the write quorum if one instance is unavailable", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, clients := newErasureCodedCluster(8, dataShards, parityShards)
+		clients[shardInstances(gateway, objectID)[5]].err = errors.New("unavailable")
+		data := randomData(1000)
+
+		// WHEN
+		err := gateway.Write(context.TODO(), objectID, bytes.NewReader(data), int64(len(data)))
+
+		// THEN
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := gateway.Metrics().DegradedWrites; got != 1 {
+			t.Errorf("unexpected number of degraded writes want: 1, got: %d", got)
+		}
+		if got, err := readObject(gateway, 0, -1); err != nil || !bytes.Equal(got, data) {
+			t.Errorf("the object is expected to be read, got: %v", err)
+		}
+	})
+
+	t.Run("shall fail if the write quorum is not reached", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, clients := newErasureCodedCluster(8, dataShards, parityShards)
+		for _, instanceID := range shardInstances(gateway, objectID)[:2] {
+			clients[instanceID].err = errors.New("unavailable")
+		}
+		data := randomData(1000)
+
+		// WHEN
+		err := gateway.Write(context.TODO(), objectID, bytes.NewReader(data), int64(len(data)))
+
+		// THEN
+		if !errors.Is(err, ErrWriteQuorum) {
+			t.Errorf("unexpected error want: %v, got: %v", ErrWriteQuorum, err)
+		}
+	})
+
+	t.Run("shall fail if the cluster is smaller than the number of shards", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, _ := newErasureCodedCluster(5, dataShards, parityShards)
+
+		// WHEN
+		err := gateway.Write(context.TODO(), objectID, strings.NewReader("qux"), 3)
+
+		// THEN
+		if !errors.Is(err, ErrWriteQuorum) {
+			t.Errorf("unexpected error want: %v, got: %v", ErrWriteQuorum, err)
+		}
+	})
+
+	t.Run("shall delete the shards and the manifests", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, clients := newErasureCodedCluster(8, dataShards, parityShards)
+		data := randomData(1000)
+		if err := gateway.Write(context.TODO(), objectID, bytes.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// WHEN
+		deleted, err := gateway.Delete(context.TODO(), objectID)
+
+		// THEN
+		if err != nil || !deleted {
+			t.Fatalf("the object is expected to be deleted, got: %v, %v", deleted, err)
+		}
+		for instanceID, client := range clients {
+			if names := client.names(); len(names) > 0 {
+				t.Errorf("no objects expected in %s, got: %v", instanceID, names)
+			}
+		}
+		if _, found, err := gateway.Read(context.TODO(), objectID, 0, -1); err != nil || found {
+			t.Errorf("the object is not expected to be found, got: %v, %v", found, err)
+		}
+	})
+}
+
+func TestGateway_erasureCoding_generations(t *testing.T) {
+	t.Parallel()
+
+	const (
+		objectID     = "obj"
+		dataShards   = 4
+		parityShards = 2
+		stripeSize   = dataShards * erasureBlockSize
+	)
+
+	readObject := func(gateway *Gateway) ([]byte, error) {
+		reader, found, err := gateway.Read(context.TODO(), objectID, 0, -1)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, errors.New("object not found")
+		}
+		defer func() { _ = reader.Close() }()
+		return io.ReadAll(reader)
+	}
+
+	// wantStored checks that every instance stores the manifest, and the shard of the given generation only
+	wantStored := func(t *testing.T, gateway *Gateway, clients map[string]*memoryStorageClient, generation string) {
+		t.Helper()
+
+		shards := shardInstances(gateway, objectID)
+		for instanceID, client := range clients {
+			var want []string
+			if i := slices.Index(shards, instanceID); i >= 0 {
+				want = []string{manifestObjectName(objectID), shardObjectName(objectID, generation, i)}
+			}
+			if got := client.names(); !slices.Equal(got, want) {
+				t.Errorf("unexpected objects in %s want: %v, got: %v", instanceID, want, got)
+			}
+		}
+	}
+
+	t.Run("shall delete the previous generation after the overwrite", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, clients := newErasureCodedCluster(8, dataShards, parityShards)
+		previous, data := randomData(1000), randomData(2000)
+		if err := gateway.Write(context.TODO(), objectID, bytes.NewReader(previous), int64(len(previous))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		previousGeneration := currentManifest(t, gateway, objectID).Generation
+
+		// WHEN
+		err := gateway.Write(context.TODO(), objectID, bytes.NewReader(data), int64(len(data)))
+
+		// THEN
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		generation := currentManifest(t, gateway, objectID).Generation
+		if generation == previousGeneration {
+			t.Fatalf("the new generation is expected to be written")
+		}
+		wantStored(t, gateway, clients, generation)
+		if got, err := readObject(gateway); err != nil || !bytes.Equal(got, data) {
+			t.Errorf("the last written object is expected to be read, got: %v", err)
+		}
+	})
+
+	tests := []struct {
+		name string
+		// failures injects failures to the instances storing the shards in the order of shards
+		// after the object was overwritten while the last of them was unavailable
+		failures func(shards []string, clients map[string]*memoryStorageClient)
+		wantErr  error
+	}{
+		{
+			name:     "shall read the last generation when the instance which missed it is back",
+			failures: func([]string, map[string]*memoryStorageClient) {},
+		},
+		{
+			name: "shall read the last generation when the instance which missed it is back and another one is down",
+			failures: func(shards []string, clients map[string]*memoryStorageClient) {
+				clients[shards[0]].err = errors.New("unavailable")
+			},
+		},
+		{
+			name: "shall not mix the generations when the instance which missed the last one is back",
+			failures: func(shards []string, clients map[string]*memoryStorageClient) {
+				clients[shards[0]].err = errors.New("unavailable")
+				clients[shards[1]].err = errors.New("unavailable")
+			},
+			wantErr: ErrNotEnoughShards,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// GIVEN
+			gateway, clients := newErasureCodedCluster(8, dataShards, parityShards)
+			previous, data := randomData(1000), randomData(2000)
+			err := gateway.Write(context.TODO(), objectID, bytes.NewReader(previous), int64(len(previous)))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			shards := shardInstances(gateway, objectID)
+			clients[shards[5]].err = errors.New("unavailable")
+			if err := gateway.Write(context.TODO(), objectID, bytes.NewReader(data), int64(len(data))); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			clients[shards[5]].err = nil
+
+			// the previous generation is deleted except in the instance which missed the last one
+			generation := currentManifest(t, gateway, objectID).Generation
+			for i, instanceID := range shards[:5] {
+				want := []string{manifestObjectName(objectID), shardObjectName(objectID, generation, i)}
+				if got := clients[instanceID].names(); !slices.Equal(got, want) {
+					t.Fatalf("unexpected objects in %s want: %v, got: %v", instanceID, want, got)
+				}
+			}
+			tt.failures(shards, clients)
+
+			// WHEN
+			got, err := readObject(gateway)
+
+			// THEN
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unexpected error want: %v, got: %v", tt.wantErr, err)
+			}
+			if tt.wantErr == nil && !bytes.Equal(got, data) {
+				t.Errorf("the last written object is expected to be read")
+			}
+		})
+	}
+
+	failedWrites := []struct {
+		name string
+		// failures injects failures to the instances storing the shards in the order of shards
+		failures func(shards []string, clients map[string]*memoryStorageClient)
+	}{
+		{
+			name: "shall keep reading the previous generation if the shards' write quorum is not reached",
+			failures: func(shards []string, clients map[string]*memoryStorageClient) {
+				clients[shards[0]].err = errors.New("unavailable")
+				clients[shards[3]].err = errors.New("unavailable")
+			},
+		},
+		{
+			name: "shall keep reading the previous generation if the manifests' write quorum is not reached",
+			failures: func(shards []string, clients map[string]*memoryStorageClient) {
+				for _, instanceID := range shards[:2] {
+					clients[instanceID].writeErrs = map[string]error{
+						manifestObjectName(objectID): errors.New("disk full"),
+					}
+				}
+			},
+		},
+	}
+	for _, tt := range failedWrites {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// GIVEN
+			gateway, clients := newErasureCodedCluster(8, dataShards, parityShards)
+			previous, data := randomData(1000), randomData(2000)
+			err := gateway.Write(context.TODO(), objectID, bytes.NewReader(previous), int64(len(previous)))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			previousGeneration := currentManifest(t, gateway, objectID).Generation
+
+			shards := shardInstances(gateway, objectID)
+			tt.failures(shards, clients)
+
+			// WHEN
+			err = gateway.Write(context.TODO(), objectID, bytes.NewReader(data), int64(len(data)))
+
+			// THEN
+			if !errors.Is(err, ErrWriteQuorum) {
+				t.Fatalf("unexpected error want: %v, got: %v", ErrWriteQuorum, err)
+			}
+
+			for _, client := range clients {
+				client.err, client.writeErrs = nil, nil
+			}
+			wantStored(t, gateway, clients, previousGeneration)
+			if got, err := readObject(gateway); err != nil || !bytes.Equal(got, previous) {
+				t.Errorf("the previous object is expected to be read, got: %v", err)
+			}
+			info, _, err := gateway.Stat(context.TODO(), objectID)
+			if wantETag := fmt.Sprintf("%x", md5.Sum(previous)); err != nil || info.ETag != wantETag {
+				t.Errorf("unexpected ETag want: %s, got: %s, %v", wantETag, info.ETag, err)
+			}
+		})
+	}
+
+	t.Run("shall reconstruct the object if the shard's checksum does not match", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, clients := newErasureCodedCluster(8, dataShards, parityShards)
+		data := randomData(1000)
+		if err := gateway.Write(context.TODO(), objectID, bytes.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		generation := currentManifest(t, gateway, objectID).Generation
+		clients[shardInstances(gateway, objectID)[0]].objects[shardObjectName(objectID, generation, 0)][0] ^= 0xff
+
+		// WHEN
+		got, err := readObject(gateway)
+
+		// THEN
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("the read object does not match the written one")
+		}
+		if got := gateway.Metrics().DegradedReads; got != 1 {
+			t.Errorf("unexpected number of degraded reads want: 1, got: %d", got)
+		}
+	})
+
+	t.Run("shall fail to read if the checksum of the shard read partially does not match", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, clients := newErasureCodedCluster(8, dataShards, parityShards)
+		data := randomData(2*stripeSize + 100)
+		if err := gateway.Write(context.TODO(), objectID, bytes.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		generation := currentManifest(t, gateway, objectID).Generation
+		clients[shardInstances(gateway, objectID)[0]].objects[shardObjectName(objectID, generation, 0)][0] ^= 0xff
+
+		// WHEN
+		_, err := readObject(gateway)
+
+		// THEN
+		if !errors.Is(err, ErrShardChecksumMismatch) {
+			t.Errorf("unexpected error want: %v, got: %v", ErrShardChecksumMismatch, err)
+		}
+	})
+}
+
+func TestGateway_List_erasureCoded(t *testing.T) {
+	// GIVEN
+	gateway, clients := newErasureCodedCluster(8, 2, 1)
+	var wantIDs []string
+	for i := 0; i < 7; i++ {
+		id := fmt.Sprintf("obj%d", i)
+		data := randomData(10 + i)
+		if err := gateway.Write(context.TODO(), id, bytes.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		wantIDs = append(wantIDs, id)
+	}
+	// the object written before erasure coding was enabled
+	_ = clients["node0"].Write(context.TODO(), "", "obj3", strings.NewReader("qux"), 3)
+
+	// WHEN
+	var (
+		gotIDs []string
+		token  string
+	)
+	for {
+		page, err := gateway.List(context.TODO(), "", token, 2)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, obj := range page.Objects {
+			gotIDs = append(gotIDs, obj.ID)
+			if obj.InstanceID == "node0" && obj.ID == "obj3" && obj.Size == 3 {
+				continue
+			}
+			if want := int64(10 + obj.ID[3] - '0'); obj.Size != want {
+				t.Errorf("unexpected size of %s want: %d, got: %d", obj.ID, want, obj.Size)
+			}
+		}
+		if token = page.ContinuationToken; token == "" {
+			break
+		}
+	}
+
+	// THEN
+	wantIDs = slices.Insert(wantIDs, 3, "obj3")
+	if !slices.Equal(gotIDs, wantIDs) {
+		t.Errorf("unexpected listing want: %v, got: %v", wantIDs, gotIDs)
+	}
+}
+
+func TestNew_erasureCoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr bool
+	}{
+		{name: "erasure coding", opts: []Option{WithErasureCoding(4, 2)}},
+		{name: "no parity shards", opts: []Option{WithErasureCoding(4, 0)}, wantErr: true},
+		{name: "too many shards", opts: []Option{WithErasureCoding(200, 100)}, wantErr: true},
+		{
+			name:    "combined with replication",
+			opts:    []Option{WithErasureCoding(4, 2), WithReplication(3, 2)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// WHEN
+			_, err := New(mockClusterPrefix, "", &mockStorageDiscoveryClient{}, &mockStorageDiscoveryClient{},
+				mockMinioConnectionFactory(nil, &mockStorageClient{}), slog.Default(), tt.opts...,
+			)
+
+			// THEN
+			if (err != nil) != tt.wantErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package gateway
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+)
+
+// DefaultFindConcurrency the default max number of instances probed concurrently to find the object.
+const DefaultFindConcurrency = 16
+
+// WithFindConcurrency sets the max number of instances probed concurrently to find the object.
+func WithFindConcurrency(n int) Option {
+	return func(g *Gateway) {
+		g.findConcurrency = n
+	}
+}
+
+type findResult struct {
+	instanceID string
+	conn       ObjectReadWriteFinder
+	found      bool
+	err        error
+}
+
+// findObject searches the object in the replicas selected by the placement strategy first, the replicas are
+// probed one by one in the order of preference. It falls back to the scan of other instances if the object is
+// not found in the replicas. The error is only returned if the object is not found, and at least one probe failed.
+func (s *Gateway) findObject(ctx context.Context, instances map[string]string, id, operation string) (
+	instanceID string, conn ObjectReadWriteFinder, found bool, err error,
+) {
+	replicas := s.pickReplicas(instances, id)
+
+	var replicaErr error
+	for _, replicaID := range replicas {
+		r := s.probeInstance(ctx, replicaID, instances[replicaID], id, operation)
+		if r.found {
+			return r.instanceID, r.conn, true, nil
+		}
+
+		if r.err != nil {
+			s.Logger.Debug("replica unavailable",
+				slog.String("operation", operation),
+				slog.String("instanceID", replicaID),
+				slog.String("objectID", id),
+				slog.String("error", r.err.Error()),
+			)
+			if replicaErr == nil {
+				replicaErr = r.err
+			}
+		}
+	}
+
+	instanceID, conn, found, err = s.findMisplacedObject(ctx, instances, replicas, id, operation)
+	if found {
+		return instanceID, conn, true, nil
+	}
+
+	if replicaErr != nil {
+		return "", nil, false, replicaErr
+	}
+
+	return "", nil, false, err
+}
+
+// findMisplacedObject scans the instances other than the replicas to find the object,
+// e.g. stored before the cluster membership changed.
+func (s *Gateway) findMisplacedObject(
+	ctx context.Context, instances map[string]string, replicas []string, id, operation string,
+) (instanceID string, conn ObjectReadWriteFinder, found bool, err error) {
+	others := excludeInstances(instances, replicas)
+	if len(others) == 0 {
+		return "", nil, false, nil
+	}
+
+	var homeInstanceID string
+	if len(replicas) > 0 {
+		homeInstanceID = replicas[0]
+	}
+
+	s.metrics.placementFallbacks.Add(1)
+	s.Logger.Debug("scanning the cluster, the object is not found in the placement instance",
+		slog.String("operation", operation),
+		slog.String("instanceID", homeInstanceID),
+		slog.String("objectID", id),
+	)
+
+	instanceID, conn, found, err = s.scanInstances(ctx, others, id, operation)
+	if found {
+		s.metrics.misplacedObjects.Add(1)
+		s.Logger.Debug("object found outside the placement instance",
+			slog.String("operation", operation),
+			slog.String("instanceID", instanceID),
+			slog.String("placementInstanceID", homeInstanceID),
+			slog.String("objectID", id),
+		)
+	}
+
+	return instanceID, conn, found, err
+}
+
+// scanInstances probes the instances concurrently to find the object.
+// It returns the instance where the object was found first, outstanding probes are cancelled then.
+// The error is only returned if the object is not found, and at least one probe failed.
+func (s *Gateway) scanInstances(ctx context.Context, instances map[string]string, id, operation string) (
+	instanceID string, conn ObjectReadWriteFinder, found bool, err error,
+) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := s.probeInstances(ctx, instances, id, operation)
+
+	var firstErr error
+	for range instances {
+		r := <-results
+		if r.found {
+			return r.instanceID, r.conn, true, nil
+		}
+		if r.err != nil && firstErr == nil {
+			firstErr = r.err
+		}
+	}
+
+	return "", nil, false, firstErr
+}
+
+// probeInstances probes the instances concurrently, at most findConcurrency at once, to find the object.
+// The result of every probe is sent to the returned channel, the probes not started yet fail
+// once the context is cancelled.
+func (s *Gateway) probeInstances(
+	ctx context.Context, instances map[string]string, id, operation string,
+) <-chan findResult {
+	cntWorkers := s.findConcurrency
+	if cntWorkers <= 0 {
+		cntWorkers = DefaultFindConcurrency
+	}
+	if cntWorkers > len(instances) {
+		cntWorkers = len(instances)
+	}
+
+	jobs := make(chan string, len(instances))
+	for instanceID := range instances {
+		jobs <- instanceID
+	}
+	close(jobs)
+
+	// the buffer lets the workers exit without blocking when the search is over
+	results := make(chan findResult, len(instances))
+	for i := 0; i < cntWorkers; i++ {
+		go func() {
+			for instanceID := range jobs {
+				if ctx.Err() != nil {
+					results <- findResult{instanceID: instanceID, err: ctx.Err()}
+					continue
+				}
+				results <- s.probeInstance(ctx, instanceID, instances[instanceID], id, operation)
+			}
+		}()
+	}
+
+	return results
+}
+
+// excludeInstances returns the instances except the given ones.
+func excludeInstances(instances map[string]string, instanceIDs []string) map[string]string {
+	o := make(map[string]string, len(instances))
+	for instanceID, ipAddress := range instances {
+		if !slices.Contains(instanceIDs, instanceID) {
+			o[instanceID] = ipAddress
+		}
+	}
+	return o
+}
+
+// selectInstances returns the given instances only.
+func selectInstances(instances map[string]string, instanceIDs []string) map[string]string {
+	o := make(map[string]string, len(instanceIDs))
+	for _, instanceID := range instanceIDs {
+		if ipAddress, ok := instances[instanceID]; ok {
+			o[instanceID] = ipAddress
+		}
+	}
+	return o
+}
+
+func (s *Gateway) probeInstance(ctx context.Context, instanceID, ipAddress, id, operation string) findResult {
+	conn, err := s.newStorageInstanceConnection(ctx, instanceID, ipAddress)
+	if err != nil {
+		return findResult{instanceID: instanceID, err: err}
+	}
+
+	s.Logger.Debug("searching",
+		slog.String("operation", operation),
+		slog.String("instanceID", instanceID),
+		slog.String("objectID", id),
+	)
+
+	var found bool
+	conn, err = s.retryOnAuthenticationError(ctx, instanceID, ipAddress, conn,
+		func(conn ObjectReadWriteFinder) (err error) {
+			found, err = conn.Find(ctx, s.storageBucket, id)
+			return err
+		})
+	return findResult{instanceID: instanceID, conn: conn, found: found && err == nil, err: err}
+}
@@ -0,0 +1,221 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newMockCluster initialises the gateway with the cluster of instances with injected latency.
+// The object is stored in the instance with the index foundInx, -1 stands for no stored object.
+func newMockCluster(cntInstances, foundInx int, latency time.Duration) *Gateway {
+	instances := make(map[string]string, cntInstances)
+	clients := make(map[string]ObjectReadWriteFinder, cntInstances)
+	for i := 0; i < cntInstances; i++ {
+		endpoint := fmt.Sprintf("192.0.2.%d", i)
+		instances[fmt.Sprintf("node%d", i)] = endpoint
+		client := &mockStorageClient{latency: latency}
+		if i == foundInx {
+			client.dataReader = strings.NewReader("qux")
+		}
+		clients[endpoint] = client
+	}
+
+	gateway := newMockGateway()
+	gateway.serviceRegistryClient = &mockStorageDiscoveryClient{instances: instances}
+	gateway.newStorageConnectionFn = mockMinioConnectionFactoryByEndpoint(clients)
+	return gateway
+}
+
+func TestGateway_findObject(t *testing.T) {
+	t.Parallel()
+
+	const (
+		cntInstances = 8
+		latency      = 50 * time.Millisecond
+	)
+
+	t.Run("shall probe instances concurrently", func(t *testing.T) {
+		// GIVEN
+		gateway := newMockCluster(cntInstances, -1, latency)
+		gateway.findConcurrency = cntInstances
+
+		// WHEN
+		start := time.Now()
+		_, exists, err := gateway.Read(context.TODO(), "obj", 0, -1)
+		elapsed := time.Since(start)
+
+		// THEN
+		if err != nil {
+			t.Errorf("no error expected, got: %v", err)
+			return
+		}
+		if exists {
+			t.Errorf("object is not expected to be found")
+			return
+		}
+		// sequential probing would take cntInstances*latency
+		if elapsed >= cntInstances*latency/2 {
+			t.Errorf("concurrent probing expected, elapsed: %v", elapsed)
+		}
+	})
+
+	t.Run("shall limit the number of concurrent probes", func(t *testing.T) {
+		// GIVEN
+		const concurrency = 2
+		gateway := newMockCluster(cntInstances, -1, latency)
+		gateway.findConcurrency = concurrency
+
+		// WHEN
+		start := time.Now()
+		_, _, err := gateway.Read(context.TODO(), "obj", 0, -1)
+		elapsed := time.Since(start)
+
+		// THEN
+		if err != nil {
+			t.Errorf("no error expected, got: %v", err)
+			return
+		}
+		if elapsed < cntInstances/concurrency*latency {
+			t.Errorf("at most %d concurrent probes expected, elapsed: %v", concurrency, elapsed)
+		}
+	})
+
+	t.Run("shall cancel outstanding probes when the object is found", func(t *testing.T) {
+		// GIVEN
+		gateway := newMockCluster(cntInstances, -1, time.Minute)
+		gateway.findConcurrency = cntInstances
+
+		instances, _ := gateway.serviceRegistryClient.Scan(context.TODO(), mockClusterPrefix)
+		homeInstanceID := pickStorageInstance(gateway.placement, instances, "obj")
+
+		// all instances but the placement instance and the instance storing the object are slow
+		clients := map[string]ObjectReadWriteFinder{}
+		var objectStored bool
+		for instanceID, endpoint := range instances {
+			switch {
+			case instanceID == homeInstanceID:
+				clients[endpoint] = &mockStorageClient{}
+			case !objectStored:
+				clients[endpoint] = &mockStorageClient{dataReader: strings.NewReader("qux")}
+				objectStored = true
+			default:
+				clients[endpoint] = &mockStorageClient{latency: time.Minute}
+			}
+		}
+		gateway.newStorageConnectionFn = mockMinioConnectionFactoryByEndpoint(clients)
+
+		// WHEN
+		start := time.Now()
+		_, exists, err := gateway.Read(context.TODO(), "obj", 0, -1)
+		elapsed := time.Since(start)
+
+		// THEN
+		if err != nil {
+			t.Errorf("no error expected, got: %v", err)
+			return
+		}
+		if !exists {
+			t.Errorf("object is expected to be found")
+			return
+		}
+		if elapsed > time.Second {
+			t.Errorf("slow probes are expected to be cancelled, elapsed: %v", elapsed)
+		}
+	})
+
+	t.Run("shall return the object found despite failed probes", func(t *testing.T) {
+		// GIVEN
+		gateway := newMockCluster(2, -1, 0)
+		gateway.newStorageConnectionFn = mockMinioConnectionFactoryByEndpoint(map[string]ObjectReadWriteFinder{
+			"192.0.2.0": &mockStorageClient{err: errors.New("foo")},
+			"192.0.2.1": &mockStorageClient{dataReader: strings.NewReader("qux")},
+		})
+
+		// WHEN
+		instanceID, _, found, err := gateway.scanInstances(context.TODO(),
+			map[string]string{"node0": "192.0.2.0", "node1": "192.0.2.1"}, "obj", "read")
+
+		// THEN
+		if err != nil || !found || instanceID != "node1" {
+			t.Errorf("object is expected to be found on node1, got: %s, %v, %v", instanceID, found, err)
+		}
+	})
+}
+
+func TestGateway_findObject_placementFirst(t *testing.T) {
+	t.Parallel()
+
+	const cntInstances = 4
+
+	newGateway := func(objectOnHomeInstance bool) (*Gateway, string) {
+		gateway := newMockCluster(cntInstances, -1, 0)
+		instances, _ := gateway.serviceRegistryClient.Scan(context.TODO(), mockClusterPrefix)
+		homeInstanceID := pickStorageInstance(gateway.placement, instances, "obj")
+
+		clients := map[string]ObjectReadWriteFinder{}
+		var objectInstanceID string
+		for instanceID, endpoint := range instances {
+			client := &mockStorageClient{}
+			switch {
+			case objectOnHomeInstance && instanceID == homeInstanceID:
+				client.dataReader = strings.NewReader("qux")
+				objectInstanceID = instanceID
+			case objectOnHomeInstance:
+				// other instances are not expected to be probed if the object is in the placement instance
+				client.latency = time.Minute
+			case instanceID != homeInstanceID && objectInstanceID == "":
+				client.dataReader = strings.NewReader("qux")
+				objectInstanceID = instanceID
+			}
+			clients[endpoint] = client
+		}
+		gateway.newStorageConnectionFn = mockMinioConnectionFactoryByEndpoint(clients)
+		return gateway, objectInstanceID
+	}
+
+	t.Run("shall find the object in the placement instance without fallback", func(t *testing.T) {
+		// GIVEN
+		gateway, wantInstanceID := newGateway(true)
+
+		// WHEN
+		instances, _ := gateway.serviceRegistryClient.Scan(context.TODO(), mockClusterPrefix)
+		start := time.Now()
+		instanceID, _, found, err := gateway.findObject(context.TODO(), instances, "obj", "read")
+
+		// THEN
+		if err != nil || !found || instanceID != wantInstanceID {
+			t.Errorf("object is expected to be found on %s, got: %s, %v, %v", wantInstanceID, instanceID, found, err)
+			return
+		}
+		if time.Since(start) > time.Second {
+			t.Errorf("other instances are not expected to be probed")
+			return
+		}
+		if got := gateway.Metrics(); got != (Metrics{}) {
+			t.Errorf("no fallback expected, got: %#v", got)
+		}
+	})
+
+	t.Run("shall fall back to the scan of the cluster", func(t *testing.T) {
+		// GIVEN
+		gateway, wantInstanceID := newGateway(false)
+
+		// WHEN
+		instances, _ := gateway.serviceRegistryClient.Scan(context.TODO(), mockClusterPrefix)
+		instanceID, _, found, err := gateway.findObject(context.TODO(), instances, "obj", "read")
+
+		// THEN
+		if err != nil || !found || instanceID != wantInstanceID {
+			t.Errorf("object is expected to be found on %s, got: %s, %v, %v", wantInstanceID, instanceID, found, err)
+			return
+		}
+		want := Metrics{PlacementFallbacks: 1, MisplacedObjects: 1}
+		if got := gateway.Metrics(); got != want {
+			t.Errorf("unexpected metrics want: %#v, got: %#v", want, got)
+		}
+	})
+}
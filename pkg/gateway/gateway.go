@@ -7,6 +7,8 @@ import (
 	"log/slog"
 	"os"
 	"sort"
+	"sync"
+	"time"
 )
 
 // New initializes a Gateway.
@@ -17,6 +19,7 @@ func New(
 	connectionDetailsReader AuthenticationDetailsReader,
 	newStorageConnectionFn StorageConnectionFn,
 	logger *slog.Logger,
+	opts ...Option,
 ) (*Gateway, error) {
 	if serviceRegistryClient == nil {
 		return nil, errors.New("serviceRegistryClient must be not nil")
@@ -40,9 +43,50 @@ func New(
 		serviceRegistryClient:    serviceRegistryClient,
 		connectionDetailsReader:  connectionDetailsReader,
 		newStorageConnectionFn:   newStorageConnectionFn,
+		placement:                defaultPlacement,
+		replicationFactor:        1,
+		writeQuorum:              1,
+		findConcurrency:          DefaultFindConcurrency,
+		connections:              newConnectionPool(DefaultConnectionTTL),
+		credentials:              newCredentialsCache(DefaultCredentialsTTL),
+		writeRetryBufferSize:     DefaultWriteRetryBufferSize,
 		Logger:                   logger,
 	}
 
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.placement == nil {
+		return nil, errors.New("placement strategy must be not nil")
+	}
+
+	if o.replicationFactor < 1 {
+		return nil, errors.New("replication factor must be positive")
+	}
+
+	if o.writeQuorum < 1 || o.writeQuorum > o.replicationFactor {
+		return nil, errors.New("write quorum must be positive and not exceed the replication factor")
+	}
+
+	if o.erasure != nil {
+		if err := o.erasure.validate(); err != nil {
+			return nil, err
+		}
+
+		if o.replicationFactor > 1 {
+			return nil, errors.New("erasure coding cannot be combined with replication")
+		}
+	}
+
+	if o.findConcurrency <= 0 {
+		return nil, errors.New("find concurrency must be positive")
+	}
+
+	if o.writeRetryBufferSize < 0 {
+		return nil, errors.New("write retry buffer size must not be negative")
+	}
+
 	const defaultBucket = "store"
 	if o.storageBucket != "" {
 		o.storageBucket = defaultBucket
@@ -70,104 +114,240 @@ type Gateway struct {
 	connectionDetailsReader AuthenticationDetailsReader
 	newStorageConnectionFn  StorageConnectionFn
 
+	// placement strategy to select the instance to store new objects.
+	placement PlacementStrategy
+	// replicationFactor number of distinct instances to store the object.
+	replicationFactor int
+	// writeQuorum number of replicas which shall acknowledge the write.
+	writeQuorum int
+	// erasure erasure coding configuration, the objects are not erasure coded if nil.
+	erasure *erasureCoding
+	// findConcurrency max number of instances probed concurrently to find the object.
+	findConcurrency int
+	// connections pool of connections to the storage instances.
+	connections *connectionPool
+	// credentials cache of the storage instances' credentials.
+	credentials *credentialsCache
+	// writeRetryBufferSize max number of bytes buffered to retry the write after the authentication failure.
+	writeRetryBufferSize int64
+
+	// draining IDs of the instances excluded from the placement of new objects.
+	draining sync.Map
+	// drainMarks the draining marks shared by the gateways if set.
+	drainMarks *drainMarksCache
+
+	// writes serializes the writes and the deletions of the object given its ID.
+	writes keyedMutex
+	// locker serializes the writes and the deletions of the object across the gateways if set.
+	locker Locker
+
+	// repairs IDs of the objects which replicas are being repaired.
+	repairs   sync.Map
+	repairsWG sync.WaitGroup
+
+	metrics metrics
+
 	Logger *slog.Logger
 }
 
+// defaultPlacement the placement strategy used unless it is set using WithPlacementStrategy.
+var defaultPlacement PlacementStrategy = SumModuloPlacement{}
+
+// Option defines the Gateway's optional configuration.
+type Option func(*Gateway)
+
+// WithPlacementStrategy sets the strategy to select the instance to store new objects.
+// SumModuloPlacement is used by default.
+func WithPlacementStrategy(p PlacementStrategy) Option {
+	return func(g *Gateway) {
+		g.placement = p
+	}
+}
+
 // Read reads the object given its ID.
-func (s *Gateway) Read(ctx context.Context, id string) (io.ReadCloser, bool, error) {
-	instances, err := s.serviceRegistryClient.Scan(ctx, s.storageInstancesSelector)
+// The object is read from the freshest replica if the objects are replicated, the outdated replicas are repaired
+// asynchronously. The erasure coded object is reconstructed from its shards if erasure coding is enabled.
+// The object's content is read starting from the offset in bytes; length defines the number of bytes to read,
+// it can be set to -1 to read the object until the end.
+func (s *Gateway) Read(ctx context.Context, id string, offset, length int64) (io.ReadCloser, bool, error) {
+	instances, err := s.scanStorageInstances(ctx)
 	if err != nil {
 		return nil, false, err
 	}
 
-	if len(instances) == 0 {
-		return nil, false, errors.New("cannot identify storage instances, check if cluster is running")
+	if s.erasure != nil {
+		reader, found, err := s.readErasureCoded(ctx, instances, id, offset, length)
+		if err != nil || found {
+			return reader, found, err
+		}
 	}
 
-	// go round-robin over all hosts and try to find requested object.
-	for instanceID, ipAddress := range instances {
-		conn, err := s.newStorageInstanceConnection(ctx, instanceID, ipAddress)
-		if err != nil {
-			return nil, false, err
-		}
+	if s.replicationFactor > 1 {
+		return s.readReplicas(ctx, instances, id, offset, length)
+	}
 
-		s.Logger.Debug("searching",
-			slog.String("operation", "read"),
-			slog.String("instanceID", instanceID),
-			slog.String("objectID", id),
-		)
+	instanceID, conn, found, err := s.findObject(ctx, instances, id, "read")
+	if err != nil || !found {
+		return nil, false, err
+	}
+
+	return s.readObject(ctx, instanceID, instances[instanceID], conn, id, offset, length)
+}
 
-		found, err := conn.Find(ctx, s.storageBucket, id)
+// Stat reads the object's metadata given its ID.
+// The metadata is read from the freshest replica, the unavailable replicas are skipped. The rest of the cluster
+// is scanned if the object is not found in the replicas. The error is only returned if the object is not found,
+// and at least one instance failed.
+func (s *Gateway) Stat(ctx context.Context, id string) (ObjectInfo, bool, error) {
+	instances, err := s.scanStorageInstances(ctx)
+	if err != nil {
+		return ObjectInfo{}, false, err
+	}
+
+	if s.erasure != nil {
+		manifest, found, err := s.findManifest(ctx, instances, id, "stat")
 		if err != nil {
-			return nil, false, err
+			return ObjectInfo{}, false, err
 		}
-
 		if found {
-			s.Logger.Debug("reading",
-				slog.String("operation", "read"),
-				slog.String("instanceID", instanceID),
-				slog.String("objectID", id),
-			)
+			return manifest.objectInfo(id), true, nil
+		}
+	}
 
-			dataReadCloser, _, err := conn.Read(ctx, s.storageBucket, id)
-			if err != nil {
-				return nil, false, err
-			}
+	replicas := s.pickReplicas(instances, id)
+	states := s.statReplicas(ctx, instances, replicas, id, "stat")
 
-			return dataReadCloser, found, nil
+	freshest, replicaErr := s.freshestReplica(states, id, "stat")
+	if freshest != nil {
+		return freshest.info, true, nil
+	}
+
+	instanceID, conn, found, err := s.findMisplacedObject(ctx, instances, replicas, id, "stat")
+	if !found {
+		if replicaErr != nil {
+			return ObjectInfo{}, false, replicaErr
 		}
+		return ObjectInfo{}, false, err
+	}
+
+	_, info, found, err := s.statObject(ctx, instanceID, instances[instanceID], conn, id)
+	if err != nil {
+		return ObjectInfo{}, false, err
 	}
 
-	return nil, false, nil
+	return info, found, nil
 }
 
 // Write writes object to the storage.
-func (s *Gateway) Write(ctx context.Context, id string, reader io.Reader, objectSizeBytes int64) error {
-	instances, err := s.serviceRegistryClient.Scan(ctx, s.storageInstancesSelector)
+// The existing object is overwritten in the instance where it is found if the objects are not replicated,
+// unless the instance is draining, otherwise the object is written to the replicas selected by the placement strategy.
+// The object is split into shards written to distinct instances if erasure coding is enabled.
+// The writes of the same object are serialized, and across the gateways if the Locker is set. The copies of the object
+// stored outside the instances it was written to are deleted after the write if the cluster membership changed
+// during the write, they are deleted by the Rebalancer otherwise. The error wrapping ErrLockLost is returned
+// if the write failed after the object's lock was lost.
+func (s *Gateway) Write(ctx context.Context, id string, reader io.Reader, objectSizeBytes int64) (err error) {
+	ctx, unlock, err := s.lock(ctx, id)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		err = lockLostError(ctx, err)
+		unlock()
+	}()
 
-	if len(instances) == 0 {
-		return errors.New("cannot identify storage instances, check if cluster is running")
+	instances, err := s.scanStorageInstances(ctx)
+	if err != nil {
+		return err
 	}
 
-	// go round-robin over all hosts to find if the object is stored to one of storage nodes
-	// it's required to ensure the "sticky"-condition: overwrite already existing object
-	for instanceID, ipAddress := range instances {
-		s.Logger.Debug("searching",
-			slog.String("operation", "write"),
-			slog.String("instanceID", instanceID),
-			slog.String("objectID", id),
-		)
+	if s.erasure != nil {
+		// the object's previous generation is deleted once the new one is written, it is left in the storage
+		// if its manifest cannot be found, e.g. because the instances are unavailable
+		previous, previousFound, err := s.findManifest(ctx, instances, id, "write")
+		if err != nil {
+			s.Logger.Debug("cannot find the previous generation of the object",
+				slog.String("operation", "write"),
+				slog.String("objectID", id),
+				slog.String("error", err.Error()),
+			)
+		}
 
-		var conn ObjectReadWriteFinder
-		conn, err = s.newStorageInstanceConnection(ctx, instanceID, ipAddress)
+		manifest, err := s.writeErasureCoded(ctx, instances, id, reader, objectSizeBytes, previous)
 		if err != nil {
 			return err
 		}
 
-		var found bool
-		found, err = conn.Find(ctx, s.storageBucket, id)
-		if err != nil {
+		if previousFound {
+			if err := s.deleteStaleLayout(ctx, instances, id, previous, manifest); err != nil {
+				s.Logger.Warn("failed to delete the previous generation of the object",
+					slog.String("operation", "write"),
+					slog.String("objectID", id),
+					slog.String("generation", previous.Generation),
+					slog.String("error", err.Error()),
+				)
+			}
+		}
+
+		// the plain copies, e.g. written before the erasure coding was enabled, are shadowed by the manifest,
+		// the copy stored in the instance selected by the placement strategy is deleted after every write
+		placed := selectInstances(instances, s.pickReplicas(instances, id))
+		s.logDuplicatesError(id, s.deleteDuplicates(ctx, placed, id, nil))
+
+		s.deleteDuplicatesIfMembershipChanged(ctx, instances, id, func(instances map[string]string) error {
+			return errors.Join(
+				s.deleteDuplicateLayouts(ctx, instances, id, manifest),
+				s.deleteDuplicates(ctx, instances, id, nil),
+			)
+		})
+		return nil
+	}
+
+	if s.replicationFactor > 1 {
+		if err := s.writeReplicas(ctx, instances, id, reader, objectSizeBytes); err != nil {
 			return err
 		}
 
-		if found {
+		replicas := s.pickReplicas(instances, id)
+		s.deleteDuplicatesIfMembershipChanged(ctx, instances, id, func(instances map[string]string) error {
+			return s.deleteDuplicates(ctx, instances, id, replicas)
+		})
+		return nil
+	}
+
+	// search the cluster to find if the object is stored to one of storage nodes
+	// it's required to ensure the "sticky"-condition: overwrite already existing object
+	instanceID, conn, found, err := s.findObject(ctx, instances, id, "write")
+	if err != nil {
+		return err
+	}
+
+	// the object found in the draining instance is written to the instance selected by the placement strategy,
+	// its copy in the draining instance is deleted afterward
+	var (
+		drainingInstanceID string
+		drainingConn       ObjectReadWriteFinder
+	)
+	if found {
+		if !s.isDraining(instanceID) {
 			s.Logger.Debug("overwriting",
 				slog.String("operation", "write"),
 				slog.String("instanceID", instanceID),
 				slog.String("objectID", id),
 			)
 
-			return conn.Write(ctx, s.storageBucket, id, reader, objectSizeBytes)
+			return s.writeSingleCopy(ctx, instances, instanceID, conn, id, reader, objectSizeBytes)
 		}
+		drainingInstanceID, drainingConn = instanceID, conn
 	}
 
 	// define the instance to store new object
-	instanceID := pickStorageInstance(instances, id)
+	instanceID = pickStorageInstance(s.placement, s.placementInstances(instances), id)
+	if instanceID == "" {
+		return ErrNoPlacementInstances
+	}
 
-	conn, err := s.newStorageInstanceConnection(ctx, instanceID, instances[instanceID])
+	conn, err = s.newStorageInstanceConnection(ctx, instanceID, instances[instanceID])
 	if err != nil {
 		return err
 	}
@@ -178,44 +358,167 @@ func (s *Gateway) Write(ctx context.Context, id string, reader io.Reader, object
 		slog.String("objectID", id),
 	)
 
-	return conn.Write(ctx, s.storageBucket, id, reader, objectSizeBytes)
+	if err := s.writeSingleCopy(ctx, instances, instanceID, conn, id, reader, objectSizeBytes); err != nil ||
+		drainingInstanceID == "" {
+		return err
+	}
+
+	s.logDuplicatesError(id,
+		s.deleteObject(ctx, drainingInstanceID, instances[drainingInstanceID], drainingConn, id))
+	return nil
+}
+
+// writeSingleCopy writes the object to the instance, and deletes its copies stored in the rest of the instances
+// if the cluster membership changed during the write.
+func (s *Gateway) writeSingleCopy(
+	ctx context.Context, instances map[string]string, instanceID string, conn ObjectReadWriteFinder,
+	id string, reader io.Reader, objectSizeBytes int64,
+) error {
+	if err := s.writeObject(ctx, instanceID, instances[instanceID], conn, id, reader, objectSizeBytes); err != nil {
+		return err
+	}
+
+	s.deleteDuplicatesIfMembershipChanged(ctx, instances, id, func(instances map[string]string) error {
+		return s.deleteDuplicates(ctx, instances, id, []string{instanceID})
+	})
+	return nil
 }
 
+// logDuplicatesError logs the failure to delete the duplicates of the written object. The write is not failed,
+// because the object is stored, the duplicates are deleted by the Rebalancer.
+func (s *Gateway) logDuplicatesError(id string, err error) {
+	if err != nil {
+		s.Logger.Warn("failed to delete the duplicates",
+			slog.String("operation", "write"),
+			slog.String("objectID", id),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// Delete deletes all copies of the object given its ID, and the shards of the erasure coded object.
+// It returns false if the object was not found in any storage instance. The error wrapping ErrLockLost is returned
+// if the deletion failed after the object's lock was lost.
+func (s *Gateway) Delete(ctx context.Context, id string) (_ bool, err error) {
+	ctx, unlock, err := s.lock(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		err = lockLostError(ctx, err)
+		unlock()
+	}()
+
+	instances, err := s.scanStorageInstances(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// go over all hosts to ensure that no copy of the object is left in the cluster
+	var deleted bool
+	for instanceID, ipAddress := range instances {
+		conn, err := s.newStorageInstanceConnection(ctx, instanceID, ipAddress)
+		if err != nil {
+			return deleted, err
+		}
+
+		s.Logger.Debug("searching",
+			slog.String("operation", "delete"),
+			slog.String("instanceID", instanceID),
+			slog.String("objectID", id),
+		)
+
+		var found bool
+		conn, err = s.retryOnAuthenticationError(ctx, instanceID, ipAddress, conn,
+			func(conn ObjectReadWriteFinder) (err error) {
+				found, err = conn.Find(ctx, s.storageBucket, id)
+				return err
+			})
+		if err != nil {
+			return deleted, err
+		}
+
+		if !found {
+			continue
+		}
+
+		s.Logger.Debug("deleting",
+			slog.String("operation", "delete"),
+			slog.String("instanceID", instanceID),
+			slog.String("objectID", id),
+		)
+
+		if _, err := s.retryOnAuthenticationError(ctx, instanceID, ipAddress, conn,
+			func(conn ObjectReadWriteFinder) error {
+				return conn.Delete(ctx, s.storageBucket, id)
+			}); err != nil {
+			return deleted, err
+		}
+
+		deleted = true
+	}
+
+	if s.erasure != nil {
+		deletedShards, err := s.deleteErasureCoded(ctx, instances, id)
+		if err != nil {
+			return deleted, err
+		}
+		deleted = deleted || deletedShards
+	}
+
+	return deleted, nil
+}
+
+// scanStorageInstances reads the instances of the storage cluster from the service registry.
+// The pooled connections to the instances which left the cluster are evicted, and the draining marks are refreshed.
+func (s *Gateway) scanStorageInstances(ctx context.Context) (map[string]string, error) {
+	instances, err := s.serviceRegistryClient.Scan(ctx, s.storageInstancesSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	s.connections.retain(instances)
+	s.credentials.retain(instances)
+	s.refreshDrainingInstances(ctx)
+
+	if len(instances) == 0 {
+		return nil, errors.New("cannot identify storage instances, check if cluster is running")
+	}
+
+	return instances, nil
+}
+
+// newStorageInstanceConnection returns the pooled connection to the instance, or establishes new connection.
 func (s *Gateway) newStorageInstanceConnection(ctx context.Context, id, ipAddress string) (
 	ObjectReadWriteFinder,
 	error,
 ) {
-	accessKeyID, secretAccessKey, err := s.connectionDetailsReader.Read(ctx, id)
+	if conn, ok := s.connections.get(id, ipAddress); ok {
+		return conn, nil
+	}
+
+	accessKeyID, secretAccessKey, err := s.readCredentials(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	return s.newStorageConnectionFn(ipAddress, accessKeyID, secretAccessKey)
-}
-
-// pickStorageInstance selects the storage instance.
-func pickStorageInstance(storageInstanceIDs map[string]string, objectID string) (id string) {
-	if len(storageInstanceIDs) == 0 {
-		return ""
+	conn, err := s.newStorageConnectionFn(ipAddress, accessKeyID, secretAccessKey)
+	if err != nil {
+		return nil, s.checkConnectionError(id, err)
 	}
 
-	ids := readSortedMapKeys(storageInstanceIDs)
+	s.connections.put(id, ipAddress, conn)
 
-	switch cntInstances := len(ids); cntInstances {
-	case 1:
-		return ids[0]
-	default:
-		idInx := hash(objectID) % cntInstances
-		return ids[idInx]
-	}
+	return conn, nil
 }
 
-func hash(id string) int {
-	var o int32
-	for _, r := range id {
-		o += r
+// pickStorageInstance selects the storage instance using the placement strategy.
+func pickStorageInstance(placement PlacementStrategy, storageInstanceIDs map[string]string, objectID string) (id string) {
+	if len(storageInstanceIDs) == 0 {
+		return ""
 	}
-	return int(o)
+
+	return placement.Pick(readSortedMapKeys(storageInstanceIDs), objectID)
 }
 
 func readSortedMapKeys(m map[string]string) []string {
@@ -229,6 +532,10 @@ func readSortedMapKeys(m map[string]string) []string {
 	return o
 }
 
+// ErrAuthentication indicates that the storage instance rejected the credentials.
+// The ObjectReadWriteFinder implementations shall wrap it to let the Gateway renew the connection.
+var ErrAuthentication = errors.New("storage authentication failed")
+
 // ServiceRegistryScanner defines the port to query the service registry.
 type ServiceRegistryScanner interface {
 	// Scan scans the "service discovery" records to find instances and return the map ID -> IP address.
@@ -241,16 +548,49 @@ type AuthenticationDetailsReader interface {
 	Read(ctx context.Context, instanceID string) (accessKeyID, secretAccessKey string, err error)
 }
 
+// CredentialsInvalidator defines the optional port of the AuthenticationDetailsReader which caches the credentials.
+// The Gateway invalidates the instance's credentials when the instance rejects them, e.g. after the rotation,
+// so the reader returns the current credentials instead of the cached ones when it is called to retry the operation.
+type CredentialsInvalidator interface {
+	// Invalidate drops the cached credentials of the instance.
+	Invalidate(instanceID string)
+}
+
 // ObjectReadWriteFinder defines the port to the storage instance.
 type ObjectReadWriteFinder interface {
-	// Read reads the object.
-	Read(ctx context.Context, bucketName, objectName string) (io.ReadCloser, bool, error)
+	// Read reads the object starting from the offset in bytes.
+	// The length defines the number of bytes to read, -1 stands for reading until the end of the object.
+	Read(ctx context.Context, bucketName, objectName string, offset, length int64) (io.ReadCloser, bool, error)
 
 	// Write writes the object.
 	Write(ctx context.Context, bucketName, objectName string, reader io.Reader, objectSizeBytes int64) error
 
 	// Find identifies if the object can be found in the instance.
 	Find(ctx context.Context, bucketName, objectName string) (bool, error)
+
+	// Delete deletes the object.
+	Delete(ctx context.Context, bucketName, objectName string) error
+
+	// Stat reads the object's metadata.
+	Stat(ctx context.Context, bucketName, objectName string) (ObjectInfo, bool, error)
+
+	// List lists up to maxKeys objects sorted by name.
+	// Only the objects with the names starting with the prefix and following startAfter lexically are listed.
+	List(ctx context.Context, bucketName, prefix, startAfter string, maxKeys int) ([]ObjectInfo, error)
+}
+
+// ObjectInfo defines the object's metadata.
+type ObjectInfo struct {
+	// ID object ID.
+	ID string
+	// Size object size in bytes.
+	Size int64
+	// ETag object's entity tag.
+	ETag string
+	// ContentType object's MIME type.
+	ContentType string
+	// LastModified time of the last object's modification.
+	LastModified time.Time
 }
 
 // StorageConnectionFn defines the factory of ObjectReadWriteFinder.
@@ -4,14 +4,11 @@ import (
 	"context"
 	"errors"
 	"io"
-	"log/slog"
 	"reflect"
 	"strings"
 	"testing"
 )
 
-const mockClusterPrefix = "myhost"
-
 func TestGateway_Read(t *testing.T) {
 	t.Parallel()
 
@@ -26,7 +23,7 @@ func TestGateway_Read(t *testing.T) {
 			&mockStorageClient{dataReader: storedDataReader})
 
 		// WHEN
-		got, _, err := gateway.Read(context.TODO(), inputID)
+		got, _, err := gateway.Read(context.TODO(), inputID, 0, -1)
 
 		want := io.NopCloser(storedDataReader)
 		// THEN
@@ -41,13 +38,34 @@ func TestGateway_Read(t *testing.T) {
 		}
 	})
 
+	t.Run("shall read the requested range of the object", func(t *testing.T) {
+		// GIVEN
+		client := &mockStorageClient{dataReader: strings.NewReader("qux")}
+		gateway := newMockGateway()
+		gateway.newStorageConnectionFn = mockMinioConnectionFactory(nil, client)
+
+		// WHEN
+		_, _, err := gateway.Read(context.TODO(), inputID, 1, 2)
+
+		// THEN
+		if err != nil {
+			t.Errorf("no error expected")
+			return
+		}
+
+		if client.readOffset != 1 || client.readLength != 2 {
+			t.Errorf("unexpected range want: 1-2, got: %d-%d", client.readOffset, client.readLength)
+			return
+		}
+	})
+
 	t.Run("shall fail to establish connection to the node", func(t *testing.T) {
 		// GIVEN
 		gateway := newMockGateway()
 		gateway.newStorageConnectionFn = mockMinioConnectionFactory(errors.New("error"), nil)
 
 		// WHEN
-		_, _, err := gateway.Read(context.TODO(), inputID)
+		_, _, err := gateway.Read(context.TODO(), inputID, 0, -1)
 
 		// THEN
 		if err == nil {
@@ -62,7 +80,7 @@ func TestGateway_Read(t *testing.T) {
 		gateway.newStorageConnectionFn = mockMinioConnectionFactory(nil, &mockStorageClient{})
 
 		// WHEN
-		_, exists, err := gateway.Read(context.TODO(), inputID)
+		_, exists, err := gateway.Read(context.TODO(), inputID, 0, -1)
 
 		// THEN
 		if err != nil {
@@ -82,7 +100,72 @@ func TestGateway_Read(t *testing.T) {
 			&mockStorageClient{err: errors.New("foo")})
 
 		// WHEN
-		_, _, err := gateway.Read(context.TODO(), inputID)
+		_, _, err := gateway.Read(context.TODO(), inputID, 0, -1)
+
+		// THEN
+		if err == nil || err.Error() != "foo" {
+			t.Errorf("error expected")
+			return
+		}
+	})
+}
+
+func TestGateway_Stat(t *testing.T) {
+	const inputID = "obj"
+
+	t.Parallel()
+	t.Run("shall successfully return the metadata of existing object", func(t *testing.T) {
+		// GIVEN
+		want := ObjectInfo{Size: 3, ETag: "foo", ContentType: "text/plain"}
+		gateway := newMockGateway()
+		gateway.newStorageConnectionFn = mockMinioConnectionFactory(nil,
+			&mockStorageClient{dataReader: strings.NewReader("qux"), info: want})
+
+		// WHEN
+		got, found, err := gateway.Stat(context.TODO(), inputID)
+
+		// THEN
+		if err != nil {
+			t.Errorf("no error expected")
+			return
+		}
+		if !found {
+			t.Errorf("object is expected to be found")
+			return
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected metadata want: %#v, got: %#v", want, got)
+			return
+		}
+	})
+
+	t.Run(`shall successfully return the status "not found"`, func(t *testing.T) {
+		// GIVEN
+		gateway := newMockGateway()
+		gateway.newStorageConnectionFn = mockMinioConnectionFactory(nil, &mockStorageClient{})
+
+		// WHEN
+		_, found, err := gateway.Stat(context.TODO(), inputID)
+
+		// THEN
+		if err != nil {
+			t.Errorf("no error expected")
+			return
+		}
+		if found {
+			t.Errorf("object is not expected to be found")
+			return
+		}
+	})
+
+	t.Run("shall fail to read the metadata", func(t *testing.T) {
+		// GIVEN
+		gateway := newMockGateway()
+		gateway.newStorageConnectionFn = mockMinioConnectionFactory(nil,
+			&mockStorageClient{err: errors.New("foo")})
+
+		// WHEN
+		_, _, err := gateway.Stat(context.TODO(), inputID)
 
 		// THEN
 		if err == nil || err.Error() != "foo" {
@@ -127,65 +210,68 @@ func TestGateway_Write(t *testing.T) {
 	})
 }
 
-func mockMinioConnectionFactory(err error, rw ObjectReadWriteFinder) StorageConnectionFn {
-	return func(endpoint, accessKeyID, secretAccessKey string) (ObjectReadWriteFinder, error) {
-		if err != nil {
-			return nil, err
-		}
-		return rw, nil
-	}
-}
+func TestGateway_Delete(t *testing.T) {
+	const inputID = "obj"
 
-type mockStorageClient struct {
-	err        error
-	dataReader io.Reader
-}
+	t.Parallel()
+	t.Run("shall successfully delete existing object", func(t *testing.T) {
+		// GIVEN
+		client := &mockStorageClient{dataReader: strings.NewReader("data")}
+		gateway := newMockGateway()
+		gateway.newStorageConnectionFn = mockMinioConnectionFactory(nil, client)
 
-func (m *mockStorageClient) Read(_ context.Context, _, _ string) (io.ReadCloser, bool, error) {
-	if m.err != nil {
-		return nil, false, m.err
-	}
-	return io.NopCloser(m.dataReader), m.dataReader != nil, nil
-}
+		// WHEN
+		found, err := gateway.Delete(context.TODO(), inputID)
 
-func (m *mockStorageClient) Write(_ context.Context, _, _ string, reader io.Reader, _ int64) error {
-	if m.err != nil {
-		return m.err
-	}
-	m.dataReader = reader
-	return nil
-}
+		// THEN
+		if err != nil {
+			t.Errorf("no error expected")
+			return
+		}
+		if !found {
+			t.Errorf("object is expected to be found")
+			return
+		}
+		if client.dataReader != nil {
+			t.Errorf("object is expected to be deleted")
+			return
+		}
+	})
 
-func (m *mockStorageClient) Find(_ context.Context, _, _ string) (bool, error) {
-	return m.dataReader != nil, m.err
-}
+	t.Run(`shall successfully return the status "not found"`, func(t *testing.T) {
+		// GIVEN
+		gateway := newMockGateway()
+		gateway.newStorageConnectionFn = mockMinioConnectionFactory(nil, &mockStorageClient{})
 
-type mockStorageDiscoveryClient struct {
-	err error
-}
+		// WHEN
+		found, err := gateway.Delete(context.TODO(), inputID)
 
-func (m mockStorageDiscoveryClient) Scan(_ context.Context, instanceNameFilter string) (map[string]string, error) {
-	if m.err != nil {
-		return nil, m.err
-	}
-	return map[string]string{instanceNameFilter + "-0": "192.0.2.10"}, nil
-}
+		// THEN
+		if err != nil {
+			t.Errorf("no error expected")
+			return
+		}
+		if found {
+			t.Errorf("object is not expected to be found")
+			return
+		}
+	})
 
-func (m mockStorageDiscoveryClient) Read(_ context.Context, _ string) (accessKeyID, secretAccessKey string, err error) {
-	if m.err != nil {
-		return "", "", m.err
-	}
-	return "foo", "bar", nil
-}
+	t.Run("shall fail to delete the object", func(t *testing.T) {
+		// GIVEN
+		gateway := newMockGateway()
+		gateway.newStorageConnectionFn = mockMinioConnectionFactory(nil,
+			&mockStorageClient{err: errors.New("foo")})
 
-func newMockGateway() *Gateway {
-	return &Gateway{
-		storageInstancesSelector: mockClusterPrefix,
-		serviceRegistryClient:    &mockStorageDiscoveryClient{},
-		connectionDetailsReader:  &mockStorageDiscoveryClient{},
-		newStorageConnectionFn:   mockMinioConnectionFactory(errors.New("undefined"), nil),
-		Logger:                   slog.Default(),
-	}
+		// WHEN
+		_, err := gateway.Delete(context.TODO(), inputID)
+
+		// THEN
+		if err == nil || err.Error() != "foo" {
+			t.Errorf("error expected")
+			return
+		}
+	})
 }
 
 func Test_readSortedMapKeys(t *testing.T) {
@@ -336,7 +422,8 @@ func Test_pickStorageInstance(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if gotID := pickStorageInstance(tt.args.storageInstanceIDs, tt.args.objectID); gotID != tt.wantID {
+			gotID := pickStorageInstance(SumModuloPlacement{}, tt.args.storageInstanceIDs, tt.args.objectID)
+			if gotID != tt.wantID {
 				t.Errorf("pickStorageInstance() = %v, want %v", gotID, tt.wantID)
 			}
 		})
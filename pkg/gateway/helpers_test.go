@@ -0,0 +1,423 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const mockClusterPrefix = "myhost"
+
+func mockMinioConnectionFactory(err error, rw ObjectReadWriteFinder) StorageConnectionFn {
+	return func(endpoint, accessKeyID, secretAccessKey string) (ObjectReadWriteFinder, error) {
+		if err != nil {
+			return nil, err
+		}
+		return rw, nil
+	}
+}
+
+type mockStorageClient struct {
+	err        error
+	dataReader io.Reader
+	info       ObjectInfo
+	objects    []ObjectInfo
+	// latency injected to the Find call
+	latency time.Duration
+
+	readOffset, readLength int64
+}
+
+func (m *mockStorageClient) Read(_ context.Context, _, _ string, offset, length int64) (io.ReadCloser, bool, error) {
+	if m.err != nil {
+		return nil, false, m.err
+	}
+	m.readOffset, m.readLength = offset, length
+	return io.NopCloser(m.dataReader), m.dataReader != nil, nil
+}
+
+func (m *mockStorageClient) Write(_ context.Context, _, _ string, reader io.Reader, _ int64) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.dataReader = reader
+	return nil
+}
+
+func (m *mockStorageClient) Find(ctx context.Context, _, _ string) (bool, error) {
+	if m.latency > 0 {
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(m.latency):
+		}
+	}
+	return m.dataReader != nil, m.err
+}
+
+func (m *mockStorageClient) Delete(_ context.Context, _, _ string) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.dataReader = nil
+	return nil
+}
+
+func (m *mockStorageClient) Stat(_ context.Context, _, _ string) (ObjectInfo, bool, error) {
+	if m.err != nil {
+		return ObjectInfo{}, false, m.err
+	}
+	return m.info, m.dataReader != nil, nil
+}
+
+func (m *mockStorageClient) List(_ context.Context, _, prefix, startAfter string, maxKeys int) ([]ObjectInfo, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	var o []ObjectInfo
+	for _, obj := range m.objects {
+		if len(o) == maxKeys {
+			break
+		}
+		if strings.HasPrefix(obj.ID, prefix) && obj.ID > startAfter {
+			o = append(o, obj)
+		}
+	}
+	return o, nil
+}
+
+// mockMinioConnectionFactoryByEndpoint returns the storage client given the instance's endpoint.
+func mockMinioConnectionFactoryByEndpoint(clients map[string]ObjectReadWriteFinder) StorageConnectionFn {
+	return func(endpoint, accessKeyID, secretAccessKey string) (ObjectReadWriteFinder, error) {
+		rw, ok := clients[endpoint]
+		if !ok {
+			return nil, errors.New("unknown endpoint " + endpoint)
+		}
+		return rw, nil
+	}
+}
+
+type mockStorageDiscoveryClient struct {
+	err       error
+	instances map[string]string
+}
+
+func (m mockStorageDiscoveryClient) Scan(_ context.Context, instanceNameFilter string) (map[string]string, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.instances != nil {
+		return m.instances, nil
+	}
+	return map[string]string{instanceNameFilter + "-0": "192.0.2.10"}, nil
+}
+
+func (m mockStorageDiscoveryClient) Read(_ context.Context, _ string) (accessKeyID, secretAccessKey string, err error) {
+	if m.err != nil {
+		return "", "", m.err
+	}
+	return "foo", "bar", nil
+}
+
+func newMockGateway() *Gateway {
+	return &Gateway{
+		storageInstancesSelector: mockClusterPrefix,
+		serviceRegistryClient:    &mockStorageDiscoveryClient{},
+		connectionDetailsReader:  &mockStorageDiscoveryClient{},
+		newStorageConnectionFn:   mockMinioConnectionFactory(errors.New("undefined"), nil),
+		placement:                defaultPlacement,
+		Logger:                   slog.Default(),
+	}
+}
+
+// memoryStorageClient stores the objects in memory.
+type memoryStorageClient struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	// modified the objects' modification time.
+	modified map[string]time.Time
+	// err the error returned by all calls, e.g. if the instance is unavailable.
+	err error
+	// readErr the error returned by the object's read, the rest of calls succeed.
+	readErr error
+	// failReadAfter the number of bytes returned by the object's reader before it fails if positive.
+	failReadAfter int
+	// failWriteAfter the number of bytes of the object read by the write before it fails if positive.
+	failWriteAfter int64
+	// writeErr the error returned by the write after the object was read.
+	writeErr error
+	// writeErrs the errors returned by the writes of the objects given their names.
+	writeErrs map[string]error
+	// corruptWrites flips the bits of the first byte of every written object.
+	corruptWrites bool
+}
+
+func newMemoryStorageClient() *memoryStorageClient {
+	return &memoryStorageClient{objects: map[string][]byte{}, modified: map[string]time.Time{}}
+}
+
+func (c *memoryStorageClient) Read(_ context.Context, _, objectName string, offset, length int64) (
+	io.ReadCloser, bool, error,
+) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return nil, false, c.err
+	}
+	if c.readErr != nil {
+		return nil, false, c.readErr
+	}
+
+	data, ok := c.objects[objectName]
+	if !ok {
+		return nil, false, nil
+	}
+
+	data = data[min(offset, int64(len(data))):]
+	if length >= 0 && length < int64(len(data)) {
+		data = data[:length]
+	}
+
+	var reader io.Reader = bytes.NewReader(data)
+	if c.failReadAfter > 0 {
+		reader = io.MultiReader(io.LimitReader(reader, int64(c.failReadAfter)),
+			&failingReader{err: errors.New("connection reset")})
+	}
+
+	return io.NopCloser(reader), true, nil
+}
+
+func (c *memoryStorageClient) Write(_ context.Context, _, objectName string, reader io.Reader, size int64) error {
+	if err := c.error(); err != nil {
+		return err
+	}
+
+	if c.failWriteAfter > 0 {
+		_, _ = io.CopyN(io.Discard, reader, c.failWriteAfter)
+		return errors.New("connection reset")
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	if c.writeErr != nil {
+		return c.writeErr
+	}
+	if err := c.writeErrs[objectName]; err != nil {
+		return err
+	}
+
+	if size >= 0 && int64(len(data)) != size {
+		return fmt.Errorf("unexpected object size want: %d, got: %d", size, len(data))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.corruptWrites && len(data) > 0 {
+		data[0] ^= 0xff
+	}
+	c.objects[objectName] = data
+	c.modified[objectName] = time.Now()
+
+	return nil
+}
+
+func (c *memoryStorageClient) Find(_ context.Context, _, objectName string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return false, c.err
+	}
+	_, ok := c.objects[objectName]
+	return ok, nil
+}
+
+func (c *memoryStorageClient) Delete(_ context.Context, _, objectName string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+	delete(c.objects, objectName)
+	delete(c.modified, objectName)
+	return nil
+}
+
+func (c *memoryStorageClient) Stat(_ context.Context, _, objectName string) (ObjectInfo, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return ObjectInfo{}, false, c.err
+	}
+	data, ok := c.objects[objectName]
+	if !ok {
+		return ObjectInfo{}, false, nil
+	}
+	return ObjectInfo{
+		ID:           objectName,
+		Size:         int64(len(data)),
+		ETag:         fmt.Sprintf("%x", md5.Sum(data)),
+		LastModified: c.modified[objectName],
+	}, true, nil
+}
+
+func (c *memoryStorageClient) List(_ context.Context, _, prefix, startAfter string, maxKeys int) (
+	[]ObjectInfo, error,
+) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	var names []string
+	for name := range c.objects {
+		if strings.HasPrefix(name, prefix) && name > startAfter {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var o []ObjectInfo
+	for _, name := range names {
+		if len(o) == maxKeys {
+			break
+		}
+		o = append(o, ObjectInfo{ID: name, Size: int64(len(c.objects[name]))})
+	}
+	return o, nil
+}
+
+func (c *memoryStorageClient) error() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// put stores the object modified at the given time.
+func (c *memoryStorageClient) put(objectName string, data []byte, lastModified time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.objects[objectName] = data
+	c.modified[objectName] = lastModified
+}
+
+// stored returns the object's content, nil if the object is not found.
+func (c *memoryStorageClient) stored(objectName string) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.objects[objectName]
+}
+
+func (c *memoryStorageClient) names() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var o []string
+	for name := range c.objects {
+		o = append(o, name)
+	}
+	sort.Strings(o)
+	return o
+}
+
+// memoryCluster the cluster of instances storing objects in memory, the instances can join and leave the cluster.
+type memoryCluster struct {
+	mu        sync.Mutex
+	instances map[string]string
+	clients   map[string]*memoryStorageClient
+}
+
+func (c *memoryCluster) Scan(context.Context, string) (map[string]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return maps.Clone(c.instances), nil
+}
+
+func (c *memoryCluster) connect(endpoint, _, _ string) (ObjectReadWriteFinder, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	client, ok := c.clients[endpoint]
+	if !ok {
+		return nil, errors.New("unknown endpoint " + endpoint)
+	}
+	return client, nil
+}
+
+// join adds the instance "node{i}" to the cluster.
+func (c *memoryCluster) join(i int) *memoryStorageClient {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	instanceID, endpoint := fmt.Sprintf("node%d", i), fmt.Sprintf("192.0.2.%d", i)
+	c.instances[instanceID] = endpoint
+	c.clients[endpoint] = newMemoryStorageClient()
+	return c.clients[endpoint]
+}
+
+func (c *memoryCluster) leave(instanceID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.instances, instanceID)
+}
+
+func (c *memoryCluster) client(instanceID string) *memoryStorageClient {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.clients[c.instances[instanceID]]
+}
+
+// instanceClients returns the clients of the cluster's instances by instance ID.
+func (c *memoryCluster) instanceClients() map[string]*memoryStorageClient {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	o := make(map[string]*memoryStorageClient, len(c.instances))
+	for instanceID, endpoint := range c.instances {
+		o[instanceID] = c.clients[endpoint]
+	}
+	return o
+}
+
+// locate returns the IDs of the instances which store the object.
+func (c *memoryCluster) locate(objectName string) []string {
+	instances, _ := c.Scan(context.TODO(), "")
+
+	var o []string
+	for _, instanceID := range readSortedMapKeys(instances) {
+		if found, _ := c.client(instanceID).Find(context.TODO(), "", objectName); found {
+			o = append(o, instanceID)
+		}
+	}
+	return o
+}
+
+func newMemoryCluster(cntInstances int) (*Gateway, *memoryCluster) {
+	cluster := &memoryCluster{instances: map[string]string{}, clients: map[string]*memoryStorageClient{}}
+	for i := 0; i < cntInstances; i++ {
+		cluster.join(i)
+	}
+
+	gateway := newMockGateway()
+	gateway.serviceRegistryClient = cluster
+	gateway.newStorageConnectionFn = cluster.connect
+	gateway.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	return gateway, cluster
+}
@@ -0,0 +1,271 @@
+package gateway
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultListLimit the number of objects returned by List if the limit is not set.
+	DefaultListLimit = 100
+	// MaxListLimit the max number of objects returned by List.
+	MaxListLimit = 1000
+
+	continuationTokenVersion = "v1:"
+)
+
+// ErrInvalidContinuationToken indicates that the continuation token cannot be decoded.
+var ErrInvalidContinuationToken = errors.New("invalid continuation token")
+
+// ObjectList defines a page of the listing of objects stored in the cluster.
+type ObjectList struct {
+	// Objects the objects sorted by ID and instance ID.
+	Objects []ListedObject
+	// ContinuationToken the token to read the next page, empty if the last page was read.
+	ContinuationToken string
+}
+
+// ListedObject defines the object's listing entry.
+type ListedObject struct {
+	ObjectInfo
+	// InstanceID the ID of the storage instance which stores the object.
+	// It is the ID of the instance which stores the manifest of the erasure coded object.
+	InstanceID string
+
+	erasureCoded bool
+}
+
+// List lists the objects stored in all instances of the cluster.
+// The objects are sorted by ID, only the objects with the ID starting with the prefix are listed.
+// The limit defines the page size, the continuationToken returned with the page shall be used to read the next page.
+// Note that all copies of the object found in the cluster are listed on the same page,
+// hence the page may contain more entries than the limit.
+func (s *Gateway) List(ctx context.Context, prefix, continuationToken string, limit int) (ObjectList, error) {
+	startAfter, err := decodeContinuationToken(continuationToken)
+	if err != nil {
+		return ObjectList{}, err
+	}
+
+	switch {
+	case limit <= 0:
+		limit = DefaultListLimit
+	case limit > MaxListLimit:
+		limit = MaxListLimit
+	}
+
+	instances, err := s.scanStorageInstances(ctx)
+	if err != nil {
+		return ObjectList{}, err
+	}
+
+	// one extra object is requested from every instance to identify if the next page exists
+	maxKeys := limit + 1
+	if s.erasure != nil {
+		// the instance stores up to three entries per object: the object, its manifest and its shard
+		maxKeys *= 3
+	}
+
+	objects, err := s.listInstances(ctx, instances, prefix, startAfter, maxKeys)
+	if err != nil {
+		return ObjectList{}, err
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		if objects[i].ID == objects[j].ID {
+			return objects[i].InstanceID < objects[j].InstanceID
+		}
+		return objects[i].ID < objects[j].ID
+	})
+
+	objects = dropManifestCopies(objects)
+
+	cnt := len(objects)
+	if cnt > limit {
+		cnt = limit
+		// keep all copies of the last object on the page
+		for cnt < len(objects) && objects[cnt].ID == objects[cnt-1].ID {
+			cnt++
+		}
+	}
+
+	if err := s.readListedManifests(ctx, instances, objects[:cnt]); err != nil {
+		return ObjectList{}, err
+	}
+
+	o := ObjectList{Objects: objects[:cnt]}
+	if cnt < len(objects) {
+		o.ContinuationToken = encodeContinuationToken(objects[cnt-1].ID)
+	}
+
+	return o, nil
+}
+
+// listInstances lists objects in all instances concurrently.
+func (s *Gateway) listInstances(
+	ctx context.Context, instances map[string]string, prefix, startAfter string, maxKeys int,
+) ([]ListedObject, error) {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		o       []ListedObject
+		errList []error
+	)
+
+	for instanceID, ipAddress := range instances {
+		wg.Add(1)
+		go func(instanceID, ipAddress string) {
+			defer wg.Done()
+
+			s.Logger.Debug("listing",
+				slog.String("operation", "list"),
+				slog.String("instanceID", instanceID),
+				slog.String("prefix", prefix),
+			)
+
+			// the internal objects of the erasure coded object named "{ID}.*" follow "{ID}" lexically,
+			// and precede "{ID}/" because object IDs are alphanumeric
+			var instanceStartAfter string
+			if startAfter != "" {
+				instanceStartAfter = startAfter + "/"
+			}
+
+			objects, err := s.listInstance(ctx, instanceID, ipAddress, prefix, instanceStartAfter, maxKeys)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				errList = append(errList, err)
+				return
+			}
+
+			for _, obj := range objects {
+				if isShardObjectName(obj.ID) {
+					continue
+				}
+
+				id, erasureCoded := strings.CutSuffix(obj.ID, manifestObjectNameSuffix)
+				obj.ID = id
+				o = append(o, ListedObject{ObjectInfo: obj, InstanceID: instanceID, erasureCoded: erasureCoded})
+			}
+		}(instanceID, ipAddress)
+	}
+
+	wg.Wait()
+
+	if len(errList) > 0 {
+		return nil, errors.Join(errList...)
+	}
+
+	return o, nil
+}
+
+func (s *Gateway) listInstance(
+	ctx context.Context, instanceID, ipAddress, prefix, startAfter string, maxKeys int,
+) ([]ObjectInfo, error) {
+	conn, err := s.newStorageInstanceConnection(ctx, instanceID, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []ObjectInfo
+	_, err = s.retryOnAuthenticationError(ctx, instanceID, ipAddress, conn,
+		func(conn ObjectReadWriteFinder) (err error) {
+			objects, err = conn.List(ctx, s.storageBucket, prefix, startAfter, maxKeys)
+			return err
+		})
+	return objects, err
+}
+
+// dropManifestCopies keeps only the first copy of the manifest of every erasure coded object
+// in the list sorted by ID.
+func dropManifestCopies(objects []ListedObject) []ListedObject {
+	var lastID string
+	return slices.DeleteFunc(objects, func(obj ListedObject) bool {
+		if !obj.erasureCoded {
+			return false
+		}
+		if obj.ID == lastID {
+			return true
+		}
+		lastID = obj.ID
+		return false
+	})
+}
+
+// readListedManifests reads the manifests of the listed erasure coded objects concurrently
+// to set the objects' metadata.
+func (s *Gateway) readListedManifests(ctx context.Context, instances map[string]string, objects []ListedObject) error {
+	cntWorkers := s.findConcurrency
+	if cntWorkers <= 0 {
+		cntWorkers = DefaultFindConcurrency
+	}
+
+	var (
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, cntWorkers)
+		errList = make([]error, len(objects))
+	)
+
+	for i := range objects {
+		if !objects[i].erasureCoded {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			instanceID, id := objects[i].InstanceID, objects[i].ID
+			conn, err := s.newStorageInstanceConnection(ctx, instanceID, instances[instanceID])
+			if err != nil {
+				errList[i] = err
+				return
+			}
+
+			manifest, found, err := s.readManifest(ctx, instanceID, instances[instanceID], conn, id)
+			switch {
+			case err != nil:
+				errList[i] = err
+			case found:
+				objects[i].ObjectInfo = manifest.objectInfo(id)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	return errors.Join(errList...)
+}
+
+// encodeContinuationToken encodes the ID of the last listed object.
+func encodeContinuationToken(lastObjectID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(continuationTokenVersion + lastObjectID))
+}
+
+// decodeContinuationToken decodes the ID of the last listed object.
+func decodeContinuationToken(token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+
+	v, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", ErrInvalidContinuationToken
+	}
+
+	lastObjectID, ok := strings.CutPrefix(string(v), continuationTokenVersion)
+	if !ok || lastObjectID == "" {
+		return "", ErrInvalidContinuationToken
+	}
+
+	return lastObjectID, nil
+}
@@ -0,0 +1,152 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestGateway_List(t *testing.T) {
+	t.Parallel()
+
+	newGateway := func() *Gateway {
+		gateway := newMockGateway()
+		gateway.serviceRegistryClient = &mockStorageDiscoveryClient{
+			instances: map[string]string{"node0": "192.0.2.10", "node1": "192.0.2.11"},
+		}
+		gateway.newStorageConnectionFn = mockMinioConnectionFactoryByEndpoint(map[string]ObjectReadWriteFinder{
+			"192.0.2.10": &mockStorageClient{objects: []ObjectInfo{{ID: "a", Size: 1}, {ID: "c", Size: 3}}},
+			"192.0.2.11": &mockStorageClient{
+				objects: []ObjectInfo{{ID: "b", Size: 2}, {ID: "c", Size: 3}, {ID: "d", Size: 4}},
+			},
+		})
+		return gateway
+	}
+
+	t.Run("shall list objects of all instances in key order page by page", func(t *testing.T) {
+		// GIVEN
+		gateway := newGateway()
+
+		want := [][]ListedObject{
+			{
+				{ObjectInfo: ObjectInfo{ID: "a", Size: 1}, InstanceID: "node0"},
+				{ObjectInfo: ObjectInfo{ID: "b", Size: 2}, InstanceID: "node1"},
+			},
+			{
+				{ObjectInfo: ObjectInfo{ID: "c", Size: 3}, InstanceID: "node0"},
+				{ObjectInfo: ObjectInfo{ID: "c", Size: 3}, InstanceID: "node1"},
+			},
+			{
+				{ObjectInfo: ObjectInfo{ID: "d", Size: 4}, InstanceID: "node1"},
+			},
+		}
+
+		// WHEN
+		var (
+			got   [][]ListedObject
+			token string
+		)
+		for i := 0; i < len(want)+1; i++ {
+			page, err := gateway.List(context.TODO(), "", token, 2)
+			if err != nil {
+				t.Errorf("no error expected, got: %v", err)
+				return
+			}
+			got = append(got, page.Objects)
+			token = page.ContinuationToken
+			if token == "" {
+				break
+			}
+		}
+
+		// THEN
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected pages want: %#v, got: %#v", want, got)
+			return
+		}
+	})
+
+	t.Run("shall list objects with the prefix", func(t *testing.T) {
+		// GIVEN
+		gateway := newGateway()
+
+		// WHEN
+		page, err := gateway.List(context.TODO(), "d", "", 0)
+
+		// THEN
+		if err != nil {
+			t.Errorf("no error expected, got: %v", err)
+			return
+		}
+
+		want := ObjectList{Objects: []ListedObject{{ObjectInfo: ObjectInfo{ID: "d", Size: 4}, InstanceID: "node1"}}}
+		if !reflect.DeepEqual(page, want) {
+			t.Errorf("unexpected page want: %#v, got: %#v", want, page)
+			return
+		}
+	})
+
+	t.Run("shall list all copies of the last object on the same page", func(t *testing.T) {
+		// GIVEN
+		gateway := newGateway()
+
+		// WHEN
+		page, err := gateway.List(context.TODO(), "", "", 3)
+
+		// THEN
+		if err != nil {
+			t.Errorf("no error expected, got: %v", err)
+			return
+		}
+
+		if len(page.Objects) != 4 || page.ContinuationToken == "" {
+			t.Errorf("four objects and the continuation token expected, got: %#v", page)
+			return
+		}
+	})
+
+	t.Run("shall fail to decode the continuation token", func(t *testing.T) {
+		// GIVEN
+		gateway := newGateway()
+
+		// WHEN
+		_, err := gateway.List(context.TODO(), "", "foo", 0)
+
+		// THEN
+		if !errors.Is(err, ErrInvalidContinuationToken) {
+			t.Errorf("ErrInvalidContinuationToken expected, got: %v", err)
+			return
+		}
+	})
+
+	t.Run("shall fail to list objects of the instance", func(t *testing.T) {
+		// GIVEN
+		gateway := newMockGateway()
+		gateway.newStorageConnectionFn = mockMinioConnectionFactory(nil,
+			&mockStorageClient{err: errors.New("foo")})
+
+		// WHEN
+		_, err := gateway.List(context.TODO(), "", "", 0)
+
+		// THEN
+		if err == nil || err.Error() != "foo" {
+			t.Errorf("error expected")
+			return
+		}
+	})
+}
+
+func Test_continuationToken(t *testing.T) {
+	const lastObjectID = "foo"
+
+	got, err := decodeContinuationToken(encodeContinuationToken(lastObjectID))
+	if err != nil {
+		t.Errorf("no error expected, got: %v", err)
+		return
+	}
+
+	if got != lastObjectID {
+		t.Errorf("decodeContinuationToken() = %v, want %v", got, lastObjectID)
+	}
+}
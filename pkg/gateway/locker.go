@@ -0,0 +1,78 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrLockLost indicates that the lock was lost before it was released, e.g. it expired and was taken over
+// by another owner.
+var ErrLockLost = errors.New("the lock was lost")
+
+// Locker defines the port to serialize the operations per key across the gateways,
+// e.g. the writes of the object given its ID by several gateways behind the load balancer.
+// Note that the lock does not fence the storage instances, i.e. the write sent before the owner noticed that
+// the lock was lost, e.g. because the gateway paused longer than the lock's TTL, is not rejected.
+type Locker interface {
+	// Lock waits until the key is unlocked, or the context is cancelled, and locks the key.
+	// It returns the context derived from ctx which is cancelled with the cause ErrLockLost if the lock is lost,
+	// and the function to unlock the key.
+	Lock(ctx context.Context, key string) (lockCtx context.Context, unlock func(), err error)
+}
+
+// WithLocker sets the Locker to serialize the writes and the deletions of the same object across the gateways.
+// The operations are only serialized by the Gateway itself by default.
+func WithLocker(l Locker) Option {
+	return func(gateway *Gateway) {
+		gateway.locker = l
+	}
+}
+
+// MemoryLocker implements Locker in memory, i.e. it serializes the operations of the gateways
+// running in the same process, e.g. in tests. The zero value is ready to use.
+type MemoryLocker struct {
+	keys keyedMutex
+}
+
+// Lock locks the key. The lock held in memory cannot be lost, hence ctx is returned.
+func (l *MemoryLocker) Lock(ctx context.Context, key string) (context.Context, func(), error) {
+	unlock, err := l.keys.lock(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ctx, unlock, nil
+}
+
+// lock serializes the writes and the deletions of the object given its ID by the Gateway,
+// and across the gateways if the Locker is set. The operation shall use the returned context,
+// which is cancelled if the shared lock is lost.
+func (s *Gateway) lock(ctx context.Context, id string) (context.Context, func(), error) {
+	unlockLocal, err := s.writes.lock(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if s.locker == nil {
+		return ctx, unlockLocal, nil
+	}
+
+	lockCtx, unlockShared, err := s.locker.Lock(ctx, id)
+	if err != nil {
+		unlockLocal()
+		return nil, nil, fmt.Errorf("cannot lock the object %s: %w", id, err)
+	}
+
+	return lockCtx, func() {
+		unlockShared()
+		unlockLocal()
+	}, nil
+}
+
+// lockLostError wraps the operation's error with ErrLockLost if the object's lock was lost during the operation.
+func lockLostError(lockCtx context.Context, err error) error {
+	if err != nil && errors.Is(context.Cause(lockCtx), ErrLockLost) && !errors.Is(err, ErrLockLost) {
+		return fmt.Errorf("%w: %w", ErrLockLost, err)
+	}
+	return err
+}
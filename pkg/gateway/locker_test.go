@@ -0,0 +1,126 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+type mockLocker struct {
+	err error
+	// lost returns the context of the lost lock.
+	lost bool
+}
+
+func (m mockLocker) Lock(ctx context.Context, _ string) (context.Context, func(), error) {
+	if m.err != nil {
+		return nil, nil, m.err
+	}
+	if m.lost {
+		ctx, cancel := context.WithCancelCause(ctx)
+		cancel(ErrLockLost)
+		return ctx, func() {}, nil
+	}
+	return ctx, func() {}, nil
+}
+
+func TestGateway_Write_locker(t *testing.T) {
+	t.Run("shall serialize the writes of the same object across the gateways", func(t *testing.T) {
+		// GIVEN
+		locker := &MemoryLocker{}
+		gateway, cluster := newMemoryCluster(3)
+		WithLocker(locker)(gateway)
+		anotherGateway, _ := newMemoryCluster(0)
+		anotherGateway.serviceRegistryClient = cluster
+		anotherGateway.newStorageConnectionFn = cluster.connect
+		WithLocker(locker)(anotherGateway)
+
+		id := pickMovedObjectID(t, gateway, cluster, 3)
+		instances, _ := cluster.Scan(context.TODO(), "")
+		home := pickStorageInstance(gateway.placement, instances, id)
+
+		first := newBlockingReader([]byte("foo"))
+		errs := make(chan error, 2)
+		go func() { errs <- gateway.Write(context.TODO(), id, first, 3) }()
+		<-first.started
+
+		// WHEN
+		// the membership changes while the first write is in progress
+		cluster.join(3)
+		go func() { errs <- anotherGateway.Write(context.TODO(), id, bytes.NewReader([]byte("bar")), 3) }()
+		waitFor(t, func() bool { return locker.keys.waiters(id) == 2 })
+		close(first.release)
+
+		// THEN
+		for i := 0; i < 2; i++ {
+			if err := <-errs; err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if got := cluster.locate(id); !slices.Equal(got, []string{home}) {
+			t.Errorf("the object is expected to be overwritten in %s, got: %v", home, got)
+		}
+		if got := readContent(t, gateway, id); string(got) != "bar" {
+			t.Errorf("the last write is expected to be read, got: %s", got)
+		}
+		if got := gateway.Metrics().DuplicatesDeleted + anotherGateway.Metrics().DuplicatesDeleted; got != 0 {
+			t.Errorf("no duplicates are expected, got: %d", got)
+		}
+		if got := locker.keys.size(); got != 0 {
+			t.Errorf("the locks are expected to be released, got: %d", got)
+		}
+	})
+
+	t.Run("shall fail if the object cannot be locked", func(t *testing.T) {
+		// GIVEN
+		lockErr := errors.New("unavailable")
+		gateway, cluster := newMemoryCluster(3)
+		WithLocker(mockLocker{err: lockErr})(gateway)
+
+		// WHEN
+		err := gateway.Write(context.TODO(), "obj", bytes.NewReader([]byte("foo")), 3)
+
+		// THEN
+		if !errors.Is(err, lockErr) {
+			t.Errorf("unexpected error want: %v, got: %v", lockErr, err)
+		}
+		if got := cluster.locate("obj"); len(got) > 0 {
+			t.Errorf("the object is not expected to be written, got: %v", got)
+		}
+		if got := gateway.writes.size(); got != 0 {
+			t.Errorf("the gateway's lock is expected to be released, got: %d", got)
+		}
+	})
+	t.Run("shall wrap the write error with ErrLockLost if the lock was lost", func(t *testing.T) {
+		// GIVEN
+		gateway, cluster := newMemoryCluster(1)
+		WithLocker(mockLocker{lost: true})(gateway)
+		cluster.client("node0").writeErr = context.Canceled
+
+		// WHEN
+		err := gateway.Write(context.TODO(), "obj", bytes.NewReader([]byte("foo")), 3)
+
+		// THEN
+		if !errors.Is(err, ErrLockLost) || !errors.Is(err, context.Canceled) {
+			t.Errorf("unexpected error want: %v, got: %v", ErrLockLost, err)
+		}
+	})
+
+	t.Run("shall not wrap the error of the write with the lock held", func(t *testing.T) {
+		// GIVEN
+		writeErr := errors.New("unavailable")
+		gateway, cluster := newMemoryCluster(1)
+		WithLocker(mockLocker{})(gateway)
+		cluster.client("node0").writeErr = writeErr
+
+		// WHEN
+		err := gateway.Write(context.TODO(), "obj", bytes.NewReader([]byte("foo")), 3)
+
+		// THEN
+		if !errors.Is(err, writeErr) || errors.Is(err, ErrLockLost) {
+			t.Errorf("unexpected error want: %v, got: %v", writeErr, err)
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package gateway
+
+import "sync/atomic"
+
+// Metrics defines the snapshot of the Gateway's operational counters.
+type Metrics struct {
+	// PlacementFallbacks the number of lookups which did not find the object in the instance selected
+	// by the placement strategy, and fell back to the scan of the cluster.
+	PlacementFallbacks uint64
+	// MisplacedObjects the number of lookups which found the object outside the instance selected
+	// by the placement strategy, e.g. after the cluster membership changed.
+	MisplacedObjects uint64
+	// AuthenticationRetries the number of operations retried with the re-read credentials
+	// after the storage instance rejected the credentials.
+	AuthenticationRetries uint64
+	// DegradedWrites the number of replicated, or erasure coded writes which reached the write quorum,
+	// but were not acknowledged by all instances.
+	DegradedWrites uint64
+	// ReadRepairs the number of outdated replicas rewritten after the read.
+	ReadRepairs uint64
+	// ReadRepairFailures the number of outdated replicas which failed to be rewritten.
+	ReadRepairFailures uint64
+	// DegradedReads the number of erasure coded objects reconstructed using the parity shards.
+	DegradedReads uint64
+	// DuplicatesDeleted the number of copies of the written object found outside the instances
+	// it was written to, and deleted after the write.
+	DuplicatesDeleted uint64
+}
+
+type metrics struct {
+	placementFallbacks    atomic.Uint64
+	misplacedObjects      atomic.Uint64
+	authenticationRetries atomic.Uint64
+	degradedWrites        atomic.Uint64
+	readRepairs           atomic.Uint64
+	readRepairFailures    atomic.Uint64
+	degradedReads         atomic.Uint64
+	duplicatesDeleted     atomic.Uint64
+}
+
+// Metrics returns the snapshot of the Gateway's counters.
+func (s *Gateway) Metrics() Metrics {
+	return Metrics{
+		PlacementFallbacks:    s.metrics.placementFallbacks.Load(),
+		MisplacedObjects:      s.metrics.misplacedObjects.Load(),
+		AuthenticationRetries: s.metrics.authenticationRetries.Load(),
+		DegradedWrites:        s.metrics.degradedWrites.Load(),
+		ReadRepairs:           s.metrics.readRepairs.Load(),
+		ReadRepairFailures:    s.metrics.readRepairFailures.Load(),
+		DegradedReads:         s.metrics.degradedReads.Load(),
+		DuplicatesDeleted:     s.metrics.duplicatesDeleted.Load(),
+	}
+}
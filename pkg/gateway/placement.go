@@ -0,0 +1,225 @@
+package gateway
+
+import (
+	"errors"
+	"hash/fnv"
+	"strings"
+	"sync"
+)
+
+// PlacementStrategy defines the strategy to select the storage instance for the object.
+type PlacementStrategy interface {
+	// Pick selects the instance given the lexically sorted instance IDs and the object ID.
+	// It returns empty string if no instances provided.
+	Pick(instanceIDs []string, objectID string) string
+}
+
+// RendezvousPlacement selects the instance with the highest random weight (HRW) computed for the pair of the instance
+// and the object IDs. Only ~1/N objects move to the new instance when the cluster of N instances is scaled out.
+type RendezvousPlacement struct{}
+
+// Pick selects the instance with the highest weight.
+func (RendezvousPlacement) Pick(instanceIDs []string, objectID string) string {
+	var (
+		o         string
+		maxWeight uint64
+	)
+
+	objectHash := hash64(objectID)
+	for i, instanceID := range instanceIDs {
+		weight := mix64(hash64(instanceID) ^ objectHash)
+		if i == 0 || weight > maxWeight {
+			o, maxWeight = instanceID, weight
+		}
+	}
+
+	return o
+}
+
+// JumpHashPlacement selects the instance using the jump consistent hash algorithm
+// by J. Lamping and E. Veach, see https://arxiv.org/abs/1406.2294.
+// Note that the minimal movement of objects is only guaranteed if new instances' IDs follow the existing IDs lexically.
+type JumpHashPlacement struct{}
+
+// Pick selects the instance using jump consistent hash.
+func (JumpHashPlacement) Pick(instanceIDs []string, objectID string) string {
+	if len(instanceIDs) == 0 {
+		return ""
+	}
+	return instanceIDs[jumpHash(mix64(hash64(objectID)), len(instanceIDs))]
+}
+
+func jumpHash(key uint64, cntBuckets int) int {
+	const (
+		multiplier = 2862933555777941757
+		shift      = 33
+		scale      = float64(int64(1) << 31)
+	)
+
+	var b, j int64 = -1, 0
+	for j < int64(cntBuckets) {
+		b = j
+		key = key*multiplier + 1
+		j = int64(float64(b+1) * (scale / float64((key>>shift)+1)))
+	}
+
+	return int(b)
+}
+
+// DefaultMaglevTableSize the default size of the Maglev lookup table.
+const DefaultMaglevTableSize = 65537
+
+// NewMaglevPlacement initialises the placement strategy based on the Maglev lookup table,
+// see https://research.google/pubs/pub44824/.
+// The tableSize shall be a prime number significantly larger than the number of instances,
+// DefaultMaglevTableSize is used if zero is provided.
+func NewMaglevPlacement(tableSize uint64) (*MaglevPlacement, error) {
+	if tableSize == 0 {
+		tableSize = DefaultMaglevTableSize
+	}
+
+	// the lookup table cannot be filled unless the skips of all instances are coprime with its size
+	if !isPrime(tableSize) {
+		return nil, errors.New("maglev table size must be a prime number")
+	}
+
+	return &MaglevPlacement{tableSize: tableSize}, nil
+}
+
+// MaglevPlacement selects the instance using the Maglev lookup table.
+// The table is built once for every set of instances, a few recently used tables are kept in memory.
+// No instance is selected if the number of instances exceeds the table size.
+type MaglevPlacement struct {
+	tableSize uint64
+
+	mu     sync.Mutex
+	tables map[string][]int
+}
+
+// Pick selects the instance using the lookup table.
+// It returns empty string if the table is smaller than the number of instances.
+func (p *MaglevPlacement) Pick(instanceIDs []string, objectID string) string {
+	if len(instanceIDs) == 0 || uint64(len(instanceIDs)) > p.tableSize {
+		return ""
+	}
+
+	table := p.lookupTable(instanceIDs)
+	return instanceIDs[table[mix64(hash64(objectID))%p.tableSize]]
+}
+
+func (p *MaglevPlacement) lookupTable(instanceIDs []string) []int {
+	membership := strings.Join(instanceIDs, "\x00")
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if table, ok := p.tables[membership]; ok {
+		return table
+	}
+
+	const maxCachedTables = 4
+	if p.tables == nil || len(p.tables) >= maxCachedTables {
+		p.tables = make(map[string][]int, maxCachedTables)
+	}
+
+	table := newMaglevLookupTable(instanceIDs, p.tableSize)
+	p.tables[membership] = table
+
+	return table
+}
+
+func newMaglevLookupTable(instanceIDs []string, tableSize uint64) []int {
+	var (
+		cntInstances = len(instanceIDs)
+		offsets      = make([]uint64, cntInstances)
+		skips        = make([]uint64, cntInstances)
+		next         = make([]uint64, cntInstances)
+		table        = make([]int, tableSize)
+	)
+
+	for i, instanceID := range instanceIDs {
+		h := hash64(instanceID)
+		offsets[i] = mix64(h) % tableSize
+		skips[i] = mix64(h^0x9e3779b97f4a7c15)%(tableSize-1) + 1
+	}
+
+	for i := range table {
+		table[i] = -1
+	}
+
+	var filled uint64
+	for {
+		for i := 0; i < cntInstances; i++ {
+			c := (offsets[i] + next[i]*skips[i]) % tableSize
+			for table[c] >= 0 {
+				next[i]++
+				c = (offsets[i] + next[i]*skips[i]) % tableSize
+			}
+
+			table[c] = i
+			next[i]++
+			filled++
+
+			if filled == tableSize {
+				return table
+			}
+		}
+	}
+}
+
+// isPrime checks if the number is prime.
+func isPrime(n uint64) bool {
+	if n < 2 {
+		return false
+	}
+	for d := uint64(2); d*d <= n; d++ {
+		if n%d == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// SumModuloPlacement selects the instance by the sum of the object ID's runes modulo the number of instances.
+// It is used by default for backward compatibility with the placement used by the gateway up to v0.0.7.
+// Note that the objects with IDs composed of the same characters are placed on the same instance,
+// and adding an instance moves almost every object. RendezvousPlacement is recommended instead,
+// the Rebalancer moves the existing objects to the instances selected by the new strategy.
+type SumModuloPlacement struct{}
+
+// Pick selects the instance.
+func (SumModuloPlacement) Pick(instanceIDs []string, objectID string) string {
+	switch cntInstances := len(instanceIDs); cntInstances {
+	case 0:
+		return ""
+	case 1:
+		return instanceIDs[0]
+	default:
+		return instanceIDs[hash(objectID)%cntInstances]
+	}
+}
+
+func hash(id string) int {
+	var o int32
+	for _, r := range id {
+		o += r
+	}
+	return int(o)
+}
+
+// hash64 calculates FNV-1a hash of the string.
+func hash64(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// mix64 is the finalizer of MurmurHash3 used to improve avalanche of the hash.
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
@@ -0,0 +1,199 @@
+package gateway
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"testing"
+)
+
+func placementStrategies() map[string]PlacementStrategy {
+	maglev, _ := NewMaglevPlacement(0)
+	return map[string]PlacementStrategy{
+		"rendezvous": RendezvousPlacement{},
+		"maglev":     maglev,
+		"jump":       JumpHashPlacement{},
+	}
+}
+
+func generateIDs(prefix string, cnt int) []string {
+	o := make([]string, cnt)
+	for i := range o {
+		o[i] = fmt.Sprintf("%s%04d", prefix, i)
+	}
+	return o
+}
+
+func TestPlacementStrategy_distribution(t *testing.T) {
+	const (
+		cntInstances = 5
+		cntObjects   = 20000
+		// max deviation of the number of objects per instance from the mean
+		tolerance = 0.1
+	)
+
+	instanceIDs := generateIDs("node", cntInstances)
+	objectIDs := generateIDs("obj", cntObjects)
+
+	for name, strategy := range placementStrategies() {
+		strategy := strategy
+		t.Run(name, func(t *testing.T) {
+			cnt := map[string]int{}
+			for _, objectID := range objectIDs {
+				cnt[strategy.Pick(instanceIDs, objectID)]++
+			}
+
+			if len(cnt) != cntInstances {
+				t.Errorf("all instances are expected to be picked, got: %v", cnt)
+				return
+			}
+
+			mean := float64(cntObjects) / cntInstances
+			for instanceID, v := range cnt {
+				if math.Abs(float64(v)-mean)/mean > tolerance {
+					t.Errorf("uneven distribution for instance %s: %d objects, mean: %.0f", instanceID, v, mean)
+				}
+			}
+		})
+	}
+}
+
+func TestPlacementStrategy_movementOnScaleOut(t *testing.T) {
+	const (
+		cntInstances = 5
+		cntObjects   = 20000
+		// max deviation of the share of moved objects from 1/(N+1)
+		tolerance = 0.2
+	)
+
+	instanceIDs := generateIDs("node", cntInstances+1)
+	before, after := instanceIDs[:cntInstances], instanceIDs
+	newInstanceID := instanceIDs[cntInstances]
+	objectIDs := generateIDs("obj", cntObjects)
+
+	for name, strategy := range placementStrategies() {
+		strategy := strategy
+		t.Run(name, func(t *testing.T) {
+			var moved, movedElsewhere int
+			for _, objectID := range objectIDs {
+				instanceBefore := strategy.Pick(before, objectID)
+				instanceAfter := strategy.Pick(after, objectID)
+				if instanceBefore != instanceAfter {
+					moved++
+					if instanceAfter != newInstanceID {
+						movedElsewhere++
+					}
+				}
+			}
+
+			want := 1. / float64(cntInstances+1)
+			got := float64(moved) / cntObjects
+			if math.Abs(got-want)/want > tolerance {
+				t.Errorf("unexpected share of moved objects, want: %.3f, got: %.3f", want, got)
+			}
+
+			// Maglev trades off a small disruption between existing instances for the even distribution
+			if got := float64(movedElsewhere) / cntObjects; got > 0.01 {
+				t.Errorf("objects are expected to move to the new instance only, %.3f moved elsewhere", got)
+			}
+		})
+	}
+}
+
+func TestPlacementStrategy_anagrams(t *testing.T) {
+	instanceIDs := generateIDs("node", 3)
+	objectIDs := []string{"abc", "acb", "bac", "bca", "cab", "cba"}
+
+	for name, strategy := range placementStrategies() {
+		strategy := strategy
+		t.Run(name, func(t *testing.T) {
+			picked := map[string]struct{}{}
+			for _, objectID := range objectIDs {
+				picked[strategy.Pick(instanceIDs, objectID)] = struct{}{}
+			}
+
+			if len(picked) == 1 {
+				t.Errorf("anagrams are not expected to be placed on the same instance")
+			}
+		})
+	}
+}
+
+func TestPlacementStrategy_emptyInput(t *testing.T) {
+	for name, strategy := range placementStrategies() {
+		strategy := strategy
+		t.Run(name, func(t *testing.T) {
+			if got := strategy.Pick(nil, "foo"); got != "" {
+				t.Errorf("empty string expected, got: %s", got)
+			}
+		})
+	}
+}
+
+func TestSumModuloPlacement_Pick(t *testing.T) {
+	// objects with IDs composed of the same characters collide
+	instanceIDs := generateIDs("node", 3)
+	strategy := SumModuloPlacement{}
+	if strategy.Pick(instanceIDs, "abc") != strategy.Pick(instanceIDs, "cba") {
+		t.Errorf("anagrams are expected to be placed on the same instance")
+	}
+}
+
+func TestNewMaglevPlacement(t *testing.T) {
+	tests := []struct {
+		name      string
+		tableSize uint64
+		wantErr   bool
+	}{
+		{name: "default table size", tableSize: 0},
+		{name: "prime table size", tableSize: 7},
+		{name: "one", tableSize: 1, wantErr: true},
+		{name: "not prime table size", tableSize: 100, wantErr: true},
+		{name: "square of prime", tableSize: 49, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// WHEN
+			got, err := NewMaglevPlacement(tt.tableSize)
+
+			// THEN
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err == nil && got.Pick(generateIDs("node", 3), "foo") == "" {
+				t.Errorf("the instance is expected to be picked")
+			}
+		})
+	}
+}
+
+func TestMaglevPlacement_Pick_tableSmallerThanCluster(t *testing.T) {
+	strategy, err := NewMaglevPlacement(7)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := strategy.Pick(generateIDs("node", 7), "foo"); got == "" {
+		t.Errorf("the instance is expected to be picked if the table size equals the number of instances")
+	}
+
+	if got := strategy.Pick(generateIDs("node", 8), "foo"); got != "" {
+		t.Errorf("no instance is expected to be picked if the table is smaller than the cluster, got: %s", got)
+	}
+}
+
+func TestNew_defaultPlacement(t *testing.T) {
+	// WHEN
+	got, err := New(mockClusterPrefix, "", &mockStorageDiscoveryClient{}, &mockStorageDiscoveryClient{},
+		mockMinioConnectionFactory(nil, &mockStorageClient{}), slog.Default(),
+	)
+
+	// THEN
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the objects stored by the previous releases shall be found in their placement instances after the upgrade
+	if _, ok := got.placement.(SumModuloPlacement); !ok {
+		t.Errorf("SumModuloPlacement is expected by default, got: %T", got.placement)
+	}
+}
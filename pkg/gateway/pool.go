@@ -0,0 +1,124 @@
+package gateway
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultConnectionTTL the default time to keep the connection to the storage instance in the pool.
+const DefaultConnectionTTL = 5 * time.Minute
+
+// WithConnectionTTL sets the time to keep the connection to the storage instance in the pool.
+// The connections are not pooled if zero is provided.
+func WithConnectionTTL(ttl time.Duration) Option {
+	return func(g *Gateway) {
+		g.connections = newConnectionPool(ttl)
+	}
+}
+
+// connectionPool caches the connections to the storage instances keyed by the instance ID.
+// Note that nil pool is valid, it does not cache connections.
+type connectionPool struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu          sync.Mutex
+	connections map[string]pooledConnection
+}
+
+type pooledConnection struct {
+	conn      ObjectReadWriteFinder
+	endpoint  string
+	expiresAt time.Time
+}
+
+func newConnectionPool(ttl time.Duration) *connectionPool {
+	if ttl <= 0 {
+		return nil
+	}
+	return &connectionPool{
+		ttl:         ttl,
+		now:         time.Now,
+		connections: map[string]pooledConnection{},
+	}
+}
+
+// get returns the connection to the instance unless it expired, or the instance's endpoint changed.
+func (p *connectionPool) get(instanceID, endpoint string) (ObjectReadWriteFinder, bool) {
+	if p == nil {
+		return nil, false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c, ok := p.connections[instanceID]
+	if !ok {
+		return nil, false
+	}
+
+	if c.endpoint != endpoint || !p.now().Before(c.expiresAt) {
+		delete(p.connections, instanceID)
+		return nil, false
+	}
+
+	return c.conn, true
+}
+
+func (p *connectionPool) put(instanceID, endpoint string, conn ObjectReadWriteFinder) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.connections[instanceID] = pooledConnection{
+		conn:      conn,
+		endpoint:  endpoint,
+		expiresAt: p.now().Add(p.ttl),
+	}
+}
+
+// invalidate removes the connection to the instance.
+func (p *connectionPool) invalidate(instanceID string) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.connections, instanceID)
+}
+
+// retain evicts connections to the instances which are not present in the cluster.
+func (p *connectionPool) retain(instances map[string]string) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for instanceID := range p.connections {
+		if _, ok := instances[instanceID]; !ok {
+			delete(p.connections, instanceID)
+		}
+	}
+}
+
+// checkConnectionError invalidates the pooled connection to the instance and the cached credentials, including
+// the credentials cached by the AuthenticationDetailsReader implementing CredentialsInvalidator,
+// if the storage client failed to authenticate. It returns the input error.
+func (s *Gateway) checkConnectionError(instanceID string, err error) error {
+	if errors.Is(err, ErrAuthentication) {
+		s.connections.invalidate(instanceID)
+		s.credentials.invalidate(instanceID)
+		if invalidator, ok := s.connectionDetailsReader.(CredentialsInvalidator); ok {
+			invalidator.Invalidate(instanceID)
+		}
+	}
+	return err
+}
@@ -0,0 +1,149 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingConnectionFactory counts the number of established connections.
+func countingConnectionFactory(cnt *atomic.Int64, fn StorageConnectionFn) StorageConnectionFn {
+	return func(endpoint, accessKeyID, secretAccessKey string) (ObjectReadWriteFinder, error) {
+		cnt.Add(1)
+		return fn(endpoint, accessKeyID, secretAccessKey)
+	}
+}
+
+func TestGateway_connectionPool(t *testing.T) {
+	t.Parallel()
+
+	const inputID = "obj"
+
+	t.Run("shall reuse the pooled connection", func(t *testing.T) {
+		// GIVEN
+		var cntConnections atomic.Int64
+		gateway := newMockGateway()
+		gateway.connections = newConnectionPool(time.Minute)
+		gateway.newStorageConnectionFn = countingConnectionFactory(&cntConnections,
+			mockMinioConnectionFactory(nil, &mockStorageClient{dataReader: strings.NewReader("qux")}))
+
+		// WHEN
+		for i := 0; i < 3; i++ {
+			if _, _, err := gateway.Read(context.TODO(), inputID, 0, -1); err != nil {
+				t.Errorf("no error expected, got: %v", err)
+				return
+			}
+		}
+
+		// THEN
+		if got := cntConnections.Load(); got != 1 {
+			t.Errorf("single connection expected, got: %d", got)
+		}
+	})
+
+	t.Run("shall reconnect after the authentication failure", func(t *testing.T) {
+		// GIVEN
+		var cntConnections atomic.Int64
+		client := &mockStorageClient{err: fmt.Errorf("%w: access denied", ErrAuthentication)}
+		gateway := newMockGateway()
+		gateway.connections = newConnectionPool(time.Minute)
+		gateway.newStorageConnectionFn = countingConnectionFactory(&cntConnections,
+			mockMinioConnectionFactory(nil, client))
+
+		// WHEN
+		if _, _, err := gateway.Read(context.TODO(), inputID, 0, -1); err == nil {
+			t.Errorf("error expected")
+			return
+		}
+		client.err = nil
+		if _, _, err := gateway.Read(context.TODO(), inputID, 0, -1); err != nil {
+			t.Errorf("no error expected, got: %v", err)
+			return
+		}
+
+		// THEN
+		// the failed read reconnects once to retry, and the next read reconnects again
+		if got := cntConnections.Load(); got != 3 {
+			t.Errorf("three connections expected, got: %d", got)
+		}
+	})
+
+	t.Run("shall evict the connection to the instance which left the cluster", func(t *testing.T) {
+		// GIVEN
+		discovery := &mockStorageDiscoveryClient{instances: map[string]string{"node0": "192.0.2.0", "node1": "192.0.2.1"}}
+		gateway := newMockGateway()
+		gateway.serviceRegistryClient = discovery
+		gateway.connections = newConnectionPool(time.Minute)
+		gateway.newStorageConnectionFn = mockMinioConnectionFactoryByEndpoint(map[string]ObjectReadWriteFinder{
+			"192.0.2.0": &mockStorageClient{},
+			"192.0.2.1": &mockStorageClient{},
+		})
+
+		if _, _, err := gateway.Read(context.TODO(), inputID, 0, -1); err != nil {
+			t.Errorf("no error expected, got: %v", err)
+			return
+		}
+
+		// WHEN
+		discovery.instances = map[string]string{"node0": "192.0.2.0"}
+		if _, _, err := gateway.Read(context.TODO(), inputID, 0, -1); err != nil {
+			t.Errorf("no error expected, got: %v", err)
+			return
+		}
+
+		// THEN
+		if _, ok := gateway.connections.get("node1", "192.0.2.1"); ok {
+			t.Errorf("connection to node1 is expected to be evicted")
+		}
+		if _, ok := gateway.connections.get("node0", "192.0.2.0"); !ok {
+			t.Errorf("connection to node0 is expected to be pooled")
+		}
+	})
+}
+
+func Test_connectionPool_get(t *testing.T) {
+	const (
+		instanceID = "node0"
+		endpoint   = "192.0.2.0"
+	)
+
+	newPool := func(now time.Time) *connectionPool {
+		p := newConnectionPool(time.Minute)
+		p.now = func() time.Time { return now }
+		p.put(instanceID, endpoint, &mockStorageClient{})
+		return p
+	}
+
+	start := time.Date(2023, 10, 21, 7, 28, 0, 0, time.UTC)
+
+	t.Run("shall return pooled connection", func(t *testing.T) {
+		if _, ok := newPool(start).get(instanceID, endpoint); !ok {
+			t.Errorf("pooled connection expected")
+		}
+	})
+
+	t.Run("shall not return expired connection", func(t *testing.T) {
+		p := newPool(start)
+		p.now = func() time.Time { return start.Add(time.Minute) }
+		if _, ok := p.get(instanceID, endpoint); ok {
+			t.Errorf("expired connection is not expected")
+		}
+	})
+
+	t.Run("shall not return the connection to the changed endpoint", func(t *testing.T) {
+		if _, ok := newPool(start).get(instanceID, "192.0.2.1"); ok {
+			t.Errorf("connection to the changed endpoint is not expected")
+		}
+	})
+
+	t.Run("shall not pool connections if ttl is not set", func(t *testing.T) {
+		p := newConnectionPool(0)
+		p.put(instanceID, endpoint, &mockStorageClient{})
+		if _, ok := p.get(instanceID, endpoint); ok {
+			t.Errorf("connection is not expected to be pooled")
+		}
+	})
+}
@@ -0,0 +1,297 @@
+package gateway
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// replicaState defines the object's metadata read from the replica.
+type replicaState struct {
+	instanceID string
+	conn       ObjectReadWriteFinder
+	info       ObjectInfo
+	found      bool
+	err        error
+}
+
+// readReplicas reads the object from the freshest replica, i.e. the replica with the latest modification time.
+// The replicas which miss the object, or store the object with the ETag different from the freshest replica's
+// are rewritten asynchronously. If the freshest replica fails to read the object, the object is read from the next
+// replica storing the object with the same ETag. The rest of the cluster is scanned if the object is not found
+// in the replicas.
+func (s *Gateway) readReplicas(
+	ctx context.Context, instances map[string]string, id string, offset, length int64,
+) (io.ReadCloser, bool, error) {
+	replicas := s.pickReplicas(instances, id)
+	states := s.statReplicas(ctx, instances, replicas, id, "read")
+
+	freshest, replicaErr := s.freshestReplica(states, id, "read")
+	if freshest == nil {
+		instanceID, conn, found, err := s.findMisplacedObject(ctx, instances, replicas, id, "read")
+		if !found {
+			if replicaErr != nil {
+				return nil, false, replicaErr
+			}
+			return nil, false, err
+		}
+		return s.readObject(ctx, instanceID, instances[instanceID], conn, id, offset, length)
+	}
+
+	var outdated []string
+	for _, state := range states {
+		switch {
+		case state.err != nil:
+		case !state.found:
+			s.Logger.Debug("replica is missing the object",
+				slog.String("instanceID", state.instanceID),
+				slog.String("objectID", id),
+			)
+			outdated = append(outdated, state.instanceID)
+		case state.info.ETag != freshest.info.ETag:
+			s.Logger.Debug("replica is stale",
+				slog.String("instanceID", state.instanceID),
+				slog.String("objectID", id),
+				slog.String("etag", state.info.ETag),
+				slog.String("freshETag", freshest.info.ETag),
+			)
+			outdated = append(outdated, state.instanceID)
+		}
+	}
+
+	if len(outdated) > 0 {
+		s.repairReplicas(ctx, instances, *freshest, outdated, id)
+	}
+
+	reader, found, err := s.readObject(ctx, freshest.instanceID, instances[freshest.instanceID], freshest.conn, id,
+		offset, length)
+	if err == nil {
+		return reader, found, nil
+	}
+
+	for _, state := range states {
+		if state.instanceID == freshest.instanceID || state.err != nil || !state.found ||
+			state.info.ETag != freshest.info.ETag {
+			continue
+		}
+
+		s.Logger.Debug("failed to read the freshest replica, reading the next one",
+			slog.String("instanceID", freshest.instanceID),
+			slog.String("nextInstanceID", state.instanceID),
+			slog.String("objectID", id),
+			slog.String("error", err.Error()),
+		)
+
+		reader, found, nextErr := s.readObject(ctx, state.instanceID, instances[state.instanceID], state.conn, id,
+			offset, length)
+		if nextErr == nil {
+			return reader, found, nil
+		}
+	}
+
+	return nil, false, err
+}
+
+// freshestReplica returns the replica storing the object with the latest modification time, nil if the object
+// is not found. The unavailable replicas are skipped, the error of the first of them is returned.
+func (s *Gateway) freshestReplica(states []replicaState, id, operation string) (*replicaState, error) {
+	var (
+		freshest   *replicaState
+		replicaErr error
+	)
+	for i, state := range states {
+		if state.err != nil {
+			s.Logger.Debug("replica unavailable",
+				slog.String("operation", operation),
+				slog.String("instanceID", state.instanceID),
+				slog.String("objectID", id),
+				slog.String("error", state.err.Error()),
+			)
+			if replicaErr == nil {
+				replicaErr = state.err
+			}
+			continue
+		}
+
+		if state.found && (freshest == nil || state.info.LastModified.After(freshest.info.LastModified)) {
+			freshest = &states[i]
+		}
+	}
+
+	return freshest, replicaErr
+}
+
+// statReplicas reads the object's metadata from the replicas concurrently.
+func (s *Gateway) statReplicas(
+	ctx context.Context, instances map[string]string, replicas []string, id, operation string,
+) []replicaState {
+	var (
+		wg sync.WaitGroup
+		o  = make([]replicaState, len(replicas))
+	)
+
+	for i, instanceID := range replicas {
+		wg.Add(1)
+		go func(i int, instanceID string) {
+			defer wg.Done()
+
+			o[i] = replicaState{instanceID: instanceID}
+
+			conn, err := s.newStorageInstanceConnection(ctx, instanceID, instances[instanceID])
+			if err != nil {
+				o[i].err = err
+				return
+			}
+
+			s.Logger.Debug("searching",
+				slog.String("operation", operation),
+				slog.String("instanceID", instanceID),
+				slog.String("objectID", id),
+			)
+
+			o[i].conn, o[i].info, o[i].found, o[i].err = s.statObject(ctx, instanceID, instances[instanceID], conn, id)
+		}(i, instanceID)
+	}
+
+	wg.Wait()
+
+	return o
+}
+
+// statObject reads the object's metadata from the instance.
+// It returns the connection used by the last attempt.
+func (s *Gateway) statObject(
+	ctx context.Context, instanceID, endpoint string, conn ObjectReadWriteFinder, id string,
+) (ObjectReadWriteFinder, ObjectInfo, bool, error) {
+	var (
+		info  ObjectInfo
+		found bool
+	)
+	conn, err := s.retryOnAuthenticationError(ctx, instanceID, endpoint, conn,
+		func(conn ObjectReadWriteFinder) (err error) {
+			info, found, err = conn.Stat(ctx, s.storageBucket, id)
+			return err
+		})
+	return conn, info, found, err
+}
+
+// readObject reads the object from the instance.
+func (s *Gateway) readObject(
+	ctx context.Context, instanceID, endpoint string, conn ObjectReadWriteFinder, id string, offset, length int64,
+) (io.ReadCloser, bool, error) {
+	s.Logger.Debug("reading",
+		slog.String("operation", "read"),
+		slog.String("instanceID", instanceID),
+		slog.String("objectID", id),
+	)
+
+	var (
+		dataReadCloser io.ReadCloser
+		found          bool
+	)
+	_, err := s.retryOnAuthenticationError(ctx, instanceID, endpoint, conn,
+		func(conn ObjectReadWriteFinder) (err error) {
+			dataReadCloser, found, err = conn.Read(ctx, s.storageBucket, id, offset, length)
+			return err
+		})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return dataReadCloser, found, nil
+}
+
+// repairReplicas rewrites the object in the outdated replicas asynchronously copying it from the source replica.
+// The repair is skipped if the object is being repaired already, or if it was changed in the source replica
+// by the time the repair locks the object.
+func (s *Gateway) repairReplicas(
+	ctx context.Context, instances map[string]string, source replicaState, outdated []string, id string,
+) {
+	if _, inProgress := s.repairs.LoadOrStore(id, struct{}{}); inProgress {
+		return
+	}
+
+	s.repairsWG.Add(1)
+	go func() {
+		defer s.repairsWG.Done()
+		defer s.repairs.Delete(id)
+
+		// the repair shall not be cancelled when the read request is over
+		ctx := context.WithoutCancel(ctx)
+
+		// the repair is serialized with the writes of the object, it is skipped if the object was rewritten,
+		// or deleted after the source replica was read
+		ctx, unlock, err := s.lock(ctx, id)
+		if err != nil {
+			s.metrics.readRepairFailures.Add(uint64(len(outdated)))
+			s.Logger.Warn("failed to lock the object to repair replicas",
+				slog.String("objectID", id),
+				slog.String("error", err.Error()),
+			)
+			return
+		}
+		defer unlock()
+
+		current := s.statReplicas(ctx, instances, []string{source.instanceID}, id, "repair")[0]
+		if current.err != nil {
+			s.metrics.readRepairFailures.Add(uint64(len(outdated)))
+			s.Logger.Warn("failed to read the object to repair replicas",
+				slog.String("instanceID", source.instanceID),
+				slog.String("objectID", id),
+				slog.String("error", current.err.Error()),
+			)
+			return
+		}
+		if !current.found || current.info.ETag != source.info.ETag ||
+			!current.info.LastModified.Equal(source.info.LastModified) {
+			s.Logger.Debug("object changed, skipping the repair",
+				slog.String("instanceID", source.instanceID),
+				slog.String("objectID", id),
+			)
+			return
+		}
+
+		reader, found, err := s.readObject(ctx, source.instanceID, instances[source.instanceID], current.conn, id, 0, -1)
+		if err == nil && !found {
+			// the object was deleted concurrently
+			return
+		}
+		if err != nil {
+			s.metrics.readRepairFailures.Add(uint64(len(outdated)))
+			s.Logger.Warn("failed to read the object to repair replicas",
+				slog.String("instanceID", source.instanceID),
+				slog.String("objectID", id),
+				slog.String("error", err.Error()),
+			)
+			return
+		}
+		defer func() { _ = reader.Close() }()
+
+		errList, readErr := s.writeToInstances(ctx, instances, outdated, id, reader, source.info.Size)
+		for i, instanceID := range outdated {
+			err := errList[i]
+			if err == nil {
+				err = readErr
+			}
+
+			if err != nil {
+				s.metrics.readRepairFailures.Add(1)
+				s.Logger.Warn("failed to repair replica",
+					slog.String("instanceID", instanceID),
+					slog.String("sourceInstanceID", source.instanceID),
+					slog.String("objectID", id),
+					slog.String("error", err.Error()),
+				)
+				continue
+			}
+
+			s.metrics.readRepairs.Add(1)
+			s.Logger.Info("replica repaired",
+				slog.String("instanceID", instanceID),
+				slog.String("sourceInstanceID", source.instanceID),
+				slog.String("objectID", id),
+			)
+		}
+	}()
+}
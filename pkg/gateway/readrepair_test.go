@@ -0,0 +1,193 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestGateway_Read_readRepair(t *testing.T) {
+	t.Parallel()
+
+	const objectID = "obj"
+
+	var (
+		staleData = []byte("foo")
+		freshData = []byte("bar")
+		modified  = time.Date(2023, 10, 21, 7, 28, 0, 0, time.UTC)
+	)
+
+	newCluster := func() (*Gateway, []string, map[string]*memoryStorageClient) {
+		gateway, clients := newReplicatedCluster(5, 3, 2)
+		instances, _ := gateway.serviceRegistryClient.Scan(context.TODO(), mockClusterPrefix)
+		return gateway, gateway.pickReplicas(instances, objectID), clients
+	}
+
+	readObject := func(t *testing.T, gateway *Gateway) []byte {
+		t.Helper()
+
+		reader, found, err := gateway.Read(context.TODO(), objectID, 0, -1)
+		if err != nil || !found {
+			t.Fatalf("the object is expected to be found, got: %v, %v", found, err)
+		}
+		data, _ := io.ReadAll(reader)
+		return data
+	}
+
+	t.Run("shall rewrite the replica which missed the write", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, replicas, clients := newCluster()
+		clients[replicas[1]].put(objectID, freshData, modified)
+		clients[replicas[2]].put(objectID, freshData, modified)
+
+		// WHEN
+		got := readObject(t, gateway)
+		gateway.repairsWG.Wait()
+
+		// THEN
+		if !bytes.Equal(got, freshData) {
+			t.Errorf("unexpected data want: %s, got: %s", freshData, got)
+		}
+		if stored := clients[replicas[0]].stored(objectID); !bytes.Equal(stored, freshData) {
+			t.Errorf("the missing replica is expected to be repaired, got: %s", stored)
+		}
+		want := Metrics{ReadRepairs: 1}
+		if got := gateway.Metrics(); got != want {
+			t.Errorf("unexpected metrics want: %#v, got: %#v", want, got)
+		}
+	})
+
+	t.Run("shall return the freshest replica and rewrite the stale one", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, replicas, clients := newCluster()
+		clients[replicas[0]].put(objectID, staleData, modified)
+		clients[replicas[1]].put(objectID, freshData, modified.Add(time.Minute))
+		clients[replicas[2]].put(objectID, freshData, modified.Add(time.Minute))
+
+		// WHEN
+		got := readObject(t, gateway)
+		gateway.repairsWG.Wait()
+
+		// THEN
+		if !bytes.Equal(got, freshData) {
+			t.Errorf("unexpected data want: %s, got: %s", freshData, got)
+		}
+		if stored := clients[replicas[0]].stored(objectID); !bytes.Equal(stored, freshData) {
+			t.Errorf("the stale replica is expected to be repaired, got: %s", stored)
+		}
+		if got := gateway.Metrics().ReadRepairs; got != 1 {
+			t.Errorf("unexpected number of repairs want: 1, got: %d", got)
+		}
+	})
+
+	t.Run("shall not rewrite the replicas with the same ETag", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, replicas, clients := newCluster()
+		clients[replicas[0]].put(objectID, freshData, modified)
+		clients[replicas[1]].put(objectID, freshData, modified.Add(time.Minute))
+		clients[replicas[2]].put(objectID, freshData, modified.Add(-time.Minute))
+
+		// WHEN
+		_ = readObject(t, gateway)
+		gateway.repairsWG.Wait()
+
+		// THEN
+		if got := gateway.Metrics(); got != (Metrics{}) {
+			t.Errorf("no repairs expected, got: %#v", got)
+		}
+	})
+
+	t.Run("shall not rewrite the unavailable replica", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, replicas, clients := newCluster()
+		clients[replicas[0]].err = errors.New("unavailable")
+		clients[replicas[1]].put(objectID, freshData, modified)
+		clients[replicas[2]].put(objectID, freshData, modified)
+
+		// WHEN
+		got := readObject(t, gateway)
+		gateway.repairsWG.Wait()
+
+		// THEN
+		if !bytes.Equal(got, freshData) {
+			t.Errorf("unexpected data want: %s, got: %s", freshData, got)
+		}
+		if got := gateway.Metrics(); got != (Metrics{}) {
+			t.Errorf("no repairs expected, got: %#v", got)
+		}
+	})
+
+	t.Run("shall count the failed repair", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, replicas, clients := newCluster()
+		clients[replicas[0]].put(objectID, staleData, modified)
+		clients[replicas[0]].writeErr = errors.New("disk full")
+		clients[replicas[1]].put(objectID, freshData, modified.Add(time.Minute))
+
+		// WHEN
+		got := readObject(t, gateway)
+		gateway.repairsWG.Wait()
+
+		// THEN
+		if !bytes.Equal(got, freshData) {
+			t.Errorf("unexpected data want: %s, got: %s", freshData, got)
+		}
+		if stored := clients[replicas[2]].stored(objectID); !bytes.Equal(stored, freshData) {
+			t.Errorf("the missing replica is expected to be repaired, got: %s", stored)
+		}
+		want := Metrics{ReadRepairs: 1, ReadRepairFailures: 1}
+		if got := gateway.Metrics(); got != want {
+			t.Errorf("unexpected metrics want: %#v, got: %#v", want, got)
+		}
+	})
+	t.Run("shall not overwrite the object written concurrently with the repair", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		locker := &MemoryLocker{}
+		gateway, replicas, clients := newCluster()
+		gateway.locker = locker
+		clients[replicas[1]].put(objectID, staleData, modified)
+		clients[replicas[2]].put(objectID, staleData, modified)
+
+		// another gateway writes the object
+		_, unlock, err := locker.Lock(context.TODO(), objectID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// WHEN
+		got := readObject(t, gateway)
+		for _, instanceID := range replicas {
+			clients[instanceID].put(objectID, freshData, modified.Add(time.Minute))
+		}
+		unlock()
+		gateway.repairsWG.Wait()
+
+		// THEN
+		if !bytes.Equal(got, staleData) {
+			t.Errorf("unexpected data want: %s, got: %s", staleData, got)
+		}
+		for _, instanceID := range replicas {
+			if stored := clients[instanceID].stored(objectID); !bytes.Equal(stored, freshData) {
+				t.Errorf("the replica %s is expected to store the written object, got: %s", instanceID, stored)
+			}
+		}
+		if got := gateway.Metrics(); got != (Metrics{}) {
+			t.Errorf("no repairs expected, got: %#v", got)
+		}
+	})
+}
@@ -0,0 +1,649 @@
+package gateway
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRebalanceInterval the default interval to check if the cluster membership changed.
+const DefaultRebalanceInterval = time.Minute
+
+// rebalanceListPageSize the number of objects listed in the instance at once.
+const rebalanceListPageSize = 1000
+
+// ErrChecksumMismatch indicates that the copied object's checksum differs from the source's.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// NewRebalancer initialises the Rebalancer which moves the objects to the instances selected by the Gateway's
+// placement strategy after the cluster membership changes.
+// The membership is checked at pollInterval. The data transfer rate is limited by maxBytesPerSecond,
+// the rate is not limited if it is set to zero.
+func NewRebalancer(gw *Gateway, pollInterval time.Duration, maxBytesPerSecond int64) (*Rebalancer, error) {
+	if gw == nil {
+		return nil, errors.New("gateway must be not nil")
+	}
+
+	if pollInterval <= 0 {
+		return nil, errors.New("pollInterval must be positive")
+	}
+
+	if maxBytesPerSecond < 0 {
+		return nil, errors.New("maxBytesPerSecond must not be negative")
+	}
+
+	logger := gw.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &Rebalancer{
+		gw:           gw,
+		pollInterval: pollInterval,
+		throttle: &throttle{
+			bytesPerSecond: maxBytesPerSecond,
+			now:            time.Now,
+			sleep:          sleepContext,
+		},
+		logger: logger.WithGroup("rebalancer"),
+		now:    time.Now,
+	}, nil
+}
+
+// Rebalancer moves the objects to the instances selected by the placement strategy for the current cluster
+// membership, so the objects can be found without the scan of the cluster.
+// The object is copied to its new instances, the copies are verified by comparing the MD5 checksums, and the source
+// copy is deleted afterward. The erasure coded object is re-encoded to the shards stored on the new instances.
+// The object is not moved concurrently with its write by the same Gateway, or by the gateways sharing the Locker.
+// Note that the objects written concurrently with the rebalancing by another gateway can be overwritten
+// by their previous versions otherwise.
+type Rebalancer struct {
+	gw           *Gateway
+	pollInterval time.Duration
+	throttle     *throttle
+	logger       *slog.Logger
+	now          func() time.Time
+
+	// runMu serializes the rebalancing passes.
+	runMu sync.Mutex
+	// members the sorted IDs of the instances the objects were rebalanced for, nil if the last pass failed.
+	members []string
+
+	mu     sync.Mutex
+	status RebalanceStatus
+	// stats the counters of the last pass.
+	stats *transferStats
+	// drains the drains of the instances by instance ID.
+	drains map[string]*drain
+}
+
+// RebalanceStatus defines the state of the last rebalancing pass.
+type RebalanceStatus struct {
+	// Running indicates that the rebalancing is in progress.
+	Running bool
+	// Instances the IDs of the instances the objects are rebalanced for.
+	Instances []string
+	// StartedAt the time the pass started, zero if no pass was run.
+	StartedAt time.Time
+	// FinishedAt the time the pass finished, zero if the pass is running.
+	FinishedAt time.Time
+	// ScannedObjects the number of listed objects and manifests of the erasure coded objects checked.
+	ScannedObjects uint64
+	// MovedObjects the number of objects moved to the instances selected by the placement strategy.
+	MovedObjects uint64
+	// MovedBytes the number of bytes copied.
+	MovedBytes uint64
+	// FailedObjects the number of objects which failed to be moved.
+	FailedObjects uint64
+	// LastError the last error of the pass.
+	LastError string
+}
+
+// Status returns the state of the last rebalancing pass.
+func (r *Rebalancer) Status() RebalanceStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	o := r.status
+	o.Instances = slices.Clone(r.status.Instances)
+	if r.stats != nil {
+		c := r.stats.counters()
+		o.ScannedObjects, o.MovedObjects, o.MovedBytes, o.FailedObjects = c.scanned, c.moved, c.movedBytes, c.failed
+		if o.LastError == "" {
+			o.LastError = c.lastError
+		}
+	}
+	return o
+}
+
+// Run checks the cluster membership at the poll interval until the context is cancelled. The objects are rebalanced
+// on start, when the membership, or the set of draining instances changes, and when the previous pass failed.
+func (r *Rebalancer) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		r.poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Rebalance runs the rebalancing pass for the current cluster membership.
+func (r *Rebalancer) Rebalance(ctx context.Context) error {
+	instances, err := r.gw.scanStorageInstances(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.runMu.Lock()
+	defer r.runMu.Unlock()
+
+	return r.rebalance(ctx, instances)
+}
+
+func (r *Rebalancer) poll(ctx context.Context) {
+	instances, err := r.gw.scanStorageInstances(ctx)
+	if err != nil {
+		r.logger.Warn("failed to read the cluster membership", slog.String("error", err.Error()))
+		return
+	}
+
+	r.runMu.Lock()
+	defer r.runMu.Unlock()
+
+	if slices.Equal(r.members, readSortedMapKeys(r.gw.placementInstances(instances))) {
+		return
+	}
+
+	_ = r.rebalance(ctx, instances)
+}
+
+// rebalance moves the objects stored in every instance, including the draining instances.
+// The caller must hold runMu.
+func (r *Rebalancer) rebalance(ctx context.Context, instances map[string]string) error {
+	members := readSortedMapKeys(r.gw.placementInstances(instances))
+	stats := &transferStats{}
+
+	r.logger.Info("rebalancing started", slog.Any("instances", members))
+	r.mu.Lock()
+	r.status = RebalanceStatus{Running: true, Instances: members, StartedAt: r.now()}
+	r.stats = stats
+	r.mu.Unlock()
+
+	var err error
+	for _, instanceID := range readSortedMapKeys(instances) {
+		if instanceErr := r.rebalanceInstance(ctx, instances, instanceID, stats); instanceErr != nil {
+			err = instanceErr
+		}
+	}
+
+	r.members = members
+	if err != nil {
+		// the pass is repeated at the next poll
+		r.members = nil
+	}
+
+	r.mu.Lock()
+	r.status.Running = false
+	r.status.FinishedAt = r.now()
+	if err != nil {
+		r.status.LastError = err.Error()
+	}
+	r.mu.Unlock()
+
+	status := r.Status()
+	r.logger.Info("rebalancing finished",
+		slog.Uint64("scannedObjects", status.ScannedObjects),
+		slog.Uint64("movedObjects", status.MovedObjects),
+		slog.Uint64("movedBytes", status.MovedBytes),
+		slog.Uint64("failedObjects", status.FailedObjects),
+	)
+
+	return err
+}
+
+// rebalanceInstance moves the objects stored in the instance page by page.
+// It returns the last error if any object failed to be moved.
+func (r *Rebalancer) rebalanceInstance(
+	ctx context.Context, instances map[string]string, instanceID string, stats *transferStats,
+) error {
+	var (
+		lastErr    error
+		startAfter string
+	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		objects, err := r.gw.listInstance(ctx, instanceID, instances[instanceID], "", startAfter,
+			rebalanceListPageSize)
+		if err != nil {
+			r.logger.Warn("failed to list objects",
+				slog.String("instanceID", instanceID),
+				slog.String("error", err.Error()),
+			)
+			return err
+		}
+
+		for _, obj := range objects {
+			if err := r.rebalanceObject(ctx, instances, instanceID, obj.ID, stats); err != nil {
+				r.logger.Warn("failed to move object",
+					slog.String("instanceID", instanceID),
+					slog.String("objectID", obj.ID),
+					slog.String("error", err.Error()),
+				)
+				stats.fail(err)
+				lastErr = err
+			}
+		}
+
+		if len(objects) < rebalanceListPageSize {
+			return lastErr
+		}
+		startAfter = objects[len(objects)-1].ID
+	}
+}
+
+func (r *Rebalancer) rebalanceObject(
+	ctx context.Context, instances map[string]string, instanceID, name string, stats *transferStats,
+) error {
+	id := name
+	shardID, shard := cutShardObjectName(name)
+	manifestID, manifest := strings.CutSuffix(name, manifestObjectNameSuffix)
+	switch {
+	case shard:
+		if !r.gw.isDraining(instanceID) {
+			// the shards are moved with the erasure coded object given its manifest
+			return nil
+		}
+		id = shardID
+	case manifest:
+		id = manifestID
+	}
+
+	// the object is not moved concurrently with its write by the gateway, or by the gateways sharing the Locker
+	ctx, unlock, err := r.gw.lock(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	stats.scan()
+
+	switch {
+	case shard:
+		// the shard is moved even if the instance does not store the object's manifest
+		if err := r.rebalanceErasureCoded(ctx, instances, instanceID, id, stats); err != nil {
+			return err
+		}
+		return r.gw.deleteObject(ctx, instanceID, instances[instanceID], nil, name)
+	case manifest:
+		return r.rebalanceErasureCoded(ctx, instances, instanceID, id, stats)
+	case r.gw.erasure != nil:
+		return r.rebalancePlainCopy(ctx, instances, instanceID, id, stats)
+	default:
+		return r.rebalanceReplicas(ctx, instances, instanceID, id, stats)
+	}
+}
+
+// rebalancePlainCopy deletes the plain copy of the erasure coded object, e.g. written before the erasure coding
+// was enabled, because it is shadowed by the object's manifest. The object without the manifest is moved
+// to its replicas.
+func (r *Rebalancer) rebalancePlainCopy(
+	ctx context.Context, instances map[string]string, instanceID, id string, stats *transferStats,
+) error {
+	_, found, err := r.gw.findManifest(ctx, instances, id, "rebalance")
+	if err != nil {
+		return err
+	}
+	if !found {
+		return r.rebalanceReplicas(ctx, instances, instanceID, id, stats)
+	}
+
+	if err := r.gw.deleteObject(ctx, instanceID, instances[instanceID], nil, id); err != nil {
+		return err
+	}
+
+	r.gw.metrics.duplicatesDeleted.Add(1)
+	r.logger.Info("duplicate deleted",
+		slog.String("instanceID", instanceID),
+		slog.String("objectID", id),
+	)
+
+	return nil
+}
+
+// rebalanceReplicas copies the object to the replicas selected by the placement strategy unless they store
+// the same, or newer object, and deletes the source copy. The object stored in one of its replicas is not moved.
+func (r *Rebalancer) rebalanceReplicas(
+	ctx context.Context, instances map[string]string, sourceInstanceID, id string, stats *transferStats,
+) error {
+	replicas := r.gw.pickReplicas(instances, id)
+	if slices.Contains(replicas, sourceInstanceID) {
+		return nil
+	}
+
+	source := r.gw.statReplicas(ctx, instances, []string{sourceInstanceID}, id, "rebalance")[0]
+	if source.err != nil || !source.found {
+		// the object could have been deleted concurrently
+		return source.err
+	}
+
+	var movedBytes uint64
+	for _, replica := range r.gw.statReplicas(ctx, instances, replicas, id, "rebalance") {
+		if replica.err != nil {
+			return replica.err
+		}
+
+		if replica.found && (replica.info.ETag == source.info.ETag ||
+			!source.info.LastModified.After(replica.info.LastModified)) {
+			continue
+		}
+
+		if err := r.copyObject(ctx, instances, source, replica.instanceID, id); err != nil {
+			return err
+		}
+		movedBytes += uint64(source.info.Size)
+	}
+
+	if err := r.gw.deleteObject(ctx, sourceInstanceID, instances[sourceInstanceID], source.conn, id); err != nil {
+		return err
+	}
+
+	r.logger.Info("object moved",
+		slog.String("objectID", id),
+		slog.String("sourceInstanceID", sourceInstanceID),
+		slog.Any("instances", replicas),
+	)
+	stats.move(movedBytes)
+
+	return nil
+}
+
+// copyObject copies the object from the source to the target instance and verifies the copy by reading it back.
+// The copy is deleted if its checksum differs from the checksum of the source object.
+func (r *Rebalancer) copyObject(
+	ctx context.Context, instances map[string]string, source replicaState, targetInstanceID, id string,
+) error {
+	reader, found, err := r.gw.readObject(ctx, source.instanceID, instances[source.instanceID], source.conn,
+		id, 0, -1)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("object is not found in the instance %s", source.instanceID)
+	}
+	defer func() { _ = reader.Close() }()
+
+	r.logger.Debug("copying",
+		slog.String("objectID", id),
+		slog.String("sourceInstanceID", source.instanceID),
+		slog.String("instanceID", targetInstanceID),
+	)
+
+	checksum := md5.New()
+	if err := r.gw.writeReplica(ctx, targetInstanceID, instances[targetInstanceID], id,
+		io.TeeReader(r.throttle.reader(ctx, reader), checksum), source.info.Size); err != nil {
+		return err
+	}
+
+	err = r.verifyObject(ctx, instances, targetInstanceID, id, hex.EncodeToString(checksum.Sum(nil)))
+	if errors.Is(err, ErrChecksumMismatch) {
+		// the corrupted copy shall not be found instead of the source object
+		if deleteErr := r.gw.deleteObject(ctx, targetInstanceID, instances[targetInstanceID], nil, id); deleteErr != nil {
+			err = errors.Join(err, deleteErr)
+		}
+	}
+
+	return err
+}
+
+// verifyObject reads the object from the instance and compares its MD5 checksum with the expected one.
+func (r *Rebalancer) verifyObject(
+	ctx context.Context, instances map[string]string, instanceID, id, wantChecksum string,
+) error {
+	conn, err := r.gw.newStorageInstanceConnection(ctx, instanceID, instances[instanceID])
+	if err != nil {
+		return err
+	}
+
+	reader, found, err := r.gw.readObject(ctx, instanceID, instances[instanceID], conn, id, 0, -1)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("%w: the copy is not found in the instance %s", ErrChecksumMismatch, instanceID)
+	}
+	defer func() { _ = reader.Close() }()
+
+	_, err = io.Copy(io.Discard, newVerifyingReader(r.throttle.reader(ctx, reader), wantChecksum))
+	return err
+}
+
+// rebalanceErasureCoded re-encodes the erasure coded object to the shards stored on the instances selected by
+// the placement strategy, and deletes the shards and the manifests left on the rest of the instances.
+// The object is read given its newest manifest, the manifest read from the source instance defines the layout
+// to clean up, e.g. the previous generation left in the instance which missed the object's overwrite.
+func (r *Rebalancer) rebalanceErasureCoded(
+	ctx context.Context, instances map[string]string, sourceInstanceID, id string, stats *transferStats,
+) error {
+	if r.gw.erasure == nil {
+		if r.gw.isDraining(sourceInstanceID) {
+			return errors.New("erasure coding must be enabled to move the erasure coded object")
+		}
+		// the shards can only be written if erasure coding is enabled
+		return nil
+	}
+
+	conn, err := r.gw.newStorageInstanceConnection(ctx, sourceInstanceID, instances[sourceInstanceID])
+	if err != nil {
+		return err
+	}
+
+	stale, staleFound, err := r.gw.readManifest(ctx, sourceInstanceID, instances[sourceInstanceID], conn, id)
+	if err != nil {
+		return err
+	}
+
+	manifest, found, err := r.gw.findManifest(ctx, instances, id, "rebalance")
+	if err != nil {
+		return err
+	}
+	// the source instance can store the newest manifest outside the instances selected by the placement strategy
+	if staleFound && (!found || stale.newerThan(manifest)) {
+		manifest, found = stale, true
+	}
+	if !found {
+		return nil
+	}
+
+	if !staleFound {
+		stale = manifest
+	}
+
+	current := manifest
+	shardInstances := r.gw.pickInstances(instances, id, r.gw.erasure.dataShards+r.gw.erasure.parityShards)
+	if !manifest.placedOn(shardInstances) {
+		if current, err = r.rewriteErasureCoded(ctx, instances, id, manifest); err != nil {
+			return err
+		}
+
+		r.logger.Info("object moved",
+			slog.String("objectID", id),
+			slog.Any("sourceInstances", manifest.instances()),
+			slog.Any("instances", current.instances()),
+		)
+		stats.move(uint64(manifest.Size))
+	}
+
+	return errors.Join(
+		r.gw.deleteStaleLayout(ctx, instances, id, manifest, current),
+		r.gw.deleteStaleLayout(ctx, instances, id, stale, current),
+	)
+}
+
+// rewriteErasureCoded reconstructs the object, and writes its new generation to the instances selected
+// by the placement strategy. The new generation is rolled back if the reconstructed object's checksum differs
+// from the manifest's one. It returns the written manifest.
+func (r *Rebalancer) rewriteErasureCoded(
+	ctx context.Context, instances map[string]string, id string, manifest erasureManifest,
+) (erasureManifest, error) {
+	reader, err := r.gw.readShards(ctx, instances, id, manifest, 0, -1)
+	if err != nil {
+		return erasureManifest{}, err
+	}
+	defer func() { _ = reader.Close() }()
+
+	return r.gw.writeErasureCoded(ctx, instances, id,
+		newVerifyingReader(r.throttle.reader(ctx, reader), manifest.ETag), manifest.Size, manifest)
+}
+
+// transferStats counts the objects processed by the rebalancing pass, or by the drain.
+type transferStats struct {
+	mu sync.Mutex
+	c  transferCounters
+}
+
+type transferCounters struct {
+	scanned, moved, movedBytes, failed uint64
+	lastError                          string
+}
+
+func (s *transferStats) scan() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.c.scanned++
+}
+
+func (s *transferStats) move(bytes uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.c.moved++
+	s.c.movedBytes += bytes
+}
+
+func (s *transferStats) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.c.failed++
+	s.c.lastError = err.Error()
+}
+
+func (s *transferStats) counters() transferCounters {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c
+}
+
+// verifyingReader returns ErrChecksumMismatch instead of io.EOF if the MD5 checksum of the content differs
+// from the expected hex encoded checksum.
+type verifyingReader struct {
+	reader       io.Reader
+	checksum     func(b []byte) []byte
+	wantChecksum string
+}
+
+func newVerifyingReader(reader io.Reader, wantChecksum string) *verifyingReader {
+	checksum := md5.New()
+	return &verifyingReader{
+		reader:       io.TeeReader(reader, checksum),
+		checksum:     checksum.Sum,
+		wantChecksum: wantChecksum,
+	}
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.reader.Read(p)
+
+	if errors.Is(err, io.EOF) {
+		if got := hex.EncodeToString(v.checksum(nil)); got != v.wantChecksum {
+			return n, fmt.Errorf("%w: want: %s, got: %s", ErrChecksumMismatch, v.wantChecksum, got)
+		}
+	}
+
+	return n, err
+}
+
+// throttle limits the data transfer rate.
+type throttle struct {
+	// bytesPerSecond the rate limit, the rate is not limited if it is not positive.
+	bytesPerSecond int64
+	now            func() time.Time
+	sleep          func(ctx context.Context, d time.Duration) error
+
+	mu sync.Mutex
+	// next the time when the data transferred so far is allowed by the rate limit.
+	next time.Time
+}
+
+// reader wraps the reader to limit its rate.
+func (t *throttle) reader(ctx context.Context, reader io.Reader) io.Reader {
+	if t.bytesPerSecond <= 0 {
+		return reader
+	}
+	return &throttledReader{ctx: ctx, reader: reader, throttle: t}
+}
+
+// wait blocks until the transfer of n more bytes is allowed by the rate limit.
+func (t *throttle) wait(ctx context.Context, n int) error {
+	t.mu.Lock()
+	now := t.now()
+	// the rate unused while idle is accumulated up to one second
+	if earliest := now.Add(-time.Second); t.next.Before(earliest) {
+		t.next = earliest
+	}
+	t.next = t.next.Add(time.Duration(n) * time.Second / time.Duration(t.bytesPerSecond))
+	d := t.next.Sub(now)
+	t.mu.Unlock()
+
+	if d <= 0 {
+		return nil
+	}
+	return t.sleep(ctx, d)
+}
+
+type throttledReader struct {
+	ctx      context.Context
+	reader   io.Reader
+	throttle *throttle
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > r.throttle.bytesPerSecond {
+		p = p[:r.throttle.bytesPerSecond]
+	}
+
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		if waitErr := r.throttle.wait(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,401 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestRebalancer(t *testing.T, gateway *Gateway) *Rebalancer {
+	t.Helper()
+
+	r, err := NewRebalancer(gateway, time.Second, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func writeObjects(t *testing.T, gateway *Gateway, cnt int) map[string][]byte {
+	t.Helper()
+
+	o := make(map[string][]byte, cnt)
+	for _, id := range generateIDs("obj", cnt) {
+		data := randomData(len(id) + len(o))
+		if err := gateway.Write(context.TODO(), id, bytes.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		o[id] = data
+	}
+	return o
+}
+
+func readObjects(t *testing.T, gateway *Gateway, objects map[string][]byte) {
+	t.Helper()
+
+	for id, want := range objects {
+		reader, found, err := gateway.Read(context.TODO(), id, 0, -1)
+		if err != nil || !found {
+			t.Fatalf("the object %s is expected to be found, got: %v, %v", id, found, err)
+		}
+		got, _ := io.ReadAll(reader)
+		_ = reader.Close()
+		if !bytes.Equal(got, want) {
+			t.Errorf("unexpected content of %s", id)
+		}
+	}
+}
+
+func TestRebalancer_Rebalance(t *testing.T) {
+	t.Parallel()
+
+	const cntObjects = 100
+
+	t.Run("shall move the objects to the instances selected after the scale out", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, cluster := newMemoryCluster(3)
+		objects := writeObjects(t, gateway, cntObjects)
+		cluster.join(3)
+		cluster.join(4)
+
+		instances, _ := cluster.Scan(context.TODO(), "")
+		var wantMoved uint64
+		for id := range objects {
+			if !slices.Equal(cluster.locate(id), gateway.pickReplicas(instances, id)) {
+				wantMoved++
+			}
+		}
+
+		// WHEN
+		r := newTestRebalancer(t, gateway)
+		err := r.Rebalance(context.TODO())
+
+		// THEN
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for id := range objects {
+			if want, got := gateway.pickReplicas(instances, id), cluster.locate(id); !slices.Equal(got, want) {
+				t.Errorf("unexpected location of %s want: %v, got: %v", id, want, got)
+			}
+		}
+
+		fallbacks := gateway.Metrics().PlacementFallbacks
+		readObjects(t, gateway, objects)
+		if got := gateway.Metrics().PlacementFallbacks - fallbacks; got != 0 {
+			t.Errorf("no placement fallbacks expected, got: %d", got)
+		}
+
+		// the moved objects can be listed again in their new instances
+		status := r.Status()
+		if wantMoved == 0 || status.MovedObjects != wantMoved || status.ScannedObjects < cntObjects ||
+			status.FailedObjects != 0 || status.Running || status.FinishedAt.IsZero() {
+			t.Errorf("unexpected status, %d objects are expected to be moved, got: %#v", wantMoved, status)
+		}
+	})
+
+	t.Run("shall move the replicas and keep the newer replica", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, cluster := newMemoryCluster(3)
+		gateway.replicationFactor, gateway.writeQuorum = 2, 2
+		objects := writeObjects(t, gateway, cntObjects)
+		cluster.join(3)
+
+		instances, _ := cluster.Scan(context.TODO(), "")
+		var misplacedID string
+		for id := range objects {
+			if replicas := gateway.pickReplicas(instances, id); slices.Contains(replicas, "node3") {
+				misplacedID = id
+				break
+			}
+		}
+		if misplacedID == "" {
+			t.Fatal("no object is placed to the new instance")
+		}
+		// the object rewritten after the scale out
+		newData := []byte("qux")
+		if err := gateway.Write(context.TODO(), misplacedID, bytes.NewReader(newData), 3); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		objects[misplacedID] = newData
+		for _, instanceID := range cluster.locate(misplacedID) {
+			if !slices.Contains(gateway.pickReplicas(instances, misplacedID), instanceID) {
+				// the stale replica is older than the written one
+				client := cluster.client(instanceID)
+				client.mu.Lock()
+				client.objects[misplacedID] = []byte("foo")
+				client.mu.Unlock()
+			}
+		}
+
+		// WHEN
+		err := newTestRebalancer(t, gateway).Rebalance(context.TODO())
+
+		// THEN
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for id := range objects {
+			want := readSorted(gateway.pickReplicas(instances, id))
+			if got := cluster.locate(id); !slices.Equal(got, want) {
+				t.Errorf("unexpected location of %s want: %v, got: %v", id, want, got)
+			}
+		}
+		readObjects(t, gateway, objects)
+	})
+
+	t.Run("shall move the erasure coded objects' shards", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, cluster := newMemoryCluster(4)
+		gateway.erasure = &erasureCoding{dataShards: 2, parityShards: 1}
+		objects := writeObjects(t, gateway, cntObjects)
+		cluster.join(4)
+		cluster.join(5)
+
+		// WHEN
+		r := newTestRebalancer(t, gateway)
+		err := r.Rebalance(context.TODO())
+
+		// THEN
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for id := range objects {
+			wantShards := shardInstances(gateway, id)
+			if got := cluster.locate(manifestObjectName(id)); !slices.Equal(got, readSorted(wantShards)) {
+				t.Errorf("unexpected location of the manifest of %s want: %v, got: %v", id, wantShards, got)
+			}
+			generation := currentManifest(t, gateway, id).Generation
+			for i, instanceID := range wantShards {
+				if got := cluster.locate(shardObjectName(id, generation, i)); !slices.Equal(got, []string{instanceID}) {
+					t.Errorf("unexpected location of the shard %d of %s want: %s, got: %v", i, id, instanceID, got)
+				}
+			}
+		}
+		readObjects(t, gateway, objects)
+		if got := r.Status().MovedObjects; got == 0 {
+			t.Errorf("the objects are expected to be moved")
+		}
+	})
+
+	t.Run("shall delete the plain copies of the erasure coded objects", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		const id = "obj"
+		gateway, cluster := newMemoryCluster(4)
+		instances, _ := cluster.Scan(context.TODO(), "")
+		for _, instanceID := range readSortedMapKeys(instances) {
+			if instanceID != pickStorageInstance(gateway.placement, instances, id) {
+				_ = cluster.client(instanceID).Write(context.TODO(), "", id, bytes.NewReader([]byte("foo")), 3)
+				break
+			}
+		}
+		gateway.erasure = &erasureCoding{dataShards: 2, parityShards: 1}
+		if err := gateway.Write(context.TODO(), id, bytes.NewReader([]byte("bar")), 3); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// WHEN
+		err := newTestRebalancer(t, gateway).Rebalance(context.TODO())
+
+		// THEN
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := cluster.locate(id); len(got) > 0 {
+			t.Errorf("the plain copies are expected to be deleted, got: %v", got)
+		}
+		readObjects(t, gateway, map[string][]byte{id: []byte("bar")})
+		if got := gateway.Metrics().DuplicatesDeleted; got != 1 {
+			t.Errorf("one duplicate is expected to be deleted, got: %d", got)
+		}
+	})
+
+	t.Run("shall delete the previous generation left in the instance which missed the overwrite", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		const id = "obj"
+		gateway, cluster := newMemoryCluster(5)
+		gateway.erasure = &erasureCoding{dataShards: 2, parityShards: 2}
+		if err := gateway.Write(context.TODO(), id, bytes.NewReader([]byte("foo")), 3); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		shards := shardInstances(gateway, id)
+		cluster.client(shards[3]).err = errors.New("unavailable")
+		if err := gateway.Write(context.TODO(), id, bytes.NewReader([]byte("bar")), 3); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		cluster.client(shards[3]).err = nil
+
+		// WHEN
+		err := newTestRebalancer(t, gateway).Rebalance(context.TODO())
+
+		// THEN
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		generation := currentManifest(t, gateway, id).Generation
+		for i, instanceID := range shards {
+			want := []string{manifestObjectName(id), shardObjectName(id, generation, i)}
+			if i == 3 {
+				want = nil
+			}
+			if got := cluster.client(instanceID).names(); !slices.Equal(got, want) {
+				t.Errorf("unexpected objects in %s want: %v, got: %v", instanceID, want, got)
+			}
+		}
+		readObjects(t, gateway, map[string][]byte{id: []byte("bar")})
+	})
+
+	t.Run("shall keep the source object if the copy is corrupted", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, cluster := newMemoryCluster(3)
+		// only the objects placed to the joining instance are moved
+		gateway.placement = RendezvousPlacement{}
+		objects := writeObjects(t, gateway, cntObjects)
+		cluster.join(3).corruptWrites = true
+
+		// WHEN
+		r := newTestRebalancer(t, gateway)
+		err := r.Rebalance(context.TODO())
+
+		// THEN
+		if !errors.Is(err, ErrChecksumMismatch) {
+			t.Fatalf("checksum mismatch error expected, got: %v", err)
+		}
+		if got := cluster.client("node3").names(); len(got) > 0 {
+			t.Errorf("the corrupted copies are expected to be deleted, got: %v", got)
+		}
+		readObjects(t, gateway, objects)
+
+		status := r.Status()
+		if status.FailedObjects == 0 || status.MovedObjects != 0 || !strings.Contains(status.LastError, "checksum") {
+			t.Errorf("unexpected status: %#v", status)
+		}
+	})
+
+	t.Run("shall not move the objects of the unchanged cluster", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, _ := newMemoryCluster(3)
+		objects := writeObjects(t, gateway, cntObjects)
+
+		// WHEN
+		r := newTestRebalancer(t, gateway)
+		err := r.Rebalance(context.TODO())
+
+		// THEN
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		readObjects(t, gateway, objects)
+		if status := r.Status(); status.MovedObjects != 0 || status.ScannedObjects != cntObjects {
+			t.Errorf("unexpected status: %#v", status)
+		}
+	})
+}
+
+func readSorted(s []string) []string {
+	o := slices.Clone(s)
+	slices.Sort(o)
+	return o
+}
+
+func TestRebalancer_poll(t *testing.T) {
+	// GIVEN
+	gateway, cluster := newMemoryCluster(3)
+	r := newTestRebalancer(t, gateway)
+	clock := &mockClock{now: time.Date(2023, 10, 21, 7, 28, 0, 0, time.UTC)}
+	r.now = clock.Now
+
+	// WHEN
+	r.poll(context.TODO())
+	startedAt := r.Status().StartedAt
+
+	clock.Advance(time.Minute)
+	r.poll(context.TODO())
+	unchanged := r.Status().StartedAt
+
+	cluster.leave("node2")
+	r.poll(context.TODO())
+	status := r.Status()
+
+	// THEN
+	if startedAt.IsZero() {
+		t.Errorf("the objects are expected to be rebalanced on start")
+	}
+	if !unchanged.Equal(startedAt) {
+		t.Errorf("the objects are not expected to be rebalanced if the membership did not change")
+	}
+	if !status.StartedAt.After(startedAt) || !slices.Equal(status.Instances, []string{"node0", "node1"}) {
+		t.Errorf("the objects are expected to be rebalanced after the membership changed, got: %#v", status)
+	}
+}
+
+func Test_throttle(t *testing.T) {
+	// GIVEN
+	clock := &mockClock{now: time.Date(2023, 10, 21, 7, 28, 0, 0, time.UTC)}
+	var slept []time.Duration
+	th := &throttle{
+		bytesPerSecond: 100,
+		now:            clock.Now,
+		sleep: func(_ context.Context, d time.Duration) error {
+			slept = append(slept, d)
+			clock.Advance(d)
+			return nil
+		},
+	}
+
+	// WHEN
+	got, err := io.ReadAll(th.reader(context.TODO(), bytes.NewReader(randomData(450))))
+
+	// THEN
+	if err != nil || len(got) != 450 {
+		t.Fatalf("unexpected result: %d, %v", len(got), err)
+	}
+	// one second of the transfer is allowed without waiting
+	want := []time.Duration{time.Second, time.Second, time.Second, 500 * time.Millisecond}
+	if !slices.Equal(slept, want) {
+		t.Errorf("unexpected waits want: %v, got: %v", want, slept)
+	}
+}
+
+func TestNewRebalancer(t *testing.T) {
+	tests := []struct {
+		name              string
+		gw                *Gateway
+		pollInterval      time.Duration
+		maxBytesPerSecond int64
+		wantErr           bool
+	}{
+		{name: "valid", gw: newMockGateway(), pollInterval: time.Second, maxBytesPerSecond: 1 << 20},
+		{name: "unlimited rate", gw: newMockGateway(), pollInterval: time.Second},
+		{name: "nil gateway", pollInterval: time.Second, wantErr: true},
+		{name: "zero poll interval", gw: newMockGateway(), wantErr: true},
+		{name: "negative rate", gw: newMockGateway(), pollInterval: time.Second, maxBytesPerSecond: -1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRebalancer(tt.gw, tt.pollInterval, tt.maxBytesPerSecond)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
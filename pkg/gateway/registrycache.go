@@ -0,0 +1,237 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"sync"
+	"time"
+)
+
+// NewCachedServiceRegistry initialises the ServiceRegistryScanner decorator which caches the membership snapshots.
+// The snapshot is refreshed when it is older than refreshInterval. The stale snapshot is returned if the registry
+// is unavailable, and the snapshot is not older than maxStaleness, the failed refresh is retried in background.
+func NewCachedServiceRegistry(
+	scanner ServiceRegistryScanner, refreshInterval, maxStaleness time.Duration, logger *slog.Logger,
+) (*CachedServiceRegistry, error) {
+	if scanner == nil {
+		return nil, errors.New("scanner must be not nil")
+	}
+
+	if refreshInterval <= 0 {
+		return nil, errors.New("refreshInterval must be positive")
+	}
+
+	if maxStaleness < refreshInterval {
+		return nil, errors.New("maxStaleness must not be shorter than refreshInterval")
+	}
+
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &CachedServiceRegistry{
+		scanner:         scanner,
+		refreshInterval: refreshInterval,
+		maxStaleness:    maxStaleness,
+		logger:          logger.WithGroup("registry"),
+		now:             time.Now,
+		snapshots:       map[string]membershipSnapshot{},
+	}, nil
+}
+
+// CachedServiceRegistry caches the membership snapshots read from the ServiceRegistryScanner.
+type CachedServiceRegistry struct {
+	scanner         ServiceRegistryScanner
+	refreshInterval time.Duration
+	maxStaleness    time.Duration
+	logger          *slog.Logger
+	now             func() time.Time
+
+	// refreshMu serializes the refreshes to avoid concurrent queries of the registry.
+	refreshMu sync.Mutex
+	// retries selectors which snapshots are being refreshed in background after the failed refresh.
+	retries   sync.Map
+	retriesWG sync.WaitGroup
+
+	mu        sync.RWMutex
+	snapshots map[string]membershipSnapshot
+}
+
+type membershipSnapshot struct {
+	instances   map[string]string
+	refreshedAt time.Time
+	// failed indicates that the last refresh failed.
+	failed bool
+	// backoff time to wait after the last failed refresh before it is retried.
+	backoff time.Duration
+	// retryAt time when the failed refresh is retried.
+	retryAt time.Time
+}
+
+// Scan returns the cached membership snapshot, or refreshes it.
+// The stale snapshot is returned without waiting for the registry if the snapshot is being refreshed already,
+// or if the last refresh failed, the failed refresh is retried in background with the exponential backoff then.
+func (c *CachedServiceRegistry) Scan(ctx context.Context, serviceLabelFilter string) (map[string]string, error) {
+	snapshot, ok := c.snapshot(serviceLabelFilter)
+	switch {
+	case ok && c.fresh(snapshot):
+		return maps.Clone(snapshot.instances), nil
+	case ok && c.usable(snapshot) && snapshot.failed:
+		c.retry(ctx, serviceLabelFilter, snapshot)
+		return maps.Clone(snapshot.instances), nil
+	case ok && c.usable(snapshot):
+		if !c.refreshMu.TryLock() {
+			return maps.Clone(snapshot.instances), nil
+		}
+	default:
+		c.refreshMu.Lock()
+	}
+	defer c.refreshMu.Unlock()
+
+	// the snapshot could have been refreshed while waiting for the lock
+	if snapshot, ok := c.snapshot(serviceLabelFilter); ok && c.fresh(snapshot) {
+		return maps.Clone(snapshot.instances), nil
+	}
+
+	return c.refresh(ctx, serviceLabelFilter)
+}
+
+// Run refreshes the snapshot in background at the refresh interval until the context is cancelled.
+func (c *CachedServiceRegistry) Run(ctx context.Context, serviceLabelFilter string) {
+	ticker := time.NewTicker(c.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		c.refreshMu.Lock()
+		_, _ = c.refresh(ctx, serviceLabelFilter)
+		c.refreshMu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// retry refreshes the snapshot in background once the backoff after the failed refresh elapsed.
+// The snapshot is refreshed by single goroutine at a time.
+func (c *CachedServiceRegistry) retry(ctx context.Context, serviceLabelFilter string, snapshot membershipSnapshot) {
+	if c.now().Before(snapshot.retryAt) {
+		return
+	}
+
+	if _, loaded := c.retries.LoadOrStore(serviceLabelFilter, struct{}{}); loaded {
+		return
+	}
+
+	c.retriesWG.Add(1)
+	go func() {
+		defer c.retriesWG.Done()
+		defer c.retries.Delete(serviceLabelFilter)
+
+		// the retry shall not be cancelled when the request is over
+		ctx := context.WithoutCancel(ctx)
+
+		c.refreshMu.Lock()
+		defer c.refreshMu.Unlock()
+		_, _ = c.refresh(ctx, serviceLabelFilter)
+	}()
+}
+
+// refresh reads the membership from the registry. The caller must hold refreshMu.
+func (c *CachedServiceRegistry) refresh(ctx context.Context, serviceLabelFilter string) (map[string]string, error) {
+	previous, ok := c.snapshot(serviceLabelFilter)
+
+	instances, err := c.scanner.Scan(ctx, serviceLabelFilter)
+	if err != nil {
+		if !ok || !c.usable(previous) {
+			c.logger.Error("failed to refresh membership",
+				slog.String("selector", serviceLabelFilter),
+				slog.String("error", err.Error()),
+			)
+			return nil, err
+		}
+
+		c.logger.Warn("failed to refresh membership, stale snapshot is used",
+			slog.String("selector", serviceLabelFilter),
+			slog.Time("refreshedAt", previous.refreshedAt),
+			slog.String("error", err.Error()),
+		)
+
+		backoff := c.refreshInterval
+		if previous.failed {
+			backoff = min(2*previous.backoff, c.maxStaleness)
+		}
+		previous.failed = true
+		previous.backoff = backoff
+		previous.retryAt = c.now().Add(backoff)
+		c.setSnapshot(serviceLabelFilter, previous)
+
+		return maps.Clone(previous.instances), nil
+	}
+
+	c.logDiff(serviceLabelFilter, previous.instances, instances)
+	c.setSnapshot(serviceLabelFilter, membershipSnapshot{
+		instances:   maps.Clone(instances),
+		refreshedAt: c.now(),
+	})
+
+	return instances, nil
+}
+
+func (c *CachedServiceRegistry) snapshot(serviceLabelFilter string) (membershipSnapshot, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	snapshot, ok := c.snapshots[serviceLabelFilter]
+	return snapshot, ok
+}
+
+func (c *CachedServiceRegistry) setSnapshot(serviceLabelFilter string, snapshot membershipSnapshot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.snapshots[serviceLabelFilter] = snapshot
+}
+
+func (c *CachedServiceRegistry) fresh(snapshot membershipSnapshot) bool {
+	return !snapshot.failed && c.now().Sub(snapshot.refreshedAt) < c.refreshInterval
+}
+
+// usable defines if the snapshot can be returned when the registry is unavailable.
+func (c *CachedServiceRegistry) usable(snapshot membershipSnapshot) bool {
+	return c.now().Sub(snapshot.refreshedAt) <= c.maxStaleness
+}
+
+// logDiff logs the instances which joined, or left the cluster.
+func (c *CachedServiceRegistry) logDiff(serviceLabelFilter string, previous, current map[string]string) {
+	for instanceID, ipAddress := range current {
+		previousIPAddress, ok := previous[instanceID]
+		switch {
+		case !ok:
+			c.logger.Info("instance joined",
+				slog.String("selector", serviceLabelFilter),
+				slog.String("instanceID", instanceID),
+				slog.String("endpoint", ipAddress),
+			)
+		case previousIPAddress != ipAddress:
+			c.logger.Info("instance endpoint changed",
+				slog.String("selector", serviceLabelFilter),
+				slog.String("instanceID", instanceID),
+				slog.String("endpoint", ipAddress),
+				slog.String("previousEndpoint", previousIPAddress),
+			)
+		}
+	}
+
+	for instanceID, ipAddress := range previous {
+		if _, ok := current[instanceID]; !ok {
+			c.logger.Info("instance left",
+				slog.String("selector", serviceLabelFilter),
+				slog.String("instanceID", instanceID),
+				slog.String("endpoint", ipAddress),
+			)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockCountingScanner struct {
+	mu        sync.Mutex
+	instances map[string]string
+	err       error
+	cntCalls  int
+	// hang blocks the calls until it is closed if set.
+	hang chan struct{}
+}
+
+func (m *mockCountingScanner) Scan(_ context.Context, _ string) (map[string]string, error) {
+	m.mu.Lock()
+	m.cntCalls++
+	hang := m.hang
+	m.mu.Unlock()
+
+	if hang != nil {
+		<-hang
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.instances, nil
+}
+
+func (m *mockCountingScanner) setHang(hang chan struct{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hang = hang
+}
+
+func (m *mockCountingScanner) set(instances map[string]string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.instances, m.err = instances, err
+}
+
+func (m *mockCountingScanner) calls() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cntCalls
+}
+
+type mockClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *mockClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *mockClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestCachedServiceRegistry_Scan(t *testing.T) {
+	t.Parallel()
+
+	const (
+		selector        = "node"
+		refreshInterval = time.Second
+		maxStaleness    = time.Minute
+	)
+
+	newRegistry := func(scanner ServiceRegistryScanner, logs *bytes.Buffer) (*CachedServiceRegistry, *mockClock) {
+		logger := slog.Default()
+		if logs != nil {
+			logger = slog.New(slog.NewTextHandler(logs, nil))
+		}
+		c, err := NewCachedServiceRegistry(scanner, refreshInterval, maxStaleness, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		clock := &mockClock{now: time.Date(2023, 10, 21, 7, 28, 0, 0, time.UTC)}
+		c.now = clock.Now
+		return c, clock
+	}
+
+	t.Run("shall serve the cached snapshot within the refresh interval", func(t *testing.T) {
+		// GIVEN
+		want := map[string]string{"node0": "192.0.2.0"}
+		scanner := &mockCountingScanner{instances: want}
+		c, clock := newRegistry(scanner, nil)
+
+		// WHEN
+		for i := 0; i < 3; i++ {
+			got, err := c.Scan(context.TODO(), selector)
+			if err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("unexpected result: %v, %v", got, err)
+				return
+			}
+			clock.Advance(refreshInterval / 4)
+		}
+
+		// THEN
+		if got := scanner.calls(); got != 1 {
+			t.Errorf("single registry call expected, got: %d", got)
+		}
+	})
+
+	t.Run("shall refresh the snapshot after the refresh interval", func(t *testing.T) {
+		// GIVEN
+		scanner := &mockCountingScanner{instances: map[string]string{"node0": "192.0.2.0"}}
+		c, clock := newRegistry(scanner, nil)
+		_, _ = c.Scan(context.TODO(), selector)
+
+		// WHEN
+		want := map[string]string{"node0": "192.0.2.0", "node1": "192.0.2.1"}
+		scanner.set(want, nil)
+		clock.Advance(refreshInterval)
+		got, err := c.Scan(context.TODO(), selector)
+
+		// THEN
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected result: %v, %v", got, err)
+		}
+	})
+
+	t.Run("shall serve the stale snapshot when the registry is unavailable, and retry in background", func(t *testing.T) {
+		// GIVEN
+		want := map[string]string{"node0": "192.0.2.0"}
+		scanner := &mockCountingScanner{instances: want}
+		c, clock := newRegistry(scanner, nil)
+		_, _ = c.Scan(context.TODO(), selector)
+
+		// WHEN
+		scanner.set(nil, errors.New("unavailable"))
+		clock.Advance(refreshInterval)
+		got, err := c.Scan(context.TODO(), selector)
+
+		// THEN
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("stale snapshot expected, got: %v, %v", got, err)
+			return
+		}
+
+		// the failed refresh is not retried until the backoff elapsed
+		_, _ = c.Scan(context.TODO(), selector)
+		if got := scanner.calls(); got != 2 {
+			t.Errorf("two registry calls expected, got: %d", got)
+		}
+
+		// the failed refresh is retried in background
+		want = map[string]string{"node0": "192.0.2.0", "node1": "192.0.2.1"}
+		scanner.set(want, nil)
+		clock.Advance(refreshInterval)
+		_, _ = c.Scan(context.TODO(), selector)
+		c.retriesWG.Wait()
+		if got := scanner.calls(); got != 3 {
+			t.Errorf("three registry calls expected, got: %d", got)
+		}
+		if got, err := c.Scan(context.TODO(), selector); err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("refreshed snapshot expected, got: %v, %v", got, err)
+		}
+	})
+
+	t.Run("shall not wait for the registry while it is unavailable", func(t *testing.T) {
+		// GIVEN
+		want := map[string]string{"node0": "192.0.2.0"}
+		scanner := &mockCountingScanner{instances: want}
+		c, clock := newRegistry(scanner, nil)
+		_, _ = c.Scan(context.TODO(), selector)
+
+		scanner.set(nil, errors.New("unavailable"))
+		clock.Advance(refreshInterval)
+		_, _ = c.Scan(context.TODO(), selector)
+
+		// the registry does not respond
+		hang := make(chan struct{})
+		scanner.setHang(hang)
+		clock.Advance(refreshInterval)
+
+		// WHEN
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 10; i++ {
+				got, err := c.Scan(context.TODO(), selector)
+				if err != nil || !reflect.DeepEqual(got, want) {
+					t.Errorf("stale snapshot expected, got: %v, %v", got, err)
+					return
+				}
+			}
+		}()
+
+		// THEN
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Errorf("the scans are expected not to wait for the registry")
+		}
+		close(hang)
+		c.retriesWG.Wait()
+		if got := scanner.calls(); got != 3 {
+			t.Errorf("single retry expected, got registry calls: %d", got)
+		}
+	})
+
+	t.Run("shall double the backoff after every failed retry", func(t *testing.T) {
+		// GIVEN
+		scanner := &mockCountingScanner{instances: map[string]string{"node0": "192.0.2.0"}}
+		c, clock := newRegistry(scanner, nil)
+		_, _ = c.Scan(context.TODO(), selector)
+
+		scanner.set(nil, errors.New("unavailable"))
+		clock.Advance(refreshInterval)
+		_, _ = c.Scan(context.TODO(), selector)
+
+		// WHEN
+		var calls []int
+		for i := 0; i < 6; i++ {
+			clock.Advance(refreshInterval)
+			_, _ = c.Scan(context.TODO(), selector)
+			c.retriesWG.Wait()
+			calls = append(calls, scanner.calls())
+		}
+
+		// THEN
+		// the failed refreshes are retried 1s, and 2s after the previous failure
+		if want := []int{3, 3, 4, 4, 4, 4}; !reflect.DeepEqual(calls, want) {
+			t.Errorf("unexpected registry calls want: %v, got: %v", want, calls)
+		}
+	})
+
+	t.Run("shall fail when the snapshot is older than max staleness", func(t *testing.T) {
+		// GIVEN
+		scanner := &mockCountingScanner{instances: map[string]string{"node0": "192.0.2.0"}}
+		c, clock := newRegistry(scanner, nil)
+		_, _ = c.Scan(context.TODO(), selector)
+
+		// WHEN
+		scanner.set(nil, errors.New("unavailable"))
+		clock.Advance(maxStaleness + time.Second)
+		_, err := c.Scan(context.TODO(), selector)
+
+		// THEN
+		if err == nil {
+			t.Errorf("error expected")
+		}
+	})
+
+	t.Run("shall log the membership changes", func(t *testing.T) {
+		// GIVEN
+		var logs bytes.Buffer
+		scanner := &mockCountingScanner{instances: map[string]string{"node0": "192.0.2.0"}}
+		c, clock := newRegistry(scanner, &logs)
+		_, _ = c.Scan(context.TODO(), selector)
+
+		// WHEN
+		scanner.set(map[string]string{"node1": "192.0.2.1"}, nil)
+		clock.Advance(refreshInterval)
+		_, _ = c.Scan(context.TODO(), selector)
+
+		// THEN
+		for _, want := range []string{
+			`msg="instance joined" registry.selector=node registry.instanceID=node0`,
+			`msg="instance joined" registry.selector=node registry.instanceID=node1`,
+			`msg="instance left" registry.selector=node registry.instanceID=node0`,
+		} {
+			if !strings.Contains(logs.String(), want) {
+				t.Errorf("log record %q expected, got logs: %s", want, logs.String())
+			}
+		}
+	})
+}
+
+func TestNewCachedServiceRegistry(t *testing.T) {
+	if _, err := NewCachedServiceRegistry(nil, time.Second, time.Minute, nil); err == nil {
+		t.Errorf("error expected for nil scanner")
+	}
+	if _, err := NewCachedServiceRegistry(&mockCountingScanner{}, 0, time.Minute, nil); err == nil {
+		t.Errorf("error expected for zero refresh interval")
+	}
+	if _, err := NewCachedServiceRegistry(&mockCountingScanner{}, time.Minute, time.Second, nil); err == nil {
+		t.Errorf("error expected for max staleness shorter than refresh interval")
+	}
+}
@@ -0,0 +1,207 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// ErrWriteQuorum indicates that fewer replicas than the write quorum acknowledged the write.
+var ErrWriteQuorum = errors.New("write quorum not reached")
+
+// WithReplication sets the number of distinct instances to store every object, and the write quorum,
+// i.e. the number of replicas which shall acknowledge the write for it to succeed.
+// The objects are not replicated by default: the replication factor and the write quorum are set to 1.
+func WithReplication(replicationFactor, writeQuorum int) Option {
+	return func(g *Gateway) {
+		g.replicationFactor = replicationFactor
+		g.writeQuorum = writeQuorum
+	}
+}
+
+// pickReplicas selects the instances to store the object's replicas using the placement strategy.
+// Fewer instances than the replication factor are returned if the cluster is smaller.
+func (s *Gateway) pickReplicas(instances map[string]string, objectID string) []string {
+	cntReplicas := s.replicationFactor
+	if cntReplicas < 1 {
+		cntReplicas = 1
+	}
+	return s.pickInstances(instances, objectID, cntReplicas)
+}
+
+// pickInstances selects up to n distinct instances for the object using the placement strategy.
+// The instances are sorted in the order of preference, the first one is the instance picked for the object by
+// the placement strategy, the next one is picked from the rest of the instances, etc.
+// The draining instances are not selected.
+func (s *Gateway) pickInstances(instances map[string]string, objectID string, n int) []string {
+	candidates := readSortedMapKeys(s.placementInstances(instances))
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+
+	o := make([]string, 0, n)
+	for len(o) < n {
+		i := slices.Index(candidates, s.placement.Pick(candidates, objectID))
+		if i < 0 {
+			break
+		}
+		o = append(o, candidates[i])
+		candidates = slices.Delete(candidates, i, i+1)
+	}
+
+	return o
+}
+
+// writeReplicas writes the object to the replicas concurrently, the reader's content is streamed to all replicas.
+// The write succeeds if at least the write quorum of replicas acknowledged it.
+// Note that the write to the replica is only retried after the authentication failure if the content does not
+// exceed the retry buffer.
+func (s *Gateway) writeReplicas(
+	ctx context.Context, instances map[string]string, id string, reader io.Reader, objectSizeBytes int64,
+) error {
+	replicas := s.pickReplicas(instances, id)
+	if len(replicas) < s.writeQuorum {
+		return fmt.Errorf("%w: %d instances available, %d replicas required",
+			ErrWriteQuorum, len(replicas), s.writeQuorum)
+	}
+
+	errList, readErr := s.writeToInstances(ctx, instances, replicas, id, reader, objectSizeBytes)
+
+	var cntAcks int
+	for i, instanceID := range replicas {
+		if errList[i] == nil {
+			cntAcks++
+			continue
+		}
+
+		s.Logger.Warn("replica write failed",
+			slog.String("operation", "write"),
+			slog.String("instanceID", instanceID),
+			slog.String("objectID", id),
+			slog.String("error", errList[i].Error()),
+		)
+	}
+
+	if readErr != nil {
+		return readErr
+	}
+
+	if cntAcks < s.writeQuorum {
+		return fmt.Errorf("%w: %d of %d replicas acknowledged the write, %d required: %w",
+			ErrWriteQuorum, cntAcks, len(replicas), s.writeQuorum, errors.Join(errList...))
+	}
+
+	if cntAcks < s.replicationFactor {
+		s.metrics.degradedWrites.Add(1)
+	}
+
+	return nil
+}
+
+// writeToInstances writes the object to the instances concurrently, the reader's content is streamed to all of them.
+// It returns the errors of the writes to every instance, and the error of reading the object.
+func (s *Gateway) writeToInstances(
+	ctx context.Context, instances map[string]string, instanceIDs []string,
+	id string, reader io.Reader, objectSizeBytes int64,
+) ([]error, error) {
+	objectNames := make([]string, len(instanceIDs))
+	for i := range objectNames {
+		objectNames[i] = id
+	}
+
+	writers, wait := s.startWrites(ctx, instances, instanceIDs, objectNames, objectSizeBytes)
+	readErr := fanOut(reader, writers)
+
+	return wait(), readErr
+}
+
+// startWrites starts writing the objects to the instances concurrently: the i-th object is written to the i-th
+// instance, its content shall be written to the i-th pipe writer. The returned function waits for the writes
+// to finish, and returns their errors.
+func (s *Gateway) startWrites(
+	ctx context.Context, instances map[string]string, instanceIDs, objectNames []string, objectSizeBytes int64,
+) ([]*io.PipeWriter, func() []error) {
+	var (
+		wg      sync.WaitGroup
+		writers = make([]*io.PipeWriter, len(instanceIDs))
+		errList = make([]error, len(instanceIDs))
+	)
+
+	for i, instanceID := range instanceIDs {
+		pipeReader, pipeWriter := io.Pipe()
+		writers[i] = pipeWriter
+
+		wg.Add(1)
+		go func(i int, instanceID string) {
+			defer wg.Done()
+
+			s.Logger.Debug("writing replica",
+				slog.String("operation", "write"),
+				slog.String("instanceID", instanceID),
+				slog.String("objectID", objectNames[i]),
+			)
+
+			errList[i] = s.writeReplica(ctx, instanceID, instances[instanceID], objectNames[i], pipeReader,
+				objectSizeBytes)
+			// unblocks the writer if the replica failed, or stopped reading the object
+			_ = pipeReader.CloseWithError(errList[i])
+		}(i, instanceID)
+	}
+
+	return writers, func() []error {
+		wg.Wait()
+		return errList
+	}
+}
+
+func (s *Gateway) writeReplica(
+	ctx context.Context, instanceID, endpoint, id string, reader io.Reader, objectSizeBytes int64,
+) error {
+	conn, err := s.newStorageInstanceConnection(ctx, instanceID, endpoint)
+	if err != nil {
+		return err
+	}
+	return s.writeObject(ctx, instanceID, endpoint, conn, id, reader, objectSizeBytes)
+}
+
+// fanOut copies the reader's content to all writers. The writer is dropped once it fails,
+// the copy stops if all writers failed. The writers are closed when the copy is over,
+// the reader's error is propagated to the writers.
+func fanOut(reader io.Reader, writers []*io.PipeWriter) error {
+	active := slices.Clone(writers)
+	defer func() {
+		for _, w := range active {
+			_ = w.Close()
+		}
+	}()
+
+	buf := make([]byte, 32*1024)
+	for len(active) > 0 {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			active = slices.DeleteFunc(active, func(w *io.PipeWriter) bool {
+				if _, err := w.Write(buf[:n]); err != nil {
+					_ = w.Close()
+					return true
+				}
+				return false
+			})
+		}
+
+		switch {
+		case errors.Is(err, io.EOF):
+			return nil
+		case err != nil:
+			for _, w := range active {
+				_ = w.CloseWithError(err)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,475 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// newReplicatedCluster initialises the gateway with the cluster of instances storing objects in memory.
+// The map of clients by instance ID is returned to inject failures.
+func newReplicatedCluster(cntInstances, replicationFactor, writeQuorum int) (
+	*Gateway, map[string]*memoryStorageClient,
+) {
+	gateway, cluster := newMemoryCluster(cntInstances)
+	gateway.replicationFactor = replicationFactor
+	gateway.writeQuorum = writeQuorum
+	return gateway, cluster.instanceClients()
+}
+
+func TestGateway_pickReplicas(t *testing.T) {
+	instances := map[string]string{}
+	for _, instanceID := range generateIDs("node", 5) {
+		instances[instanceID] = instanceID
+	}
+
+	for name, strategy := range placementStrategies() {
+		strategy := strategy
+		t.Run(name, func(t *testing.T) {
+			for _, objectID := range generateIDs("obj", 100) {
+				// GIVEN
+				gateway := newMockGateway()
+				gateway.placement = strategy
+				gateway.replicationFactor = 3
+
+				// WHEN
+				got := gateway.pickReplicas(instances, objectID)
+
+				// THEN
+				if len(got) != 3 {
+					t.Fatalf("3 replicas expected, got: %v", got)
+				}
+				if got[0] != pickStorageInstance(strategy, instances, objectID) {
+					t.Fatalf("the first replica is expected to be picked by the placement strategy, got: %v", got)
+				}
+				if got[0] == got[1] || got[0] == got[2] || got[1] == got[2] {
+					t.Fatalf("distinct replicas expected, got: %v", got)
+				}
+				if !slices.Equal(got, gateway.pickReplicas(instances, objectID)) {
+					t.Fatalf("replicas are expected to be picked deterministically")
+				}
+			}
+		})
+	}
+
+	t.Run("shall pick all instances of the cluster smaller than the replication factor", func(t *testing.T) {
+		// GIVEN
+		gateway := newMockGateway()
+		gateway.replicationFactor = 3
+
+		// WHEN
+		got := gateway.pickReplicas(map[string]string{"node0": "", "node1": ""}, "obj")
+
+		// THEN
+		slices.Sort(got)
+		if !slices.Equal(got, []string{"node0", "node1"}) {
+			t.Errorf("unexpected replicas: %v", got)
+		}
+	})
+}
+
+func TestGateway_Write_replicated(t *testing.T) {
+	t.Parallel()
+
+	const (
+		objectID     = "obj"
+		cntInstances = 5
+	)
+	data := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+
+	type testCase struct {
+		name string
+		// failures injects failures to the replicas given their IDs in the order of preference
+		failures           func(replicas []string, clients map[string]*memoryStorageClient)
+		wantErr            error
+		wantAcks           int
+		wantDegradedWrites uint64
+	}
+
+	tests := []testCase{
+		{
+			name:     "shall write the object to all replicas",
+			failures: func([]string, map[string]*memoryStorageClient) {},
+			wantAcks: 3,
+		},
+		{
+			name: "shall reach the write quorum if one replica is unavailable",
+			failures: func(replicas []string, clients map[string]*memoryStorageClient) {
+				clients[replicas[0]].err = errors.New("unavailable")
+			},
+			wantAcks:           2,
+			wantDegradedWrites: 1,
+		},
+		{
+			name: "shall reach the write quorum if one replica fails while reading the object",
+			failures: func(replicas []string, clients map[string]*memoryStorageClient) {
+				clients[replicas[1]].failWriteAfter = 100 * 1024
+			},
+			wantAcks:           2,
+			wantDegradedWrites: 1,
+		},
+		{
+			name: "shall fail if the write quorum is not reached",
+			failures: func(replicas []string, clients map[string]*memoryStorageClient) {
+				clients[replicas[0]].err = errors.New("unavailable")
+				clients[replicas[2]].failWriteAfter = 1
+			},
+			wantErr:  ErrWriteQuorum,
+			wantAcks: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// GIVEN
+			gateway, clients := newReplicatedCluster(cntInstances, 3, 2)
+			instances, _ := gateway.serviceRegistryClient.Scan(context.TODO(), mockClusterPrefix)
+			replicas := gateway.pickReplicas(instances, objectID)
+			tt.failures(replicas, clients)
+
+			// WHEN
+			err := gateway.Write(context.TODO(), objectID, bytes.NewReader(data), int64(len(data)))
+
+			// THEN
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unexpected error want: %v, got: %v", tt.wantErr, err)
+			}
+
+			var cntAcks int
+			for instanceID, client := range clients {
+				stored := client.stored(objectID)
+				if stored == nil {
+					continue
+				}
+				if !slices.Contains(replicas, instanceID) {
+					t.Errorf("the object is not expected to be written to %s", instanceID)
+				}
+				if !bytes.Equal(stored, data) {
+					t.Errorf("unexpected object stored in %s", instanceID)
+				}
+				cntAcks++
+			}
+			if cntAcks != tt.wantAcks {
+				t.Errorf("unexpected number of stored replicas want: %d, got: %d", tt.wantAcks, cntAcks)
+			}
+
+			if got := gateway.Metrics().DegradedWrites; got != tt.wantDegradedWrites {
+				t.Errorf("unexpected degraded writes want: %d, got: %d", tt.wantDegradedWrites, got)
+			}
+		})
+	}
+
+	t.Run("shall fail if the cluster is smaller than the write quorum", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, clients := newReplicatedCluster(1, 3, 2)
+
+		// WHEN
+		err := gateway.Write(context.TODO(), objectID, bytes.NewReader(data), int64(len(data)))
+
+		// THEN
+		if !errors.Is(err, ErrWriteQuorum) {
+			t.Errorf("unexpected error want: %v, got: %v", ErrWriteQuorum, err)
+		}
+		if clients["node0"].stored(objectID) != nil {
+			t.Errorf("the object is not expected to be written")
+		}
+	})
+
+	t.Run("shall fail if the object cannot be read", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, clients := newReplicatedCluster(cntInstances, 3, 2)
+		wantErr := errors.New("client disconnected")
+		reader := io.MultiReader(bytes.NewReader(data[:1024]), &failingReader{err: wantErr})
+
+		// WHEN
+		err := gateway.Write(context.TODO(), objectID, reader, int64(len(data)))
+
+		// THEN
+		if !errors.Is(err, wantErr) {
+			t.Errorf("unexpected error want: %v, got: %v", wantErr, err)
+		}
+		for instanceID, client := range clients {
+			if client.stored(objectID) != nil {
+				t.Errorf("the object is not expected to be written to %s", instanceID)
+			}
+		}
+	})
+}
+
+type failingReader struct {
+	err error
+}
+
+func (r *failingReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func TestGateway_Read_replicated(t *testing.T) {
+	t.Parallel()
+
+	const objectID = "obj"
+	data := []byte("qux")
+
+	newCluster := func(t *testing.T) (*Gateway, []string, map[string]*memoryStorageClient) {
+		t.Helper()
+
+		gateway, clients := newReplicatedCluster(5, 3, 2)
+		if err := gateway.Write(context.TODO(), objectID, bytes.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		instances, _ := gateway.serviceRegistryClient.Scan(context.TODO(), mockClusterPrefix)
+		return gateway, gateway.pickReplicas(instances, objectID), clients
+	}
+
+	t.Run("shall read the object from the next replica if the first one is unavailable", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, replicas, clients := newCluster(t)
+		clients[replicas[0]].err = errors.New("unavailable")
+
+		// WHEN
+		got, found, err := gateway.Read(context.TODO(), objectID, 0, -1)
+
+		// THEN
+		if err != nil || !found {
+			t.Fatalf("the object is expected to be found, got: %v, %v", found, err)
+		}
+		gotData, _ := io.ReadAll(got)
+		if !bytes.Equal(gotData, data) {
+			t.Errorf("unexpected data want: %s, got: %s", data, gotData)
+		}
+		if got := gateway.Metrics().PlacementFallbacks; got != 0 {
+			t.Errorf("no fallback to the scan of the cluster expected, got: %d", got)
+		}
+	})
+
+	t.Run("shall read the object if only the last replica is available", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, replicas, clients := newCluster(t)
+		// the first replica is unreachable
+		instances, _ := gateway.serviceRegistryClient.Scan(context.TODO(), mockClusterPrefix)
+		connections := map[string]ObjectReadWriteFinder{}
+		for instanceID, endpoint := range instances {
+			if instanceID != replicas[0] {
+				connections[endpoint] = clients[instanceID]
+			}
+		}
+		gateway.newStorageConnectionFn = mockMinioConnectionFactoryByEndpoint(connections)
+		// the second replica lost the object
+		_ = clients[replicas[1]].Delete(context.TODO(), "", objectID)
+
+		// WHEN
+		_, found, err := gateway.Read(context.TODO(), objectID, 0, -1)
+
+		// THEN
+		if err != nil || !found {
+			t.Errorf("the object is expected to be found, got: %v, %v", found, err)
+		}
+	})
+
+	t.Run("shall read the next replica storing the same version if the freshest one fails", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, replicas, clients := newCluster(t)
+		modified := time.Now()
+		clients[replicas[0]].put(objectID, data, modified)
+		clients[replicas[0]].readErr = errors.New("connection reset")
+		clients[replicas[1]].put(objectID, []byte("foo"), modified.Add(-time.Minute))
+		clients[replicas[2]].put(objectID, data, modified.Add(-time.Second))
+
+		// WHEN
+		got, found, err := gateway.Read(context.TODO(), objectID, 0, -1)
+		gateway.repairsWG.Wait()
+
+		// THEN
+		if err != nil || !found {
+			t.Fatalf("the object is expected to be found, got: %v, %v", found, err)
+		}
+		gotData, _ := io.ReadAll(got)
+		if !bytes.Equal(gotData, data) {
+			t.Errorf("unexpected data want: %s, got: %s", data, gotData)
+		}
+	})
+
+	t.Run("shall fail if no other replica stores the freshest version", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, replicas, clients := newCluster(t)
+		modified := time.Now()
+		wantErr := errors.New("connection reset")
+		clients[replicas[0]].put(objectID, data, modified)
+		clients[replicas[0]].readErr = wantErr
+		clients[replicas[1]].put(objectID, []byte("foo"), modified.Add(-time.Minute))
+		clients[replicas[2]].put(objectID, []byte("foo"), modified.Add(-time.Minute))
+
+		// WHEN
+		_, found, err := gateway.Read(context.TODO(), objectID, 0, -1)
+		gateway.repairsWG.Wait()
+
+		// THEN
+		if !errors.Is(err, wantErr) || found {
+			t.Errorf("unexpected result want: %v, got: %v, %v", wantErr, found, err)
+		}
+	})
+
+	t.Run("shall fail if all replicas are unavailable", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, replicas, clients := newCluster(t)
+		for _, instanceID := range replicas {
+			clients[instanceID].err = errors.New("unavailable")
+		}
+
+		// WHEN
+		_, found, err := gateway.Read(context.TODO(), objectID, 0, -1)
+
+		// THEN
+		if err == nil || found {
+			t.Errorf("error expected, got: %v, %v", found, err)
+		}
+	})
+}
+
+func TestGateway_Stat_replicated(t *testing.T) {
+	t.Parallel()
+
+	const objectID = "obj"
+
+	newCluster := func(t *testing.T) (*Gateway, []string, map[string]*memoryStorageClient) {
+		t.Helper()
+
+		gateway, clients := newReplicatedCluster(5, 3, 2)
+		instances, _ := gateway.serviceRegistryClient.Scan(context.TODO(), mockClusterPrefix)
+		return gateway, gateway.pickReplicas(instances, objectID), clients
+	}
+
+	t.Run("shall return the metadata of the freshest replica", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, replicas, clients := newCluster(t)
+		clients[replicas[0]].put(objectID, []byte("foo"), time.Unix(100, 0))
+		clients[replicas[1]].put(objectID, []byte("quxx"), time.Unix(200, 0))
+
+		// WHEN
+		got, found, err := gateway.Stat(context.TODO(), objectID)
+
+		// THEN
+		if err != nil || !found {
+			t.Fatalf("the object is expected to be found, got: %v, %v", found, err)
+		}
+		want, _, _ := clients[replicas[1]].Stat(context.TODO(), "", objectID)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected metadata want: %#v, got: %#v", want, got)
+		}
+	})
+
+	t.Run("shall skip the unavailable replica", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, replicas, clients := newCluster(t)
+		clients[replicas[0]].err = errors.New("unavailable")
+		clients[replicas[2]].put(objectID, []byte("foo"), time.Unix(100, 0))
+
+		// WHEN
+		got, found, err := gateway.Stat(context.TODO(), objectID)
+
+		// THEN
+		if err != nil || !found {
+			t.Fatalf("the object is expected to be found, got: %v, %v", found, err)
+		}
+		if got.Size != 3 {
+			t.Errorf("unexpected size: %d", got.Size)
+		}
+	})
+
+	t.Run("shall return the metadata of the object stored outside the replicas", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, replicas, clients := newCluster(t)
+		for instanceID, client := range clients {
+			if !slices.Contains(replicas, instanceID) {
+				client.put(objectID, []byte("foo"), time.Unix(100, 0))
+				break
+			}
+		}
+
+		// WHEN
+		got, found, err := gateway.Stat(context.TODO(), objectID)
+
+		// THEN
+		if err != nil || !found {
+			t.Fatalf("the object is expected to be found, got: %v, %v", found, err)
+		}
+		if got.Size != 3 {
+			t.Errorf("unexpected size: %d", got.Size)
+		}
+	})
+
+	t.Run("shall fail if the object is not found, and the replica is unavailable", func(t *testing.T) {
+		t.Parallel()
+
+		// GIVEN
+		gateway, replicas, clients := newCluster(t)
+		clients[replicas[1]].err = errors.New("unavailable")
+
+		// WHEN
+		_, found, err := gateway.Stat(context.TODO(), objectID)
+
+		// THEN
+		if err == nil || found {
+			t.Errorf("error expected, got: %v, %v", found, err)
+		}
+	})
+}
+
+func TestNew_replication(t *testing.T) {
+	tests := []struct {
+		name              string
+		replicationFactor int
+		writeQuorum       int
+		wantErr           bool
+	}{
+		{name: "no replication", replicationFactor: 1, writeQuorum: 1},
+		{name: "majority quorum", replicationFactor: 3, writeQuorum: 2},
+		{name: "zero replication factor", replicationFactor: 0, writeQuorum: 0, wantErr: true},
+		{name: "zero write quorum", replicationFactor: 3, writeQuorum: 0, wantErr: true},
+		{name: "write quorum exceeds replication factor", replicationFactor: 2, writeQuorum: 3, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// WHEN
+			_, err := New(mockClusterPrefix, "", &mockStorageDiscoveryClient{}, &mockStorageDiscoveryClient{},
+				mockMinioConnectionFactory(nil, &mockStorageClient{}), slog.Default(),
+				WithReplication(tt.replicationFactor, tt.writeQuorum),
+			)
+
+			// THEN
+			if (err != nil) != tt.wantErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
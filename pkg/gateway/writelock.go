@@ -0,0 +1,65 @@
+package gateway
+
+import (
+	"context"
+	"sync"
+)
+
+// keyedMutex serializes the operations per key, e.g. the writes of the object given its ID.
+// The zero value is ready to use.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+// keyLock defines the key's lock, it is released from memory once no one holds, or waits for it.
+type keyLock struct {
+	sem  chan struct{}
+	refs int
+}
+
+// lock waits until the key is unlocked, or the context is cancelled. It returns the function to unlock the key.
+func (m *keyedMutex) lock(ctx context.Context, key string) (unlock func(), err error) {
+	m.mu.Lock()
+	if m.locks == nil {
+		m.locks = map[string]*keyLock{}
+	}
+	l, ok := m.locks[key]
+	if !ok {
+		l = &keyLock{sem: make(chan struct{}, 1)}
+		m.locks[key] = l
+	}
+	l.refs++
+	m.mu.Unlock()
+
+	select {
+	case l.sem <- struct{}{}:
+		var once sync.Once
+		return func() {
+			once.Do(func() {
+				<-l.sem
+				m.release(key, l)
+			})
+		}, nil
+	case <-ctx.Done():
+		m.release(key, l)
+		return nil, ctx.Err()
+	}
+}
+
+func (m *keyedMutex) release(key string, l *keyLock) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l.refs--
+	if l.refs == 0 {
+		delete(m.locks, key)
+	}
+}
+
+// size returns the number of keys which are locked, or waited for.
+func (m *keyedMutex) size() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.locks)
+}
@@ -0,0 +1,96 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_keyedMutex(t *testing.T) {
+	t.Run("shall serialize the operations per key", func(t *testing.T) {
+		// GIVEN
+		const cntOperations = 100
+
+		var (
+			m                   keyedMutex
+			wg                  sync.WaitGroup
+			running, maxRunning atomic.Int64
+		)
+
+		// WHEN
+		for i := 0; i < cntOperations; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				unlock, err := m.lock(context.TODO(), "foo")
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+				defer unlock()
+
+				n := running.Add(1)
+				for {
+					cur := maxRunning.Load()
+					if n <= cur || maxRunning.CompareAndSwap(cur, n) {
+						break
+					}
+				}
+				running.Add(-1)
+			}()
+		}
+		wg.Wait()
+
+		// THEN
+		if got := maxRunning.Load(); got != 1 {
+			t.Errorf("the operations are expected to be serialized, got %d concurrent operations", got)
+		}
+		if got := m.size(); got != 0 {
+			t.Errorf("the locks are expected to be released, got: %d", got)
+		}
+	})
+
+	t.Run("shall not block the operations with different keys", func(t *testing.T) {
+		// GIVEN
+		var m keyedMutex
+		unlockFoo, _ := m.lock(context.TODO(), "foo")
+		defer unlockFoo()
+
+		// WHEN
+		unlockBar, err := m.lock(context.TODO(), "bar")
+
+		// THEN
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		unlockBar()
+		if got := m.size(); got != 1 {
+			t.Errorf("one lock is expected to be held, got: %d", got)
+		}
+	})
+
+	t.Run("shall stop waiting once the context is cancelled", func(t *testing.T) {
+		// GIVEN
+		var m keyedMutex
+		unlock, _ := m.lock(context.TODO(), "foo")
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+
+		// WHEN
+		_, err := m.lock(ctx, "foo")
+
+		// THEN
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("unexpected error want: %v, got: %v", context.Canceled, err)
+		}
+
+		unlock()
+		unlock()
+		if got := m.size(); got != 0 {
+			t.Errorf("the locks are expected to be released, got: %d", got)
+		}
+	})
+}